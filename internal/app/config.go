@@ -12,6 +12,12 @@ import (
 )
 
 func LoadConfig(v any) {
+	configMu.Lock()
+	loadConfig(v)
+	configMu.Unlock()
+}
+
+func loadConfig(v any) {
 	for _, data := range configs {
 		if err := yaml.Unmarshal(data, v); err != nil {
 			Logger.Warn().Err(err).Send()
@@ -40,6 +46,16 @@ func PatchConfig(path []string, value any) error {
 	return os.WriteFile(ConfigPath, b, 0644)
 }
 
+// ReloadConfig - read config files again and load them to v, for modules with
+// hot reload support. Main config path and storage stay the same.
+func ReloadConfig(v any) {
+	configMu.Lock()
+	configs = nil
+	initConfig(configFlags)
+	loadConfig(v)
+	configMu.Unlock()
+}
+
 type flagConfig []string
 
 func (c *flagConfig) String() string {
@@ -52,12 +68,15 @@ func (c *flagConfig) Set(value string) error {
 }
 
 var configs [][]byte
+var configFlags flagConfig
 
 func initConfig(confs flagConfig) {
 	if confs == nil {
 		confs = []string{"go2rtc.yaml"}
 	}
 
+	configFlags = confs
+
 	for _, conf := range confs {
 		if len(conf) == 0 {
 			continue
@@ -3,6 +3,7 @@ package app
 import (
 	"io"
 	"os"
+	"strconv"
 	"strings"
 	"sync"
 
@@ -32,6 +33,7 @@ func GetLogger(module string) zerolog.Logger {
 // - format: empty (autodetect color support), color, json, text
 // - time:   empty (disable timestamp), UNIXMS, UNIXMICRO, UNIXNANO
 // - level:  disabled, trace, debug, info, warn, error...
+// - stream_log: last entries of each stream for the API, 0 - disabled
 func initLogger() {
 	var cfg struct {
 		Mod map[string]string `yaml:"log"`
@@ -56,6 +58,8 @@ func initLogger() {
 		writer, _ = os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
 	}
 
+	StreamLog.size, _ = strconv.Atoi(modules["stream_log"])
+
 	timeFormat := modules["time"]
 
 	if writer != nil {
@@ -86,9 +90,9 @@ func initLogger() {
 			writer = console
 		}
 
-		writer = zerolog.MultiLevelWriter(writer, MemoryLog)
+		writer = zerolog.MultiLevelWriter(writer, MemoryLog, StreamLog)
 	} else {
-		writer = MemoryLog
+		writer = zerolog.MultiLevelWriter(MemoryLog, StreamLog)
 	}
 
 	writer = creds.SecretWriter(writer)
@@ -106,10 +110,11 @@ var Logger zerolog.Logger
 
 // modules log levels
 var modules = map[string]string{
-	"format": "", // useless, but anyway
-	"level":  "info",
-	"output": "stdout", // TODO: change to stderr someday
-	"time":   zerolog.TimeFormatUnixMs,
+	"format":     "", // useless, but anyway
+	"level":      "info",
+	"output":     "stdout", // TODO: change to stderr someday
+	"stream_log": "100",
+	"time":       zerolog.TimeFormatUnixMs,
 }
 
 const (
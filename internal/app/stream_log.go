@@ -0,0 +1,109 @@
+package app
+
+import (
+	"bytes"
+	"encoding/json"
+	"sync"
+)
+
+// StreamLog - last log entries with the stream field, for each stream separately,
+// so the support request can be checked without the whole log of the server
+var StreamLog = &streamLog{size: 100, streams: 1000}
+
+type streamLog struct {
+	size    int
+	streams int // max tracked streams, log can have names of temporary or unknown streams
+	entries map[string]*ring
+	seq     uint64
+	mu      sync.Mutex
+}
+
+type ring struct {
+	items [][]byte
+	w     int
+	seq   uint64 // last write, for dropping the oldest stream
+}
+
+var streamField = []byte(`"stream":`)
+
+func (s *streamLog) Write(p []byte) (n int, err error) {
+	n = len(p)
+
+	// fast check without JSON parsing for most of the log entries
+	if s.size <= 0 || !bytes.Contains(p, streamField) {
+		return
+	}
+
+	var entry struct {
+		Stream string `json:"stream"`
+	}
+	if json.Unmarshal(p, &entry) != nil || entry.Stream == "" {
+		return
+	}
+
+	item := bytes.TrimRight(p, "\n")
+	item = append(make([]byte, 0, len(item)), item...) // zerolog reuses the buffer
+
+	s.mu.Lock()
+	if s.entries == nil {
+		s.entries = map[string]*ring{}
+	}
+	r := s.entries[entry.Stream]
+	if r == nil {
+		if len(s.entries) >= s.streams {
+			s.dropOldest()
+		}
+		r = &ring{}
+		s.entries[entry.Stream] = r
+	}
+	s.seq++
+	r.seq = s.seq
+	if len(r.items) < s.size {
+		r.items = append(r.items, item)
+	} else {
+		r.items[r.w] = item
+		if r.w++; r.w == len(r.items) {
+			r.w = 0
+		}
+	}
+	s.mu.Unlock()
+	return
+}
+
+func (s *streamLog) dropOldest() {
+	var oldest string
+	var seq uint64
+	for name, r := range s.entries {
+		if oldest == "" || r.seq < seq {
+			oldest, seq = name, r.seq
+		}
+	}
+	delete(s.entries, oldest)
+}
+
+// Get - last n entries of the stream from old to new, all entries if n <= 0
+func (s *streamLog) Get(name string, n int) []json.RawMessage {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	r := s.entries[name]
+	if r == nil {
+		return nil
+	}
+
+	items := make([]json.RawMessage, 0, len(r.items))
+	for i := range r.items {
+		items = append(items, r.items[(r.w+i)%len(r.items)])
+	}
+	if n > 0 && n < len(items) {
+		items = items[len(items)-n:]
+	}
+	return items
+}
+
+// Delete - drop entries of the removed stream
+func (s *streamLog) Delete(name string) {
+	s.mu.Lock()
+	delete(s.entries, name)
+	s.mu.Unlock()
+}
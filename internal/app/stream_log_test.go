@@ -0,0 +1,57 @@
+package app
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStreamLog(t *testing.T) {
+	s := &streamLog{size: 3, streams: 10}
+	logger := zerolog.New(s)
+
+	logger.Info().Msg("without stream")
+	for i := range 5 {
+		logger.Warn().Str("stream", "camera1").Int("i", i).Send()
+	}
+	logger.Error().Str("stream", "camera2").Msg("error")
+
+	entries := s.Get("camera1", 0)
+	require.Len(t, entries, 3)
+
+	var entry struct {
+		I int `json:"i"`
+	}
+	require.Nil(t, json.Unmarshal(entries[0], &entry))
+	require.Equal(t, 2, entry.I)
+
+	// last n entries
+	entries = s.Get("camera1", 1)
+	require.Len(t, entries, 1)
+	require.Nil(t, json.Unmarshal(entries[0], &entry))
+	require.Equal(t, 4, entry.I)
+
+	require.Len(t, s.Get("camera2", 10), 1)
+	require.Nil(t, s.Get("camera3", 10))
+
+	s.Delete("camera1")
+	require.Nil(t, s.Get("camera1", 0))
+}
+
+func TestStreamLogLimit(t *testing.T) {
+	s := &streamLog{size: 3, streams: 2}
+	logger := zerolog.New(s)
+
+	logger.Warn().Str("stream", "camera1").Send()
+	logger.Warn().Str("stream", "camera2").Send()
+	logger.Warn().Str("stream", "camera1").Send()
+	logger.Warn().Str("stream", "camera3").Send()
+
+	// camera2 has the oldest write
+	require.Len(t, s.entries, 2)
+	require.Nil(t, s.Get("camera2", 0))
+	require.Len(t, s.Get("camera1", 0), 2)
+	require.Len(t, s.Get("camera3", 0), 1)
+}
@@ -0,0 +1,243 @@
+package metrics
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/AlexxIT/go2rtc/internal/app"
+	"github.com/AlexxIT/go2rtc/internal/streams"
+	"github.com/rs/zerolog"
+)
+
+func Init() {
+	var cfg struct {
+		Mod struct {
+			URL      string `yaml:"url"`
+			Format   string `yaml:"format"`   // influx or statsd
+			Interval int    `yaml:"interval"` // in seconds
+			Token    string `yaml:"token"`    // InfluxDB API token for HTTP endpoint
+			Prefix   string `yaml:"prefix"`
+		} `yaml:"metrics"`
+	}
+
+	cfg.Mod.Format = FormatInflux
+	cfg.Mod.Interval = 10
+	cfg.Mod.Prefix = "go2rtc"
+
+	app.LoadConfig(&cfg)
+
+	if cfg.Mod.URL == "" {
+		return
+	}
+
+	log = app.GetLogger("metrics")
+
+	u, err := url.Parse(cfg.Mod.URL)
+	if err != nil {
+		log.Error().Err(err).Send()
+		return
+	}
+
+	r := &reporter{url: u, format: cfg.Mod.Format, token: cfg.Mod.Token, prefix: cfg.Mod.Prefix}
+
+	switch r.format {
+	case FormatInflux, FormatStatsD:
+	default:
+		log.Error().Msgf("[metrics] unsupported format=%s", r.format)
+		return
+	}
+
+	switch u.Scheme {
+	case "udp", "http", "https":
+	default:
+		log.Error().Msgf("[metrics] unsupported url=%s", u.Redacted())
+		return
+	}
+
+	go r.worker(time.Duration(max(cfg.Mod.Interval, 1)) * time.Second)
+}
+
+var log zerolog.Logger
+
+const (
+	FormatInflux = "influx" // InfluxDB line protocol
+	FormatStatsD = "statsd"
+)
+
+type reporter struct {
+	url    *url.URL
+	format string
+	token  string
+	prefix string
+
+	conn   net.Conn // for UDP endpoint
+	client http.Client
+	failed bool // log only the first error in a row
+
+	counters map[string]int // last values of the StatsD counters, they are sent as deltas
+}
+
+// worker - endpoint errors don't affect streams, the report is dropped and
+// the next one is sent on the next interval
+func (r *reporter) worker(interval time.Duration) {
+	r.client.Timeout = interval
+
+	for range time.Tick(interval) {
+		b := r.report(time.Now())
+		if len(b) == 0 {
+			continue
+		}
+
+		if err := r.send(b); err != nil {
+			if !r.failed {
+				log.Warn().Err(err).Msgf("[metrics] send to %s", r.url.Redacted())
+				r.failed = true
+			}
+			continue
+		}
+
+		if r.failed {
+			log.Info().Msgf("[metrics] send to %s restored", r.url.Redacted())
+			r.failed = false
+		}
+	}
+}
+
+// report - stats of all streams, sorted by name
+func (r *reporter) report(now time.Time) []byte {
+	names := streams.GetAllNames()
+	slices.Sort(names)
+
+	var b []byte
+	for _, name := range names {
+		if stream := streams.Get(name); stream != nil {
+			b = r.append(b, name, stream.Stats(), now)
+		}
+	}
+	return b
+}
+
+func (r *reporter) append(b []byte, name string, stats *streams.StreamStats, now time.Time) []byte {
+	values := []struct {
+		key     string
+		value   int
+		counter bool // cumulative value
+	}{
+		{"producers", stats.Producers, false},
+		{"consumers", stats.Consumers, false},
+		{"bytes_recv", stats.BytesRecv, true},
+		{"packets_recv", stats.PacketsRecv, true},
+		{"bytes_send", stats.BytesSend, true},
+		{"packets_send", stats.PacketsSend, true},
+		{"drops", stats.Drops, true},
+		{"buffer_bytes", stats.BufferBytes, false},
+	}
+
+	if r.format == FormatStatsD {
+		// prefix.stream.key:value|g or prefix.stream.key:delta|c
+		name = statsdEscape.Replace(name)
+		for _, v := range values {
+			if !v.counter {
+				b = fmt.Appendf(b, "%s.%s.%s:%d|g\n", r.prefix, name, v.key, v.value)
+				continue
+			}
+			b = fmt.Appendf(b, "%s.%s.%s:%d|c\n", r.prefix, name, v.key, r.delta(name+"."+v.key, v.value))
+		}
+		return b
+	}
+
+	// prefix_stream,stream=name key=1i,key=2i 1700000000000000000
+	b = fmt.Appendf(b, "%s_stream,stream=%s ", r.prefix, influxEscape.Replace(name))
+	for i, v := range values {
+		if i > 0 {
+			b = append(b, ',')
+		}
+		b = fmt.Appendf(b, "%s=%di", v.key, v.value)
+	}
+	return fmt.Appendf(b, " %d\n", now.UnixNano())
+}
+
+// delta - counter increment since the last report, totals are reset on the reconnect,
+// so the smaller value is the increment since the reset
+func (r *reporter) delta(key string, value int) int {
+	if r.counters == nil {
+		r.counters = map[string]int{}
+	}
+	last, ok := r.counters[key]
+	r.counters[key] = value
+	if !ok || value < last {
+		return value
+	}
+	return value - last
+}
+
+// influxEscape - tag values https://docs.influxdata.com/influxdb/v2/reference/syntax/line-protocol/#special-characters
+var influxEscape = strings.NewReplacer(",", `\,`, "=", `\=`, " ", `\ `)
+
+// statsdEscape - dots are path separators, colons and pipes are the syntax
+var statsdEscape = strings.NewReplacer(".", "_", ":", "_", "|", "_", " ", "_")
+
+func (r *reporter) send(b []byte) error {
+	if r.url.Scheme == "udp" {
+		return r.sendUDP(b)
+	}
+
+	req, err := http.NewRequest("POST", r.url.String(), bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "text/plain; charset=utf-8")
+	if r.token != "" {
+		req.Header.Set("Authorization", "Token "+r.token)
+	}
+
+	res, err := r.client.Do(req)
+	if err != nil {
+		return err
+	}
+	_ = res.Body.Close()
+
+	if res.StatusCode >= 300 {
+		return errors.New("metrics: wrong response " + res.Status)
+	}
+	return nil
+}
+
+// maxDatagram - split the report by lines, so each UDP packet is not fragmented
+const maxDatagram = 1400
+
+func (r *reporter) sendUDP(b []byte) error {
+	if r.conn == nil {
+		conn, err := net.Dial("udp", r.url.Host)
+		if err != nil {
+			return err
+		}
+		r.conn = conn
+	}
+
+	for len(b) > 0 {
+		n := len(b)
+		if n > maxDatagram {
+			if i := bytes.LastIndexByte(b[:maxDatagram], '\n'); i > 0 {
+				n = i + 1
+			} else if i = bytes.IndexByte(b, '\n'); i > 0 {
+				n = i + 1
+			}
+		}
+
+		if _, err := r.conn.Write(b[:n]); err != nil {
+			_ = r.conn.Close()
+			r.conn = nil
+			return err
+		}
+		b = b[n:]
+	}
+	return nil
+}
@@ -0,0 +1,58 @@
+package metrics
+
+import (
+	"net"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/AlexxIT/go2rtc/internal/streams"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAppend(t *testing.T) {
+	stats := &streams.StreamStats{Producers: 1, Consumers: 2, BytesRecv: 1000, PacketsRecv: 10, BytesSend: 2000, PacketsSend: 20, Drops: 1, BufferBytes: 3000}
+	now := time.Unix(1700000000, 0)
+
+	r := &reporter{format: FormatInflux, prefix: "go2rtc"}
+	b := r.append(nil, "front door,1", stats, now)
+	require.Equal(t, `go2rtc_stream,stream=front\ door\,1 producers=1i,consumers=2i,bytes_recv=1000i,packets_recv=10i,bytes_send=2000i,packets_send=20i,drops=1i,buffer_bytes=3000i 1700000000000000000`+"\n", string(b))
+
+	r = &reporter{format: FormatStatsD, prefix: "go2rtc"}
+	b = r.append(nil, "camera.1", stats, now)
+	require.Contains(t, string(b), "go2rtc.camera_1.producers:1|g\n")
+	require.Contains(t, string(b), "go2rtc.camera_1.drops:1|c\n")
+
+	// counters are sent as increments since the last report
+	stats.BytesRecv, stats.Drops = 1500, 1
+	b = r.append(nil, "camera.1", stats, now)
+	require.Contains(t, string(b), "go2rtc.camera_1.bytes_recv:500|c\n")
+	require.Contains(t, string(b), "go2rtc.camera_1.drops:0|c\n")
+
+	// reconnect resets totals
+	stats.BytesRecv = 100
+	b = r.append(nil, "camera.1", stats, now)
+	require.Contains(t, string(b), "go2rtc.camera_1.bytes_recv:100|c\n")
+}
+
+func TestSendUDP(t *testing.T) {
+	server, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	require.Nil(t, err)
+	defer server.Close()
+
+	r := &reporter{url: &url.URL{Scheme: "udp", Host: server.LocalAddr().String()}}
+
+	// report is split by lines into datagrams
+	var b []byte
+	for i := 0; i < 30; i++ {
+		b = append(b, "go2rtc.camera1.bytes_send:1234567890|g\n"...)
+	}
+	require.Nil(t, r.send(b))
+
+	buf := make([]byte, 2000)
+	_ = server.SetReadDeadline(time.Now().Add(time.Second))
+	n, err := server.Read(buf)
+	require.Nil(t, err)
+	require.LessOrEqual(t, n, maxDatagram)
+	require.Equal(t, byte('\n'), buf[n-1])
+}
@@ -4,6 +4,7 @@ import (
 	"errors"
 	"io"
 	"net/http"
+	"net/url"
 	"strconv"
 	"strings"
 	"time"
@@ -23,6 +24,15 @@ import (
 )
 
 func Init() {
+	var cfg struct {
+		Mod struct {
+			Decoder    string                      `yaml:"decoder"`
+			Thumbnails map[string]thumbnailsConfig `yaml:"thumbnails"`
+		} `yaml:"mjpeg"`
+	}
+
+	app.LoadConfig(&cfg)
+
 	api.HandleFunc("api/frame.jpeg", handlerKeyframe)
 	api.HandleFunc("api/stream.mjpeg", handlerStream)
 	api.HandleFunc("api/stream.ascii", handlerStream)
@@ -31,10 +41,21 @@ func Init() {
 	ws.HandleFunc("mjpeg", handlerWS)
 
 	log = app.GetLogger("mjpeg")
+
+	if name := cfg.Mod.Decoder; name != "" && name != "ffmpeg" && mjpeg.GetDecoder(name) == nil {
+		log.Error().Strs("available", mjpeg.Decoders()).Msgf("[mjpeg] unknown decoder=%s, fallback to ffmpeg", name)
+	} else {
+		decoder = name
+	}
+
+	initThumbnails(cfg.Mod.Thumbnails)
 }
 
 var log zerolog.Logger
 
+// decoder - backend for H264/H265 snapshots, empty - external FFmpeg
+var decoder string
+
 func handlerKeyframe(w http.ResponseWriter, r *http.Request) {
 	stream, _ := streams.GetOrPatch(r.URL.Query())
 	if stream == nil {
@@ -60,7 +81,7 @@ func handlerKeyframe(w http.ResponseWriter, r *http.Request) {
 	case core.CodecH264, core.CodecH265:
 		ts := time.Now()
 		var err error
-		if b, err = ffmpeg.JPEGWithQuery(b, r.URL.Query()); err != nil {
+		if b, err = keyframeJPEG(cons.CodecName(), b, r.URL.Query()); err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
@@ -81,6 +102,33 @@ func handlerKeyframe(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// keyframeJPEG - H264/H265 keyframe to JPEG with the built-in decoder or with FFmpeg,
+// the decoder param overrides the backend from the config
+func keyframeJPEG(codec string, b []byte, query url.Values) ([]byte, error) {
+	name := query.Get("decoder")
+	if name == "" {
+		name = decoder
+	} else if name != "ffmpeg" && mjpeg.GetDecoder(name) == nil {
+		log.Warn().Strs("available", mjpeg.Decoders()).Msgf("[mjpeg] unknown decoder=%s, fallback to ffmpeg", name)
+		name = ""
+	}
+	if name == "" || name == "ffmpeg" {
+		return ffmpeg.JPEGWithQuery(b, query)
+	}
+
+	width, height := -1, -1
+	for k, v := range query {
+		switch k {
+		case "width", "w":
+			width = core.Atoi(v[0])
+		case "height", "h":
+			height = core.Atoi(v[0])
+		}
+	}
+
+	return mjpeg.DecodeKeyframe(name, codec, b, width, height)
+}
+
 func handlerStream(w http.ResponseWriter, r *http.Request) {
 	if r.Method != "POST" {
 		outputMjpeg(w, r)
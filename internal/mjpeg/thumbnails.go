@@ -0,0 +1,151 @@
+package mjpeg
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/AlexxIT/go2rtc/internal/api"
+	"github.com/AlexxIT/go2rtc/internal/streams"
+	"github.com/AlexxIT/go2rtc/pkg/core"
+	"github.com/AlexxIT/go2rtc/pkg/magic"
+	"github.com/AlexxIT/go2rtc/pkg/mjpeg"
+)
+
+type thumbnailsConfig struct {
+	Interval int    `yaml:"interval"` // seconds
+	Grid     string `yaml:"grid"`     // columns x rows
+	Width    int    `yaml:"width"`
+}
+
+type thumbnails struct {
+	sprite   *mjpeg.Sprite
+	interval time.Duration
+}
+
+var sprites = map[string]*thumbnails{}
+
+func initThumbnails(items map[string]thumbnailsConfig) {
+	for name, item := range items {
+		cols, rows := 5, 5
+		if item.Grid != "" {
+			if _, err := fmt.Sscanf(item.Grid, "%dx%d", &cols, &rows); err != nil || cols <= 0 || rows <= 0 {
+				log.Error().Str("grid", item.Grid).Msgf("[mjpeg] wrong thumbnails grid for stream=%s", name)
+				continue
+			}
+		}
+
+		width := 160
+		if item.Width > 0 {
+			width = item.Width
+		}
+
+		interval := 10 * time.Second
+		if item.Interval > 0 {
+			interval = time.Duration(item.Interval) * time.Second
+		}
+
+		t := &thumbnails{sprite: mjpeg.NewSprite(cols, rows, width), interval: interval}
+		sprites[name] = t
+
+		go t.worker(name)
+	}
+
+	api.HandleFunc("api/thumbnails.jpeg", apiThumbnailsJPEG)
+	api.HandleFunc("api/thumbnails.vtt", apiThumbnailsVTT)
+}
+
+func (t *thumbnails) worker(name string) {
+	for range time.Tick(t.interval) {
+		stream := streams.Get(name)
+		if stream == nil {
+			continue
+		}
+
+		ts := time.Now()
+
+		b, err := thumbnail(stream, t.sprite.Width)
+		if err == nil {
+			err = t.sprite.Add(b, ts)
+		}
+		if err != nil {
+			log.Debug().Err(err).Msgf("[mjpeg] thumbnail stream=%s", name)
+		}
+	}
+}
+
+// thumbnail - same as frame.jpeg, but with timeout, because sources without
+// keyframes can block the worker forever
+func thumbnail(stream *streams.Stream, width int) ([]byte, error) {
+	cons := magic.NewKeyframe()
+	cons.FormatName = "thumbnails"
+
+	if err := stream.AddConsumer(cons); err != nil {
+		return nil, err
+	}
+
+	timer := time.AfterFunc(core.ProbeTimeout, func() {
+		_ = cons.Stop()
+	})
+
+	once := &core.OnceBuffer{}
+	_, _ = cons.WriteTo(once)
+	b := once.Buffer()
+
+	timer.Stop()
+	stream.RemoveConsumer(cons)
+
+	if b == nil {
+		return nil, errors.New("mjpeg: can't get keyframe")
+	}
+
+	switch cons.CodecName() {
+	case core.CodecH264, core.CodecH265:
+		return keyframeJPEG(cons.CodecName(), b, url.Values{"width": {strconv.Itoa(width)}})
+	case core.CodecJPEG:
+		return mjpeg.FixJPEG(b), nil
+	}
+
+	return nil, errors.New("mjpeg: unsupported codec " + cons.CodecName())
+}
+
+func apiThumbnailsJPEG(w http.ResponseWriter, r *http.Request) {
+	t := sprites[r.URL.Query().Get("src")]
+	if t == nil {
+		http.Error(w, api.StreamNotFound, http.StatusNotFound)
+		return
+	}
+
+	b := t.sprite.JPEG()
+	if b == nil {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	h := w.Header()
+	h.Set("Content-Type", "image/jpeg")
+	h.Set("Content-Length", strconv.Itoa(len(b)))
+	h.Set("Cache-Control", "no-cache")
+
+	_, _ = w.Write(b)
+}
+
+func apiThumbnailsVTT(w http.ResponseWriter, r *http.Request) {
+	src := r.URL.Query().Get("src")
+	t := sprites[src]
+	if t == nil {
+		http.Error(w, api.StreamNotFound, http.StatusNotFound)
+		return
+	}
+
+	b := t.sprite.WebVTT("thumbnails.jpeg?src="+url.QueryEscape(src), t.interval)
+
+	h := w.Header()
+	h.Set("Content-Type", "text/vtt")
+	h.Set("Cache-Control", "no-cache")
+
+	_, _ = w.Write(b)
+}
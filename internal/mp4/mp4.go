@@ -17,8 +17,18 @@ import (
 )
 
 func Init() {
+	var cfg struct {
+		Mod struct {
+			Record map[string]recordConfig `yaml:"record"`
+		} `yaml:"mp4"`
+	}
+
+	app.LoadConfig(&cfg)
+
 	log = app.GetLogger("mp4")
 
+	initRecord(cfg.Mod.Record)
+
 	ws.HandleFunc("mse", handlerWSMSE)
 	ws.HandleFunc("mp4", handlerWSMP4)
 
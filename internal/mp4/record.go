@@ -0,0 +1,58 @@
+package mp4
+
+import (
+	"time"
+
+	"github.com/AlexxIT/go2rtc/internal/streams"
+	"github.com/AlexxIT/go2rtc/pkg/mp4"
+)
+
+type recordConfig struct {
+	Path    string `yaml:"path"`    // file path with %Y, %m, %d, %H, %M, %S fields
+	Segment int    `yaml:"segment"` // seconds
+}
+
+func initRecord(items map[string]recordConfig) {
+	for name, item := range items {
+		if item.Path == "" {
+			log.Error().Msgf("[mp4] empty record path for stream=%s", name)
+			continue
+		}
+
+		segment := time.Minute
+		if item.Segment > 0 {
+			segment = time.Duration(item.Segment) * time.Second
+		}
+
+		go record(name, item.Path, segment)
+	}
+}
+
+// record - keep the recorder on the stream, start it again after the stream stop
+func record(name, path string, segment time.Duration) {
+	for ; ; time.Sleep(10 * time.Second) {
+		stream := streams.Get(name)
+		if stream == nil {
+			continue
+		}
+
+		rec := mp4.NewRecorder(nil)
+		rec.Segment = segment
+		rec.Filename = func(ts time.Time) string {
+			return mp4.Filename(path, ts)
+		}
+
+		if err := stream.AddConsumer(rec); err != nil {
+			log.Debug().Err(err).Msgf("[mp4] record stream=%s", name)
+			continue
+		}
+
+		log.Debug().Msgf("[mp4] start record stream=%s", name)
+
+		rec.Wait()
+
+		stream.RemoveConsumer(rec)
+
+		log.Debug().Msgf("[mp4] stop record stream=%s", name)
+	}
+}
@@ -16,7 +16,17 @@ func apiStreamAAC(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	cons := aac.NewConsumer()
+	var cons *aac.Consumer
+	var contentType string
+
+	// format=latm - LOAS/LATM framing, default - ADTS
+	if r.URL.Query().Get("format") == "latm" {
+		cons = aac.NewLATMConsumer()
+		contentType = "audio/mp4a-latm"
+	} else {
+		cons = aac.NewConsumer()
+		contentType = "audio/aac"
+	}
 	cons.WithRequest(r)
 
 	if err := stream.AddConsumer(cons); err != nil {
@@ -24,7 +34,7 @@ func apiStreamAAC(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	w.Header().Add("Content-Type", "audio/aac")
+	w.Header().Add("Content-Type", contentType)
 
 	_, _ = cons.WriteTo(w)
 
@@ -0,0 +1,48 @@
+package mpegts
+
+import (
+	"io"
+	"net/http"
+
+	"github.com/AlexxIT/go2rtc/internal/api"
+	"github.com/AlexxIT/go2rtc/internal/streams"
+	"github.com/AlexxIT/go2rtc/pkg/core"
+	"github.com/AlexxIT/go2rtc/pkg/h264"
+	"github.com/AlexxIT/go2rtc/pkg/h265"
+)
+
+type annexbConsumer interface {
+	core.Consumer
+	core.Info
+	io.WriterTo
+}
+
+func apiStreamH264(w http.ResponseWriter, r *http.Request) {
+	outputAnnexB(w, r, h264.NewConsumer(), "video/h264")
+}
+
+func apiStreamH265(w http.ResponseWriter, r *http.Request) {
+	outputAnnexB(w, r, h265.NewConsumer(), "video/h265")
+}
+
+func outputAnnexB(w http.ResponseWriter, r *http.Request, cons annexbConsumer, contentType string) {
+	src := r.URL.Query().Get("src")
+	stream := streams.Get(src)
+	if stream == nil {
+		http.Error(w, api.StreamNotFound, http.StatusNotFound)
+		return
+	}
+
+	cons.WithRequest(r)
+
+	if err := stream.AddConsumer(cons); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Add("Content-Type", contentType)
+
+	_, _ = cons.WriteTo(w)
+
+	stream.RemoveConsumer(cons)
+}
@@ -11,6 +11,8 @@ import (
 func Init() {
 	api.HandleFunc("api/stream.ts", apiHandle)
 	api.HandleFunc("api/stream.aac", apiStreamAAC)
+	api.HandleFunc("api/stream.h264", apiStreamH264)
+	api.HandleFunc("api/stream.h265", apiStreamH265)
 }
 
 func apiHandle(w http.ResponseWriter, r *http.Request) {
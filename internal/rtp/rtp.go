@@ -0,0 +1,53 @@
+package rtp
+
+import (
+	"errors"
+	"net/url"
+	"strings"
+
+	"github.com/AlexxIT/go2rtc/internal/streams"
+	"github.com/AlexxIT/go2rtc/pkg/core"
+	"github.com/AlexxIT/go2rtc/pkg/rtp"
+)
+
+func Init() {
+	streams.HandleFunc("rtp", handle)
+}
+
+// handle - source rtp://:5004#rtpmap=96:H264/90000, there is no SDP, so the codec
+// is required, optional #fmtp=... and #rtcp=1 (RTCP on the next port)
+func handle(source string) (core.Producer, error) {
+	rawURL, rawQuery, _ := strings.Cut(source, "#")
+
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+
+	query := streams.ParseQuery(rawQuery)
+
+	codec := parseRTPMap(query.Get("rtpmap"))
+	if codec == nil {
+		return nil, errors.New("rtp: codec is required, ex. #rtpmap=96:H264/90000")
+	}
+	codec.FmtpLine = query.Get("fmtp")
+
+	return rtp.NewProducer(u.Host, codec, query.Get("rtcp") == "1")
+}
+
+// parseRTPMap - type:codec/clock rate/channels, same as a=rtpmap
+func parseRTPMap(s string) *core.Codec {
+	pt, value, ok := strings.Cut(s, ":")
+	if !ok {
+		return nil
+	}
+	ss := strings.Split(value, "/")
+	codec := &core.Codec{Name: strings.ToUpper(ss[0]), PayloadType: core.ParseByte(pt)}
+	if len(ss) > 1 {
+		codec.ClockRate = uint32(core.Atoi(ss[1]))
+	}
+	if len(ss) > 2 && ss[2] == "2" {
+		codec.Channels = 2
+	}
+	return codec
+}
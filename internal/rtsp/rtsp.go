@@ -4,9 +4,13 @@ import (
 	"errors"
 	"io"
 	"net"
+	"net/http"
 	"net/url"
+	"strconv"
 	"strings"
+	"time"
 
+	"github.com/AlexxIT/go2rtc/internal/api"
 	"github.com/AlexxIT/go2rtc/internal/app"
 	"github.com/AlexxIT/go2rtc/internal/streams"
 	"github.com/AlexxIT/go2rtc/pkg/core"
@@ -23,6 +27,21 @@ func Init() {
 			Password     string `yaml:"password" json:"-"`
 			DefaultQuery string `yaml:"default_query" json:"default_query"`
 			PacketSize   uint16 `yaml:"pkt_size" json:"pkt_size,omitempty"`
+			SetupTimeout int    `yaml:"setup_timeout" json:"setup_timeout,omitempty"` // in seconds
+
+			// RTSP client response timeout on DESCRIBE/SETUP/PLAY, in seconds
+			CommandTimeout int `yaml:"command_timeout" json:"command_timeout,omitempty"`
+
+			// RTSP client allowed and denied source hosts, CIDR or hostname pattern
+			AllowHosts []string `yaml:"allow_hosts" json:"allow_hosts,omitempty"`
+			DenyHosts  []string `yaml:"deny_hosts" json:"deny_hosts,omitempty"`
+
+			// re-send cached SPS/PPS before keyframes for all consumers, interval in seconds
+			ParamSets      bool `yaml:"param_sets" json:"param_sets,omitempty"`
+			ParamsInterval int  `yaml:"param_sets_interval" json:"param_sets_interval,omitempty"`
+
+			// allowed codecs per stream name, ex. `camera1: h264,aac`
+			Codecs map[string]string `yaml:"codecs" json:"codecs,omitempty"`
 		} `yaml:"rtsp"`
 	}
 
@@ -35,11 +54,24 @@ func Init() {
 
 	log = app.GetLogger("rtsp")
 
+	commandTimeout = time.Duration(conf.Mod.CommandTimeout) * time.Second
+
+	if conf.Mod.AllowHosts != nil || conf.Mod.DenyHosts != nil {
+		var err error
+		if hostFilter, err = tcp.NewFilter(conf.Mod.AllowHosts, conf.Mod.DenyHosts); err != nil {
+			log.Error().Err(err).Msg("[rtsp] wrong hosts filter, deny all sources")
+			hostFilter, _ = tcp.NewFilter(nil, []string{"*", "0.0.0.0/0", "::/0"})
+		}
+	}
+
 	// RTSP client support
 	streams.HandleFunc("rtsp", rtspHandler)
 	streams.HandleFunc("rtsps", rtspHandler)
 	streams.HandleFunc("rtspx", rtspHandler)
 
+	api.HandleFunc("api/rtsp/probe", apiProbe)
+	api.HandleFunc("api/stream.data", apiStreamData)
+
 	// RTSP server support
 	address := conf.Mod.Listen
 	if address == "" {
@@ -60,6 +92,10 @@ func Init() {
 		defaultMedias = ParseQuery(query)
 	}
 
+	for name, value := range conf.Mod.Codecs {
+		allowCodecs[name] = parseCodecs(value)
+	}
+
 	go func() {
 		for {
 			conn, err := ln.Accept()
@@ -69,6 +105,9 @@ func Init() {
 
 			c := rtsp.NewServer(conn)
 			c.PacketSize = conf.Mod.PacketSize
+			c.SetupTimeout = time.Duration(conf.Mod.SetupTimeout) * time.Second
+			c.ParamSets = conf.Mod.ParamSets
+			c.ParamsInterval = time.Duration(conf.Mod.ParamsInterval) * time.Second
 			// skip check auth for localhost
 			if conf.Mod.Username != "" && !conn.RemoteAddr().(*net.TCPAddr).IP.IsLoopback() {
 				c.Auth(conf.Mod.Username, conf.Mod.Password)
@@ -91,20 +130,246 @@ var Port string
 var log zerolog.Logger
 var handlers []Handler
 var defaultMedias []*core.Media
+var allowCodecs = map[string][]*core.Codec{}
+var commandTimeout time.Duration
+var hostFilter *tcp.Filter
 
 func rtspHandler(rawURL string) (core.Producer, error) {
+	conn, data, err := describe(rawURL)
+	if err != nil {
+		return nil, err
+	}
+
+	conn.Listen(func(msg any) {
+		if typ := eventType(msg); typ != "" {
+			streams.PublishEvent(typ, rawURL, msg)
+		}
+	})
+
+	// vendor data medias (ex. telemetry), payloads are fired as *rtsp.Data events
+	if data {
+		if err = conn.SetupData(); err != nil {
+			return nil, err
+		}
+	}
+
+	// MPEG-TS over RTP, demux it to video, audio and KLV metadata
+	if media, _ := conn.MPEGTSMedia(); media != nil {
+		prod, err := rtsp.OpenMPEGTS(conn)
+		if err != nil {
+			return nil, err
+		}
+		return prod, nil
+	}
+
+	return conn, nil
+}
+
+// RTSP source events for the streams subscribers, see streams.Subscribe
+const (
+	EventFreeze = "rtsp_freeze" // Data - rtsp.EventFreeze, session will be restarted
+	EventSSRC   = "rtsp_ssrc"   // Data - *rtsp.SSRCChange
+	EventClock  = "rtsp_clock"  // Data - *rtsp.ClockRate
+	EventAVSync = "rtsp_avsync" // Data - *rtsp.AVSync
+	EventMagic  = "rtsp_magic"  // Data - *rtsp.Magic
+	EventData   = "rtsp_data"   // Data - *rtsp.Data
+)
+
+// eventType - streams event type for the RTSP connection event, empty for other events
+func eventType(msg any) string {
+	switch msg := msg.(type) {
+	case string:
+		if msg == rtsp.EventFreeze {
+			return EventFreeze
+		}
+	case *rtsp.SSRCChange:
+		return EventSSRC
+	case *rtsp.ClockRate:
+		return EventClock
+	case *rtsp.AVSync:
+		return EventAVSync
+	case *rtsp.Magic:
+		return EventMagic
+	case *rtsp.Data:
+		return EventData
+	}
+	return ""
+}
+
+// apiProbe - codecs of the RTSP source (src) with the verdict for each one,
+// ex. for the setup wizard, DESCRIBE only, without SETUP and PLAY
+func apiProbe(w http.ResponseWriter, r *http.Request) {
+	src := r.URL.Query().Get("src")
+	if !strings.HasPrefix(src, "rtsp") {
+		http.Error(w, "wrong RTSP source", http.StatusBadRequest)
+		return
+	}
+
+	conn, _, err := describe(src)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	_ = conn.Close()
+
+	api.ResponseJSON(w, map[string]any{
+		"url":         core.StripUserinfo(src),
+		"backchannel": conn.Backchannel,
+		"codecs":      conn.Probe(),
+		"skipped":     conn.Skipped,
+	})
+}
+
+// apiStreamData - payloads of the vendor data medias as JSON lines, source
+// should have the `#data=1` param
+func apiStreamData(w http.ResponseWriter, r *http.Request) {
+	src := r.URL.Query().Get("src")
+	stream := streams.Get(src)
+	if stream == nil {
+		http.Error(w, api.StreamNotFound, http.StatusNotFound)
+		return
+	}
+
+	cons := rtsp.NewDataConsumer()
+	cons.WithRequest(r)
+
+	if err := stream.AddConsumer(cons); err != nil {
+		log.Error().Err(err).Caller().Send()
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+
+	_, _ = cons.WriteTo(w)
+
+	stream.RemoveConsumer(cons)
+}
+
+// describe - RTSP client with the source params, after the DESCRIBE answer
+func describe(rawURL string) (*rtsp.Conn, bool, error) {
 	rawURL, rawQuery, _ := strings.Cut(rawURL, "#")
 
 	conn := rtsp.NewClient(rawURL)
 	conn.Backchannel = true
 	conn.UserAgent = app.UserAgent
+	conn.CommandTimeout = commandTimeout
+	conn.Filter = hostFilter
+
+	var data bool
 
 	if rawQuery != "" {
 		query := streams.ParseQuery(rawQuery)
+		data = query.Get("data") == "1"
 		conn.Backchannel = query.Get("backchannel") == "1"
+		conn.DrainTimeout = time.Duration(core.Atoi(query.Get("drain_timeout"))) * time.Millisecond
 		conn.Media = query.Get("media")
+		conn.Lang = query.Get("lang")
+		conn.Magic = query.Get("magic")
+		conn.IPVersion = query.Get("ip")
+		conn.ClockAdapt = query.Get("clock_adapt") == "1"
+		conn.ContentLength = query.Get("content_length") == "1"
+		conn.SSRCRewrite = query.Get("ssrc") == "1"
+		if s := query.Get("av_sync"); s != "" {
+			conn.AVSync = time.Duration(core.Atoi(s)) * time.Millisecond
+		}
+		if s := query.Get("scale"); s != "" {
+			conn.Scale, _ = strconv.ParseFloat(s, 64)
+		}
+		conn.FastStart = query.Get("fast_start") == "1"
+		conn.Fingerprint = query.Get("fingerprint")
+		conn.Fallback = query.Get("fallback")
+		conn.StatusActions = rtsp.ParseStatusActions(query.Get("status"))
+		conn.NoReconnect = query.Get("reconnect") == "0"
+		if s := query.Get("max_session"); s != "" {
+			conn.MaxSession = time.Duration(core.Atoi(s)) * time.Second
+		}
+		if s := query.Get("command_timeout"); s != "" {
+			conn.CommandTimeout = time.Duration(core.Atoi(s)) * time.Second
+		}
+		if s := query.Get("freeze"); s != "" {
+			conn.FreezeTime = time.Duration(core.Atoi(s)) * time.Second
+		}
+		if query.Has("dedup") {
+			if conn.DedupWindow = uint16(core.Atoi(query.Get("dedup"))); conn.DedupWindow == 0 {
+				conn.DedupWindow = core.DedupWindow
+			}
+		}
 		conn.Timeout = core.Atoi(query.Get("timeout"))
 		conn.Transport = query.Get("transport")
+		conn.Unknown = query.Get("unknown")
+		conn.PayloadMap = parsePayloadMap(query.Get("pt_remap"))
+		conn.RTPMap = parseRTPMap(query.Get("rtpmap"))
+		conn.RTCPReports = query.Get("rtcp_reports") == "1"
+		conn.Feedback = query.Get("rtcp_fb")
+		conn.RTX = query.Get("rtx") == "1"
+		if s := query.Get("supported"); s != "" {
+			conn.Supported = strings.Split(s, ",")
+		}
+
+		// param names like ffmpeg style https://ffmpeg.org/ffmpeg-protocols.html#tcp
+		if query.Get("tcp_nodelay") == "0" {
+			conn.NoDelay = false
+		}
+		conn.ReadBuffer = core.Atoi(query.Get("recv_buffer_size"))
+		conn.WriteBuffer = core.Atoi(query.Get("send_buffer_size"))
+	}
+
+	if conn.Fallback != "" {
+		conn.Listen(func(msg any) {
+			if msg == rtsp.EventFallback {
+				log.Warn().Str("url", core.StripUserinfo(conn.Fallback)).Msg("[rtsp] main stream not available, fallback to substream")
+			}
+		})
+	}
+
+	if conn.ClockAdapt {
+		conn.Listen(func(msg any) {
+			if msg, ok := msg.(*rtsp.ClockRate); ok {
+				log.Warn().Str("url", core.StripUserinfo(rawURL)).Str("codec", msg.Codec.String()).
+					Msgf("[rtsp] clock rate changed from %d to %d, rescale timestamps", msg.Old, msg.Real)
+			}
+		})
+	}
+
+	if conn.AVSync != 0 {
+		conn.Listen(func(msg any) {
+			if msg, ok := msg.(*rtsp.AVSync); ok {
+				log.Warn().Str("url", core.StripUserinfo(rawURL)).Str("media", msg.Media.String()).
+					Msgf("[rtsp] audio drift %s from video, resync audio timestamps", msg.Drift)
+			}
+		})
+	}
+
+	if conn.Magic == "auto" {
+		conn.Listen(func(msg any) {
+			if msg, ok := msg.(*rtsp.Magic); ok {
+				log.Warn().Str("url", core.StripUserinfo(rawURL)).
+					Msgf("[rtsp] camera uses non-standard interleaved magic byte 0x%02X", msg.Byte)
+			}
+		})
+	}
+
+	conn.Listen(func(msg any) {
+		if err, ok := msg.(error); ok && errors.Is(err, rtsp.ErrFastStart) {
+			log.Warn().Err(err).Str("url", core.StripUserinfo(rawURL)).Msg("[rtsp] play without deferred medias")
+		}
+	})
+
+	conn.Listen(func(msg any) {
+		if msg, ok := msg.(*rtsp.SSRCChange); ok {
+			log.Warn().Str("url", core.StripUserinfo(rawURL)).Str("codec", msg.Codec.String()).
+				Bool("rewrite", msg.Rewrite).Msgf("[rtsp] source changed SSRC from %08X to %08X", msg.Old, msg.New)
+		}
+	})
+
+	if conn.Scale != 0 {
+		conn.Listen(func(msg any) {
+			if msg, ok := msg.(*rtsp.Playback); ok && msg.Real != msg.Scale {
+				log.Warn().Str("url", core.StripUserinfo(rawURL)).
+					Msgf("[rtsp] playback scale %g requested, server plays %g", msg.Scale, msg.Real)
+			}
+		})
 	}
 
 	if log.Trace().Enabled() {
@@ -114,6 +379,10 @@ func rtspHandler(rawURL string) (core.Producer, error) {
 				log.Trace().Msgf("[rtsp] client request:\n%s", msg)
 			case *tcp.Response:
 				log.Trace().Msgf("[rtsp] client response:\n%s", msg)
+			case *rtsp.APP:
+				log.Trace().Msgf("[rtsp] client rtcp app name=%s subtype=%d data=%x", msg.Name, msg.SubType, msg.Data)
+			case *rtsp.Data:
+				log.Trace().Msgf("[rtsp] client data media=%s ts=%d size=%d", msg.Media, msg.Timestamp, len(msg.Payload))
 			case string:
 				log.Trace().Msgf("[rtsp] client msg: %s", msg)
 			}
@@ -121,26 +390,35 @@ func rtspHandler(rawURL string) (core.Producer, error) {
 	}
 
 	if err := conn.Dial(); err != nil {
-		return nil, err
+		if errors.Is(err, tcp.ErrDenied) {
+			log.Warn().Err(err).Str("url", core.StripUserinfo(rawURL)).Msg("[rtsp] source denied")
+		} else if errors.Is(err, tcp.ErrFingerprint) {
+			log.Warn().Err(err).Str("url", core.StripUserinfo(rawURL)).Msg("[rtsp] possible MITM or camera certificate was changed")
+		}
+		return nil, false, err
 	}
 
 	if err := conn.Describe(); err != nil {
 		if !conn.Backchannel {
-			return nil, err
+			return nil, false, err
 		}
 		log.Trace().Msgf("[rtsp] describe (backchannel=%t) err: %v", conn.Backchannel, err)
 
 		// second try without backchannel, we need to reconnect
 		conn.Backchannel = false
 		if err = conn.Dial(); err != nil {
-			return nil, err
+			return nil, false, err
 		}
 		if err = conn.Describe(); err != nil {
-			return nil, err
+			return nil, false, err
 		}
 	}
 
-	return conn, nil
+	for _, skipped := range conn.Skipped {
+		log.Warn().Str("url", core.StripUserinfo(rawURL)).Str("media", skipped.Media).Msg("[rtsp] skip media: " + skipped.Reason)
+	}
+
+	return conn, data, nil
 }
 
 func tcpHandler(conn *rtsp.Conn) {
@@ -169,7 +447,7 @@ func tcpHandler(conn *rtsp.Conn) {
 
 			name = conn.URL.Path[1:]
 
-			stream := streams.Get(name)
+			stream := streams.GetQuality(name, conn.URL.Query().Get("quality"))
 			if stream == nil {
 				return
 			}
@@ -186,7 +464,12 @@ func tcpHandler(conn *rtsp.Conn) {
 				}
 			}
 
-			if query.Get("backchannel") == "1" {
+			if codecs := allowCodecs[name]; codecs != nil {
+				conn.Medias = filterCodecs(conn.Medias, codecs)
+			}
+
+			// backchannel will be in SDP only if the stream source supports it
+			if s := query.Get("backchannel"); s == "1" || conn.Backchannel && s != "0" {
 				conn.Medias = append(conn.Medias, &core.Media{
 					Kind:      core.KindAudio,
 					Direction: core.DirectionRecvonly,
@@ -202,10 +485,23 @@ func tcpHandler(conn *rtsp.Conn) {
 				})
 			}
 
+			conn.Kinds = core.ParseKinds(query.Get("media"))
+
 			if s := query.Get("pkt_size"); s != "" {
 				conn.PacketSize = uint16(core.Atoi(s))
 			}
 
+			conn.Pacing = query.Get("pacing") == "1"
+			conn.Coalesce = query.Get("coalesce") == "1"
+			conn.Rebase = query.Get("rebase") == "1"
+
+			if s := query.Get("param_sets"); s != "" {
+				conn.ParamSets = s == "1"
+			}
+			if s := query.Get("param_sets_interval"); s != "" {
+				conn.ParamsInterval = time.Duration(core.Atoi(s)) * time.Second
+			}
+
 			// param name like ffmpeg style https://ffmpeg.org/ffmpeg-protocols.html
 			if s := query.Get("log_level"); s != "" {
 				if lvl, err := zerolog.ParseLevel(s); err == nil {
@@ -256,6 +552,8 @@ func tcpHandler(conn *rtsp.Conn) {
 	if err := conn.Accept(); err != nil {
 		if errors.Is(err, rtsp.FailedAuth) {
 			log.Warn().Str("remote_addr", conn.Connection.RemoteAddr).Msg("[rtsp] failed authentication")
+		} else if errors.Is(err, rtsp.ErrSetupTimeout) {
+			log.Info().Str("remote_addr", conn.Connection.RemoteAddr).Str("stream", name).Msg("[rtsp] close session without PLAY after SETUP")
 		} else if err != io.EOF {
 			log.WithLevel(level).Err(err).Caller().Send()
 		}
@@ -285,6 +583,95 @@ func tcpHandler(conn *rtsp.Conn) {
 	_ = conn.Close()
 }
 
+// parsePayloadMap - parse `35:96,36:97` to map of incoming => SDP payload types
+func parsePayloadMap(s string) map[uint8]uint8 {
+	if s == "" {
+		return nil
+	}
+	m := map[uint8]uint8{}
+	for _, pair := range strings.Split(s, ",") {
+		if from, to, ok := strings.Cut(pair, ":"); ok {
+			m[core.ParseByte(from)] = core.ParseByte(to)
+		}
+	}
+	return m
+}
+
+// parseRTPMap - parse `96:H264/90000,97:PCMA/8000/1` to map of payload type => codec
+func parseRTPMap(s string) map[uint8]*core.Codec {
+	if s == "" {
+		return nil
+	}
+	m := map[uint8]*core.Codec{}
+	for _, pair := range strings.Split(s, ",") {
+		pt, value, ok := strings.Cut(pair, ":")
+		if !ok {
+			continue
+		}
+		ss := strings.Split(value, "/")
+		codec := &core.Codec{Name: strings.ToUpper(ss[0])}
+		if len(ss) > 1 {
+			codec.ClockRate = uint32(core.Atoi(ss[1]))
+		}
+		if len(ss) > 2 && ss[2] == "2" {
+			codec.Channels = 2 // same as for a=rtpmap
+		}
+		m[core.ParseByte(pt)] = codec
+	}
+	return m
+}
+
+// parseCodecs - parse allowed codecs list, ex. `h264,aac,pcma`
+func parseCodecs(s string) []*core.Codec {
+	// same aliases as for query params
+	medias := core.ParseQuery(map[string][]string{core.KindVideo: {s}})
+	return medias[0].Codecs
+}
+
+// filterCodecs - leave only allowed codecs in consumer medias,
+// so legacy clients will never see unsupported codecs (ex. H265) in SDP
+func filterCodecs(medias []*core.Media, allowed []*core.Codec) []*core.Media {
+	var filtered []*core.Media
+
+	for _, media := range medias {
+		var kindCodecs []*core.Codec
+		for _, codec := range allowed {
+			if core.GetKind(codec.Name) == media.Kind {
+				kindCodecs = append(kindCodecs, codec.Clone())
+			}
+		}
+
+		var codecs []*core.Codec
+		for _, codec := range media.Codecs {
+			switch codec.Name {
+			case core.CodecAll:
+				// all tracks - separate media for each allowed codec
+				for _, kindCodec := range kindCodecs {
+					filtered = append(filtered, &core.Media{
+						Kind: media.Kind, Direction: media.Direction, Codecs: []*core.Codec{kindCodec},
+					})
+				}
+			case core.CodecAny:
+				codecs = append(codecs, kindCodecs...)
+			default:
+				for _, kindCodec := range kindCodecs {
+					if kindCodec.Name == codec.Name {
+						codecs = append(codecs, codec)
+						break
+					}
+				}
+			}
+		}
+
+		if codecs != nil {
+			media.Codecs = codecs
+			filtered = append(filtered, media)
+		}
+	}
+
+	return filtered
+}
+
 func ParseQuery(query map[string][]string) []*core.Media {
 	if v := query["mp4"]; v != nil {
 		return []*core.Media{
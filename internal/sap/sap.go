@@ -0,0 +1,201 @@
+package sap
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/AlexxIT/go2rtc/internal/app"
+	"github.com/AlexxIT/go2rtc/internal/streams"
+	"github.com/AlexxIT/go2rtc/pkg/core"
+	"github.com/AlexxIT/go2rtc/pkg/sap"
+	"github.com/rs/zerolog"
+)
+
+func Init() {
+	var cfg struct {
+		Mod struct {
+			Listen string `yaml:"listen"` // ex. 224.2.127.254:9875, empty - disabled
+		} `yaml:"sap"`
+	}
+
+	app.LoadConfig(&cfg)
+
+	if cfg.Mod.Listen == "" {
+		return
+	}
+
+	log = app.GetLogger("sap")
+
+	addr, err := net.ResolveUDPAddr("udp", cfg.Mod.Listen)
+	if err != nil {
+		log.Error().Err(err).Send()
+		return
+	}
+
+	streams.HandleFunc("sap", streamSAP)
+
+	go listen(addr)
+	go expire()
+}
+
+var log zerolog.Logger
+
+// Tags - of each discovered stream, so they can be selected by the streams API
+var Tags = []string{"discovered", "sap"}
+
+// Timeout - remove the session without the new announcement, RFC 2974 recommends one hour
+var Timeout = time.Hour
+
+type session struct {
+	name string // stream name
+	sdp  []byte
+	seen time.Time
+}
+
+var (
+	sessions = map[string]*session{} // by session ID from SDP origin
+	mu       sync.Mutex
+)
+
+// streamSAP - source sap:name, the last SDP of the discovered session
+func streamSAP(source string) (core.Producer, error) {
+	name := source[4:]
+
+	mu.Lock()
+	var rawSDP []byte
+	for _, s := range sessions {
+		if s.name == name {
+			rawSDP = s.sdp
+			break
+		}
+	}
+	mu.Unlock()
+
+	if rawSDP == nil {
+		return nil, errors.New("sap: session not announced: " + name)
+	}
+
+	return sap.NewProducer(rawSDP)
+}
+
+func listen(addr *net.UDPAddr) {
+	conn, err := net.ListenMulticastUDP("udp", nil, addr)
+	if err != nil {
+		log.Error().Err(err).Send()
+		return
+	}
+
+	log.Info().Str("addr", addr.String()).Msg("[sap] listen")
+
+	b := make([]byte, 64*1024)
+	for {
+		n, err := conn.Read(b)
+		if err != nil {
+			log.Error().Err(err).Send()
+			return
+		}
+
+		a, err := sap.Unmarshal(b[:n])
+		if err != nil {
+			log.Trace().Err(err).Send()
+			continue
+		}
+
+		handle(a, time.Now())
+	}
+}
+
+func handle(a *sap.Announcement, now time.Time) {
+	id, title, err := sap.Session(a.SDP)
+	if err != nil {
+		log.Trace().Err(err).Send()
+		return
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	s := sessions[id]
+
+	if a.Delete {
+		if s != nil {
+			remove(id, s)
+		}
+		return
+	}
+
+	if s != nil {
+		s.seen = now
+		if string(s.sdp) != string(a.SDP) {
+			// new version of the session, producers should dial it again
+			s.sdp = a.SDP
+			if stream := streams.Get(s.name); stream != nil {
+				go stream.Reconnect()
+			}
+		}
+		return
+	}
+
+	name := streamName(title, a.Hash)
+	if name == "" {
+		log.Warn().Msgf("[sap] stream name already used for session: %s", title)
+		return
+	}
+
+	if _, err = streams.NewTagged(name, Tags, "sap:"+name); err != nil {
+		log.Warn().Err(err).Caller().Send()
+		return
+	}
+
+	sessions[id] = &session{name: name, sdp: a.SDP, seen: now}
+
+	log.Info().Str("origin", a.Origin.String()).Msgf("[sap] discovered stream=%s session=%s", name, title)
+}
+
+// streamName - from the session name, with the hash if it is already used
+func streamName(title string, hash uint16) string {
+	if title = strings.TrimSpace(title); title == "" || title == "-" {
+		title = fmt.Sprintf("%04x", hash)
+	}
+
+	name := "sap_" + strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '_' {
+			return r
+		}
+		return '_'
+	}, title)
+
+	for _, name := range []string{name, fmt.Sprintf("%s_%04x", name, hash)} {
+		if streams.Get(name) == nil {
+			return name
+		}
+	}
+	return ""
+}
+
+func expire() {
+	for now := range time.Tick(time.Minute) {
+		mu.Lock()
+		for id, s := range sessions {
+			if now.Sub(s.seen) > Timeout {
+				remove(id, s)
+			}
+		}
+		mu.Unlock()
+	}
+}
+
+func remove(id string, s *session) {
+	delete(sessions, id)
+
+	if stream := streams.Get(s.name); stream != nil {
+		streams.Delete(s.name)
+		go stream.Stop()
+	}
+
+	log.Info().Msgf("[sap] remove stream=%s", s.name)
+}
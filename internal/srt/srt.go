@@ -0,0 +1,13 @@
+package srt
+
+import (
+	"github.com/AlexxIT/go2rtc/internal/streams"
+	"github.com/AlexxIT/go2rtc/pkg/core"
+	"github.com/AlexxIT/go2rtc/pkg/srt"
+)
+
+func Init() {
+	streams.HandleFunc("srt", func(source string) (core.Producer, error) {
+		return srt.Open(source)
+	})
+}
@@ -8,86 +8,123 @@ import (
 )
 
 func (s *Stream) AddConsumer(cons core.Consumer) (err error) {
-	// support for multiple simultaneous pending from different consumers
+	if s.stopped.Load() {
+		return errStreamStopped
+	}
+
+	// support for multiple simultaneous pending from different consumers,
+	// lock protects from stopping producers in the same time
+	s.mu.Lock()
 	consN := s.pending.Add(1) - 1
+	s.mu.Unlock()
 
 	var prodErrors = make([]error, len(s.producers))
 	var prodMedias []*core.Media
 	var prodStarts []*Producer
 
 	// Step 1. Get consumer medias
-	consMedias := cons.GetMedias()
+	consMedias := filterKinds(cons, cons.GetMedias())
 	for _, consMedia := range consMedias {
 		log.Trace().Msgf("[streams] check cons=%d media=%s", consN, consMedia)
 
-	producers:
-		for prodN, prod := range s.producers {
-			// check for loop request, ex. `camera1: ffmpeg:camera1`
-			if info, ok := cons.(core.Info); ok && prod.url == info.GetSource() {
-				log.Trace().Msgf("[streams] skip cons=%d prod=%d", consN, prodN)
-				continue
-			}
+		var matched bool
 
-			if prodErrors[prodN] != nil {
-				log.Trace().Msgf("[streams] skip cons=%d prod=%d", consN, prodN)
-				continue
+		// second pass with transcoders, only if no producer has the consumer codec
+		for _, transcode := range []bool{false, true} {
+			if matched || transcode && transcoders == nil {
+				break
 			}
 
-			if err = prod.Dial(); err != nil {
-				log.Trace().Err(err).Msgf("[streams] dial cons=%d prod=%d", consN, prodN)
-				prodErrors[prodN] = err
-				continue
-			}
-
-			// Step 2. Get producer medias (not tracks yet)
-			for _, prodMedia := range prod.GetMedias() {
-				log.Trace().Msgf("[streams] check cons=%d prod=%d media=%s", consN, prodN, prodMedia)
-				prodMedias = append(prodMedias, prodMedia)
+		producers:
+			for prodN, prod := range s.producers {
+				// check for loop request, ex. `camera1: ffmpeg:camera1`
+				if info, ok := cons.(core.Info); ok && prod.source() == info.GetSource() {
+					log.Trace().Msgf("[streams] skip cons=%d prod=%d", consN, prodN)
+					continue
+				}
 
-				// Step 3. Match consumer/producer codecs list
-				prodCodec, consCodec := prodMedia.MatchMedia(consMedia)
-				if prodCodec == nil {
+				if prodErrors[prodN] != nil {
+					log.Trace().Msgf("[streams] skip cons=%d prod=%d", consN, prodN)
 					continue
 				}
 
-				var track *core.Receiver
+				if err = prod.Dial(); err != nil {
+					log.Trace().Err(err).Msgf("[streams] dial cons=%d prod=%d", consN, prodN)
+					prodErrors[prodN] = err
+					continue
+				}
 
-				switch prodMedia.Direction {
-				case core.DirectionRecvonly:
-					log.Trace().Msgf("[streams] match cons=%d <= prod=%d", consN, prodN)
+				// Step 2. Get producer medias (not tracks yet)
+				for _, prodMedia := range prod.GetMedias() {
+					log.Trace().Msgf("[streams] check cons=%d prod=%d media=%s", consN, prodN, prodMedia)
+					if !transcode {
+						prodMedias = append(prodMedias, prodMedia)
+					}
 
-					// Step 4. Get recvonly track from producer
-					if track, err = prod.GetTrack(prodMedia, prodCodec); err != nil {
-						log.Info().Err(err).Msg("[streams] can't get track")
-						prodErrors[prodN] = err
-						continue
+					// Step 3. Match consumer/producer codecs list
+					var transcoder core.Transcoder
+					prodCodec, consCodec := prodMedia.MatchMedia(consMedia)
+					if transcode {
+						if prodCodec != nil {
+							continue // already checked in the first pass
+						}
+						transcoder, prodCodec, consCodec = matchTranscoder(prodMedia, consMedia)
 					}
-					// Step 5. Add track to consumer
-					if err = cons.AddTrack(consMedia, consCodec, track); err != nil {
-						log.Info().Err(err).Msg("[streams] can't add track")
+					if prodCodec == nil {
 						continue
 					}
 
-				case core.DirectionSendonly:
-					log.Trace().Msgf("[streams] match cons=%d => prod=%d", consN, prodN)
-
-					// Step 4. Get recvonly track from consumer (backchannel)
-					if track, err = cons.(core.Producer).GetTrack(consMedia, consCodec); err != nil {
-						log.Info().Err(err).Msg("[streams] can't get track")
-						continue
-					}
-					// Step 5. Add track to producer
-					if err = prod.AddTrack(prodMedia, prodCodec, track); err != nil {
-						log.Info().Err(err).Msg("[streams] can't add track")
-						prodErrors[prodN] = err
-						continue
+					var track *core.Receiver
+
+					switch prodMedia.Direction {
+					case core.DirectionRecvonly:
+						log.Trace().Msgf("[streams] match cons=%d <= prod=%d", consN, prodN)
+
+						// Step 4. Get recvonly track from producer
+						if track, err = prod.GetTrack(prodMedia, prodCodec); err != nil {
+							log.Info().Err(err).Msg("[streams] can't get track")
+							prodErrors[prodN] = err
+							continue
+						}
+						if track.Codec.IsAudio() {
+							track.Mute(s.muted.Load())
+						}
+						if transcoder != nil {
+							log.Trace().Msgf("[streams] transcode cons=%d %s <= prod=%d %s", consN, consCodec, prodN, prodCodec)
+							track = transcodeTrack(transcoder, consMedia, consCodec, track)
+						}
+						// Step 5. Add track to consumer
+						if err = cons.AddTrack(consMedia, consCodec, track); err != nil {
+							log.Info().Err(err).Msg("[streams] can't add track")
+							continue
+						}
+
+					case core.DirectionSendonly:
+						log.Trace().Msgf("[streams] match cons=%d => prod=%d", consN, prodN)
+
+						// Step 4. Get recvonly track from consumer (backchannel)
+						if track, err = cons.(core.Producer).GetTrack(consMedia, consCodec); err != nil {
+							log.Info().Err(err).Msg("[streams] can't get track")
+							continue
+						}
+						if transcoder != nil {
+							log.Trace().Msgf("[streams] transcode cons=%d %s => prod=%d %s", consN, consCodec, prodN, prodCodec)
+							track = transcodeTrack(transcoder, prodMedia, prodCodec, track)
+						}
+						// Step 5. Add track to producer
+						if err = prod.AddTrack(prodMedia, prodCodec, track); err != nil {
+							log.Info().Err(err).Msg("[streams] can't add track")
+							prodErrors[prodN] = err
+							continue
+						}
 					}
-				}
 
-				prodStarts = append(prodStarts, prod)
+					prodStarts = append(prodStarts, prod)
+					matched = true
 
-				if !consMedia.MatchAll() {
-					break producers
+					if !consMedia.MatchAll() {
+						break producers
+					}
 				}
 			}
 		}
@@ -95,20 +132,32 @@ func (s *Stream) AddConsumer(cons core.Consumer) (err error) {
 
 	// stop producers if they don't have readers
 	if s.pending.Add(-1) == 0 {
-		s.stopProducers()
+		s.stopProducers(StopGrace)
 	}
 
 	if len(prodStarts) == 0 {
 		return formatError(consMedias, prodMedias, prodErrors)
 	}
 
+	setBuffer(cons)
+	setPrebuffer(cons)
+	setLayers(cons)
+
 	s.mu.Lock()
 	s.consumers = append(s.consumers, cons)
+	s.consumerStarted(cons)
 	s.mu.Unlock()
 
 	// there may be duplicates, but that's not a problem
+	var started bool
 	for _, prod := range prodStarts {
-		prod.start()
+		if prod.start() {
+			started = true
+		}
+	}
+
+	if started {
+		fireLifecycle(EventStart, s.name, ReasonConsumer)
 	}
 
 	return nil
@@ -164,3 +213,32 @@ func appendString(s, elem string) string {
 	}
 	return s + ", " + elem
 }
+
+// ConsumerBuffers - queue depth of each consumer track by the consumer type
+// (format name), ex. small for WebRTC and big for HLS, default depends on the codec
+var ConsumerBuffers map[string]int
+
+func setBuffer(cons core.Consumer) {
+	if len(ConsumerBuffers) == 0 {
+		return
+	}
+
+	buffered, ok := cons.(interface{ SetBuffer(size int) })
+	if !ok {
+		return
+	}
+
+	name := formatName(cons)
+	if size := ConsumerBuffers[name]; size > 0 {
+		log.Trace().Msgf("[streams] set buffer=%d for consumer=%s", size, name)
+		buffered.SetBuffer(size)
+	}
+}
+
+// formatName - consumer type without the JSON roundtrip of the whole connection
+func formatName(cons core.Consumer) string {
+	if c, ok := cons.(interface{ GetFormatName() string }); ok {
+		return c.GetFormatName()
+	}
+	return ""
+}
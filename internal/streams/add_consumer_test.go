@@ -0,0 +1,230 @@
+package streams
+
+import (
+	"math/rand"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/AlexxIT/go2rtc/pkg/core"
+	"github.com/stretchr/testify/require"
+)
+
+type testProducer struct {
+	core.Connection
+	done chan struct{}
+	live *atomic.Int32
+	err  error // Start result after Stop
+}
+
+func (p *testProducer) Start() error {
+	<-p.done
+	return p.err
+}
+
+func (p *testProducer) Stop() error {
+	select {
+	case <-p.done:
+	default:
+		close(p.done)
+		p.live.Add(-1)
+	}
+	return p.Connection.Stop()
+}
+
+type testConsumer struct {
+	core.Connection
+}
+
+func (c *testConsumer) AddTrack(media *core.Media, _ *core.Codec, track *core.Receiver) error {
+	sender := core.NewSender(media, track.Codec)
+	sender.Handler = func(*core.Packet) {}
+	sender.HandleRTP(track)
+	c.Senders = append(c.Senders, sender)
+	return nil
+}
+
+func newTestConsumer() *testConsumer {
+	return &testConsumer{
+		Connection: core.Connection{
+			Medias: []*core.Media{
+				{Kind: core.KindVideo, Direction: core.DirectionSendonly, Codecs: []*core.Codec{{Name: core.CodecH264}}},
+			},
+		},
+	}
+}
+
+func TestConsumersStorm(t *testing.T) {
+	var live, maxLive, dials atomic.Int32
+
+	HandleFunc("storm", func(url string) (core.Producer, error) {
+		dials.Add(1)
+		if n := live.Add(1); n > maxLive.Load() {
+			maxLive.Store(n)
+		}
+		return &testProducer{
+			Connection: core.Connection{
+				Medias: []*core.Media{
+					{Kind: core.KindVideo, Direction: core.DirectionRecvonly, Codecs: []*core.Codec{{Name: core.CodecH264, ClockRate: 90000}}},
+				},
+			},
+			done: make(chan struct{}),
+			live: &live,
+		}, nil
+	})
+
+	stream := NewStream("storm:camera1")
+
+	// require can't be called from other goroutines, so errors are checked after
+	errs := make(chan error, 100*10)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 10; j++ {
+				cons := newTestConsumer()
+				if err := stream.AddConsumer(cons); err != nil {
+					errs <- err
+					continue
+				}
+				time.Sleep(time.Duration(rand.Intn(100)) * time.Microsecond)
+				stream.RemoveConsumer(cons)
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		require.Nil(t, err)
+	}
+
+	require.Equal(t, int32(1), maxLive.Load(), "only one upstream connection at a time")
+	require.Equal(t, int32(0), live.Load(), "connection closed after last consumer")
+	require.Equal(t, stateNone, stream.producers[0].state)
+	require.Empty(t, stream.consumers)
+}
+
+func TestStopGrace(t *testing.T) {
+	var live, dials atomic.Int32
+
+	HandleFunc("grace", func(url string) (core.Producer, error) {
+		dials.Add(1)
+		live.Add(1)
+		return &testProducer{
+			Connection: core.Connection{
+				Medias: []*core.Media{
+					{Kind: core.KindVideo, Direction: core.DirectionRecvonly, Codecs: []*core.Codec{{Name: core.CodecH264, ClockRate: 90000}}},
+				},
+			},
+			done: make(chan struct{}),
+			live: &live,
+		}, nil
+	})
+
+	StopGrace = 50 * time.Millisecond
+	defer func() { StopGrace = 0 }()
+
+	stream := NewStream("grace:camera1")
+
+	cons := newTestConsumer()
+	require.Nil(t, stream.AddConsumer(cons))
+	stream.RemoveConsumer(cons)
+
+	// reconnect within grace reuses the same connection
+	cons = newTestConsumer()
+	require.Nil(t, stream.AddConsumer(cons))
+	require.Equal(t, int32(1), dials.Load())
+	stream.RemoveConsumer(cons)
+
+	require.Eventually(t, func() bool { return live.Load() == 0 }, time.Second, 10*time.Millisecond)
+}
+
+func TestReconnectGrace(t *testing.T) {
+	var live, dials atomic.Int32
+
+	HandleFunc("dropped", func(url string) (core.Producer, error) {
+		dials.Add(1)
+		live.Add(1)
+		return &testProducer{
+			Connection: core.Connection{
+				Medias: []*core.Media{
+					{Kind: core.KindVideo, Direction: core.DirectionRecvonly, Codecs: []*core.Codec{{Name: core.CodecH264, ClockRate: 90000}}},
+				},
+			},
+			done: make(chan struct{}),
+			live: &live,
+		}, nil
+	})
+
+	ReconnectGrace = 50 * time.Millisecond
+	defer func() { ReconnectGrace = 0 }()
+
+	stream := NewStream("dropped:camera1")
+
+	cons := newTestConsumer()
+	require.Nil(t, stream.AddConsumer(cons))
+	stream.DropConsumer(cons)
+
+	// reconnect within grace reuses the same connection
+	cons = newTestConsumer()
+	require.Nil(t, stream.AddConsumer(cons))
+	require.Equal(t, int32(1), dials.Load())
+
+	// clean leave doesn't cancel the grace of the dropped consumer
+	stream.RemoveConsumer(cons)
+	require.Equal(t, int32(1), live.Load())
+
+	require.Eventually(t, func() bool { return live.Load() == 0 }, time.Second, 10*time.Millisecond)
+
+	// without drop producer stops at once
+	cons = newTestConsumer()
+	require.Nil(t, stream.AddConsumer(cons))
+	stream.RemoveConsumer(cons)
+	require.Eventually(t, func() bool { return live.Load() == 0 }, time.Second, 10*time.Millisecond)
+	require.Equal(t, int32(2), dials.Load())
+}
+
+func TestEndOfStream(t *testing.T) {
+	var live atomic.Int32
+	var prod *testProducer
+
+	HandleFunc("vod", func(url string) (core.Producer, error) {
+		live.Add(1)
+		prod = &testProducer{
+			Connection: core.Connection{
+				Medias: []*core.Media{
+					{Kind: core.KindVideo, Direction: core.DirectionRecvonly, Codecs: []*core.Codec{{Name: core.CodecH264, ClockRate: 90000}}},
+				},
+			},
+			done: make(chan struct{}),
+			live: &live,
+			err:  core.ErrEndOfStream,
+		}
+		return prod, nil
+	})
+
+	stream := NewStream("vod:recording1")
+	stream.name = "vod_end"
+	streamsMu.Lock()
+	streams["vod_end"] = stream
+	streamsMu.Unlock()
+	defer Delete("vod_end")
+
+	require.Nil(t, stream.AddConsumer(newTestConsumer()))
+
+	// source finished playback, consumers are stopped without reconnect
+	_ = prod.Stop()
+	require.Eventually(t, func() bool {
+		stream.mu.Lock()
+		defer stream.mu.Unlock()
+		return len(stream.consumers) == 0
+	}, time.Second, 10*time.Millisecond)
+
+	stream.producers[0].mu.Lock()
+	require.Equal(t, stateNone, stream.producers[0].state)
+	stream.producers[0].mu.Unlock()
+}
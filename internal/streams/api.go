@@ -1,7 +1,9 @@
 package streams
 
 import (
+	"encoding/json"
 	"net/http"
+	"slices"
 
 	"github.com/AlexxIT/go2rtc/internal/api"
 	"github.com/AlexxIT/go2rtc/internal/app"
@@ -16,9 +18,13 @@ func apiStreams(w http.ResponseWriter, r *http.Request) {
 	query := r.URL.Query()
 	src := query.Get("src")
 
-	// without source - return all streams list
+	// without source - return all streams list or streams with tag
 	if src == "" && r.Method != "POST" {
-		api.ResponseJSON(w, streams)
+		if tag := query.Get("tag"); tag != "" {
+			api.ResponseJSON(w, GetByTag(tag))
+		} else {
+			api.ResponseJSON(w, streams)
+		}
 		return
 	}
 
@@ -99,6 +105,7 @@ func apiStreams(w http.ResponseWriter, r *http.Request) {
 
 	case "DELETE":
 		delete(streams, src)
+		app.StreamLog.Delete(src)
 
 		if err := app.PatchConfig([]string{"streams", src}, nil); err != nil {
 			http.Error(w, err.Error(), http.StatusBadRequest)
@@ -106,6 +113,152 @@ func apiStreams(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// apiStreamsReconnect - reconnect streams by names (src) and/or by tags (tag)
+func apiStreamsReconnect(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case "GET":
+		api.ResponseJSON(w, struct {
+			limiterStats
+			MaxAttempts int     `json:"max_attempts"`    // for each source, 0 - unlimited
+			Window      float64 `json:"attempts_window"` // in seconds
+			HostRate    float64 `json:"host_rate"`       // attempts per minute for each host, 0 - unlimited
+			HostBurst   int     `json:"host_burst"`
+			HostWaiting int     `json:"host_waiting"` // attempts waiting for the host token
+		}{
+			limiterStats: reconnects.stats(),
+			MaxAttempts:  MaxReconnects,
+			Window:       ReconnectWindow.Seconds(),
+			HostRate:     hosts.rate * 60,
+			HostBurst:    hosts.burst,
+			HostWaiting:  hosts.stats(),
+		})
+		return
+	case "POST":
+	default:
+		http.Error(w, "", http.StatusMethodNotAllowed)
+		return
+	}
+
+	selected := selectStreams(r)
+	if len(selected) == 0 {
+		http.Error(w, "", http.StatusNotFound)
+		return
+	}
+
+	names := make([]string, 0, len(selected))
+	for name, stream := range selected {
+		stream.Reconnect()
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	api.ResponseJSON(w, names)
+}
+
+// apiStreamsDTMF - send DTMF digits (POST) over the backchannel of the stream (src),
+// ex. the door unlock code for the intercom
+func apiStreamsDTMF(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "", http.StatusMethodNotAllowed)
+		return
+	}
+
+	query := r.URL.Query()
+
+	stream := Get(query.Get("src"))
+	if stream == nil {
+		http.Error(w, api.StreamNotFound, http.StatusNotFound)
+		return
+	}
+
+	digits := query.Get("digits")
+	if digits == "" {
+		http.Error(w, "no digits", http.StatusBadRequest)
+		return
+	}
+
+	if err := stream.WriteDTMF(digits); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	}
+}
+
+// apiStreamsLog - last n log entries of the stream (src), ex. reconnects and errors
+func apiStreamsLog(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	src := query.Get("src")
+	if Get(src) == nil {
+		http.Error(w, api.StreamNotFound, http.StatusNotFound)
+		return
+	}
+
+	entries := app.StreamLog.Get(src, core.Atoi(query.Get("n")))
+	if entries == nil {
+		entries = []json.RawMessage{}
+	}
+	api.ResponseJSON(w, entries)
+}
+
+// apiStreamsMute - mute (POST) or unmute (DELETE) audio of streams by names (src) and/or by tags (tag)
+func apiStreamsMute(w http.ResponseWriter, r *http.Request) {
+	var muted bool
+
+	switch r.Method {
+	case "GET":
+		names := []string{}
+		streamsMu.Lock()
+		for name, stream := range streams {
+			if stream.Muted() {
+				names = append(names, name)
+			}
+		}
+		streamsMu.Unlock()
+		slices.Sort(names)
+
+		api.ResponseJSON(w, names)
+		return
+	case "POST":
+		muted = true
+	case "DELETE":
+	default:
+		http.Error(w, "", http.StatusMethodNotAllowed)
+		return
+	}
+
+	selected := selectStreams(r)
+	if len(selected) == 0 {
+		http.Error(w, "", http.StatusNotFound)
+		return
+	}
+
+	names := make([]string, 0, len(selected))
+	for name, stream := range selected {
+		stream.Mute(muted)
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	api.ResponseJSON(w, names)
+}
+
+// selectStreams - streams by names (src) and by tags (tag) from the query
+func selectStreams(r *http.Request) map[string]*Stream {
+	query := r.URL.Query()
+
+	selected := map[string]*Stream{}
+	for _, name := range query["src"] {
+		if stream := Get(name); stream != nil {
+			selected[name] = stream
+		}
+	}
+	for _, tag := range query["tag"] {
+		for name, stream := range GetByTag(tag) {
+			selected[name] = stream
+		}
+	}
+	return selected
+}
+
 func apiStreamsDOT(w http.ResponseWriter, r *http.Request) {
 	query := r.URL.Query()
 
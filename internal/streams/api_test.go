@@ -64,3 +64,147 @@ func TestApiSchemesNoDuplicates(t *testing.T) {
 	// Should only appear once
 	require.Equal(t, 1, count, "scheme 'duplicate' should appear exactly once")
 }
+
+func TestApiStreamsTag(t *testing.T) {
+	streamsMu.Lock()
+	for name, item := range map[string]any{
+		"tag1": map[string]any{"url": "rtsp://localhost/1", "tags": []any{"parking", "building1"}},
+		"tag2": map[string]any{"url": "rtsp://localhost/2", "tags": "parking"},
+		"tag3": "rtsp://localhost/3",
+	} {
+		streams[name] = NewStream(item)
+		streams[name].name = name
+	}
+	streams["tag1_alias"] = streams["tag1"]
+	streamsMu.Unlock()
+
+	req := httptest.NewRequest("GET", "/api/streams?tag=parking", nil)
+	w := httptest.NewRecorder()
+	apiStreams(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var info map[string]struct {
+		Tags []string `json:"tags"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &info))
+	require.Len(t, info, 2)
+	require.Equal(t, []string{"parking", "building1"}, info["tag1"].Tags)
+	require.Equal(t, []string{"parking"}, info["tag2"].Tags)
+
+	req = httptest.NewRequest("POST", "/api/streams/reconnect?tag=building1&src=tag3", nil)
+	w = httptest.NewRecorder()
+	apiStreamsReconnect(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+	require.JSONEq(t, `["tag1","tag3"]`, w.Body.String())
+
+	req = httptest.NewRequest("POST", "/api/streams/reconnect?tag=unknown", nil)
+	w = httptest.NewRecorder()
+	apiStreamsReconnect(w, req)
+	require.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestApiStreamsMute(t *testing.T) {
+	audio := core.NewReceiver(nil, &core.Codec{Name: core.CodecPCMA})
+	video := core.NewReceiver(nil, &core.Codec{Name: core.CodecH264})
+
+	stream := NewStream("rtsp://localhost/mute")
+	stream.producers[0].receivers = []*core.Receiver{video, audio}
+
+	streamsMu.Lock()
+	streams["mute1"] = stream
+	streamsMu.Unlock()
+
+	req := httptest.NewRequest("POST", "/api/streams/mute?src=mute1", nil)
+	w := httptest.NewRecorder()
+	apiStreamsMute(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+	require.JSONEq(t, `["mute1"]`, w.Body.String())
+
+	require.True(t, stream.Muted())
+	require.True(t, audio.Muted())
+	require.False(t, video.Muted())
+
+	b, err := stream.MarshalJSON()
+	require.NoError(t, err)
+	require.Contains(t, string(b), `"muted":true`)
+
+	req = httptest.NewRequest("GET", "/api/streams/mute", nil)
+	w = httptest.NewRecorder()
+	apiStreamsMute(w, req)
+	require.JSONEq(t, `["mute1"]`, w.Body.String())
+
+	req = httptest.NewRequest("DELETE", "/api/streams/mute?src=mute1", nil)
+	w = httptest.NewRecorder()
+	apiStreamsMute(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+	require.False(t, audio.Muted())
+
+	req = httptest.NewRequest("POST", "/api/streams/mute?src=unknown", nil)
+	w = httptest.NewRecorder()
+	apiStreamsMute(w, req)
+	require.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestApiStreamsDebug(t *testing.T) {
+	stream := NewStream("rtsp://localhost/debug")
+
+	streamsMu.Lock()
+	streams["debug1"] = stream
+	streamsMu.Unlock()
+
+	req := httptest.NewRequest("GET", "/api/streams/debug?src=debug1", nil)
+	w := httptest.NewRecorder()
+	apiStreamsDebug(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Contains(t, w.Body.String(), `"debug1":{"locked":false,"pending":0,"producers":[{"url":"rtsp://localhost/debug","state":"none","locked":false}]`)
+
+	// stuck producer doesn't block the API
+	stream.producers[0].mu.Lock()
+	w = httptest.NewRecorder()
+	apiStreamsDebug(w, req)
+	stream.producers[0].mu.Unlock()
+	require.Contains(t, w.Body.String(), `"producers":[{"url":"rtsp://localhost/debug","locked":true}]`)
+
+	stream.mu.Lock()
+	w = httptest.NewRecorder()
+	apiStreamsDebug(w, req)
+	stream.mu.Unlock()
+	require.Contains(t, w.Body.String(), `"debug1":{"locked":true,"pending":0,"consumers":0}`)
+}
+
+func TestApiStreamsBatch(t *testing.T) {
+	streamsMu.Lock()
+	streams["batch1"] = NewStream("rtsp://localhost/batch1")
+	streams["batch2"] = NewStream("rtsp://localhost/batch2")
+	streamsMu.Unlock()
+
+	req := httptest.NewRequest("POST", "/api/streams/batch?action=stop&src=batch1&src=batch2", nil)
+	w := httptest.NewRecorder()
+	apiStreamsBatch(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+	require.JSONEq(t, `{"batch1":{"ok":true},"batch2":{"ok":true}}`, w.Body.String())
+
+	require.ErrorIs(t, streams["batch1"].AddConsumer(newTestConsumer()), errStreamStopped)
+
+	// partial failure
+	req = httptest.NewRequest("POST", "/api/streams/batch?action=start&src=batch2", nil)
+	apiStreamsBatch(httptest.NewRecorder(), req)
+
+	req = httptest.NewRequest("POST", "/api/streams/batch?action=reconnect&src=batch1&src=batch2", nil)
+	w = httptest.NewRecorder()
+	apiStreamsBatch(w, req)
+	require.Equal(t, http.StatusMultiStatus, w.Code)
+	require.JSONEq(t, `{"batch1":{"ok":false,"error":"streams: stream stopped"},"batch2":{"ok":true}}`, w.Body.String())
+
+	// unknown stream fails the whole request
+	req = httptest.NewRequest("POST", "/api/streams/batch?action=start&src=batch1&src=unknown", nil)
+	w = httptest.NewRecorder()
+	apiStreamsBatch(w, req)
+	require.Equal(t, http.StatusNotFound, w.Code)
+	require.True(t, streams["batch1"].stopped.Load())
+
+	req = httptest.NewRequest("POST", "/api/streams/batch?action=pause&src=batch1", nil)
+	w = httptest.NewRecorder()
+	apiStreamsBatch(w, req)
+	require.Equal(t, http.StatusBadRequest, w.Code)
+}
@@ -0,0 +1,107 @@
+package streams
+
+import (
+	"errors"
+	"net/http"
+	"sync"
+
+	"github.com/AlexxIT/go2rtc/internal/api"
+)
+
+var errStreamStopped = errors.New("streams: stream stopped")
+
+// Stop - stop all consumers and producers, new consumers are rejected until Start,
+// ex. for the maintenance window
+func (s *Stream) Stop() {
+	s.stopped.Store(true)
+	s.stopAll()
+}
+
+// Start - accept consumers again after Stop and restore the stream preload
+func (s *Stream) Start() error {
+	if !s.stopped.Swap(false) {
+		return nil
+	}
+
+	preloadsMu.Lock()
+	p := preloads[s.name]
+	preloadsMu.Unlock()
+
+	if p != nil {
+		return AddPreload(s.name, p.Query)
+	}
+	return nil
+}
+
+type batchResult struct {
+	OK    bool   `json:"ok"`
+	Error string `json:"error,omitempty"`
+}
+
+// batchMu - batch operations don't interleave with each other
+var batchMu sync.Mutex
+
+// apiStreamsBatch - start, stop or reconnect streams by names (src) and/or by tags (tag)
+// with the result for each stream. Unknown names fail the whole request before any action.
+func apiStreamsBatch(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "", http.StatusMethodNotAllowed)
+		return
+	}
+
+	query := r.URL.Query()
+
+	var action func(stream *Stream) error
+	switch query.Get("action") {
+	case "start":
+		action = (*Stream).Start
+	case "stop":
+		action = func(stream *Stream) error {
+			stream.Stop()
+			return nil
+		}
+	case "reconnect":
+		action = func(stream *Stream) error {
+			if stream.stopped.Load() {
+				return errStreamStopped
+			}
+			stream.Reconnect()
+			return nil
+		}
+	default:
+		http.Error(w, "wrong action", http.StatusBadRequest)
+		return
+	}
+
+	for _, name := range query["src"] {
+		if Get(name) == nil {
+			http.Error(w, "stream not found: "+name, http.StatusNotFound)
+			return
+		}
+	}
+
+	selected := selectStreams(r)
+	if len(selected) == 0 {
+		http.Error(w, "", http.StatusNotFound)
+		return
+	}
+
+	batchMu.Lock()
+	results := make(map[string]*batchResult, len(selected))
+	var failed bool
+	for name, stream := range selected {
+		if err := action(stream); err != nil {
+			results[name] = &batchResult{Error: err.Error()}
+			failed = true
+		} else {
+			results[name] = &batchResult{OK: true}
+		}
+	}
+	batchMu.Unlock()
+
+	if failed {
+		w.Header().Set("Content-Type", api.MimeJSON)
+		w.WriteHeader(http.StatusMultiStatus)
+	}
+	api.ResponseJSON(w, results)
+}
@@ -0,0 +1,150 @@
+package streams
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/AlexxIT/go2rtc/internal/api"
+	"github.com/AlexxIT/go2rtc/pkg/core"
+	"github.com/AlexxIT/go2rtc/pkg/creds"
+)
+
+// BitrateInterval and BitrateSamples - resolution and length of the incoming bitrate
+// history of each producer, default - last 5 minutes at 10 seconds, for UI sparklines
+// without the external TSDB
+var BitrateInterval = 10 * time.Second
+var BitrateSamples = 30
+
+// bitrateHistory - ring of the bitrate samples in bits per second, memory is bounded
+// by BitrateSamples
+type bitrateHistory struct {
+	items []int
+	w     int
+	conn  core.Producer // connection of the last sample
+	last  int           // bytes counter of the connection at the last sample
+}
+
+// add - sample of the bytes counter of the conn, nil if producer is stopped
+func (h *bitrateHistory) add(conn core.Producer, bytes int, interval time.Duration) {
+	if conn != h.conn {
+		h.conn = conn
+		h.last = 0 // counter of the new connection after reconnect
+	}
+
+	delta := bytes - h.last
+	if delta < 0 {
+		delta = bytes
+	}
+	h.last = bytes
+
+	bps := int(float64(delta*8) / interval.Seconds())
+
+	if len(h.items) < BitrateSamples {
+		h.items = append(h.items, bps)
+	} else if len(h.items) > 0 {
+		h.items[h.w] = bps
+		if h.w++; h.w == len(h.items) {
+			h.w = 0
+		}
+	}
+}
+
+// values - samples from old to new
+func (h *bitrateHistory) values() []int {
+	items := make([]int, 0, len(h.items))
+	for i := range h.items {
+		items = append(items, h.items[(h.w+i)%len(h.items)])
+	}
+	return items
+}
+
+// sampleBitrate - add the sample to each producer, stopped producers get zero
+func (s *Stream) sampleBitrate(interval time.Duration) {
+	s.mu.Lock()
+	producers := make([]*Producer, len(s.producers))
+	copy(producers, s.producers)
+	s.mu.Unlock()
+
+	for _, prod := range producers {
+		prod.mu.Lock()
+		conn := prod.conn
+		prod.mu.Unlock()
+
+		var bytes int
+		if conn != nil {
+			if c, err := marshalConn(conn); err == nil {
+				bytes = c.recvBytes()
+			}
+		}
+
+		prod.mu.Lock()
+		prod.bitrate.add(conn, bytes, interval)
+		prod.mu.Unlock()
+	}
+}
+
+func bitrateWorker() {
+	for range time.Tick(BitrateInterval) {
+		for _, stream := range namedStreams() {
+			stream.sampleBitrate(BitrateInterval)
+		}
+	}
+}
+
+// BitrateStats - response of the bitrate history API
+type BitrateStats struct {
+	Interval  float64            `json:"interval"` // in seconds
+	Bitrate   []int              `json:"bitrate"`  // sum of all producers in bits per second, from old to new
+	Producers []*ProducerBitrate `json:"producers"`
+}
+
+type ProducerBitrate struct {
+	URL     string `json:"url"`
+	Bitrate []int  `json:"bitrate"`
+}
+
+// BitrateStats - incoming bitrate of the stream, producers added later have
+// shorter history, so the sum is aligned by the last sample
+func (s *Stream) BitrateStats() *BitrateStats {
+	s.mu.Lock()
+	producers := make([]*Producer, len(s.producers))
+	copy(producers, s.producers)
+	s.mu.Unlock()
+
+	stats := &BitrateStats{
+		Interval:  BitrateInterval.Seconds(),
+		Bitrate:   []int{},
+		Producers: make([]*ProducerBitrate, 0, len(producers)),
+	}
+
+	for _, prod := range producers {
+		prod.mu.Lock()
+		values := prod.bitrate.values()
+		prod.mu.Unlock()
+
+		stats.Producers = append(stats.Producers, &ProducerBitrate{URL: prod.source(), Bitrate: values})
+
+		if n := len(values) - len(stats.Bitrate); n > 0 {
+			stats.Bitrate = append(make([]int, n), stats.Bitrate...)
+		}
+		offset := len(stats.Bitrate) - len(values)
+		for i, value := range values {
+			stats.Bitrate[offset+i] += value
+		}
+	}
+
+	return stats
+}
+
+// apiBitrate - incoming bitrate history of the stream
+func apiBitrate(w http.ResponseWriter, r *http.Request) {
+	w = creds.SecretResponse(w)
+
+	stream := Get(r.URL.Query().Get("src"))
+	if stream == nil {
+		http.Error(w, api.StreamNotFound, http.StatusNotFound)
+		return
+	}
+
+	api.ResponseJSON(w, stream.BitrateStats())
+}
@@ -0,0 +1,55 @@
+package streams
+
+import (
+	"testing"
+	"time"
+
+	"github.com/AlexxIT/go2rtc/pkg/core"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBitrateHistory(t *testing.T) {
+	BitrateSamples = 3
+	defer func() { BitrateSamples = 30 }()
+
+	conn1, conn2 := &testProducer{}, &testProducer{}
+
+	var h bitrateHistory
+	h.add(conn1, 1000, time.Second)
+	h.add(conn1, 3000, time.Second)
+	require.Equal(t, []int{8000, 16000}, h.values())
+
+	// new connection after reconnect, its counter can be bigger than the old one,
+	// ring keeps only the last samples
+	h.add(conn2, 5000, time.Second)
+	h.add(conn2, 6000, time.Second)
+	require.Equal(t, []int{16000, 40000, 8000}, h.values())
+
+	// stopped producer
+	h.add(nil, 0, time.Second)
+	require.Equal(t, []int{40000, 8000, 0}, h.values())
+}
+
+func TestBitrateStats(t *testing.T) {
+	recv := core.NewReceiver(nil, &core.Codec{Name: core.CodecH264})
+	conn := &testProducer{Connection: core.Connection{Receivers: []*core.Receiver{recv}}}
+
+	stream := NewStream([]any{"rtsp://camera1", "rtsp://camera2"})
+	stream.producers[0].conn = conn
+
+	recv.Bytes = 10_000
+	stream.sampleBitrate(10 * time.Second)
+
+	// second producer started later
+	stream.producers[1].conn = conn
+	recv.Bytes = 20_000
+	stream.sampleBitrate(10 * time.Second)
+
+	stats := stream.BitrateStats()
+	require.Equal(t, 10.0, stats.Interval)
+	require.Len(t, stats.Producers, 2)
+	require.Equal(t, "rtsp://camera1", stats.Producers[0].URL)
+	require.Equal(t, []int{8000, 8000}, stats.Producers[0].Bitrate)
+	require.Equal(t, []int{0, 16000}, stats.Producers[1].Bitrate)
+	require.Equal(t, []int{8000, 24000}, stats.Bitrate)
+}
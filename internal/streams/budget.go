@@ -0,0 +1,151 @@
+package streams
+
+import (
+	"slices"
+	"time"
+
+	"github.com/AlexxIT/go2rtc/pkg/core"
+)
+
+// BufferMemory - budget in bytes for consumers queues, consumers prebuffers and held
+// frames of all streams, zero - unlimited, other memory (ex. sockets buffers) isn't
+// counted. Near the budget queues are shrunk, streams with less consumers first.
+// Queues grow back when the memory is released.
+var BufferMemory int
+
+var BudgetInterval = time.Second
+
+// minQueue - queue depth is never shrunk below it
+const minQueue = 16
+
+type streamBuffers struct {
+	name      string
+	senders   []*core.Sender
+	consumers int
+	bytes     int
+}
+
+// budget - original queue depth of the shrunk senders, used only by the worker
+type budget struct {
+	shrunk map[*core.Sender]int
+}
+
+func budgetWorker() {
+	b := &budget{shrunk: map[*core.Sender]int{}}
+	for range time.Tick(BudgetInterval) {
+		b.check(collectBuffers())
+	}
+}
+
+// collectBuffers - buffers of each stream, aliases are skipped
+func collectBuffers() []*streamBuffers {
+	streamsMu.Lock()
+	var list []*Stream
+	for name, stream := range streams {
+		if stream.name == name {
+			list = append(list, stream)
+		}
+	}
+	streamsMu.Unlock()
+
+	items := make([]*streamBuffers, 0, len(list))
+	for _, stream := range list {
+		items = append(items, stream.buffers())
+	}
+	return items
+}
+
+func (s *Stream) buffers() *streamBuffers {
+	item := &streamBuffers{name: s.name}
+
+	s.mu.Lock()
+	consumers := slices.Clone(s.consumers)
+	for _, prod := range s.producers {
+		item.bytes += prod.hold.bytes()
+	}
+	s.mu.Unlock()
+
+	item.consumers = len(consumers)
+
+	// one prebuffer is shared by all senders of the consumer
+	var prebuffers []*core.Prebuffer
+
+	for _, cons := range consumers {
+		if c, ok := cons.(interface{ GetSenders() []*core.Sender }); ok {
+			for _, sender := range c.GetSenders() {
+				item.senders = append(item.senders, sender)
+				item.bytes += sender.QueueBytes()
+
+				if p := sender.Prebuffer(); p != nil && !slices.Contains(prebuffers, p) {
+					prebuffers = append(prebuffers, p)
+					item.bytes += p.Bytes()
+				}
+			}
+		}
+	}
+
+	return item
+}
+
+func (b *budget) check(items []*streamBuffers) {
+	var total int
+	for _, item := range items {
+		total += item.bytes
+	}
+
+	// forget closed senders
+	for sender := range b.shrunk {
+		if _, size := sender.Queue(); size == 0 {
+			delete(b.shrunk, sender)
+		}
+	}
+
+	if high := BufferMemory * 9 / 10; total > high {
+		// streams with less consumers first, then the bigger ones
+		slices.SortFunc(items, func(x, y *streamBuffers) int {
+			if x.consumers != y.consumers {
+				return x.consumers - y.consumers
+			}
+			return y.bytes - x.bytes
+		})
+
+		over := total - high
+		for _, item := range items {
+			if over <= 0 {
+				break
+			}
+			if item.bytes == 0 {
+				continue
+			}
+			if b.shrink(item.senders) {
+				log.Debug().Msgf("[streams] buffers memory %d over budget, shrink queues of stream=%s", total, item.name)
+				over -= item.bytes / 2
+			}
+		}
+	} else if total < BufferMemory/2 {
+		for sender, size := range b.shrunk {
+			_, current := sender.Queue()
+			current = min(current*2, size)
+			sender.SetBuffer(current)
+			if current == size {
+				delete(b.shrunk, sender)
+			}
+		}
+	}
+}
+
+// shrink - halve queues depth, returns false if all queues are already minimal
+func (b *budget) shrink(senders []*core.Sender) (ok bool) {
+	for _, sender := range senders {
+		_, size := sender.Queue()
+		if size <= minQueue {
+			continue
+		}
+		if _, exists := b.shrunk[sender]; !exists {
+			b.shrunk[sender] = size
+		}
+		sender.SetBuffer(max(size/2, minQueue))
+		ok = true
+	}
+	return
+}
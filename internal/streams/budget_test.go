@@ -0,0 +1,61 @@
+package streams
+
+import (
+	"testing"
+	"time"
+
+	"github.com/AlexxIT/go2rtc/pkg/core"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBufferBudget(t *testing.T) {
+	BufferMemory = 1000
+	defer func() { BufferMemory = 0 }()
+
+	codec := &core.Codec{Name: core.CodecH264, ClockRate: 90000, PayloadType: 96}
+	newItem := func(name string, consumers, bytes int) *streamBuffers {
+		sender := core.NewSender(nil, codec)
+		sender.Input(&core.Packet{Payload: make([]byte, bytes)}) // not started, so stays in the queue
+		return &streamBuffers{name: name, senders: []*core.Sender{sender}, consumers: consumers, bytes: bytes}
+	}
+
+	watched := newItem("watched", 3, 500)
+	idle := newItem("idle", 1, 500)
+
+	b := &budget{shrunk: map[*core.Sender]int{}}
+	b.check([]*streamBuffers{watched, idle})
+
+	// only the stream with less consumers is shrunk
+	_, size := watched.senders[0].Queue()
+	require.Equal(t, 4096, size)
+	_, size = idle.senders[0].Queue()
+	require.Equal(t, 2048, size)
+	require.Equal(t, 500, idle.senders[0].QueueBytes())
+
+	// queues grow back when the memory is released
+	watched.bytes, idle.bytes = 0, 0
+	b.check([]*streamBuffers{watched, idle})
+	_, size = idle.senders[0].Queue()
+	require.Equal(t, 4096, size)
+	require.Empty(t, b.shrunk)
+}
+
+func TestBufferBudgetPrebuffer(t *testing.T) {
+	codec := &core.Codec{Name: core.CodecH264, ClockRate: 90000, PayloadType: 96}
+
+	cons := newTestConsumer()
+	prebuffer := &core.Prebuffer{Duration: time.Hour}
+	for i := 0; i < 2; i++ {
+		sender := core.NewSender(nil, codec)
+		sender.SetPrebuffer(prebuffer)
+		sender.Input(&core.Packet{Payload: make([]byte, 100)})
+		cons.Senders = append(cons.Senders, sender)
+	}
+
+	stream := &Stream{consumers: []core.Consumer{cons}}
+
+	// shared prebuffer is counted once, packets are not in the queues yet
+	item := stream.buffers()
+	require.Equal(t, 200, item.bytes)
+	require.Len(t, item.senders, 2)
+}
@@ -0,0 +1,9 @@
+//go:build !unix && !windows
+
+package streams
+
+import "time"
+
+func processCPU() (time.Duration, bool) {
+	return 0, false
+}
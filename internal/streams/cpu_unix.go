@@ -0,0 +1,17 @@
+//go:build unix
+
+package streams
+
+import (
+	"syscall"
+	"time"
+)
+
+// processCPU - user and system CPU time of the go2rtc process
+func processCPU() (time.Duration, bool) {
+	var usage syscall.Rusage
+	if err := syscall.Getrusage(syscall.RUSAGE_SELF, &usage); err != nil {
+		return 0, false
+	}
+	return time.Duration(usage.Utime.Nano() + usage.Stime.Nano()), true
+}
@@ -0,0 +1,25 @@
+//go:build windows
+
+package streams
+
+import (
+	"syscall"
+	"time"
+)
+
+func processCPU() (time.Duration, bool) {
+	handle, err := syscall.GetCurrentProcess()
+	if err != nil {
+		return 0, false
+	}
+	var creation, exit, kernel, user syscall.Filetime
+	if err = syscall.GetProcessTimes(handle, &creation, &exit, &kernel, &user); err != nil {
+		return 0, false
+	}
+	return time.Duration(filetime(kernel)+filetime(user)) * 100, true
+}
+
+// filetime - duration in 100-nanosecond intervals
+func filetime(ft syscall.Filetime) int64 {
+	return int64(ft.HighDateTime)<<32 | int64(ft.LowDateTime)
+}
@@ -0,0 +1,89 @@
+package streams
+
+import (
+	"errors"
+	"runtime"
+	"time"
+)
+
+// DeadmanTimeout - force recreate the running producer when its tracks get no packets
+// this time, the last resort for the wedged read loop that the source watchdogs and
+// the network deadlines did not catch. Zero means disabled.
+var DeadmanTimeout time.Duration
+
+var errDeadman = errors.New("streams: producer stalled, dead man's switch")
+
+// maxStacks - goroutines dump limit for the intervention log
+const maxStacks = 1 << 20
+
+// beat - heartbeat of the read loop, called for each packet from the guard
+func (p *Producer) beat() {
+	p.heartbeat.Store(time.Now().UnixNano())
+}
+
+// stalled - duration without the heartbeat, zero if the producer is not running
+func (p *Producer) stalled(now time.Time) time.Duration {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if !p.reading || len(p.receivers) == 0 {
+		return 0
+	}
+
+	last := p.connectedAt
+	if beat := time.Unix(0, p.heartbeat.Load()); beat.After(last) {
+		last = beat
+	}
+	return now.Sub(last)
+}
+
+// deadman - orphan the wedged worker and reconnect, the old connection is stopped
+// in the background, because its Stop can hang together with the read loop
+func (p *Producer) deadman(stale time.Duration) {
+	p.mu.Lock()
+	if !p.reading {
+		p.mu.Unlock()
+		return
+	}
+	conn := p.conn
+	p.wedged = conn
+	p.reading = false
+	p.workerID++ // the wedged worker will not reconnect, if it returns
+	workerID := p.workerID
+	p.mu.Unlock()
+
+	stacks := make([]byte, maxStacks)
+	stacks = stacks[:runtime.Stack(stacks, true)]
+
+	name := p.streamName()
+
+	log.Error().Str("stream", name).Str("url", p.source()).Dur("stale", stale).Bytes("goroutines", stacks).
+		Msg("[streams] dead man's switch: recreate stalled producer, please report this bug")
+	p.publish(EventError, errDeadman)
+
+	go func() { _ = conn.Stop() }()
+
+	p.reconnect(workerID, 0)
+}
+
+func deadmanWorker() {
+	for range time.Tick(max(DeadmanTimeout/4, time.Second)) {
+		streamsMu.Lock()
+		var producers []*Producer
+		for name, stream := range streams {
+			if stream.name == name {
+				stream.mu.Lock()
+				producers = append(producers, stream.producers...)
+				stream.mu.Unlock()
+			}
+		}
+		streamsMu.Unlock()
+
+		now := time.Now()
+		for _, prod := range producers {
+			if stale := prod.stalled(now); stale > DeadmanTimeout {
+				go prod.deadman(stale)
+			}
+		}
+	}
+}
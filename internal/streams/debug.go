@@ -0,0 +1,132 @@
+package streams
+
+import (
+	"net/http"
+	"runtime"
+	"time"
+
+	"github.com/AlexxIT/go2rtc/internal/api"
+)
+
+// debugger - connection with the lock free snapshot of internals, ex. RTSP
+type debugger interface {
+	Debug() any
+}
+
+type streamDebug struct {
+	Locked    bool            `json:"locked"`  // stream mutex is held, producers and consumers are unknown
+	Pending   int32           `json:"pending"` // consumers inside AddConsumer
+	KeepUntil time.Time       `json:"keep_until,omitzero"`
+	Producers []producerDebug `json:"producers,omitempty"`
+	Consumers int             `json:"consumers"`
+	Conns     []any           `json:"consumers_conns,omitempty"` // snapshots of consumers with debug support
+}
+
+type producerDebug struct {
+	URL         string    `json:"url"`
+	State       string    `json:"state,omitempty"` // empty if producer mutex is held
+	Locked      bool      `json:"locked"`
+	ConnectedAt time.Time `json:"connected_at,omitzero"`
+	Conn        any       `json:"conn,omitempty"`
+}
+
+func (s state) String() string {
+	switch s {
+	case stateNone:
+		return "none"
+	case stateMedias:
+		return "medias"
+	case stateTracks:
+		return "tracks"
+	case stateStart:
+		return "start"
+	case stateExternal:
+		return "external"
+	case stateInternal:
+		return "internal"
+	}
+	return "unknown"
+}
+
+// debug - never waits for the locks, so it works for the stuck stream
+func (s *Stream) debug() *streamDebug {
+	d := &streamDebug{Pending: s.pending.Load()}
+
+	if !s.mu.TryLock() {
+		d.Locked = true
+		return d
+	}
+	defer s.mu.Unlock()
+
+	d.KeepUntil = s.keepUntil
+
+	for _, producer := range s.producers {
+		d.Producers = append(d.Producers, producer.debug())
+	}
+	d.Consumers = len(s.consumers)
+	for _, consumer := range s.consumers {
+		if cons, ok := consumer.(debugger); ok {
+			d.Conns = append(d.Conns, cons.Debug())
+		}
+	}
+
+	return d
+}
+
+func (p *Producer) debug() producerDebug {
+	d := producerDebug{URL: p.source()}
+
+	if !p.mu.TryLock() {
+		d.Locked = true
+		return d
+	}
+	defer p.mu.Unlock()
+
+	d.State = p.state.String()
+	d.ConnectedAt = p.connectedAt
+	if conn, ok := p.conn.(debugger); ok {
+		d.Conn = conn.Debug()
+	}
+
+	return d
+}
+
+// apiStreamsDebug - state and locks of streams for deadlock debugging without pprof, by names (src), default - all
+func apiStreamsDebug(w http.ResponseWriter, r *http.Request) {
+	response := struct {
+		Goroutines int                     `json:"goroutines"` // total for the process
+		Locked     bool                    `json:"locked"`     // streams list mutex is held
+		Streams    map[string]*streamDebug `json:"streams,omitempty"`
+	}{
+		Goroutines: runtime.NumGoroutine(),
+	}
+
+	if !streamsMu.TryLock() {
+		response.Locked = true
+		api.ResponseJSON(w, response)
+		return
+	}
+
+	selected := map[string]*Stream{}
+	if names := r.URL.Query()["src"]; names != nil {
+		for _, name := range names {
+			if stream := streams[name]; stream != nil {
+				selected[name] = stream
+			}
+		}
+	} else {
+		for name, stream := range streams {
+			if stream.name == name {
+				selected[name] = stream
+			}
+		}
+	}
+	streamsMu.Unlock()
+
+	response.Streams = map[string]*streamDebug{}
+	for name, stream := range selected {
+		response.Streams[name] = stream.debug()
+	}
+
+	api.ResponseJSON(w, response)
+}
@@ -0,0 +1,116 @@
+package streams
+
+import (
+	"net/url"
+	"slices"
+	"strings"
+)
+
+// dependency - name of the stream used by the source, ex. "camera1" for
+// "ffmpeg:camera1#video=h264" or "rtsp://127.0.0.1:8554/camera1",
+// empty if source doesn't reference another stream
+func dependency(source string) string {
+	if i := strings.IndexByte(source, '#'); i >= 0 {
+		source = source[:i]
+	}
+
+	var name string
+	if strings.Contains(source, "://") {
+		// loopback to go2rtc RTSP server
+		u, err := url.Parse(source)
+		if err != nil || u.Scheme != "rtsp" || (u.Hostname() != "127.0.0.1" && u.Hostname() != "localhost") {
+			return ""
+		}
+		name = strings.TrimPrefix(u.Path, "/")
+	} else if i := strings.IndexByte(source, ':'); i > 0 {
+		name = source[i+1:]
+	}
+
+	if name == "" {
+		return ""
+	}
+
+	streamsMu.Lock()
+	_, ok := streams[name]
+	streamsMu.Unlock()
+
+	if !ok {
+		return ""
+	}
+	return name
+}
+
+// Depends - names of the streams used by sources of this stream
+func (s *Stream) Depends() []string {
+	var names []string
+
+	for _, source := range s.Sources() {
+		if name := dependency(source); name != "" && name != s.name && !slices.Contains(names, name) {
+			names = append(names, name)
+		}
+	}
+
+	return names
+}
+
+// restartDependents - base stream producer reconnected, deliver the news to
+// derived streams, so their producers restart with the new source connection
+// or stop waiting long reconnect timeout
+func restartDependents(base *Producer) {
+	// skip aliases, stream lock can't be taken under streams lock
+	var all []*Stream
+	streamsMu.Lock()
+	for name, stream := range streams {
+		if stream.name == name {
+			all = append(all, stream)
+		}
+	}
+	streamsMu.Unlock()
+
+	var baseStream *Stream
+	for _, stream := range all {
+		if stream.hasProducer(base) {
+			baseStream = stream
+			break
+		}
+	}
+
+	if baseStream == nil {
+		return
+	}
+
+	for _, stream := range all {
+		// protect from endless restarts of streams that depend on each other
+		if stream == baseStream || slices.Contains(baseStream.Depends(), stream.name) {
+			continue
+		}
+
+		stream.mu.Lock()
+		producers := slices.Clone(stream.producers)
+		stream.mu.Unlock()
+
+		baseName := baseStream.name
+		for _, prod := range producers {
+			if dependency(prod.source()) != baseName {
+				continue
+			}
+
+			log.Debug().Msgf("[streams] restart derived stream=%s source=%s", stream.name, baseName)
+
+			prod.restart()
+			prod.retryNow()
+		}
+	}
+}
+
+func (s *Stream) hasProducer(prod *Producer) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, producer := range s.producers {
+		if producer == prod {
+			return true
+		}
+	}
+	return false
+}
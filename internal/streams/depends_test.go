@@ -0,0 +1,42 @@
+package streams
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDepends(t *testing.T) {
+	streamsMu.Lock()
+	for name, item := range map[string]any{
+		"dep_base":    "rtsp://192.168.1.123/stream1",
+		"dep_ffmpeg":  []any{"ffmpeg:dep_base#video=h264", "ffmpeg:unknown#video=h264"},
+		"dep_rtsp":    "rtsp://127.0.0.1:8554/dep_base?video",
+		"dep_another": "rtsp://192.168.1.123/dep_base",
+	} {
+		streams[name] = NewStream(item)
+		streams[name].name = name
+	}
+	streamsMu.Unlock()
+
+	require.Nil(t, streams["dep_base"].Depends())
+	require.Equal(t, []string{"dep_base"}, streams["dep_ffmpeg"].Depends())
+	require.Equal(t, []string{"dep_base"}, streams["dep_rtsp"].Depends())
+	require.Nil(t, streams["dep_another"].Depends())
+
+	// derived producer waits long reconnect timeout
+	fired := make(chan struct{})
+	derived := streams["dep_ffmpeg"].producers[0]
+	derived.retry = time.AfterFunc(time.Hour, func() {
+		close(fired)
+	})
+
+	restartDependents(streams["dep_base"].producers[0])
+
+	select {
+	case <-fired:
+	case <-time.After(time.Second):
+		require.FailNow(t, "derived stream not restarted")
+	}
+}
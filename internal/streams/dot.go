@@ -56,13 +56,16 @@ func humanBytes(i int) string {
 }
 
 type node struct {
-	ID     uint32         `json:"id"`
-	Codec  map[string]any `json:"codec"`
-	Parent uint32         `json:"parent"`
-	Childs []uint32       `json:"childs"`
-	Bytes  int            `json:"bytes"`
-	//Packets uint32         `json:"packets"`
-	//Drops   uint32         `json:"drops"`
+	ID         uint32         `json:"id"`
+	Codec      map[string]any `json:"codec"`
+	Parent     uint32         `json:"parent"`
+	Childs     []uint32       `json:"childs"`
+	Bytes      int            `json:"bytes"`
+	Packets    int            `json:"packets"`
+	Drops      int            `json:"drops"`
+	Queue      int            `json:"queue"`
+	Buffer     int            `json:"buffer"`
+	QueueBytes int            `json:"queue_bytes"`
 }
 
 var codecKeys = []string{"codec_name", "sample_rate", "channels", "profile", "level"}
@@ -96,17 +99,18 @@ func (n *node) appendDOT(dot []byte, group string) []byte {
 }
 
 type conn struct {
-	ID         uint32 `json:"id"`
-	FormatName string `json:"format_name"`
-	Protocol   string `json:"protocol"`
-	RemoteAddr string `json:"remote_addr"`
-	Source     string `json:"source"`
-	URL        string `json:"url"`
-	UserAgent  string `json:"user_agent"`
-	Receivers  []node `json:"receivers"`
-	Senders    []node `json:"senders"`
-	BytesRecv  int    `json:"bytes_recv"`
-	BytesSend  int    `json:"bytes_send"`
+	ID         uint32   `json:"id"`
+	FormatName string   `json:"format_name"`
+	Protocol   string   `json:"protocol"`
+	RemoteAddr string   `json:"remote_addr"`
+	Source     string   `json:"source"`
+	URL        string   `json:"url"`
+	UserAgent  string   `json:"user_agent"`
+	Kinds      []string `json:"kinds"`
+	Receivers  []node   `json:"receivers"`
+	Senders    []node   `json:"senders"`
+	BytesRecv  int      `json:"bytes_recv"`
+	BytesSend  int      `json:"bytes_send"`
 }
 
 func (c *conn) appendDOT(dot []byte, group string) []byte {
@@ -0,0 +1,40 @@
+package streams
+
+import (
+	"errors"
+	"slices"
+
+	"github.com/AlexxIT/go2rtc/pkg/dtmf"
+)
+
+// DTMFWriter - producer with DTMF over the backchannel, ex. RTSP with telephone-event
+type DTMFWriter interface {
+	WriteDTMF(digits string) error
+}
+
+var errNoDTMF = errors.New("streams: no backchannel with DTMF")
+
+// WriteDTMF - send digits over the first working backchannel with DTMF support,
+// so the talkback consumer should be connected to the stream
+func (s *Stream) WriteDTMF(digits string) error {
+	s.mu.Lock()
+	producers := slices.Clone(s.producers)
+	s.mu.Unlock()
+
+	err := errNoDTMF
+	for _, prod := range producers {
+		prod.mu.Lock()
+		w, ok := prod.conn.(DTMFWriter)
+		talkback := len(prod.senders) > 0
+		prod.mu.Unlock()
+
+		if !ok || !talkback {
+			continue
+		}
+
+		if err = w.WriteDTMF(digits); err == nil || errors.Is(err, dtmf.ErrDigit) {
+			return err
+		}
+	}
+	return err
+}
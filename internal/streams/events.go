@@ -0,0 +1,247 @@
+package streams
+
+import (
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/AlexxIT/go2rtc/pkg/core"
+)
+
+// Event - typed in-process event of streams and their producers, for plugins and
+// integrations, ex. automation on reconnect or the keyframe based thumbnails
+type Event struct {
+	Type   string    `json:"type"`
+	Stream string    `json:"stream,omitempty"`
+	URL    string    `json:"url,omitempty"` // producer source, empty for stream events
+	Time   time.Time `json:"time"`
+	Data   any       `json:"data,omitempty"`
+}
+
+const (
+	EventStart     = "start"     // Data - reason, ex. ReasonConsumer
+	EventStop      = "stop"      // Data - reason, ex. ReasonIdle
+	EventError     = "error"     // Data - producer error, reconnect will follow
+	EventReconnect = "reconnect" // Data - retry number, producer is live again
+	EventKeyframe  = "keyframe"  // Data - *core.Codec of the video track
+	EventCodec     = "codec"     // Data - *CodecChange, codec params changed after reconnect
+	EventStats     = "stats"     // Data - *StreamStats, each EventStatsInterval
+)
+
+type CodecChange struct {
+	Old *core.Codec `json:"old"`
+	New *core.Codec `json:"new"`
+}
+
+// EventFilter - empty list matches all types or streams
+type EventFilter struct {
+	Types   []string
+	Streams []string
+}
+
+func (f *EventFilter) match(event *Event) bool {
+	return (f.Types == nil || slices.Contains(f.Types, event.Type)) &&
+		(f.Streams == nil || slices.Contains(f.Streams, event.Stream))
+}
+
+// EventQueue - queue size of each subscriber, events are dropped if the subscriber is too slow
+var EventQueue = 256
+
+var EventStatsInterval = 10 * time.Second
+
+type Subscription struct {
+	filter EventFilter
+	queue  chan *Event
+	done   chan struct{}
+	drops  atomic.Int64
+}
+
+var (
+	subscriptions   atomic.Pointer[[]*Subscription] // copy on write, so publish never locks
+	subscriptionsMu sync.Mutex
+)
+
+// Subscribe - call f for each matched event from own goroutine, so the slow
+// subscriber never blocks the media path
+func Subscribe(filter EventFilter, f func(event *Event)) *Subscription {
+	s := &Subscription{
+		filter: filter,
+		queue:  make(chan *Event, EventQueue),
+		done:   make(chan struct{}),
+	}
+
+	go func() {
+		for {
+			select {
+			case event := <-s.queue:
+				f(event)
+			case <-s.done:
+				return
+			}
+		}
+	}()
+
+	subscriptionsMu.Lock()
+	var list []*Subscription
+	if old := subscriptions.Load(); old != nil {
+		list = slices.Clone(*old)
+	}
+	list = append(list, s)
+	subscriptions.Store(&list)
+	subscriptionsMu.Unlock()
+
+	return s
+}
+
+// Close - stop the subscription, queued events are dropped
+func (s *Subscription) Close() {
+	subscriptionsMu.Lock()
+	if old := subscriptions.Load(); old != nil {
+		if i := slices.Index(*old, s); i >= 0 {
+			list := slices.Delete(slices.Clone(*old), i, i+1)
+			subscriptions.Store(&list)
+			close(s.done)
+		}
+	}
+	subscriptionsMu.Unlock()
+}
+
+// Drops - events dropped because of the full queue
+func (s *Subscription) Drops() int {
+	return int(s.drops.Load())
+}
+
+// subscribed - somebody waits events of this type, so the event should be prepared
+func subscribed(typ string) bool {
+	if list := subscriptions.Load(); list != nil {
+		for _, s := range *list {
+			if s.filter.Types == nil || slices.Contains(s.filter.Types, typ) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func publish(event *Event) {
+	list := subscriptions.Load()
+	if list == nil {
+		return
+	}
+
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+
+	for _, s := range *list {
+		if !s.filter.match(event) {
+			continue
+		}
+		select {
+		case s.queue <- event:
+		default:
+			s.drops.Add(1)
+		}
+	}
+}
+
+func (p *Producer) publish(typ string, data any) {
+	publish(&Event{Type: typ, Stream: p.streamName(), URL: p.source(), Data: data})
+}
+
+var (
+	sourceEvents     chan *Event
+	sourceEventsOnce sync.Once
+)
+
+// PublishEvent - event of the source connection from the source handler, ex. RTSP
+// video freeze. Source events are fired from the read loop, so the stream is
+// found by the producer URL in own goroutine, empty if the source is not found.
+func PublishEvent(typ, url string, data any) {
+	if !subscribed(typ) {
+		return
+	}
+
+	sourceEventsOnce.Do(func() {
+		sourceEvents = make(chan *Event, EventQueue)
+		go func() {
+			for event := range sourceEvents {
+				event.Stream = streamBySource(event.URL)
+				publish(event)
+			}
+		}()
+	})
+
+	select {
+	case sourceEvents <- &Event{Type: typ, URL: url, Time: time.Now(), Data: data}:
+	default:
+	}
+}
+
+// streamBySource - name of the stream with the producer of this URL
+func streamBySource(url string) string {
+	for _, stream := range namedStreams() {
+		stream.mu.Lock()
+		found := slices.ContainsFunc(stream.producers, func(prod *Producer) bool {
+			return prod.source() == url
+		})
+		stream.mu.Unlock()
+		if found {
+			return stream.name
+		}
+	}
+	return ""
+}
+
+// streamName - empty for the producer without the stream
+func (p *Producer) streamName() string {
+	if p.stream != nil {
+		return p.stream.name
+	}
+	return ""
+}
+
+// watch - publish keyframes of the video track, only while somebody subscribed
+func (p *Producer) watch(track *core.Receiver) {
+	if !track.Codec.IsVideo() {
+		return
+	}
+
+	var ts uint32
+	var started bool
+
+	input := track.Input
+	track.Input = func(packet *core.Packet) {
+		// one keyframe can be split to many packets with the same timestamp
+		if (!started || packet.Timestamp != ts) && subscribed(EventKeyframe) && isKeyframe(track.Codec, packet.Payload) {
+			ts, started = packet.Timestamp, true
+			p.publish(EventKeyframe, track.Codec)
+		}
+		input(packet)
+	}
+}
+
+func statsWorker() {
+	for range time.Tick(EventStatsInterval) {
+		if !subscribed(EventStats) {
+			continue
+		}
+
+		for _, stream := range namedStreams() {
+			publish(&Event{Type: EventStats, Stream: stream.name, Data: stream.Stats()})
+		}
+	}
+}
+
+// namedStreams - streams by their own names, without aliases
+func namedStreams() (list []*Stream) {
+	streamsMu.Lock()
+	for name, stream := range streams {
+		if stream.name == name {
+			list = append(list, stream)
+		}
+	}
+	streamsMu.Unlock()
+	return
+}
@@ -0,0 +1,107 @@
+package streams
+
+import (
+	"testing"
+	"time"
+
+	"github.com/AlexxIT/go2rtc/pkg/core"
+	"github.com/pion/rtp"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEvents(t *testing.T) {
+	events := make(chan *Event, 10)
+	sub := Subscribe(EventFilter{Types: []string{EventStart, EventKeyframe}, Streams: []string{"camera1"}}, func(event *Event) {
+		events <- event
+	})
+
+	require.True(t, subscribed(EventKeyframe))
+	require.False(t, subscribed(EventStats))
+
+	fireLifecycle(EventStart, "camera1", ReasonConsumer)
+	fireLifecycle(EventStart, "camera2", ReasonConsumer) // other stream
+	fireLifecycle(EventStop, "camera1", ReasonIdle)      // other type
+
+	event := <-events
+	require.Equal(t, EventStart, event.Type)
+	require.Equal(t, "camera1", event.Stream)
+	require.Equal(t, ReasonConsumer, event.Data)
+
+	// keyframes of the producer track, once for the frame
+	stream := NewStream("rtsp://camera1")
+	stream.name = "camera1"
+	prod := stream.producers[0]
+
+	codec := &core.Codec{Name: core.CodecH264, ClockRate: 90000, PayloadType: 96}
+	track := core.NewReceiver(nil, codec)
+	prod.watch(track)
+
+	idr := []byte{0x65, 0, 0}
+	track.Input(&rtp.Packet{Header: rtp.Header{Timestamp: 1}, Payload: []byte{0x41, 0, 0}})
+	track.Input(&rtp.Packet{Header: rtp.Header{Timestamp: 2}, Payload: idr})
+	track.Input(&rtp.Packet{Header: rtp.Header{Timestamp: 2}, Payload: idr})
+
+	event = <-events
+	require.Equal(t, EventKeyframe, event.Type)
+	require.Equal(t, "rtsp://camera1", event.URL)
+	require.Equal(t, codec, event.Data)
+
+	sub.Close()
+	require.False(t, subscribed(EventKeyframe))
+
+	select {
+	case event = <-events:
+		require.Fail(t, "unexpected event", event.Type)
+	case <-time.After(10 * time.Millisecond):
+	}
+}
+
+func TestEventsSlowSubscriber(t *testing.T) {
+	EventQueue = 2
+	defer func() { EventQueue = 256 }()
+
+	block := make(chan struct{})
+	sub := Subscribe(EventFilter{}, func(event *Event) {
+		<-block
+	})
+	defer sub.Close()
+
+	// publish never waits for the subscriber
+	for range 10 {
+		publish(&Event{Type: EventStats})
+	}
+	require.GreaterOrEqual(t, sub.Drops(), 7)
+	close(block)
+}
+
+func TestPublishEvent(t *testing.T) {
+	events := make(chan *Event, 10)
+	sub := Subscribe(EventFilter{Types: []string{"rtsp_freeze"}, Streams: []string{"camera1"}}, func(event *Event) {
+		events <- event
+	})
+	defer sub.Close()
+
+	stream := NewStream("rtsp://camera1")
+	stream.name = "camera1"
+	streamsMu.Lock()
+	streams["camera1"] = stream
+	streamsMu.Unlock()
+	defer func() {
+		streamsMu.Lock()
+		delete(streams, "camera1")
+		streamsMu.Unlock()
+	}()
+
+	PublishEvent("rtsp_ssrc", "rtsp://camera1", nil) // nobody subscribed
+	PublishEvent("rtsp_freeze", "rtsp://camera1", "RTSP video freeze")
+
+	select {
+	case event := <-events:
+		require.Equal(t, "rtsp_freeze", event.Type)
+		require.Equal(t, "camera1", event.Stream)
+		require.Equal(t, "rtsp://camera1", event.URL)
+		require.Equal(t, "RTSP video freeze", event.Data)
+	case <-time.After(time.Second):
+		require.FailNow(t, "event timeout")
+	}
+}
@@ -62,6 +62,7 @@ func GetProducer(url string) (core.Producer, error) {
 		}
 
 		if handler, ok := handlers[scheme]; ok {
+			hosts.wait(url)
 			return handler(url)
 		}
 	}
@@ -0,0 +1,233 @@
+package streams
+
+import (
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/AlexxIT/go2rtc/pkg/core"
+	"github.com/AlexxIT/go2rtc/pkg/h264"
+	"github.com/AlexxIT/go2rtc/pkg/h265"
+	"github.com/pion/rtp"
+)
+
+// HoldInterval - how often the last keyframe is repeated during reconnect
+var HoldInterval = time.Second
+
+// frameHold - repeat the last video keyframe to consumers while the producer
+// reconnects, so video walls show a frozen picture instead of a black screen
+type frameHold struct {
+	frames []*heldFrame
+	done   chan struct{}
+	mu     sync.Mutex
+}
+
+type heldFrame struct {
+	receiver *core.Receiver
+	input    core.HandlerFunc // receiver input without the tap
+
+	group []*rtp.Packet // packets of the current frame (same timestamp)
+	key   bool          // current frame is keyframe
+	last  []*rtp.Packet // packets of the last complete keyframe
+
+	// last live packet
+	seq uint16
+	ts  uint32
+	at  time.Time
+}
+
+// maxHoldPackets - memory protection for sources without timestamps change
+const maxHoldPackets = 2000
+
+// tap - remember keyframes of the video track, should be called before the data flow
+func (h *frameHold) tap(receiver *core.Receiver) {
+	if h == nil || !receiver.Codec.IsVideo() {
+		return
+	}
+
+	f := &heldFrame{}
+
+	h.mu.Lock()
+	h.frames = append(h.frames, f)
+	h.wrap(f, receiver)
+	h.mu.Unlock()
+}
+
+// move - new receiver after reconnect, the last keyframe stays the same
+func (h *frameHold) move(old, receiver *core.Receiver) {
+	if h == nil {
+		return
+	}
+
+	h.mu.Lock()
+	for _, f := range h.frames {
+		if f.receiver == old {
+			h.wrap(f, receiver)
+		}
+	}
+	h.mu.Unlock()
+}
+
+func (h *frameHold) wrap(f *heldFrame, receiver *core.Receiver) {
+	f.receiver = receiver
+	f.input = receiver.Input
+
+	input := receiver.Input
+	receiver.Input = func(packet *core.Packet) {
+		h.mu.Lock()
+		f.add(packet)
+		h.mu.Unlock()
+
+		input(packet)
+	}
+}
+
+func (f *heldFrame) add(packet *rtp.Packet) {
+	if packet.Timestamp != f.ts || len(f.group) >= maxHoldPackets {
+		if f.key {
+			f.last = f.group
+		}
+		f.group = nil
+		f.key = false
+	}
+
+	clone := *packet
+	clone.Payload = slices.Clone(packet.Payload)
+	f.group = append(f.group, &clone)
+
+	if !f.key && isKeyframe(f.receiver.Codec, packet.Payload) {
+		f.key = true
+	}
+
+	f.seq = packet.SequenceNumber
+	f.ts = packet.Timestamp
+	f.at = time.Now()
+}
+
+// start - repeat last keyframes until stop
+func (h *frameHold) start() {
+	if h == nil {
+		return
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.done != nil {
+		return
+	}
+
+	done := make(chan struct{})
+	h.done = done
+
+	go func() {
+		ticker := time.NewTicker(HoldInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				h.repeat()
+			}
+		}
+	}()
+}
+
+func (h *frameHold) stop() {
+	if h == nil {
+		return
+	}
+
+	h.mu.Lock()
+	if h.done != nil {
+		close(h.done)
+		h.done = nil
+	}
+	h.mu.Unlock()
+}
+
+// reset - stop and forget all tracks, producer is stopped
+func (h *frameHold) reset() {
+	if h == nil {
+		return
+	}
+
+	h.stop()
+
+	h.mu.Lock()
+	h.frames = nil
+	h.mu.Unlock()
+}
+
+func (h *frameHold) repeat() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for _, f := range h.frames {
+		if f.last == nil {
+			continue
+		}
+
+		// timestamps continue with the wall clock, so players don't drop the frame
+		f.ts += uint32(time.Since(f.at) * time.Duration(f.receiver.Codec.ClockRate) / time.Second)
+		f.at = time.Now()
+
+		for _, packet := range f.last {
+			f.seq++
+
+			clone := *packet
+			clone.SequenceNumber = f.seq
+			clone.Timestamp = f.ts
+			f.input(&clone)
+		}
+	}
+}
+
+// bytes - memory of the held keyframes and the current frames
+func (h *frameHold) bytes() (n int) {
+	if h == nil {
+		return 0
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for _, f := range h.frames {
+		for _, packet := range f.last {
+			n += len(packet.Payload)
+		}
+		for _, packet := range f.group {
+			n += len(packet.Payload)
+		}
+	}
+	return
+}
+
+func (h *frameHold) holding() bool {
+	if h == nil {
+		return false
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.done != nil
+}
+
+// isKeyframe - check if packet starts H264/H265 keyframe (IDR) in RTP or AVCC format
+func isKeyframe(codec *core.Codec, payload []byte) bool {
+	switch codec.Name {
+	case core.CodecH264:
+		if codec.IsRTP() {
+			return h264.IsKeyframeRTP(payload)
+		}
+		return h264.IsKeyframe(payload)
+	case core.CodecH265:
+		if codec.IsRTP() {
+			return h265.IsKeyframeRTP(payload)
+		}
+		return h265.IsKeyframe(payload)
+	}
+	return false
+}
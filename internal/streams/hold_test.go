@@ -0,0 +1,57 @@
+package streams
+
+import (
+	"testing"
+	"time"
+
+	"github.com/AlexxIT/go2rtc/pkg/core"
+	"github.com/pion/rtp"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFrameHold(t *testing.T) {
+	HoldInterval = 10 * time.Millisecond
+
+	codec := &core.Codec{Name: core.CodecH264, ClockRate: 90000, PayloadType: 96}
+	receiver := core.NewReceiver(nil, codec)
+
+	hold := new(frameHold)
+	hold.tap(receiver)
+
+	packets := make(chan *rtp.Packet, 10)
+	sender := core.NewSender(nil, codec)
+	sender.Handler = func(packet *rtp.Packet) {
+		packets <- packet
+	}
+	sender.HandleRTP(receiver)
+
+	live := []*rtp.Packet{
+		{Header: rtp.Header{SequenceNumber: 1, Timestamp: 0}, Payload: []byte{0x7C, 0x85, 0x01}}, // FU-A IDR start
+		{Header: rtp.Header{SequenceNumber: 2, Timestamp: 0, Marker: true}, Payload: []byte{0x7C, 0x45, 0x02}},
+		{Header: rtp.Header{SequenceNumber: 3, Timestamp: 3000, Marker: true}, Payload: []byte{0x41, 0x9A, 0x03}}, // P-frame
+	}
+	for _, packet := range live {
+		receiver.WriteRTP(packet)
+	}
+	for range live {
+		<-packets
+	}
+
+	require.False(t, hold.holding())
+	hold.start()
+	require.True(t, hold.holding())
+
+	for i, seq := range []uint16{4, 5} {
+		select {
+		case packet := <-packets:
+			require.Equal(t, seq, packet.SequenceNumber)
+			require.Equal(t, live[i].Payload, packet.Payload)
+			require.Greater(t, packet.Timestamp, uint32(3000))
+		case <-time.After(time.Second):
+			require.FailNow(t, "keyframe not repeated")
+		}
+	}
+
+	hold.stop()
+	require.False(t, hold.holding())
+}
@@ -0,0 +1,29 @@
+package streams
+
+import (
+	"slices"
+
+	"github.com/AlexxIT/go2rtc/pkg/core"
+)
+
+// filterKinds - skip consumer medias of the not requested kinds, ex. `media=audio`
+// for the audio monitoring, so the producer tracks of other kinds are not requested
+func filterKinds(cons core.Consumer, medias []*core.Media) []*core.Media {
+	c, ok := cons.(interface{ GetKinds() []string })
+	if !ok {
+		return medias
+	}
+
+	kinds := c.GetKinds()
+	if len(kinds) == 0 {
+		return medias
+	}
+
+	var filtered []*core.Media
+	for _, media := range medias {
+		if slices.Contains(kinds, media.Kind) {
+			filtered = append(filtered, media)
+		}
+	}
+	return filtered
+}
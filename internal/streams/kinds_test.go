@@ -0,0 +1,49 @@
+package streams
+
+import (
+	"sync/atomic"
+	"testing"
+
+	"github.com/AlexxIT/go2rtc/pkg/core"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConsumerKinds(t *testing.T) {
+	var live atomic.Int32
+	var prod *testProducer
+
+	HandleFunc("kinds", func(url string) (core.Producer, error) {
+		live.Add(1)
+		prod = &testProducer{
+			Connection: core.Connection{
+				Medias: []*core.Media{
+					{Kind: core.KindVideo, Direction: core.DirectionRecvonly, Codecs: []*core.Codec{{Name: core.CodecH264, ClockRate: 90000}}},
+					{Kind: core.KindAudio, Direction: core.DirectionRecvonly, Codecs: []*core.Codec{{Name: core.CodecPCMA, ClockRate: 8000}}},
+				},
+			},
+			done: make(chan struct{}),
+			live: &live,
+		}
+		return prod, nil
+	})
+
+	stream := NewStream("kinds:camera1")
+
+	cons := newTestConsumer()
+	cons.Medias = append(cons.Medias, &core.Media{
+		Kind: core.KindAudio, Direction: core.DirectionSendonly, Codecs: []*core.Codec{{Name: core.CodecPCMA}},
+	})
+	cons.Kinds = core.ParseKinds("audio")
+	require.Nil(t, stream.AddConsumer(cons))
+
+	// the producer doesn't get the request for the video track
+	require.Len(t, prod.Receivers, 1)
+	require.Equal(t, core.CodecPCMA, prod.Receivers[0].Codec.Name)
+	require.Len(t, cons.Senders, 1)
+	require.Equal(t, core.CodecPCMA, cons.Senders[0].Codec.Name)
+
+	require.Equal(t, []string{core.KindAudio}, stream.ConsumersStats()[0].Kinds)
+
+	stream.RemoveConsumer(cons)
+	require.Equal(t, int32(0), live.Load())
+}
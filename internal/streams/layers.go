@@ -0,0 +1,81 @@
+package streams
+
+import (
+	"github.com/AlexxIT/go2rtc/pkg/core"
+	"github.com/AlexxIT/go2rtc/pkg/h264"
+	"github.com/AlexxIT/go2rtc/pkg/h265"
+)
+
+// setLayers - forward only the lower temporal layers to consumer video tracks with
+// the temporal option, ex. `temporal=1` halves the frame rate of the stream with
+// two layers, without transcoding
+func setLayers(cons core.Consumer) {
+	c, ok := cons.(interface{ GetSenders() []*core.Sender })
+	if !ok {
+		return
+	}
+
+	for _, sender := range c.GetSenders() {
+		if sender.Media == nil || sender.Media.Temporal <= 0 {
+			continue
+		}
+
+		switch sender.Codec.Name {
+		case core.CodecH264, core.CodecH265:
+			f := &temporalFilter{codec: sender.Codec, layers: byte(min(sender.Media.Temporal, 7))}
+			sender.SetFilter(f.filter)
+		}
+	}
+}
+
+// temporalFilter - drop access units of the higher temporal layers, sequence numbers
+// are shifted, so consumers don't see dropped packets as lost
+type temporalFilter struct {
+	codec  *core.Codec
+	layers byte
+
+	ts      uint32 // current access unit
+	tid     byte
+	started bool
+
+	dropped uint16
+}
+
+func (f *temporalFilter) filter(packet *core.Packet) *core.Packet {
+	if !f.started || packet.Timestamp != f.ts {
+		f.ts = packet.Timestamp
+		f.tid = 0
+		f.started = true
+	}
+
+	// H264 AVC NAL units get temporal_id from the SVC prefix NAL unit of the same AU
+	if tid, ok := f.temporalID(packet.Payload); ok {
+		f.tid = tid
+	}
+
+	if f.tid >= f.layers {
+		f.dropped++
+		return nil
+	}
+
+	if f.dropped == 0 {
+		return packet
+	}
+
+	clone := *packet
+	clone.SequenceNumber -= f.dropped
+	return &clone
+}
+
+func (f *temporalFilter) temporalID(payload []byte) (byte, bool) {
+	if f.codec.Name == core.CodecH265 {
+		if f.codec.IsRTP() {
+			return h265.TemporalID(payload), true
+		}
+		return h265.AUTemporalID(payload), true
+	}
+	if f.codec.IsRTP() {
+		return h264.RTPTemporalID(payload)
+	}
+	return h264.AUTemporalID(payload)
+}
@@ -0,0 +1,44 @@
+package streams
+
+import (
+	"testing"
+
+	"github.com/AlexxIT/go2rtc/pkg/core"
+	"github.com/pion/rtp"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLayers(t *testing.T) {
+	media := &core.Media{Kind: core.KindVideo, Temporal: 1}
+	codec := &core.Codec{Name: core.CodecH265, ClockRate: 90000, PayloadType: 96}
+	sender := core.NewSender(media, codec)
+
+	cons := &testConsumer{Connection: core.Connection{Senders: []*core.Sender{sender}}}
+	setLayers(cons)
+
+	recv := make(chan *core.Packet, 10)
+	sender.Output = func(packet *core.Packet) {
+		recv <- packet
+	}
+	sender.Start()
+	defer sender.Close()
+
+	write := func(seq uint16, ts uint32, tid byte) {
+		sender.Input(&core.Packet{
+			Header:  rtp.Header{SequenceNumber: seq, Timestamp: ts},
+			Payload: []byte{0x02, tid + 1},
+		})
+	}
+
+	write(1, 3000, 0)
+	write(2, 6000, 1) // dropped
+	write(3, 6000, 1) // dropped, same frame
+	write(4, 9000, 0)
+
+	require.Equal(t, 2, sender.Packets)
+	require.Equal(t, uint16(1), (<-recv).SequenceNumber)
+
+	packet := <-recv
+	require.Equal(t, uint16(2), packet.SequenceNumber) // without the gap
+	require.Equal(t, uint32(9000), packet.Timestamp)
+}
@@ -0,0 +1,42 @@
+package streams
+
+// LifecycleFunc - external integration hook, ex. turn on camera PoE only while streaming.
+// Called with stream name and reason. Errors are only logged.
+type LifecycleFunc func(name, reason string) error
+
+const (
+	ReasonConsumer = "consumer" // producer started for first consumer
+	ReasonIdle     = "idle"     // producer stopped because there are no consumers
+	ReasonEnded    = "ended"    // source finished playback, ex. VOD end of stream
+)
+
+var onStart, onStop []LifecycleFunc
+
+// OnStart - register callback for stream producer start
+func OnStart(f LifecycleFunc) {
+	onStart = append(onStart, f)
+}
+
+// OnStop - register callback for stream producer stop
+func OnStop(f LifecycleFunc) {
+	onStop = append(onStop, f)
+}
+
+// fireLifecycle - typ is EventStart or EventStop, also published to the events subscribers
+func fireLifecycle(typ, name, reason string) {
+	publish(&Event{Type: typ, Stream: name, Data: reason})
+
+	funcs := onStart
+	if typ == EventStop {
+		funcs = onStop
+	}
+
+	// run async, so callbacks can't block the media path
+	for _, f := range funcs {
+		go func(f LifecycleFunc) {
+			if err := f(name, reason); err != nil {
+				log.Warn().Err(err).Str("stream", name).Str("reason", reason).Msg("[streams] lifecycle")
+			}
+		}(f)
+	}
+}
@@ -0,0 +1,71 @@
+package streams
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLimiter(t *testing.T) {
+	l := &limiter{limit: 1}
+	l.acquire(false)
+
+	order := make(chan string, 3)
+	wait := func(name string, priority bool) {
+		n := l.stats().Queued
+		go func() {
+			l.acquire(priority)
+			order <- name
+		}()
+		// wait until goroutine in the queue
+		for l.stats().Queued == n {
+			time.Sleep(time.Millisecond)
+		}
+	}
+
+	wait("idle1", false)
+	wait("idle2", false)
+	wait("viewed", true)
+
+	require.Equal(t, limiterStats{Limit: 1, Active: 1, Queued: 3}, l.stats())
+
+	for _, name := range []string{"viewed", "idle1", "idle2"} {
+		l.release()
+		require.Equal(t, name, <-order)
+	}
+
+	l.release()
+	require.Equal(t, limiterStats{Limit: 1}, l.stats())
+}
+
+func TestLimiterUnlimited(t *testing.T) {
+	l := &limiter{}
+	for i := 0; i < 100; i++ {
+		l.acquire(false)
+	}
+	require.Equal(t, 100, l.stats().Active)
+}
+
+func TestHostLimiter(t *testing.T) {
+	l := &hostLimiter{rate: 1, burst: 2}
+	now := time.Now()
+
+	require.Zero(t, l.reserve("10.0.0.1", now))
+	require.Zero(t, l.reserve("10.0.0.1", now))
+	require.Equal(t, time.Second, l.reserve("10.0.0.1", now))
+	require.Equal(t, 2*time.Second, l.reserve("10.0.0.1", now))
+
+	// other host has own bucket
+	require.Zero(t, l.reserve("10.0.0.2", now))
+
+	// tokens are refilled with the rate, but not more than burst
+	require.Zero(t, l.reserve("10.0.0.2", now.Add(time.Minute)))
+	require.Zero(t, l.reserve("10.0.0.2", now.Add(time.Minute)))
+	require.Equal(t, time.Second, l.reserve("10.0.0.2", now.Add(time.Minute)))
+
+	require.Equal(t, "10.0.0.1", sourceHost("ffmpeg:rtsp://user:p@ss@10.0.0.1:554/stream#video=h264"))
+	require.Equal(t, "camera.lan", sourceHost("http://Camera.lan/snapshot.jpg"))
+	require.Equal(t, "::1", sourceHost("rtsp://[::1]:8554/camera1"))
+	require.Equal(t, "", sourceHost("ffmpeg:camera1#video=h264"))
+}
@@ -0,0 +1,169 @@
+package streams
+
+import (
+	"runtime"
+	"slices"
+	"sync/atomic"
+	"time"
+
+	"github.com/AlexxIT/go2rtc/pkg/core"
+)
+
+// OverloadCPU - percent of all CPU cores used by go2rtc, when the usage stays above it
+// OverloadDuration, go2rtc sheds load: eligible consumers get only OverloadTemporal
+// video layers and new requests with `quality=auto` get the substream. Everything is
+// reverted when the usage stays below OverloadRecover the same time. Zero - disabled.
+var OverloadCPU float64
+var OverloadRecover float64
+var OverloadDuration = 30 * time.Second
+var OverloadTemporal = 1
+
+// OverloadConsumers - eligible consumer types (format names), ex. webrtc, empty - all
+var OverloadConsumers []string
+
+var OverloadInterval = time.Second
+
+var overloaded atomic.Bool
+
+// overload - controller state, used only by the worker
+type overload struct {
+	since   time.Time // first sample on the other side of the threshold
+	filters map[*core.Sender]*shedFilter
+}
+
+func overloadWorker() {
+	prev, ok := processCPU()
+	if !ok {
+		log.Warn().Msg("[streams] overload: CPU usage is not supported on this OS")
+		return
+	}
+
+	o := &overload{filters: map[*core.Sender]*shedFilter{}}
+
+	prevTime := time.Now()
+	for now := range time.Tick(OverloadInterval) {
+		cpu, _ := processCPU()
+		usage := float64(cpu-prev) / float64(now.Sub(prevTime)) / float64(runtime.NumCPU()) * 100
+		prev, prevTime = cpu, now
+
+		if o.check(usage, now) {
+			if overloaded.Load() {
+				log.Warn().Msgf("[streams] overload: CPU usage %.0f%%, shed load of consumers", usage)
+			} else {
+				log.Info().Msgf("[streams] overload: CPU usage %.0f%%, revert consumers", usage)
+			}
+		}
+
+		o.apply(collectSenders())
+	}
+}
+
+// check - switch the overloaded state after the usage stays on the other side
+// of the threshold OverloadDuration, true if the state was changed
+func (o *overload) check(usage float64, now time.Time) bool {
+	active := overloaded.Load()
+
+	var cross bool
+	if active {
+		cross = usage < OverloadRecover
+	} else {
+		cross = usage > OverloadCPU
+	}
+
+	if !cross {
+		o.since = time.Time{}
+		return false
+	}
+
+	if o.since.IsZero() {
+		o.since = now
+	}
+	if now.Sub(o.since) < OverloadDuration {
+		return false
+	}
+
+	o.since = time.Time{}
+	overloaded.Store(!active)
+	return true
+}
+
+// apply - switch filters of the eligible video senders by the overloaded state,
+// filters stay on senders after the revert, so consumers never see the sequence jump
+func (o *overload) apply(senders []*core.Sender) {
+	// forget closed senders
+	for sender := range o.filters {
+		if _, size := sender.Queue(); size == 0 {
+			delete(o.filters, sender)
+		}
+	}
+
+	active := overloaded.Load()
+
+	for _, sender := range senders {
+		f := o.filters[sender]
+		if f == nil {
+			if !active {
+				continue
+			}
+			f = &shedFilter{temporalFilter: temporalFilter{codec: sender.Codec, layers: byte(min(OverloadTemporal, 7))}}
+			sender.SetFilter(f.filter)
+			o.filters[sender] = f
+		}
+		f.active.Store(active)
+	}
+}
+
+// collectSenders - H264 and H265 senders of the eligible consumers without
+// the own temporal option
+func collectSenders() (senders []*core.Sender) {
+	for _, stream := range namedStreams() {
+		stream.mu.Lock()
+		consumers := slices.Clone(stream.consumers)
+		stream.mu.Unlock()
+
+		for _, cons := range consumers {
+			c, ok := cons.(interface{ GetSenders() []*core.Sender })
+			if !ok {
+				continue
+			}
+
+			if OverloadConsumers != nil && !slices.Contains(OverloadConsumers, formatName(cons)) {
+				continue
+			}
+
+			for _, sender := range c.GetSenders() {
+				if sender.Media != nil && sender.Media.Temporal > 0 {
+					continue
+				}
+				switch sender.Codec.Name {
+				case core.CodecH264, core.CodecH265:
+					senders = append(senders, sender)
+				}
+			}
+		}
+	}
+	return
+}
+
+// shedFilter - temporal filter that can be switched off, sequence numbers stay
+// shifted by the dropped packets
+type shedFilter struct {
+	temporalFilter
+	active atomic.Bool
+}
+
+func (f *shedFilter) filter(packet *core.Packet) *core.Packet {
+	if f.active.Load() {
+		return f.temporalFilter.filter(packet)
+	}
+
+	f.started = false // new access unit after the next switch on
+
+	if f.dropped == 0 {
+		return packet
+	}
+
+	clone := *packet
+	clone.SequenceNumber -= f.dropped
+	return &clone
+}
@@ -0,0 +1,87 @@
+package streams
+
+import (
+	"testing"
+	"time"
+
+	"github.com/AlexxIT/go2rtc/pkg/core"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOverloadCheck(t *testing.T) {
+	OverloadCPU, OverloadRecover = 80, 60
+	defer func() {
+		OverloadCPU, OverloadRecover = 0, 0
+		overloaded.Store(false)
+	}()
+
+	o := &overload{}
+	now := time.Now()
+
+	// short peak is ignored
+	require.False(t, o.check(90, now))
+	require.False(t, o.check(50, now.Add(10*time.Second)))
+
+	require.False(t, o.check(90, now.Add(20*time.Second)))
+	require.True(t, o.check(95, now.Add(50*time.Second)))
+	require.True(t, overloaded.Load())
+
+	// usage between recover and threshold keeps the state
+	require.False(t, o.check(70, now.Add(100*time.Second)))
+	require.True(t, overloaded.Load())
+
+	require.False(t, o.check(50, now.Add(110*time.Second)))
+	require.True(t, o.check(50, now.Add(140*time.Second)))
+	require.False(t, overloaded.Load())
+}
+
+func TestOverloadShed(t *testing.T) {
+	defer overloaded.Store(false)
+
+	codec := &core.Codec{Name: core.CodecH265, PayloadType: 96, ClockRate: 90000}
+	sender := core.NewSender(&core.Media{Kind: core.KindVideo}, codec)
+	defer sender.Close()
+
+	o := &overload{filters: map[*core.Sender]*shedFilter{}}
+	o.apply([]*core.Sender{sender})
+	require.Empty(t, o.filters, "no filter without overload")
+
+	overloaded.Store(true)
+	o.apply([]*core.Sender{sender})
+	require.Len(t, o.filters, 1)
+
+	var seqs []uint16
+	write := func(seq uint16, tid byte) {
+		packet := &core.Packet{Payload: []byte{1 << 1, tid + 1}} // H265 NAL header with TID
+		packet.SequenceNumber, packet.Timestamp = seq, uint32(seq)*3000
+		if packet = o.filters[sender].filter(packet); packet != nil {
+			seqs = append(seqs, packet.SequenceNumber)
+		}
+	}
+
+	write(1, 0)
+	write(2, 1) // dropped
+	write(3, 0)
+
+	// after the revert all layers pass with the same shift
+	overloaded.Store(false)
+	o.apply([]*core.Sender{sender})
+	write(4, 1)
+
+	require.Equal(t, []uint16{1, 2, 3}, seqs)
+}
+
+func TestQualityAuto(t *testing.T) {
+	defer overloaded.Store(false)
+
+	mainStream, _ := Patch("overload1", "rtsp://camera1")
+	sub, _ := Patch("overload1_sub", "rtsp://camera1/sub")
+	defer Delete("overload1")
+	defer Delete("overload1_sub")
+
+	require.Equal(t, mainStream, GetQuality("overload1", QualityAuto))
+
+	overloaded.Store(true)
+	require.Equal(t, sub, GetQuality("overload1", QualityAuto))
+	require.Equal(t, mainStream, GetQuality("overload1", ""))
+}
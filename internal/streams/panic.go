@@ -0,0 +1,132 @@
+package streams
+
+import (
+	"errors"
+	"fmt"
+	"runtime/debug"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/AlexxIT/go2rtc/pkg/core"
+)
+
+// MaxPanics - quarantine the source after N panics inside PanicWindow (ex. depacketizer
+// crash on the malformed payload), so one bad camera doesn't destabilize the whole
+// process. Zero means never, but panics are still recovered.
+var MaxPanics = 3
+var PanicWindow = 10 * time.Minute
+
+var errQuarantined = errors.New("streams: source quarantined after repeated panics")
+
+// lastPackets - ring buffer size of the last packets of each track for bug reports
+const lastPackets = 4
+
+// panicState - separate from the producer mutex, because panic can happen in
+// the read loop while Producer.stop waits for this loop under the mutex
+type panicState struct {
+	times       []time.Time
+	quarantined atomic.Bool
+	mu          sync.Mutex
+}
+
+// add - count the panic and return true if the source should be quarantined
+func (s *panicState) add(now time.Time) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := 0
+	for i < len(s.times) && now.Sub(s.times[i]) > PanicWindow {
+		i++
+	}
+	s.times = append(s.times[i:], now)
+
+	if MaxPanics > 0 && len(s.times) >= MaxPanics {
+		s.quarantined.Store(true)
+	}
+	return s.quarantined.Load()
+}
+
+func (s *panicState) reset() {
+	s.mu.Lock()
+	s.times = nil
+	s.quarantined.Store(false)
+	s.mu.Unlock()
+}
+
+// packetInfo - header and the start of the payload, packet memory is reused by readers
+type packetInfo struct {
+	seq     uint16
+	ts      uint32
+	pt      uint8
+	marker  bool
+	size    int
+	payload [32]byte
+}
+
+type packetRing struct {
+	items [lastPackets]packetInfo
+	n     int // total packets
+}
+
+func (r *packetRing) add(packet *core.Packet) {
+	item := &r.items[r.n%lastPackets]
+	item.seq = packet.SequenceNumber
+	item.ts = packet.Timestamp
+	item.pt = packet.PayloadType
+	item.marker = packet.Marker
+	item.size = len(packet.Payload)
+	copy(item.payload[:], packet.Payload)
+	r.n++
+}
+
+// String - from the oldest to the offending packet
+func (r *packetRing) String() string {
+	var sb strings.Builder
+	for i := max(r.n-lastPackets, 0); i < r.n; i++ {
+		item := &r.items[i%lastPackets]
+		if sb.Len() > 0 {
+			sb.WriteString(", ")
+		}
+		fmt.Fprintf(&sb, "seq=%d ts=%d pt=%d marker=%t size=%d payload=%x",
+			item.seq, item.ts, item.pt, item.marker, item.size, item.payload[:min(item.size, len(item.payload))])
+	}
+	return sb.String()
+}
+
+// guard - recover panics inside the track handlers, the packet is dropped.
+// Should be called after all other wraps of the receiver input.
+func (p *Producer) guard(conn core.Producer, track *core.Receiver) {
+	var ring packetRing
+
+	input := track.Input
+	track.Input = func(packet *core.Packet) {
+		defer func() {
+			if r := recover(); r != nil {
+				log.Error().Str("stream", p.streamName()).Str("url", p.source()).Str("codec", track.Codec.String()).Str("packets", ring.String()).
+					Bytes("stack", debug.Stack()).Msgf("[streams] panic: %v", r)
+				if p.panics.add(time.Now()) {
+					go func() { _ = conn.Stop() }() // worker will not reconnect
+				}
+			}
+		}()
+
+		p.beat()
+		ring.add(packet)
+		input(packet)
+	}
+}
+
+// safeStart - recover panics inside the producer read loop
+func (p *Producer) safeStart(conn core.Producer) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Error().Str("stream", p.streamName()).Str("url", p.source()).Bytes("stack", debug.Stack()).Msgf("[streams] panic: %v", r)
+			p.panics.add(time.Now())
+			err = fmt.Errorf("streams: panic: %v", r)
+		}
+	}()
+
+	return conn.Start()
+}
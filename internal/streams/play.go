@@ -61,7 +61,7 @@ func (s *Stream) Play(urlOrProd any) error {
 
 	for _, producer := range s.producers {
 		// start new client
-		dst, err := GetProducer(producer.url)
+		dst, err := GetProducer(producer.source())
 		if err != nil {
 			continue
 		}
@@ -118,6 +118,7 @@ func (s *Stream) AddInternalProducer(conn core.Producer) {
 func (s *Stream) AddInternalConsumer(conn core.Consumer) {
 	s.mu.Lock()
 	s.consumers = append(s.consumers, conn)
+	s.consumerStarted(conn)
 	s.mu.Unlock()
 }
 
@@ -129,6 +130,7 @@ func (s *Stream) RemoveInternalConsumer(conn core.Consumer) {
 			break
 		}
 	}
+	delete(s.started, conn)
 	s.mu.Unlock()
 }
 
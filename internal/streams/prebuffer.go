@@ -0,0 +1,58 @@
+package streams
+
+import (
+	"time"
+
+	"github.com/AlexxIT/go2rtc/pkg/core"
+)
+
+// PrebufferConfig - hold packets of the new consumer until Keyframes video keyframes
+// (GOPs) are buffered or Duration (in ms) is passed, whichever comes first
+type PrebufferConfig struct {
+	Keyframes int `yaml:"keyframes"`
+	Duration  int `yaml:"duration"`
+}
+
+// ConsumerPrebuffers - startup buffer by the consumer type (format name), default - none
+var ConsumerPrebuffers map[string]PrebufferConfig
+
+func setPrebuffer(cons core.Consumer) {
+	if len(ConsumerPrebuffers) == 0 {
+		return
+	}
+
+	buffered, ok := cons.(interface{ SetPrebuffer(p *core.Prebuffer) })
+	if !ok {
+		return
+	}
+
+	c, err := marshalConn(cons)
+	if err != nil {
+		return
+	}
+
+	cfg, ok := ConsumerPrebuffers[c.FormatName]
+	if !ok {
+		return
+	}
+
+	p := &core.Prebuffer{
+		Duration:   time.Duration(cfg.Duration) * time.Millisecond,
+		IsKeyframe: isKeyframe,
+	}
+
+	// keyframes are counted only for the consumer with video
+	for _, sender := range c.Senders {
+		if sender.Codec["codec_type"] == core.KindVideo {
+			p.Keyframes = cfg.Keyframes
+			break
+		}
+	}
+
+	if p.Keyframes <= 0 && p.Duration <= 0 {
+		return
+	}
+
+	log.Trace().Msgf("[streams] set prebuffer keyframes=%d duration=%s for consumer=%s", p.Keyframes, p.Duration, c.FormatName)
+	buffered.SetPrebuffer(p)
+}
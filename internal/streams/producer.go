@@ -5,6 +5,7 @@ import (
 	"errors"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/AlexxIT/go2rtc/pkg/core"
@@ -26,6 +27,7 @@ type Producer struct {
 
 	url      string
 	template string
+	urlMu    sync.Mutex // url and template can be changed by API or config reload
 
 	conn      core.Producer
 	receivers []*core.Receiver
@@ -34,8 +36,38 @@ type Producer struct {
 	state    state
 	mu       sync.Mutex
 	workerID int
+	retry    *time.Timer
+	hold     *frameHold // nil if disabled
+	stream   *Stream    // owner for events, nil for the standalone producer
+
+	authFails int
+
+	attempts    []time.Time // reconnect attempts inside ReconnectWindow
+	gaveUp      bool        // reconnect stopped after MaxReconnects or on fatal error
+	panics      panicState
+	connectedAt time.Time
+	bitrate     bitrateHistory // incoming bitrate, sampled each BitrateInterval
+
+	reading   bool          // worker is inside the read loop
+	heartbeat atomic.Int64  // last packet time in unix nano, for the dead man's switch
+	wedged    core.Producer // connection stopped by the dead man's switch
 }
 
+// MaxAuthFailures - stop reconnects after N consecutive auth errors,
+// because some cameras lock out accounts after many wrong logins
+var MaxAuthFailures = 3
+
+var errAuthTripped = errors.New("streams: reconnect stopped after auth failures, check credentials")
+
+// MaxReconnects - stop reconnects after N attempts inside ReconnectWindow and
+// mark the source failed, zero means unlimited. Counter is cleared when the
+// connection works ReconnectHealthy time.
+var MaxReconnects int
+var ReconnectWindow = 10 * time.Minute
+var ReconnectHealthy = time.Minute
+
+var errReconnectGaveUp = errors.New("streams: reconnect stopped after max attempts")
+
 const SourceTemplate = "{input}"
 
 func NewProducer(source string) *Producer {
@@ -47,11 +79,26 @@ func NewProducer(source string) *Producer {
 }
 
 func (p *Producer) SetSource(s string) {
+	p.mu.Lock()
+	p.authFails = 0 // new source - new chance
+	p.resetAttempts()
+	p.mu.Unlock()
+	p.panics.reset()
+
+	p.urlMu.Lock()
 	if p.template == "" {
 		p.url = s
 	} else {
 		p.url = strings.Replace(p.template, SourceTemplate, s, 1)
 	}
+	p.urlMu.Unlock()
+}
+
+// source - current URL of the producer, safe to call with or without p.mu
+func (p *Producer) source() string {
+	p.urlMu.Lock()
+	defer p.urlMu.Unlock()
+	return p.url
 }
 
 func (p *Producer) Dial() error {
@@ -59,11 +106,24 @@ func (p *Producer) Dial() error {
 	defer p.mu.Unlock()
 
 	if p.state == stateNone {
-		conn, err := GetProducer(p.url)
+		if p.authTripped() {
+			return errAuthTripped
+		}
+		if p.gaveUp {
+			return errReconnectGaveUp
+		}
+		if p.panics.quarantined.Load() {
+			return errQuarantined
+		}
+
+		conn, err := GetProducer(p.source())
 		if err != nil {
+			p.checkAuth(err)
 			return err
 		}
 
+		p.authFails = 0
+
 		p.conn = conn
 		p.state = stateMedias
 	}
@@ -101,6 +161,9 @@ func (p *Producer) GetTrack(media *core.Media, codec *core.Codec) (*core.Receive
 		return nil, err
 	}
 
+	p.hold.tap(track)
+	p.watch(track)
+	p.guard(p.conn, track)
 	p.receivers = append(p.receivers, track)
 
 	if p.state == stateMedias {
@@ -110,6 +173,18 @@ func (p *Producer) GetTrack(media *core.Media, codec *core.Codec) (*core.Receive
 	return track, nil
 }
 
+// muteAudio - mute audio tracks, new tracks get the stream state in AddConsumer
+// and the state moves with tracks on reconnect
+func (p *Producer) muteAudio(muted bool) {
+	p.mu.Lock()
+	for _, track := range p.receivers {
+		if track.Codec.IsAudio() {
+			track.Mute(muted)
+		}
+	}
+	p.mu.Unlock()
+}
+
 func (p *Producer) AddTrack(media *core.Media, codec *core.Codec, track *core.Receiver) error {
 	p.mu.Lock()
 	defer p.mu.Unlock()
@@ -132,33 +207,106 @@ func (p *Producer) AddTrack(media *core.Media, codec *core.Codec, track *core.Re
 }
 
 func (p *Producer) MarshalJSON() ([]byte, error) {
-	if conn := p.conn; conn != nil {
+	// fields are changed by the worker and reconnect under the lock
+	p.mu.Lock()
+	authTripped, gaveUp, conn := p.authTripped(), p.gaveUp, p.conn
+	p.mu.Unlock()
+
+	if authTripped {
+		info := map[string]string{"url": p.source(), "state": "failed-auth"}
+		return json.Marshal(info)
+	}
+	if gaveUp {
+		info := map[string]string{"url": p.source(), "state": "failed-reconnect"}
+		return json.Marshal(info)
+	}
+	if p.panics.quarantined.Load() {
+		info := map[string]string{"url": p.source(), "state": "failed-panic"}
+		return json.Marshal(info)
+	}
+	if conn != nil {
 		return json.Marshal(conn)
 	}
-	info := map[string]string{"url": p.url}
+	info := map[string]string{"url": p.source()}
 	return json.Marshal(info)
 }
 
 // internals
 
-func (p *Producer) start() {
+// checkAuth - count consecutive auth errors and return true if circuit breaker tripped
+func (p *Producer) checkAuth(err error) bool {
+	if errors.Is(err, core.ErrAuth) {
+		p.authFails++
+	} else {
+		p.authFails = 0
+	}
+	return p.authTripped()
+}
+
+func (p *Producer) authTripped() bool {
+	return MaxAuthFailures > 0 && p.authFails >= MaxAuthFailures
+}
+
+// checkAttempts - count the reconnect attempt and return true if the limit is reached
+func (p *Producer) checkAttempts() bool {
+	if MaxReconnects <= 0 {
+		return false
+	}
+
+	now := time.Now()
+
+	i := 0
+	for i < len(p.attempts) && now.Sub(p.attempts[i]) > ReconnectWindow {
+		i++
+	}
+	p.attempts = append(p.attempts[i:], now)
+
+	p.gaveUp = len(p.attempts) > MaxReconnects
+	return p.gaveUp
+}
+
+func (p *Producer) resetAttempts() {
+	p.attempts = nil
+	p.gaveUp = false
+}
+
+// resetFailures - clear all circuit breakers of the source
+func (p *Producer) resetFailures() {
+	p.resetAttempts()
+	p.authFails = 0
+	p.panics.reset()
+}
+
+func (p *Producer) start() bool {
 	p.mu.Lock()
 	defer p.mu.Unlock()
 
 	if p.state != stateTracks {
-		return
+		return false
 	}
 
-	log.Debug().Msgf("[streams] start producer url=%s", p.url)
+	log.Debug().Str("stream", p.streamName()).Msgf("[streams] start producer url=%s", p.source())
 
 	p.state = stateStart
 	p.workerID++
+	p.connectedAt = time.Now()
+	p.reading = true
 
 	go p.worker(p.conn, p.workerID)
+
+	return true
 }
 
 func (p *Producer) worker(conn core.Producer, workerID int) {
-	if err := conn.Start(); err != nil {
+	err := p.safeStart(conn)
+
+	p.mu.Lock()
+	if p.workerID == workerID {
+		p.reading = false
+	}
+	p.mu.Unlock()
+
+	if err != nil {
 		p.mu.Lock()
 		closed := p.workerID != workerID
 		p.mu.Unlock()
@@ -167,42 +315,103 @@ func (p *Producer) worker(conn core.Producer, workerID int) {
 			return
 		}
 
-		log.Warn().Err(err).Str("url", p.url).Caller().Send()
+		if errors.Is(err, core.ErrEndOfStream) {
+			log.Info().Str("stream", p.streamName()).Str("url", p.source()).Msg("[streams] end of stream")
+			go endOfStream(p)
+			return
+		}
+
+		log.Warn().Str("stream", p.streamName()).Err(err).Str("url", p.source()).Caller().Send()
+		p.publish(EventError, err)
+
+		if errors.Is(err, core.ErrNoReconnect) {
+			return
+		}
+	}
+
+	if p.panics.quarantined.Load() {
+		log.Error().Str("stream", p.streamName()).Str("url", p.source()).Msgf("[streams] quarantine source after %d panics", MaxPanics)
+		return
+	}
+
+	p.mu.Lock()
+	if time.Since(p.connectedAt) >= ReconnectHealthy {
+		p.attempts = nil // connection was healthy
 	}
+	p.mu.Unlock()
 
+	p.hold.start()
 	p.reconnect(workerID, 0)
 }
 
 func (p *Producer) reconnect(workerID, retry int) {
+	reconnects.acquire(p.hasConsumers())
+	defer reconnects.release()
+
 	p.mu.Lock()
 	defer p.mu.Unlock()
 
 	if p.workerID != workerID {
-		log.Trace().Msgf("[streams] stop reconnect url=%s", p.url)
+		log.Trace().Str("stream", p.streamName()).Msgf("[streams] stop reconnect url=%s", p.source())
+		p.hold.stop()
 		return
 	}
 
-	log.Debug().Msgf("[streams] retry=%d to url=%s", retry, p.url)
+	if p.checkAttempts() {
+		log.Error().Str("stream", p.streamName()).Str("url", p.source()).Msgf("[streams] stop reconnect after %d attempts", len(p.attempts)-1)
+		p.hold.stop()
+		return
+	}
 
-	conn, err := GetProducer(p.url)
+	if p.panics.quarantined.Load() {
+		p.hold.stop()
+		return
+	}
+
+	log.Debug().Str("stream", p.streamName()).Msgf("[streams] retry=%d to url=%s", retry, p.source())
+
+	conn, err := GetProducer(p.source())
 	if err != nil {
 		log.Debug().Msgf("[streams] producer=%s", err)
+		p.publish(EventError, err)
+
+		if p.checkAuth(err) {
+			log.Error().Str("stream", p.streamName()).Err(err).Str("url", p.source()).Msgf("[streams] stop reconnect after %d auth failures", p.authFails)
+			p.hold.stop()
+			return
+		}
+
+		if errors.Is(err, core.ErrNoReconnect) {
+			log.Error().Str("stream", p.streamName()).Err(err).Str("url", p.source()).Msg("[streams] stop reconnect on fatal error")
+			p.gaveUp = true
+			p.hold.stop()
+			return
+		}
 
 		timeout := time.Minute
 		if retry < 5 {
-			timeout = time.Second
+			if errors.Is(err, core.ErrReconnect) {
+				timeout = 0 // transient error, first retries without delay
+			} else {
+				timeout = time.Second
+			}
 		} else if retry < 10 {
 			timeout = time.Second * 5
 		} else if retry < 20 {
 			timeout = time.Second * 10
 		}
 
-		time.AfterFunc(timeout, func() {
+		p.retry = time.AfterFunc(timeout, func() {
 			p.reconnect(workerID, retry+1)
 		})
 		return
 	}
 
+	p.retry = nil
+
+	// live video is back
+	p.hold.stop()
+
 	for _, media := range conn.GetMedias() {
 		switch media.Direction {
 		case core.DirectionRecvonly:
@@ -217,6 +426,13 @@ func (p *Producer) reconnect(workerID, retry int) {
 					continue
 				}
 
+				if codec.FmtpLine != receiver.Codec.FmtpLine {
+					p.publish(EventCodec, &CodecChange{Old: receiver.Codec, New: codec})
+				}
+
+				p.hold.move(receiver, track)
+				p.watch(track)
+				p.guard(conn, track)
 				receiver.Replace(track)
 				p.receivers[i] = track
 				break
@@ -234,30 +450,94 @@ func (p *Producer) reconnect(workerID, retry int) {
 		}
 	}
 
-	// stop previous connection after moving tracks (fix ghost exec/ffmpeg)
-	_ = p.conn.Stop()
+	p.authFails = 0
+	p.connectedAt = time.Now()
+	p.reading = true
+
+	// stop previous connection after moving tracks (fix ghost exec/ffmpeg),
+	// the wedged connection is already stopping in the background
+	if p.conn != p.wedged {
+		_ = p.conn.Stop()
+	}
+	p.wedged = nil
 	// swap connections
 	p.conn = conn
 
 	go p.worker(conn, workerID)
+
+	go restartDependents(p)
+
+	p.publish(EventReconnect, retry)
 }
 
-func (p *Producer) stop() {
+// retryNow - skip waiting of the reconnect timeout
+func (p *Producer) retryNow() {
 	p.mu.Lock()
 	defer p.mu.Unlock()
 
+	// Stop returns false if reconnect already started
+	if p.retry != nil && p.retry.Stop() {
+		p.retry.Reset(0)
+	}
+}
+
+// hasConsumers - producer tracks have consumers waiting for the data
+func (p *Producer) hasConsumers() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for _, receiver := range p.receivers {
+		if len(receiver.Senders()) > 0 {
+			return true
+		}
+	}
+
+	return len(p.senders) > 0
+}
+
+// restart - stop current connection, so worker will reconnect to the source
+func (p *Producer) restart() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.state != stateStart || p.conn == nil {
+		// source failed before the first connection, the next consumer dials again
+		p.resetFailures()
+		return
+	}
+
+	if p.gaveUp || p.authTripped() || p.panics.quarantined.Load() {
+		// manual restart gives the failed source a new chance
+		log.Debug().Str("stream", p.streamName()).Msgf("[streams] retry failed producer url=%s", p.source())
+		p.resetFailures()
+		go p.reconnect(p.workerID, 0)
+		return
+	}
+
+	log.Debug().Str("stream", p.streamName()).Msgf("[streams] restart producer url=%s", p.source())
+
+	_ = p.conn.Stop()
+}
+
+func (p *Producer) stop() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	var started bool
+
 	switch p.state {
 	case stateExternal:
 		log.Trace().Msgf("[streams] skip stop external producer")
-		return
+		return false
 	case stateNone:
 		log.Trace().Msgf("[streams] skip stop none producer")
-		return
+		return false
 	case stateStart:
 		p.workerID++
+		started = true
 	}
 
-	log.Debug().Msgf("[streams] stop producer url=%s", p.url)
+	log.Debug().Str("stream", p.streamName()).Msgf("[streams] stop producer url=%s", p.source())
 
 	if p.conn != nil {
 		_ = p.conn.Stop()
@@ -265,6 +545,10 @@ func (p *Producer) stop() {
 	}
 
 	p.state = stateNone
+	p.reading = false
 	p.receivers = nil
 	p.senders = nil
+	p.hold.reset()
+
+	return started
 }
@@ -0,0 +1,228 @@
+package streams
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/AlexxIT/go2rtc/pkg/core"
+	"github.com/pion/rtp"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMaxReconnects(t *testing.T) {
+	MaxReconnects = 2
+	defer func() { MaxReconnects = 0 }()
+
+	p := NewProducer("unknown://camera")
+
+	require.False(t, p.checkAttempts())
+	require.False(t, p.checkAttempts())
+	require.True(t, p.checkAttempts())
+
+	b, err := json.Marshal(p)
+	require.Nil(t, err)
+	require.Equal(t, `{"state":"failed-reconnect","url":"unknown://camera"}`, string(b))
+	require.ErrorIs(t, p.Dial(), errReconnectGaveUp)
+
+	// new source - new chance
+	p.SetSource("unknown://camera2")
+	require.False(t, p.gaveUp)
+
+	// old attempts are outside the window
+	p.attempts = []time.Time{time.Now().Add(-time.Hour), time.Now().Add(-time.Hour)}
+	require.False(t, p.checkAttempts())
+	require.Len(t, p.attempts, 1)
+}
+
+func TestMaxReconnectsUnlimited(t *testing.T) {
+	p := NewProducer("unknown://camera")
+	for i := 0; i < 100; i++ {
+		require.False(t, p.checkAttempts())
+	}
+	require.Nil(t, p.attempts)
+}
+
+func TestReconnectFatal(t *testing.T) {
+	HandleFunc("fatal", func(url string) (core.Producer, error) {
+		return nil, fmt.Errorf("%w: status 404 on DESCRIBE", core.ErrNoReconnect)
+	})
+
+	p := NewProducer("fatal://camera")
+	p.reconnect(p.workerID, 0)
+	require.True(t, p.gaveUp)
+	require.Nil(t, p.retry)
+	require.ErrorIs(t, p.Dial(), errReconnectGaveUp)
+}
+
+func TestRestartAuthFailures(t *testing.T) {
+	var wrong atomic.Bool
+	wrong.Store(true)
+
+	var live, dials atomic.Int32
+
+	HandleFunc("auth", func(url string) (core.Producer, error) {
+		if wrong.Load() {
+			return nil, fmt.Errorf("%w: status 401 on DESCRIBE", core.ErrAuth)
+		}
+		dials.Add(1)
+		return &testProducer{done: make(chan struct{}), live: &live}, nil
+	})
+
+	p := NewProducer("auth://camera")
+
+	for i := 0; i < MaxAuthFailures; i++ {
+		require.ErrorIs(t, p.Dial(), core.ErrAuth)
+	}
+	require.ErrorIs(t, p.Dial(), errAuthTripped)
+
+	// credentials fixed on the camera, reconnect API resumes the source
+	wrong.Store(false)
+	p.restart()
+	require.Nil(t, p.Dial())
+	p.stop()
+
+	// breaker tripped by reconnects of the running source
+	require.Nil(t, p.Dial())
+	p.mu.Lock()
+	p.state = stateStart
+	p.authFails = MaxAuthFailures
+	p.mu.Unlock()
+
+	p.restart()
+	require.Eventually(t, func() bool {
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		return p.authFails == 0 && dials.Load() == 3
+	}, time.Second, time.Millisecond)
+	p.stop()
+}
+
+func TestMarshalJSONReconnecting(t *testing.T) {
+	var live atomic.Int32
+
+	HandleFunc("marshal", func(url string) (core.Producer, error) {
+		live.Add(1)
+		return &testProducer{done: make(chan struct{}), live: &live}, nil
+	})
+
+	p := NewProducer("marshal://camera")
+
+	// API readers marshal the producer while the worker changes it
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 100; i++ {
+			_, _ = json.Marshal(p)
+		}
+	}()
+
+	for i := 0; i < 20; i++ {
+		require.Nil(t, p.Dial())
+		p.checkAuth(core.ErrAuth)
+		p.checkAttempts()
+		p.stop()
+	}
+	<-done
+}
+
+func TestPanicQuarantine(t *testing.T) {
+	p := NewProducer("unknown://camera")
+
+	live := new(atomic.Int32)
+	live.Add(1)
+	conn := &testProducer{done: make(chan struct{}), live: live}
+
+	codec := &core.Codec{Name: core.CodecH264, ClockRate: 90000, PayloadType: 96}
+	receiver := core.NewReceiver(nil, codec)
+	receiver.Input = func(packet *core.Packet) {
+		if packet.Payload[0] == 0xFF {
+			panic("malformed payload")
+		}
+	}
+	p.guard(conn, receiver)
+
+	for i := 0; i < MaxPanics-1; i++ {
+		receiver.WriteRTP(&rtp.Packet{Header: rtp.Header{SequenceNumber: uint16(i)}, Payload: []byte{0xFF}})
+	}
+	receiver.WriteRTP(&rtp.Packet{Payload: []byte{0x41}}) // good packet still works
+	require.False(t, p.panics.quarantined.Load())
+
+	receiver.WriteRTP(&rtp.Packet{Payload: []byte{0xFF}})
+	require.True(t, p.panics.quarantined.Load())
+	require.Eventually(t, func() bool { return live.Load() == 0 }, time.Second, time.Millisecond)
+
+	b, err := json.Marshal(p)
+	require.Nil(t, err)
+	require.Equal(t, `{"state":"failed-panic","url":"unknown://camera"}`, string(b))
+	require.ErrorIs(t, p.Dial(), errQuarantined)
+
+	// old panics are outside the window
+	p.panics.times = []time.Time{time.Now().Add(-time.Hour), time.Now().Add(-time.Hour)}
+	p.panics.quarantined.Store(false)
+	require.False(t, p.panics.add(time.Now()))
+
+	// new source - new chance
+	p.SetSource("unknown://camera2")
+	require.False(t, p.panics.quarantined.Load())
+}
+
+func TestPacketRing(t *testing.T) {
+	var ring packetRing
+	for i := 1; i <= 5; i++ {
+		ring.add(&rtp.Packet{Header: rtp.Header{SequenceNumber: uint16(i), PayloadType: 96}, Payload: []byte{byte(i)}})
+	}
+	require.Equal(t, "seq=2 ts=0 pt=96 marker=false size=1 payload=02, "+
+		"seq=3 ts=0 pt=96 marker=false size=1 payload=03, "+
+		"seq=4 ts=0 pt=96 marker=false size=1 payload=04, "+
+		"seq=5 ts=0 pt=96 marker=false size=1 payload=05", ring.String())
+}
+
+func TestDeadman(t *testing.T) {
+	var live atomic.Int32
+	var conns []*testProducer
+
+	HandleFunc("wedged", func(url string) (core.Producer, error) {
+		live.Add(1)
+		conn := &testProducer{
+			Connection: core.Connection{
+				Medias: []*core.Media{
+					{Kind: core.KindVideo, Direction: core.DirectionRecvonly, Codecs: []*core.Codec{{Name: core.CodecH264, ClockRate: 90000}}},
+				},
+			},
+			done: make(chan struct{}),
+			live: &live,
+		}
+		conns = append(conns, conn)
+		return conn, nil
+	})
+
+	p := NewProducer("wedged://camera")
+	require.Nil(t, p.Dial())
+	media := p.GetMedias()[0]
+	track, err := p.GetTrack(media, media.Codecs[0])
+	require.Nil(t, err)
+	require.True(t, p.start())
+
+	// packets are the heartbeat
+	track.WriteRTP(&rtp.Packet{Payload: []byte{0x41}})
+	require.Less(t, p.stalled(time.Now()), time.Second)
+
+	stale := p.stalled(time.Now().Add(time.Minute))
+	require.Greater(t, stale, time.Minute-time.Second)
+
+	p.deadman(stale)
+	require.Len(t, conns, 2)
+	require.Eventually(t, func() bool {
+		return live.Load() == 1 // wedged connection is stopped in the background
+	}, time.Second, 10*time.Millisecond)
+	require.Equal(t, conns[1], p.conn)
+	require.Nil(t, p.wedged)
+	require.Less(t, p.stalled(time.Now()), time.Second)
+
+	// offline source is not stalled
+	p.stop()
+	require.Zero(t, p.stalled(time.Now().Add(time.Hour)))
+}
@@ -0,0 +1,166 @@
+package streams
+
+import (
+	"net/http"
+	"reflect"
+	"slices"
+
+	"github.com/AlexxIT/go2rtc/internal/api"
+	"github.com/AlexxIT/go2rtc/internal/app"
+)
+
+// ReloadResult - stream names affected by the config reload
+type ReloadResult struct {
+	Added     []string `json:"added"`
+	Removed   []string `json:"removed"`
+	Restarted []string `json:"restarted"`
+}
+
+// configured - stream items from the last loaded config, for the reload diff
+var configured map[string]any
+
+// Reload - apply the new streams config with minimal changes. Streams with
+// the same config keep producers and consumers untouched. Streams created
+// from API and not present in the config are not touched too.
+func Reload(items map[string]any) *ReloadResult {
+	res := &ReloadResult{Added: []string{}, Removed: []string{}, Restarted: []string{}}
+
+	var removed []*Stream
+	var changed = map[string]*Stream{}
+
+	streamsMu.Lock()
+	for name := range configured {
+		if _, ok := items[name]; ok {
+			continue
+		}
+		if stream := streams[name]; stream != nil && stream.name == name {
+			removed = append(removed, stream)
+			delete(streams, name)
+			app.StreamLog.Delete(name)
+			res.Removed = append(res.Removed, name)
+		}
+	}
+
+	for name, item := range items {
+		stream := streams[name]
+		if stream == nil || stream.name != name {
+			streams[name] = NewStream(item)
+			streams[name].name = name
+			res.Added = append(res.Added, name)
+			continue
+		}
+
+		if old, ok := configured[name]; !ok || !reflect.DeepEqual(old, item) {
+			changed[name] = stream
+		}
+	}
+
+	configured = items
+	streamsMu.Unlock()
+
+	// producers can be busy with the dial, so apply changes without the global lock
+	for _, stream := range removed {
+		stream.stopAll()
+	}
+
+	for name, stream := range changed {
+		if stream.reload(NewStream(items[name])) {
+			res.Restarted = append(res.Restarted, name)
+		}
+	}
+
+	slices.Sort(res.Added)
+	slices.Sort(res.Removed)
+	slices.Sort(res.Restarted)
+
+	return res
+}
+
+// reload - take sources and tags from the new config. With the same number of
+// sources running producers reconnect to the new URLs and keep consumers,
+// otherwise all producers and consumers are stopped. Returns false if only
+// tags were changed.
+func (s *Stream) reload(next *Stream) bool {
+	s.mu.Lock()
+	s.tags = next.tags
+
+	if len(s.producers) == len(next.producers) {
+		producers := s.producers
+		s.mu.Unlock()
+
+		var restarted bool
+		for i, prod := range producers {
+			if prod.reload(next.producers[i]) {
+				restarted = true
+			}
+		}
+		return restarted
+	}
+	s.mu.Unlock()
+
+	s.stopAll()
+
+	s.mu.Lock()
+	s.producers = next.producers
+	for _, prod := range s.producers {
+		prod.stream = s
+	}
+	s.mu.Unlock()
+
+	return true
+}
+
+// stopAll - stop all consumers and producers of the stream
+func (s *Stream) stopAll() {
+	s.mu.Lock()
+	consumers := s.consumers
+	s.consumers = nil
+	s.started = nil
+	for _, producer := range s.producers {
+		producer.stop()
+	}
+	s.mu.Unlock()
+
+	for _, consumer := range consumers {
+		_ = consumer.Stop()
+	}
+}
+
+// reload - new source from the config, running connection moves to the new
+// source with the same tracks, waiting reconnect retries immediately
+func (p *Producer) reload(next *Producer) bool {
+	p.mu.Lock()
+	if p.state == stateExternal || p.url == next.url && p.template == next.template {
+		p.mu.Unlock()
+		return false
+	}
+	p.url = next.url
+	p.template = next.template
+	p.authFails = 0 // new source - new chance
+	p.resetAttempts()
+	p.mu.Unlock()
+
+	p.restart()
+	p.retryNow()
+
+	return true
+}
+
+func apiStreamsReload(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var cfg struct {
+		Streams map[string]any `yaml:"streams"`
+	}
+	app.ReloadConfig(&cfg)
+
+	res := Reload(cfg.Streams)
+
+	log.Info().Strs("added", res.Added).Strs("removed", res.Removed).
+		Strs("restarted", res.Restarted).Msg("[streams] reload config")
+
+	api.ResponseJSON(w, res)
+}
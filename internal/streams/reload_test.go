@@ -0,0 +1,97 @@
+package streams
+
+import (
+	"strconv"
+	"sync/atomic"
+	"testing"
+
+	"github.com/AlexxIT/go2rtc/pkg/core"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReload(t *testing.T) {
+	res := Reload(map[string]any{
+		"reload_same":    "rtsp://192.168.1.123/stream1",
+		"reload_url":     "rtsp://192.168.1.123/stream2",
+		"reload_sources": "rtsp://192.168.1.123/stream3",
+		"reload_tags":    map[string]any{"url": "rtsp://192.168.1.123/stream4", "tags": "yard"},
+		"reload_removed": "rtsp://192.168.1.123/stream5",
+	})
+	require.Len(t, res.Added, 5)
+
+	// stream from API, not from the config
+	streamsMu.Lock()
+	streams["reload_api"] = NewStream("rtsp://192.168.1.123/stream6")
+	streams["reload_api"].name = "reload_api"
+	streamsMu.Unlock()
+
+	same := Get("reload_same")
+	sameProd := same.producers[0]
+	url := Get("reload_url")
+
+	res = Reload(map[string]any{
+		"reload_same":    "rtsp://192.168.1.123/stream1",
+		"reload_url":     "rtsp://192.168.1.123/stream2_new",
+		"reload_sources": []any{"rtsp://192.168.1.123/stream3", "ffmpeg:reload_same#audio=opus"},
+		"reload_tags":    map[string]any{"url": "rtsp://192.168.1.123/stream4", "tags": "garage"},
+		"reload_added":   "rtsp://192.168.1.123/stream7",
+	})
+	require.Equal(t, []string{"reload_added"}, res.Added)
+	require.Equal(t, []string{"reload_removed"}, res.Removed)
+	require.Equal(t, []string{"reload_sources", "reload_url"}, res.Restarted)
+
+	// unchanged stream keeps the same producer
+	require.True(t, same == Get("reload_same"))
+	require.True(t, sameProd == same.producers[0])
+
+	// changed stream keeps the same object with the new source
+	require.True(t, url == Get("reload_url"))
+	require.Equal(t, []string{"rtsp://192.168.1.123/stream2_new"}, url.Sources())
+
+	require.Len(t, Get("reload_sources").Sources(), 2)
+	require.Equal(t, []string{"garage"}, Get("reload_tags").Tags())
+	require.Nil(t, Get("reload_removed"))
+
+	// streams from API are not touched
+	require.NotNil(t, Get("reload_api"))
+}
+
+func TestReloadStreaming(t *testing.T) {
+	var live atomic.Int32
+
+	HandleFunc("reloading", func(url string) (core.Producer, error) {
+		live.Add(1)
+		return &testProducer{
+			Connection: core.Connection{
+				Medias: []*core.Media{
+					{Kind: core.KindVideo, Direction: core.DirectionRecvonly, Codecs: []*core.Codec{{Name: core.CodecH264, ClockRate: 90000}}},
+				},
+			},
+			done: make(chan struct{}),
+			live: &live,
+		}, nil
+	})
+
+	Reload(map[string]any{"reload_live": "reloading:camera0"})
+
+	stream := Get("reload_live")
+	cons := newTestConsumer()
+	require.Nil(t, stream.AddConsumer(cons))
+
+	// API readers use the source while the config is reloaded
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 100; i++ {
+			_ = stream.Sources()
+		}
+	}()
+
+	for i := 1; i <= 20; i++ {
+		Reload(map[string]any{"reload_live": "reloading:camera" + strconv.Itoa(i)})
+	}
+	<-done
+
+	require.Equal(t, []string{"reloading:camera20"}, stream.Sources())
+	stream.RemoveConsumer(cons)
+}
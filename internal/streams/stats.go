@@ -0,0 +1,178 @@
+package streams
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/AlexxIT/go2rtc/internal/api"
+	"github.com/AlexxIT/go2rtc/pkg/core"
+	"github.com/AlexxIT/go2rtc/pkg/creds"
+)
+
+// ConsumerStats - summary of the consumer for finding misbehaving clients,
+// counters are summed from all consumer senders
+type ConsumerStats struct {
+	ID         uint32           `json:"id"`
+	Type       string           `json:"type,omitempty"` // webrtc, rtsp, hls, mp4...
+	Protocol   string           `json:"protocol,omitempty"`
+	RemoteAddr string           `json:"remote_addr,omitempty"`
+	UserAgent  string           `json:"user_agent,omitempty"`
+	Kinds      []string         `json:"kinds,omitempty"`  // requested media kinds, all if empty
+	Codecs     []map[string]any `json:"codecs,omitempty"` // negotiated codecs
+	Bytes      int              `json:"bytes_send"`
+	Packets    int              `json:"packets_send"`
+	Drops      int              `json:"drops"`  // packets dropped because consumer is too slow
+	Queue      int              `json:"queue"`  // packets waiting in the senders queues
+	Buffer     int              `json:"buffer"` // queue depth of all senders
+	QueueBytes int              `json:"queue_bytes"`
+	Uptime     float64          `json:"uptime"` // in seconds
+}
+
+// consumerStarted - remember the start time for the consumer uptime, under the stream lock
+func (s *Stream) consumerStarted(cons core.Consumer) {
+	if s.started == nil {
+		s.started = map[core.Consumer]time.Time{}
+	}
+	s.started[cons] = time.Now()
+}
+
+// ConsumersStats - stats for all consumers of the stream
+func (s *Stream) ConsumersStats() []*ConsumerStats {
+	s.mu.Lock()
+	consumers := make([]core.Consumer, len(s.consumers))
+	copy(consumers, s.consumers)
+	started := make([]time.Time, len(s.consumers))
+	for i, cons := range consumers {
+		started[i] = s.started[cons]
+	}
+	s.mu.Unlock()
+
+	now := time.Now()
+
+	stats := make([]*ConsumerStats, 0, len(consumers))
+	for i, cons := range consumers {
+		c, err := marshalConn(cons)
+		if err != nil {
+			continue
+		}
+
+		stat := &ConsumerStats{
+			ID:         c.ID,
+			Type:       c.FormatName,
+			Protocol:   c.Protocol,
+			RemoteAddr: c.RemoteAddr,
+			UserAgent:  c.UserAgent,
+			Kinds:      c.Kinds,
+		}
+		for _, sender := range c.Senders {
+			stat.Codecs = append(stat.Codecs, sender.Codec)
+			stat.Bytes += sender.Bytes
+			stat.Packets += sender.Packets
+			stat.Drops += sender.Drops
+			stat.Queue += sender.Queue
+			stat.Buffer += sender.Buffer
+			stat.QueueBytes += sender.QueueBytes
+		}
+		if c.BytesSend > 0 {
+			stat.Bytes = c.BytesSend // real bytes on the wire, with the container overhead
+		}
+		if !started[i].IsZero() {
+			stat.Uptime = now.Sub(started[i]).Round(time.Millisecond).Seconds()
+		}
+		stats = append(stats, stat)
+	}
+	return stats
+}
+
+// StreamStats - summary of the stream for metrics reporters, counters are summed
+// from running producers and from all consumers
+type StreamStats struct {
+	Producers   int // running producers
+	Consumers   int
+	BytesRecv   int
+	PacketsRecv int
+	BytesSend   int
+	PacketsSend int
+	Drops       int // packets dropped because consumers are too slow
+	BufferBytes int // memory of consumers queues and prebuffers and held frames, same as for the budget
+}
+
+func (s *Stream) Stats() *StreamStats {
+	s.mu.Lock()
+	var producers []core.Producer
+	stats := &StreamStats{}
+	for _, prod := range s.producers {
+		prod.mu.Lock()
+		if prod.conn != nil {
+			producers = append(producers, prod.conn)
+		}
+		prod.mu.Unlock()
+	}
+	s.mu.Unlock()
+
+	for _, prod := range producers {
+		c, err := marshalConn(prod)
+		if err != nil {
+			continue
+		}
+
+		stats.Producers++
+		stats.BytesRecv += c.recvBytes()
+		for _, recv := range c.Receivers {
+			stats.PacketsRecv += recv.Packets
+		}
+	}
+
+	for _, cons := range s.ConsumersStats() {
+		stats.Consumers++
+		stats.BytesSend += cons.Bytes
+		stats.PacketsSend += cons.Packets
+		stats.Drops += cons.Drops
+	}
+
+	stats.BufferBytes = s.buffers().bytes
+
+	return stats
+}
+
+// recvBytes - real bytes on the wire if the producer counts them, or payloads of all tracks
+func (c *conn) recvBytes() int {
+	if c.BytesRecv > 0 {
+		return c.BytesRecv
+	}
+	var bytes int
+	for _, recv := range c.Receivers {
+		bytes += recv.Bytes
+	}
+	return bytes
+}
+
+// apiConsumers - stats of stream consumers, optional filter by consumer id
+func apiConsumers(w http.ResponseWriter, r *http.Request) {
+	w = creds.SecretResponse(w)
+
+	query := r.URL.Query()
+
+	stream := Get(query.Get("src"))
+	if stream == nil {
+		http.Error(w, "", http.StatusNotFound)
+		return
+	}
+
+	stats := stream.ConsumersStats()
+
+	if s := query.Get("id"); s != "" {
+		id, _ := strconv.ParseUint(s, 10, 32)
+		for _, stat := range stats {
+			if stat.ID == uint32(id) {
+				api.ResponseJSON(w, stat)
+				return
+			}
+		}
+		http.Error(w, "", http.StatusNotFound)
+		return
+	}
+
+	api.ResponseJSON(w, stats)
+}
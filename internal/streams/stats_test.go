@@ -0,0 +1,80 @@
+package streams
+
+import (
+	"testing"
+
+	"github.com/AlexxIT/go2rtc/pkg/core"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConsumersStats(t *testing.T) {
+	cons := newTestConsumer()
+	cons.ID = 123
+	cons.FormatName = "webrtc"
+	cons.RemoteAddr = "192.168.1.2:5000"
+
+	media := cons.Medias[0]
+	for i := 0; i < 2; i++ {
+		sender := core.NewSender(media, &core.Codec{Name: core.CodecH264, ClockRate: 90000})
+		sender.Bytes = 1000
+		sender.Packets = 10
+		sender.Drops = i
+		cons.Senders = append(cons.Senders, sender)
+	}
+
+	stream := NewStream(nil)
+	stream.AddInternalConsumer(cons)
+
+	stats := stream.ConsumersStats()
+	require.Len(t, stats, 1)
+
+	stat := stats[0]
+	require.Equal(t, uint32(123), stat.ID)
+	require.Equal(t, "webrtc", stat.Type)
+	require.Equal(t, "192.168.1.2:5000", stat.RemoteAddr)
+	require.Equal(t, 2000, stat.Bytes)
+	require.Equal(t, 20, stat.Packets)
+	require.Equal(t, 1, stat.Drops)
+	require.Len(t, stat.Codecs, 2)
+	require.Equal(t, "h264", stat.Codecs[0]["codec_name"])
+	require.GreaterOrEqual(t, stat.Uptime, 0.0)
+
+	// real bytes on the wire have priority
+	cons.Send = 2500
+	require.Equal(t, 2500, stream.ConsumersStats()[0].Bytes)
+
+	// summary for metrics reporters
+	require.Equal(t, &StreamStats{Consumers: 1, BytesSend: 2500, PacketsSend: 20, Drops: 1}, stream.Stats())
+
+	stream.RemoveInternalConsumer(cons)
+	require.Empty(t, stream.ConsumersStats())
+	require.Empty(t, stream.started)
+}
+
+func TestConsumerBuffers(t *testing.T) {
+	ConsumerBuffers = map[string]int{"webrtc": 16}
+	defer func() { ConsumerBuffers = nil }()
+
+	cons := newTestConsumer()
+	cons.FormatName = "webrtc"
+	for i := 0; i < 2; i++ {
+		sender := core.NewSender(cons.Medias[0], &core.Codec{Name: core.CodecH264, ClockRate: 90000})
+		cons.Senders = append(cons.Senders, sender)
+	}
+	setBuffer(cons)
+
+	stream := NewStream(nil)
+	stream.AddInternalConsumer(cons)
+
+	stat := stream.ConsumersStats()[0]
+	require.Equal(t, 32, stat.Buffer)
+	require.Equal(t, 0, stat.Queue)
+
+	// other consumer types keep the default
+	cons = newTestConsumer()
+	cons.FormatName = "mp4"
+	cons.Senders = append(cons.Senders, core.NewSender(cons.Medias[0], &core.Codec{Name: core.CodecAAC}))
+	setBuffer(cons)
+	_, size := cons.Senders[0].Queue()
+	require.Equal(t, 128, size)
+}
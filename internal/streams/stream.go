@@ -2,20 +2,46 @@ package streams
 
 import (
 	"encoding/json"
+	"slices"
+	"strings"
 	"sync"
 	"sync/atomic"
+	"time"
 
 	"github.com/AlexxIT/go2rtc/pkg/core"
 )
 
 type Stream struct {
+	name      string
+	tags      []string
 	producers []*Producer
 	consumers []core.Consumer
+	started   map[core.Consumer]time.Time // consumers start time for stats
 	mu        sync.Mutex
 	pending   atomic.Int32
+	muted     atomic.Bool // audio isn't forwarded to consumers
+	stopped   atomic.Bool // stopped by the API, new consumers are rejected
+	stopTimer *time.Timer
+	keepUntil time.Time // idle producers are kept until this time
 }
 
+// StopGrace - keep idle producers some time after the last consumer leaves,
+// so a quick consumer reconnect (ex. page reload) reuses the same connection
+var StopGrace time.Duration
+
+// ReconnectGrace - keep idle producers some time after the consumer with the broken
+// connection (ex. WebRTC failed), because such clients usually reconnect soon
+var ReconnectGrace time.Duration
+
 func NewStream(source any) *Stream {
+	s := newStream(source)
+	for _, prod := range s.producers {
+		prod.stream = s
+	}
+	return s
+}
+
+func newStream(source any) *Stream {
 	switch source := source.(type) {
 	case string:
 		return &Stream{
@@ -39,7 +65,14 @@ func NewStream(source any) *Stream {
 		}
 		return s
 	case map[string]any:
-		return NewStream(source["url"])
+		s := newStream(source["url"])
+		s.tags = parseTags(source["tags"])
+		if hold, _ := source["hold_frame"].(bool); hold {
+			for _, prod := range s.producers {
+				prod.hold = new(frameHold)
+			}
+		}
+		return s
 	case nil:
 		return new(Stream)
 	default:
@@ -50,18 +83,67 @@ func NewStream(source any) *Stream {
 func (s *Stream) Sources() []string {
 	sources := make([]string, 0, len(s.producers))
 	for _, prod := range s.producers {
-		sources = append(sources, prod.url)
+		sources = append(sources, prod.source())
 	}
 	return sources
 }
 
+// Tags - return stream tags from config, ex. zone or building of the camera
+func (s *Stream) Tags() []string {
+	return s.tags
+}
+
+func (s *Stream) HasTag(tag string) bool {
+	return slices.Contains(s.tags, tag)
+}
+
+// Reconnect - restart connections of all running producers without
+// dropping consumers, tracks are moved to the new connection
+func (s *Stream) Reconnect() {
+	s.mu.Lock()
+	for _, producer := range s.producers {
+		producer.restart()
+	}
+	s.mu.Unlock()
+}
+
+// Mute - stop (or continue) forwarding audio from all producers to consumers,
+// without renegotiation, ex. for the privacy toggle. Video is not affected.
+func (s *Stream) Mute(muted bool) {
+	s.muted.Store(muted)
+
+	s.mu.Lock()
+	for _, producer := range s.producers {
+		producer.muteAudio(muted)
+	}
+	s.mu.Unlock()
+}
+
+func (s *Stream) Muted() bool {
+	return s.muted.Load()
+}
+
 func (s *Stream) SetSource(source string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 	for _, prod := range s.producers {
 		prod.SetSource(source)
 	}
 }
 
 func (s *Stream) RemoveConsumer(cons core.Consumer) {
+	s.removeConsumer(cons)
+	s.stopProducers(StopGrace)
+}
+
+// DropConsumer - same as RemoveConsumer for the consumer that lost the connection,
+// idle producers are kept for the ReconnectGrace (but not less than StopGrace)
+func (s *Stream) DropConsumer(cons core.Consumer) {
+	s.removeConsumer(cons)
+	s.stopProducers(max(StopGrace, ReconnectGrace))
+}
+
+func (s *Stream) removeConsumer(cons core.Consumer) {
 	_ = cons.Stop()
 
 	s.mu.Lock()
@@ -71,13 +153,12 @@ func (s *Stream) RemoveConsumer(cons core.Consumer) {
 			break
 		}
 	}
+	delete(s.started, cons)
 	s.mu.Unlock()
-
-	s.stopProducers()
 }
 
 func (s *Stream) AddProducer(prod core.Producer) {
-	producer := &Producer{conn: prod, state: stateExternal, url: "external"}
+	producer := &Producer{conn: prod, state: stateExternal, url: "external", stream: s}
 	s.mu.Lock()
 	s.producers = append(s.producers, producer)
 	s.mu.Unlock()
@@ -94,13 +175,42 @@ func (s *Stream) RemoveProducer(prod core.Producer) {
 	s.mu.Unlock()
 }
 
-func (s *Stream) stopProducers() {
-	if s.pending.Load() > 0 {
-		log.Trace().Msg("[streams] skip stop pending producer")
+// stopProducers - stop idle producers after the grace, a shorter grace never
+// cancels a longer one from the previous consumer
+func (s *Stream) stopProducers(grace time.Duration) {
+	s.mu.Lock()
+	until := time.Now().Add(grace)
+	if until.Before(s.keepUntil) {
+		until = s.keepUntil
+	}
+	d := time.Until(until)
+	if d <= 0 {
+		s.mu.Unlock()
+		s.stopIdleProducers()
 		return
 	}
 
+	s.keepUntil = until
+	if s.stopTimer == nil {
+		s.stopTimer = time.AfterFunc(d, s.stopIdleProducers)
+	} else {
+		s.stopTimer.Reset(d)
+	}
+	s.mu.Unlock()
+}
+
+// stopIdleProducers - stop producers without consumers. Number of senders for
+// each track is a reference counter for the producer. Check it under the lock,
+// because AddConsumer can take tracks from the same producer in parallel.
+func (s *Stream) stopIdleProducers() {
+	var stopped bool
+
 	s.mu.Lock()
+	if s.pending.Load() > 0 {
+		s.mu.Unlock()
+		log.Trace().Msg("[streams] skip stop pending producer")
+		return
+	}
 producers:
 	for _, producer := range s.producers {
 		for _, track := range producer.receivers {
@@ -113,18 +223,85 @@ producers:
 				continue producers
 			}
 		}
-		producer.stop()
+		if producer.stop() {
+			stopped = true
+		}
 	}
 	s.mu.Unlock()
+
+	if stopped {
+		fireLifecycle(EventStop, s.name, ReasonIdle)
+	}
+}
+
+// endOfStream - source finished playback, stop the stream consumers as after a normal
+// close instead of waiting the timeout, the next consumer starts the playback again
+func endOfStream(prod *Producer) {
+	streamsMu.Lock()
+	var all []*Stream
+	for name, stream := range streams {
+		if stream.name == name {
+			all = append(all, stream)
+		}
+	}
+	streamsMu.Unlock()
+
+	for _, stream := range all {
+		if stream.hasProducer(prod) {
+			stream.stopAll()
+			fireLifecycle(EventStop, stream.name, ReasonEnded)
+			return
+		}
+	}
+}
+
+// stale - some producer repeats the last keyframe instead of the live video
+func (s *Stream) stale() bool {
+	for _, prod := range s.producers {
+		if prod.hold.holding() {
+			return true
+		}
+	}
+	return false
+}
+
+func parseTags(v any) (tags []string) {
+	switch v := v.(type) {
+	case string:
+		for _, tag := range strings.Split(v, ",") {
+			if tag = strings.TrimSpace(tag); tag != "" {
+				tags = append(tags, tag)
+			}
+		}
+	case []any:
+		for _, tag := range v {
+			if str, ok := tag.(string); ok {
+				tags = append(tags, str)
+			}
+		}
+	}
+	return
 }
 
 func (s *Stream) MarshalJSON() ([]byte, error) {
 	var info = struct {
-		Producers []*Producer     `json:"producers"`
-		Consumers []core.Consumer `json:"consumers"`
+		Tags      []string         `json:"tags,omitempty"`
+		Depends   []string         `json:"depends,omitempty"`
+		Stale     bool             `json:"stale,omitempty"` // last keyframe is repeated during reconnect
+		Muted     bool             `json:"muted,omitempty"` // audio isn't forwarded to consumers
+		Stopped   bool             `json:"stopped,omitempty"`
+		Producers []*Producer      `json:"producers"`
+		Consumers []core.Consumer  `json:"consumers"`
+		Stats     []*ConsumerStats `json:"consumers_stats,omitempty"`
 	}{
+		Tags:      s.tags,
+		Depends:   s.Depends(),
+		Stale:     s.stale(),
+		Muted:     s.muted.Load(),
+		Stopped:   s.stopped.Load(),
 		Producers: s.producers,
 		Consumers: s.consumers,
+		Stats:     s.ConsumersStats(),
 	}
 	return json.Marshal(info)
 }
@@ -9,29 +9,44 @@ import (
 )
 
 func TestRecursion(t *testing.T) {
+	HandleFunc("rtsp", func(url string) (core.Producer, error) { return nil, nil }) // bypass HasProducer
+
+	// other tests of the package also add streams
+	n := len(streams)
+	t.Cleanup(func() {
+		delete(streams, "from_yaml")
+		delete(streams, "rtsp://localhost:8554/from_yaml?video")
+	})
+
 	// create stream with some source
-	stream1 := New("from_yaml", "does_not_matter")
-	require.Len(t, streams, 1)
+	stream1, err := New("from_yaml", "rtsp://does_not_matter")
+	require.Nil(t, err)
+	require.Len(t, streams, n+1)
 
 	// ask another unnamed stream that links go2rtc
 	query, err := url.ParseQuery("src=rtsp://localhost:8554/from_yaml?video")
 	require.Nil(t, err)
-	stream2 := GetOrPatch(query)
+	stream2, err := GetOrPatch(query)
+	require.Nil(t, err)
 
 	// check stream is same
 	require.Equal(t, stream1, stream2)
 	// check stream urls is same
 	require.Equal(t, stream1.producers[0].url, stream2.producers[0].url)
-	require.Len(t, streams, 2)
+	require.Len(t, streams, n+2)
 }
 
 func TestTempate(t *testing.T) {
-	HandleFunc("rtsp", func(url string) (core.Producer, error) { return nil, nil }) // bypass HasProducer
+	HandleFunc("rtsp", func(url string) (core.Producer, error) { return nil, nil })   // bypass HasProducer
+	HandleFunc("ffmpeg", func(url string) (core.Producer, error) { return nil, nil }) // bypass HasProducer
+	t.Cleanup(func() { delete(streams, "camera.from_hass") })
 
 	// config from yaml
-	stream1 := New("camera.from_hass", "ffmpeg:{input}#video=copy")
+	stream1, err := New("camera.from_hass", "ffmpeg:{input}#video=copy")
+	require.Nil(t, err)
 	// request from hass
-	stream2 := Patch("camera.from_hass", "rtsp://example.com")
+	stream2, err := Patch("camera.from_hass", "rtsp://example.com")
+	require.Nil(t, err)
 
 	require.Equal(t, stream1, stream2)
 	require.Equal(t, "ffmpeg:rtsp://example.com#video=copy", stream1.producers[0].url)
@@ -3,11 +3,13 @@ package streams
 import (
 	"errors"
 	"net/url"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/AlexxIT/go2rtc/internal/api"
 	"github.com/AlexxIT/go2rtc/internal/app"
+	"github.com/AlexxIT/go2rtc/pkg/pcm"
 	"github.com/rs/zerolog"
 )
 
@@ -16,18 +18,102 @@ func Init() {
 		Streams map[string]any    `yaml:"streams"`
 		Publish map[string]any    `yaml:"publish"`
 		Preload map[string]string `yaml:"preload"`
+		Buffers map[string]int    `yaml:"buffers"`        // queue depth by consumer type
+		Memory  int               `yaml:"buffers_memory"` // in megabytes
+
+		Prebuffer map[string]PrebufferConfig `yaml:"prebuffer"` // startup buffer by consumer type
+
+		Reconnect struct {
+			MaxConcurrent int  `yaml:"max_concurrent"`
+			MaxAttempts   int  `yaml:"max_attempts"`
+			Window        int  `yaml:"attempts_window"` // in seconds
+			ConsumerGrace int  `yaml:"consumer_grace"`  // in seconds
+			StopGrace     int  `yaml:"stop_grace"`      // in seconds
+			MaxPanics     *int `yaml:"max_panics"`
+			Deadman       int  `yaml:"deadman"` // in seconds
+
+			HostRate  float64 `yaml:"host_rate"` // attempts per minute for each camera host
+			HostBurst int     `yaml:"host_burst"`
+		} `yaml:"reconnect"`
+
+		Overload struct {
+			CPU       float64  `yaml:"cpu"` // percent of all CPU cores
+			Recover   float64  `yaml:"recover"`
+			Duration  int      `yaml:"duration"` // in seconds
+			Temporal  int      `yaml:"temporal"`
+			Consumers []string `yaml:"consumers"`
+		} `yaml:"overload"`
 	}
 
 	app.LoadConfig(&cfg)
 
 	log = app.GetLogger("streams")
 
+	reconnects.limit = cfg.Reconnect.MaxConcurrent
+
+	MaxReconnects = cfg.Reconnect.MaxAttempts
+	if cfg.Reconnect.Window > 0 {
+		ReconnectWindow = time.Duration(cfg.Reconnect.Window) * time.Second
+	}
+	ReconnectGrace = time.Duration(cfg.Reconnect.ConsumerGrace) * time.Second
+	StopGrace = time.Duration(cfg.Reconnect.StopGrace) * time.Second
+	if cfg.Reconnect.MaxPanics != nil {
+		MaxPanics = *cfg.Reconnect.MaxPanics
+	}
+	if cfg.Reconnect.Deadman > 0 {
+		DeadmanTimeout = time.Duration(cfg.Reconnect.Deadman) * time.Second
+		go deadmanWorker()
+	}
+	hosts.rate = cfg.Reconnect.HostRate / 60
+	hosts.burst = cfg.Reconnect.HostBurst
+
+	ConsumerBuffers = cfg.Buffers
+	ConsumerPrebuffers = cfg.Prebuffer
+	if cfg.Memory > 0 {
+		BufferMemory = cfg.Memory << 20
+		go budgetWorker()
+	}
+
+	if cfg.Overload.CPU > 0 {
+		OverloadCPU = cfg.Overload.CPU
+		OverloadRecover = cfg.Overload.Recover
+		if OverloadRecover <= 0 || OverloadRecover > OverloadCPU {
+			OverloadRecover = OverloadCPU * 3 / 4
+		}
+		if cfg.Overload.Duration > 0 {
+			OverloadDuration = time.Duration(cfg.Overload.Duration) * time.Second
+		}
+		if cfg.Overload.Temporal > 0 {
+			OverloadTemporal = cfg.Overload.Temporal
+		}
+		OverloadConsumers = cfg.Overload.Consumers
+		go overloadWorker()
+	}
+
+	go statsWorker()
+	go bitrateWorker()
+
+	// pure-Go G.711 and PCM, other modules can register FFmpeg or others
+	RegisterTranscoder(pcm.Transcoder{})
+
 	for name, item := range cfg.Streams {
 		streams[name] = NewStream(item)
+		streams[name].name = name
 	}
 
+	configured = cfg.Streams
+
 	api.HandleFunc("api/streams", apiStreams)
 	api.HandleFunc("api/streams.dot", apiStreamsDOT)
+	api.HandleFunc("api/streams/batch", apiStreamsBatch)
+	api.HandleFunc("api/streams/bitrate", apiBitrate)
+	api.HandleFunc("api/streams/consumers", apiConsumers)
+	api.HandleFunc("api/streams/debug", apiStreamsDebug)
+	api.HandleFunc("api/streams/dtmf", apiStreamsDTMF)
+	api.HandleFunc("api/streams/log", apiStreamsLog)
+	api.HandleFunc("api/streams/reconnect", apiStreamsReconnect)
+	api.HandleFunc("api/streams/mute", apiStreamsMute)
+	api.HandleFunc("api/streams/reload", apiStreamsReload)
 	api.HandleFunc("api/preload", apiPreload)
 	api.HandleFunc("api/schemes", apiSchemes)
 
@@ -51,6 +137,11 @@ func Init() {
 }
 
 func New(name string, sources ...string) (*Stream, error) {
+	return NewTagged(name, nil, sources...)
+}
+
+// NewTagged - same as New, tags are set before the stream is available, ex. for discovered streams
+func NewTagged(name string, tags []string, sources ...string) (*Stream, error) {
 	for _, source := range sources {
 		if !HasProducer(source) {
 			return nil, errors.New("streams: source not supported")
@@ -62,6 +153,8 @@ func New(name string, sources ...string) (*Stream, error) {
 	}
 
 	stream := NewStream(sources)
+	stream.name = name
+	stream.tags = tags
 
 	streamsMu.Lock()
 	streams[name] = stream
@@ -111,6 +204,7 @@ func Patch(name string, source string) (*Stream, error) {
 
 	// create new stream with this name
 	stream := NewStream(source)
+	stream.name = name
 	streams[name] = stream
 	return stream, nil
 }
@@ -123,7 +217,7 @@ func GetOrPatch(query url.Values) (*Stream, error) {
 	}
 
 	// check if src is stream name
-	if stream := Get(source); stream != nil {
+	if stream := GetQuality(source, query.Get("quality")); stream != nil {
 		return stream, nil
 	}
 
@@ -149,10 +243,48 @@ func Get(name string) *Stream {
 	return streams[name]
 }
 
+// SubstreamSuffix - naming convention for linking main and sub stream, ex. `camera1` and `camera1_sub`
+var SubstreamSuffix = "_sub"
+
+const QualityLow = "low"
+
+// QualityAuto - main stream, or substream while go2rtc is overloaded, see OverloadCPU
+const QualityAuto = "auto"
+
+// GetQuality - return linked substream for consumers with low quality hint, ex. grid of cameras UI.
+// Fallback to main stream if substream not configured.
+func GetQuality(name, quality string) *Stream {
+	if quality == QualityAuto && overloaded.Load() {
+		quality = QualityLow // lower requests while go2rtc sheds load
+	}
+	if quality == QualityLow && !strings.HasSuffix(name, SubstreamSuffix) {
+		if stream := Get(name + SubstreamSuffix); stream != nil {
+			log.Trace().Msgf("[streams] use substream for name=%s", name)
+			return stream
+		}
+	}
+	return Get(name)
+}
+
 func Delete(name string) {
 	streamsMu.Lock()
 	defer streamsMu.Unlock()
 	delete(streams, name)
+	app.StreamLog.Delete(name)
+}
+
+// GetByTag - return all streams with tag, aliases are skipped
+func GetByTag(tag string) map[string]*Stream {
+	streamsMu.Lock()
+	defer streamsMu.Unlock()
+
+	tagged := map[string]*Stream{}
+	for name, stream := range streams {
+		if stream.name == name && stream.HasTag(tag) {
+			tagged[name] = stream
+		}
+	}
+	return tagged
 }
 
 func GetAllNames() []string {
@@ -0,0 +1,49 @@
+package streams
+
+import (
+	"github.com/AlexxIT/go2rtc/pkg/core"
+)
+
+var transcoders []core.Transcoder
+
+// RegisterTranscoder - add codec adaptation for consumers without the source codec,
+// transcoders are checked in the registration order
+func RegisterTranscoder(t core.Transcoder) {
+	transcoders = append(transcoders, t)
+}
+
+// matchTranscoder - like MatchMedia, but the codecs are converted by the transcoder,
+// the source is the producer media for recvonly and the consumer media for backchannel
+func matchTranscoder(prodMedia, consMedia *core.Media) (t core.Transcoder, prodCodec, consCodec *core.Codec) {
+	if prodMedia.Kind != consMedia.Kind || prodMedia.Direction == consMedia.Direction {
+		return
+	}
+
+	src, dst := prodMedia, consMedia
+	if prodMedia.Direction == core.DirectionSendonly {
+		src, dst = consMedia, prodMedia
+	}
+
+	for _, t = range transcoders {
+		for _, srcCodec := range src.Codecs {
+			for _, dstCodec := range dst.Codecs {
+				if out := t.Output(dstCodec, srcCodec); out != nil {
+					if src == prodMedia {
+						return t, srcCodec, out
+					}
+					return t, out, srcCodec
+				}
+			}
+		}
+	}
+	return nil, nil, nil
+}
+
+// transcodeTrack - receiver with the converted packets of the track, it is closed
+// with the last sender, so the source track doesn't feed the transcoder without readers
+func transcodeTrack(t core.Transcoder, media *core.Media, out *core.Codec, track *core.Receiver) *core.Receiver {
+	transcoded := core.NewReceiver(media, out)
+	transcoded.Input = t.Handler(out, track.Codec, transcoded.Input)
+	transcoded.Node.WithParent(&track.Node)
+	return transcoded
+}
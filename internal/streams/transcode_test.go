@@ -0,0 +1,78 @@
+package streams
+
+import (
+	"sync/atomic"
+	"testing"
+
+	"github.com/AlexxIT/go2rtc/pkg/core"
+	"github.com/AlexxIT/go2rtc/pkg/pcm"
+	"github.com/pion/rtp"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTranscode(t *testing.T) {
+	RegisterTranscoder(pcm.Transcoder{})
+	defer func() { transcoders = nil }()
+
+	var live atomic.Int32
+	var prod *testProducer
+
+	HandleFunc("transcode", func(url string) (core.Producer, error) {
+		live.Add(1)
+		prod = &testProducer{
+			Connection: core.Connection{
+				Medias: []*core.Media{
+					{Kind: core.KindAudio, Direction: core.DirectionRecvonly, Codecs: []*core.Codec{{Name: core.CodecPCMU, ClockRate: 16000}}},
+				},
+			},
+			done: make(chan struct{}),
+			live: &live,
+		}
+		return prod, nil
+	})
+
+	stream := NewStream("transcode:camera1")
+
+	cons := &testConsumer{Connection: core.Connection{
+		Medias: []*core.Media{
+			{Kind: core.KindAudio, Direction: core.DirectionSendonly, Codecs: []*core.Codec{{Name: core.CodecPCMA}}},
+		},
+	}}
+	require.Nil(t, stream.AddConsumer(cons))
+	require.Len(t, cons.Senders, 1)
+
+	sender := cons.Senders[0]
+	require.Equal(t, &core.Codec{Name: core.CodecPCMA, ClockRate: 8000}, sender.Codec)
+
+	packets := make(chan *rtp.Packet, 1)
+	sender.Handler = func(packet *core.Packet) {
+		packets <- packet
+	}
+
+	prod.Receivers[0].WriteRTP(&rtp.Packet{Payload: make([]byte, 320)})
+	packet := <-packets
+	require.Len(t, packet.Payload, 160) // half of the clock rate
+
+	// transcoder is closed with the last consumer
+	stream.RemoveConsumer(cons)
+	require.Zero(t, prod.Receivers[0].Len())
+	require.Equal(t, int32(0), live.Load())
+}
+
+func TestMatchTranscoder(t *testing.T) {
+	RegisterTranscoder(pcm.Transcoder{})
+	defer func() { transcoders = nil }()
+
+	prodMedia := &core.Media{Kind: core.KindAudio, Direction: core.DirectionRecvonly, Codecs: []*core.Codec{{Name: core.CodecPCMA, ClockRate: 8000}}}
+	consMedia := &core.Media{Kind: core.KindAudio, Direction: core.DirectionSendonly, Codecs: []*core.Codec{{Name: core.CodecOpus}}}
+	transcoder, _, _ := matchTranscoder(prodMedia, consMedia)
+	require.Nil(t, transcoder)
+
+	// backchannel: consumer audio is the source
+	prodMedia = &core.Media{Kind: core.KindAudio, Direction: core.DirectionSendonly, Codecs: []*core.Codec{{Name: core.CodecPCMU, ClockRate: 8000}}}
+	consMedia = &core.Media{Kind: core.KindAudio, Direction: core.DirectionRecvonly, Codecs: []*core.Codec{{Name: core.CodecPCML, ClockRate: 16000}}}
+	transcoder, prodCodec, consCodec := matchTranscoder(prodMedia, consMedia)
+	require.NotNil(t, transcoder)
+	require.Equal(t, prodMedia.Codecs[0], prodCodec)
+	require.Equal(t, consMedia.Codecs[0], consCodec)
+}
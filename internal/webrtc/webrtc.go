@@ -171,15 +171,23 @@ func asyncHandler(tr *ws.Transport, msg *ws.Message) (err error) {
 	conn.Mode = mode
 	conn.Protocol = "ws"
 	conn.UserAgent = tr.Request.UserAgent()
+	var dropped bool // connection lost, client will probably reconnect
 	conn.Listen(func(msg any) {
 		switch msg := msg.(type) {
 		case pion.PeerConnectionState:
+			if msg == pion.PeerConnectionStateDisconnected || msg == pion.PeerConnectionStateFailed {
+				dropped = true
+			}
 			if msg != pion.PeerConnectionStateClosed {
 				return
 			}
 			switch mode {
 			case core.ModePassiveConsumer:
-				stream.RemoveConsumer(conn)
+				if dropped {
+					stream.DropConsumer(conn)
+				} else {
+					stream.RemoveConsumer(conn)
+				}
 			case core.ModePassiveProducer:
 				stream.RemoveProducer(conn)
 			}
@@ -206,6 +214,24 @@ func asyncHandler(tr *ws.Transport, msg *ws.Message) (err error) {
 
 	switch mode {
 	case core.ModePassiveConsumer:
+		// only audio or only video, ex. media=audio
+		conn.Kinds = core.ParseKinds(query.Get("media"))
+
+		// backchannel audio over the DataChannel, ex. talkback=PCML/16000
+		if s := query.Get("talkback"); s != "" {
+			codec := core.ParseCodecString(s)
+			if codec == nil {
+				err = errors.New("webrtc: unsupported talkback codec")
+			} else {
+				err = conn.SetTalkback(codec)
+			}
+			if err != nil {
+				log.Warn().Err(err).Caller().Send()
+				_ = conn.Close()
+				return err
+			}
+		}
+
 		// 2. AddConsumer, so we get new tracks
 		if err = stream.AddConsumer(conn); err != nil {
 			log.Debug().Err(err).Msg("[webrtc] add consumer")
@@ -251,16 +277,22 @@ func ExchangeSDP(stream *streams.Stream, offer, desc, userAgent string) (answer
 	conn.FormatName = desc
 	conn.UserAgent = userAgent
 	conn.Protocol = "http"
+	var dropped bool
 	conn.Listen(func(msg any) {
 		switch msg := msg.(type) {
 		case pion.PeerConnectionState:
+			if msg == pion.PeerConnectionStateDisconnected || msg == pion.PeerConnectionStateFailed {
+				dropped = true
+			}
 			if msg != pion.PeerConnectionStateClosed {
 				return
 			}
-			if conn.Mode == core.ModePassiveConsumer {
-				stream.RemoveConsumer(conn)
-			} else {
+			if conn.Mode != core.ModePassiveConsumer {
 				stream.RemoveProducer(conn)
+			} else if dropped {
+				stream.DropConsumer(conn)
+			} else {
+				stream.RemoveConsumer(conn)
 			}
 		}
 	})
@@ -5,6 +5,7 @@ import (
 	"testing"
 
 	"github.com/AlexxIT/go2rtc/pkg/core"
+	"github.com/pion/rtp"
 	"github.com/stretchr/testify/require"
 )
 
@@ -50,3 +51,31 @@ func TestEncodeConfig(t *testing.T) {
 	conf = EncodeConfig(TypeAACLC, 8000, 1, false)
 	require.Equal(t, "1588", hex.EncodeToString(conf))
 }
+
+func TestLATM(t *testing.T) {
+	codec := &core.Codec{Name: core.CodecAAC, FmtpLine: FMTP + "1210"} // AAC-LC 44100 2ch
+	conf := CodecToConfig(codec)
+	require.Equal(t, []byte{0x12, 0x10}, conf)
+
+	unit := make([]byte, 300)
+	for i := range unit {
+		unit[i] = byte(i)
+	}
+
+	b := EncodeLATM(conf, unit)
+	require.True(t, IsLOAS(b))
+
+	size := int(b[1]&0x1F)<<8 | int(b[2])
+	require.Equal(t, len(b)-LOASHeaderSize, size)
+
+	// 1 bit useSameStreamMux + 15 bits StreamMuxConfig header + 16 bits config
+	// + 13 bits tail + 16 bits PayloadLengthInfo (255 + 45) = 61 bits
+	require.Equal(t, LOASHeaderSize+(61+len(unit)*8+7)/8, len(b))
+
+	var out []byte
+	handler := EncodeToLATM(codec, func(packet *rtp.Packet) {
+		out = packet.Payload
+	})
+	handler(&rtp.Packet{Payload: unit})
+	require.Equal(t, b, out)
+}
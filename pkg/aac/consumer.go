@@ -13,6 +13,15 @@ type Consumer struct {
 }
 
 func NewConsumer() *Consumer {
+	return newConsumer("adts")
+}
+
+// NewLATMConsumer - same as NewConsumer, but with LOAS/LATM framing instead of ADTS
+func NewLATMConsumer() *Consumer {
+	return newConsumer("latm")
+}
+
+func newConsumer(format string) *Consumer {
 	medias := []*core.Media{
 		{
 			Kind:      core.KindAudio,
@@ -26,7 +35,7 @@ func NewConsumer() *Consumer {
 	return &Consumer{
 		Connection: core.Connection{
 			ID:         core.NewID(),
-			FormatName: "adts",
+			FormatName: format,
 			Medias:     medias,
 			Transport:  wr,
 		},
@@ -43,9 +52,14 @@ func (c *Consumer) AddTrack(media *core.Media, codec *core.Codec, track *core.Re
 		}
 	}
 
-	if track.Codec.IsRTP() {
+	switch {
+	case c.FormatName == "latm" && track.Codec.IsRTP():
+		sender.Handler = RTPToLATM(track.Codec, sender.Handler)
+	case c.FormatName == "latm":
+		sender.Handler = EncodeToLATM(track.Codec, sender.Handler)
+	case track.Codec.IsRTP():
 		sender.Handler = RTPToADTS(track.Codec, sender.Handler)
-	} else {
+	default:
 		sender.Handler = EncodeToADTS(track.Codec, sender.Handler)
 	}
 
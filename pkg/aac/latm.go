@@ -0,0 +1,96 @@
+package aac
+
+import (
+	"encoding/hex"
+
+	"github.com/AlexxIT/go2rtc/pkg/bits"
+	"github.com/AlexxIT/go2rtc/pkg/core"
+	"github.com/pion/rtp"
+)
+
+const LOASHeaderSize = 3
+
+// CodecToConfig - return AudioSpecificConfig from codec FMTP line
+func CodecToConfig(codec *core.Codec) []byte {
+	s := core.Between(codec.FmtpLine, "config=", ";")
+	conf, err := hex.DecodeString(s)
+	if err != nil {
+		return nil
+	}
+	return conf
+}
+
+func IsLOAS(b []byte) bool {
+	// AudioSyncStream syncword 0x2B7 (11 bits)
+	return len(b) > LOASHeaderSize && b[0] == 0x56 && b[1]&0xE0 == 0xE0
+}
+
+// EncodeLATM - wrap raw AU to LOAS AudioSyncStream with in-band StreamMuxConfig,
+// so every frame can be decoded independently (like ADTS)
+// https://wiki.multimedia.cx/index.php/Understanding_AAC
+func EncodeLATM(conf, unit []byte) []byte {
+	wr := bits.NewWriter(make([]byte, 0, LOASHeaderSize+len(conf)+len(unit)/255+len(unit)+8))
+
+	wr.WriteBits16(0x2B7, 11) // AudioSyncStream syncword
+	wr.WriteBits16(0, 13)     // audioMuxLengthBytes
+
+	// AudioMuxElement(muxConfigPresent=1)
+	wr.WriteBit(0) // useSameStreamMux
+
+	// StreamMuxConfig
+	wr.WriteBit(0)      // audioMuxVersion
+	wr.WriteBit(1)      // allStreamsSameTimeFraming
+	wr.WriteBits8(0, 6) // numSubFrames
+	wr.WriteBits8(0, 4) // numProgram
+	wr.WriteBits8(0, 3) // numLayer
+	for _, b := range conf {
+		wr.WriteBits8(b, 8) // AudioSpecificConfig
+	}
+	wr.WriteBits8(0, 3)   // frameLengthType
+	wr.WriteAllBits(1, 8) // latmBufferFullness
+	wr.WriteBit(0)        // otherDataPresent
+	wr.WriteBit(0)        // crcCheckPresent
+
+	// PayloadLengthInfo
+	for i := len(unit); ; i -= 255 {
+		if i < 255 {
+			wr.WriteBits8(byte(i), 8)
+			break
+		}
+		wr.WriteAllBits(1, 8)
+	}
+
+	// PayloadMux
+	for _, b := range unit {
+		wr.WriteBits8(b, 8)
+	}
+
+	b := wr.Bytes()
+	size := len(b) - LOASHeaderSize
+	b[1] |= byte(size >> 8)
+	b[2] = byte(size)
+	return b
+}
+
+func EncodeToLATM(codec *core.Codec, handler core.HandlerFunc) core.HandlerFunc {
+	conf := CodecToConfig(codec)
+
+	return func(packet *rtp.Packet) {
+		if IsLOAS(packet.Payload) {
+			handler(packet)
+			return
+		}
+
+		clone := *packet
+		if IsADTS(packet.Payload) {
+			clone.Payload = EncodeLATM(conf, packet.Payload[ADTSHeaderSize:])
+		} else {
+			clone.Payload = EncodeLATM(conf, packet.Payload)
+		}
+		handler(&clone)
+	}
+}
+
+func RTPToLATM(codec *core.Codec, handler core.HandlerFunc) core.HandlerFunc {
+	return RTPDepay(EncodeToLATM(codec, handler))
+}
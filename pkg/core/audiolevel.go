@@ -0,0 +1,47 @@
+package core
+
+// ExtAudioLevel - client-to-mixer audio level RTP header extension (RFC 6464)
+const ExtAudioLevel = "urn:ietf:params:rtp-hdrext:ssrc-audio-level"
+
+// AudioLevel - level of one audio packet in -dBov, 0 - the loudest, 127 - silence,
+// Voice - voice activity flag, if the source sends it
+type AudioLevel struct {
+	Level byte `json:"level"`
+	Voice bool `json:"voice,omitempty"`
+}
+
+// ExtensionID - RTP header extension ID from SDP extmap, zero if media doesn't have it
+func (m *Media) ExtensionID(uri string) byte {
+	for id, s := range m.Extensions {
+		if s == uri {
+			return id
+		}
+	}
+	return 0
+}
+
+// GetAudioLevel - from the header extension of the packet, consumers can get the id
+// with track.Media.ExtensionID(ExtAudioLevel), false if the packet doesn't have it
+func GetAudioLevel(packet *Packet, id byte) (level AudioLevel, ok bool) {
+	if id == 0 || !packet.Extension {
+		return
+	}
+	b := packet.GetExtension(id)
+	if len(b) == 0 {
+		return
+	}
+	return AudioLevel{Level: b[0] & 0x7F, Voice: b[0]&0x80 != 0}, true
+}
+
+// pack - level for the atomic value, zero is reserved for the unknown level
+func (l AudioLevel) pack() uint32 {
+	v := 0x100 | uint32(l.Level)
+	if l.Voice {
+		v |= 0x80
+	}
+	return v
+}
+
+func unpackAudioLevel(v uint32) (AudioLevel, bool) {
+	return AudioLevel{Level: byte(v) & 0x7F, Voice: v&0x80 != 0}, v != 0
+}
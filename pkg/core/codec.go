@@ -184,6 +184,9 @@ func UnmarshalCodec(md *sdp.MediaDescription, payloadType string) *Codec {
 		case "26":
 			c.Name = CodecJPEG
 			c.ClockRate = 90000
+		case "33":
+			c.Name = CodecMP2T
+			c.ClockRate = 90000
 		case "96", "97", "98":
 			if len(md.Bandwidth) == 0 {
 				c.Name = payloadType
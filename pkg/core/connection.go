@@ -43,6 +43,9 @@ type Connection struct {
 	SDP        string `json:"sdp,omitempty"`
 	UserAgent  string `json:"user_agent,omitempty"`
 
+	// Kinds - consumer wants only these media kinds from the stream, all if empty
+	Kinds []string `json:"kinds,omitempty"`
+
 	Medias    []*Media    `json:"medias,omitempty"`
 	Receivers []*Receiver `json:"receivers,omitempty"`
 	Senders   []*Sender   `json:"senders,omitempty"`
@@ -80,6 +83,30 @@ func (c *Connection) Stop() error {
 	return nil
 }
 
+// SetBuffer - queue depth for all consumer senders
+func (c *Connection) SetBuffer(size int) {
+	for _, sender := range c.Senders {
+		sender.SetBuffer(size)
+	}
+}
+
+// GetSenders - queues of the consumer, ex. for the buffers memory budget
+func (c *Connection) GetSenders() []*Sender {
+	return c.Senders
+}
+
+// SetPrebuffer - hold packets of all senders at start, ex. for players that stall with a small buffer
+func (c *Connection) SetPrebuffer(p *Prebuffer) {
+	for _, sender := range c.Senders {
+		sender.SetPrebuffer(p)
+	}
+}
+
+// GetKinds - requested media kinds of the consumer, ex. only audio for monitoring
+func (c *Connection) GetKinds() []string {
+	return c.Kinds
+}
+
 // Deprecated:
 func (c *Connection) Codecs() []*Codec {
 	codecs := make([]*Codec, len(c.Senders))
@@ -122,12 +149,18 @@ func (c *Connection) WithRequest(r *http.Request) {
 	}
 
 	c.UserAgent = r.UserAgent()
+	c.Kinds = ParseKinds(r.URL.Query().Get("media"))
 }
 
 func (c *Connection) GetSource() string {
 	return c.Source
 }
 
+// GetFormatName - type of the connection, ex. for the settings by consumer type
+func (c *Connection) GetFormatName() string {
+	return c.FormatName
+}
+
 // Create like os.Create, init Consumer with existing Transport
 func Create(w io.Writer) (*Connection, error) {
 	return &Connection{Transport: w}, nil
@@ -11,6 +11,7 @@ const (
 const (
 	KindVideo = "video"
 	KindAudio = "audio"
+	KindData  = "application"
 )
 
 const (
@@ -21,6 +22,7 @@ const (
 	CodecAV1  = "AV1"
 	CodecJPEG = "JPEG" // payloadType: 26
 	CodecRAW  = "RAW"
+	CodecMP2T = "MP2T" // payloadType: 33, MPEG-TS over RTP
 
 	CodecPCMU = "PCMU" // payloadType: 0
 	CodecPCMA = "PCMA" // payloadType: 8
@@ -35,6 +37,10 @@ const (
 	CodecELD  = "ELD" // AAC-ELD
 	CodecFLAC = "FLAC"
 
+	CodecTelephoneEvent = "TELEPHONE-EVENT" // RFC 4733 DTMF events
+
+	CodecKLV = "KLV" // MISB ST 0601 metadata from MPEG-TS
+
 	CodecAll = "ALL"
 	CodecAny = "ANY"
 )
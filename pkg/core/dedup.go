@@ -0,0 +1,59 @@
+package core
+
+// DedupWindow - default sliding window size for duplicate RTP packets detection
+const DedupWindow = 64
+
+// Dedup - drop duplicate packets by sequence number within sliding window.
+// Over UDP and with some buggy cameras over TCP, same packets can arrive twice.
+func (r *Receiver) Dedup(window uint16) {
+	if window == 0 {
+		return
+	}
+
+	// power of two size, so slots don't alias across the uint16 wrap
+	size := 1
+	for size < int(window) {
+		size <<= 1
+	}
+	mask := uint16(size - 1)
+
+	var last uint16
+	var started bool
+	seen := make([]bool, size)
+	input := r.Input
+
+	r.Input = func(packet *Packet) {
+		seq := packet.SequenceNumber
+
+		if !started {
+			started = true
+			last = seq
+			seen[seq&mask] = true
+			input(packet)
+			return
+		}
+
+		switch diff := int16(seq - last); {
+		case diff > 0:
+			// clear the slots between the last and the new packet
+			if int(diff) >= size {
+				clear(seen)
+			} else {
+				for i := last + 1; i != seq; i++ {
+					seen[i&mask] = false
+				}
+			}
+			last = seq
+		case -int(diff) >= size:
+			// too old packet, can't check it, so just pass it
+			input(packet)
+			return
+		case seen[seq&mask]:
+			r.Duplicates++
+			return
+		}
+
+		seen[seq&mask] = true
+		input(packet)
+	}
+}
@@ -3,6 +3,8 @@ package core
 import (
 	"encoding/json"
 	"fmt"
+	"slices"
+	"strconv"
 	"strings"
 
 	"github.com/pion/sdp/v3"
@@ -16,7 +18,21 @@ type Media struct {
 	Direction string   `json:"direction,omitempty"` // sendonly, recvonly
 	Codecs    []*Codec `json:"codecs,omitempty"`
 
-	ID string `json:"id,omitempty"` // MID for WebRTC, Control for RTSP
+	ID   string `json:"id,omitempty"`   // MID for WebRTC, Control for RTSP
+	Lang string `json:"lang,omitempty"` // language from SDP a=lang, ex. for multi-audio cameras
+
+	Feedback []string `json:"rtcp_fb,omitempty"` // RTCP feedback from SDP a=rtcp-fb, ex. "nack pli"
+
+	// Extensions - RTP header extensions from SDP a=extmap, ID to URI
+	Extensions map[byte]string `json:"extmap,omitempty"`
+
+	// Video - declared size and frame rate from SDP a=framesize, a=x-dimensions and
+	// a=framerate, nil if unknown, RTSP client fills it from SPS if SDP doesn't have it
+	Video *VideoInfo `json:"video,omitempty"`
+
+	// Temporal - consumer option, forward only this number of the lower temporal layers
+	// of H264 SVC and H265 video, ex. 1 - only the base layer, zero - all layers
+	Temporal int `json:"temporal,omitempty"`
 }
 
 func (m *Media) String() string {
@@ -33,6 +49,26 @@ func (m *Media) String() string {
 	return s
 }
 
+// VideoInfo - video params for UI without the decoding, zero if unknown
+type VideoInfo struct {
+	Width     int     `json:"width,omitempty"`
+	Height    int     `json:"height,omitempty"`
+	FrameRate float64 `json:"framerate,omitempty"`
+}
+
+// SetVideoInfo - set unknown video params, known params are not changed
+func (m *Media) SetVideoInfo(width, height int, fps float64) {
+	if m.Video == nil {
+		m.Video = &VideoInfo{}
+	}
+	if m.Video.Width == 0 && width > 0 && height > 0 {
+		m.Video.Width, m.Video.Height = width, height
+	}
+	if m.Video.FrameRate == 0 && fps > 0 {
+		m.Video.FrameRate = fps
+	}
+}
+
 func (m *Media) MarshalJSON() ([]byte, error) {
 	return json.Marshal(m.String())
 }
@@ -92,10 +128,12 @@ func (m *Media) Equal(media *Media) bool {
 
 func GetKind(name string) string {
 	switch name {
-	case CodecH264, CodecH265, CodecVP8, CodecVP9, CodecAV1, CodecJPEG, CodecRAW:
+	case CodecH264, CodecH265, CodecVP8, CodecVP9, CodecAV1, CodecJPEG, CodecRAW, CodecMP2T:
 		return KindVideo
 	case CodecPCMU, CodecPCMA, CodecAAC, CodecOpus, CodecG722, CodecMP3, CodecPCM, CodecPCML, CodecELD, CodecFLAC:
 		return KindAudio
+	case CodecKLV:
+		return KindData
 	}
 	return ""
 }
@@ -166,6 +204,37 @@ func UnmarshalMedia(md *sdp.MediaDescription) *Media {
 			m.Direction = attr.Key
 		case "control", "mid":
 			m.ID = attr.Value
+		case "lang":
+			m.Lang = attr.Value
+		case "rtcp-fb":
+			// ex. "96 nack pli", payload type or * for all codecs
+			if _, fb, ok := strings.Cut(attr.Value, " "); ok && !slices.Contains(m.Feedback, fb) {
+				m.Feedback = append(m.Feedback, fb)
+			}
+		case "extmap":
+			// ex. "1 urn:ietf:params:rtp-hdrext:ssrc-audio-level" or "2/recvonly uri attrs"
+			if id, uri, ok := strings.Cut(attr.Value, " "); ok {
+				id, _, _ = strings.Cut(id, "/")
+				if i := Atoi(id); i > 0 && i < 256 {
+					if m.Extensions == nil {
+						m.Extensions = map[byte]string{}
+					}
+					uri, _, _ = strings.Cut(uri, " ")
+					m.Extensions[byte(i)] = uri
+				}
+			}
+		case "framerate":
+			if fps, err := strconv.ParseFloat(strings.TrimSpace(attr.Value), 64); err == nil && fps > 0 {
+				m.SetVideoInfo(0, 0, fps)
+			}
+		case "framesize":
+			// ex. "96 1920-1080" (RFC 6064)
+			if _, size, ok := strings.Cut(attr.Value, " "); ok {
+				m.setSize(size, "-")
+			}
+		case "x-dimensions":
+			// ex. "1920,1080"
+			m.setSize(attr.Value, ",")
 		}
 	}
 
@@ -176,6 +245,25 @@ func UnmarshalMedia(md *sdp.MediaDescription) *Media {
 	return m
 }
 
+func (m *Media) setSize(s, sep string) {
+	w, h, ok := strings.Cut(strings.TrimSpace(s), sep)
+	if !ok {
+		return
+	}
+	m.SetVideoInfo(Atoi(w), Atoi(h), 0)
+}
+
+// ParseKinds - media kinds from the query value, ex. `media=audio` or `media=video,audio`,
+// nil for all kinds
+func ParseKinds(s string) (kinds []string) {
+	for _, kind := range []string{KindVideo, KindAudio} {
+		if strings.Contains(s, kind) {
+			kinds = append(kinds, kind)
+		}
+	}
+	return
+}
+
 func ParseQuery(query map[string][]string) (medias []*Media) {
 	// set media candidates from query list
 	for key, values := range query {
@@ -207,5 +295,13 @@ func ParseQuery(query map[string][]string) (medias []*Media) {
 		}
 	}
 
+	if values := query["temporal"]; values != nil {
+		for _, media := range medias {
+			if media.Kind == KindVideo {
+				media.Temporal = Atoi(values[0])
+			}
+		}
+	}
+
 	return
 }
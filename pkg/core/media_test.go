@@ -42,6 +42,16 @@ func TestParseQuery(t *testing.T) {
 			{Kind: KindVideo, Direction: DirectionSendonly, Codecs: []*Codec{{Name: CodecAny}}},
 		}, medias)
 	}
+
+	u, _ = url.Parse("rtsp://localhost:8554/camera1?video&audio&temporal=1")
+	medias = ParseQuery(u.Query())
+	for _, media := range medias {
+		if media.Kind == KindVideo {
+			assert.Equal(t, 1, media.Temporal)
+		} else {
+			assert.Equal(t, 0, media.Temporal)
+		}
+	}
 }
 
 func TestClone(t *testing.T) {
@@ -62,3 +72,9 @@ func TestClone(t *testing.T) {
 	p4 := fmt.Sprintf("%p", media2.Codecs[0])
 	require.NotEqualValues(t, p3, p4)
 }
+
+func TestParseKinds(t *testing.T) {
+	require.Nil(t, ParseKinds(""))
+	require.Equal(t, []string{KindVideo}, ParseKinds("video"))
+	require.Equal(t, []string{KindVideo, KindAudio}, ParseKinds("audio,video"))
+}
@@ -1,7 +1,9 @@
 package core
 
 import (
+	"slices"
 	"sync"
+	"sync/atomic"
 
 	"github.com/pion/rtp"
 )
@@ -30,6 +32,7 @@ type Node struct {
 	id     uint32
 	childs []*Node
 	parent *Node
+	clock  atomic.Pointer[Clock] // only for the root node (Receiver)
 
 	mu sync.Mutex
 }
@@ -44,7 +47,7 @@ func (n *Node) AppendChild(child *Node) {
 	n.childs = append(n.childs, child)
 	n.mu.Unlock()
 
-	child.parent = n
+	child.setParent(n)
 }
 
 func (n *Node) RemoveChild(child *Node) {
@@ -58,16 +61,30 @@ func (n *Node) RemoveChild(child *Node) {
 	n.mu.Unlock()
 }
 
+// Len - number of childs, it is a reference counter for the Receiver
+func (n *Node) Len() int {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return len(n.childs)
+}
+
 func (n *Node) Close() {
-	if parent := n.parent; parent != nil {
+	if parent := n.getParent(); parent != nil {
 		parent.RemoveChild(n)
 
-		if len(parent.childs) == 0 {
+		// root node without childs has nothing to close, and it may get
+		// a new child from another consumer at the same time
+		if parent.getParent() != nil && parent.Len() == 0 {
 			parent.Close()
 		}
 	} else {
-		for _, childs := range n.childs {
-			childs.Close()
+		// copy, because child.Close modifies the childs slice
+		n.mu.Lock()
+		childs := slices.Clone(n.childs)
+		n.mu.Unlock()
+
+		for _, child := range childs {
+			child.Close()
 		}
 	}
 }
@@ -83,6 +100,30 @@ func MoveNode(dst, src *Node) {
 	dst.mu.Unlock()
 
 	for _, child := range childs {
-		child.parent = dst
+		child.setParent(dst)
+	}
+}
+
+// parent is changed by MoveNode when the producer reconnects
+func (n *Node) getParent() *Node {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.parent
+}
+
+func (n *Node) setParent(parent *Node) {
+	n.mu.Lock()
+	n.parent = parent
+	n.mu.Unlock()
+}
+
+// root - Receiver node of the chain
+func (n *Node) root() *Node {
+	for {
+		parent := n.getParent()
+		if parent == nil {
+			return n
+		}
+		n = parent
 	}
 }
@@ -0,0 +1,86 @@
+package core
+
+import (
+	"sync"
+	"time"
+)
+
+// Prebuffer - hold packets of all consumer senders until Keyframes video keyframes
+// (GOPs) are buffered or Duration is passed, whichever comes first. Some players
+// stall right after the join with a tiny buffer, so they get a bigger one at start.
+type Prebuffer struct {
+	Keyframes int
+	Duration  time.Duration
+
+	// IsKeyframe - keyframe check for the packet payload of the video codec
+	IsKeyframe func(codec *Codec, payload []byte) bool
+
+	items []prebufferItem
+	start time.Time
+	keys  int
+	keyTS uint32
+	done  bool
+	mu    sync.Mutex
+}
+
+type prebufferItem struct {
+	sender *Sender
+	packet *Packet
+}
+
+// maxPrebuffer - memory protection for sources without keyframes
+const maxPrebuffer = 8192
+
+// hold - returns false if the packet should go to the sender queue as usual
+func (p *Prebuffer) hold(s *Sender, packet *Packet) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.done {
+		return false
+	}
+
+	now := time.Now()
+	if p.start.IsZero() {
+		p.start = now
+	}
+
+	if s.Codec.IsVideo() && p.IsKeyframe != nil && p.IsKeyframe(s.Codec, packet.Payload) {
+		// one keyframe can be split to many packets with the same timestamp
+		if p.keys == 0 || packet.Timestamp != p.keyTS {
+			p.keys++
+			p.keyTS = packet.Timestamp
+		}
+	}
+
+	// start of the next keyframe means all previous keyframes are complete
+	if p.Keyframes > 0 && p.keys > p.Keyframes ||
+		p.Duration > 0 && now.Sub(p.start) >= p.Duration ||
+		len(p.items) >= maxPrebuffer {
+		p.release()
+		return false
+	}
+
+	p.items = append(p.items, prebufferItem{sender: s, packet: packet})
+	return true
+}
+
+// Bytes - payload size of the held packets, ex. for the buffers memory budget
+func (p *Prebuffer) Bytes() (n int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, item := range p.items {
+		n += len(item.packet.Payload)
+	}
+	return
+}
+
+// release - send the buffered packets in the original order, under the lock,
+// so new packets from other tracks wait for the end
+func (p *Prebuffer) release() {
+	for _, item := range p.items {
+		item.sender.push(item.packet)
+	}
+	p.items = nil
+	p.done = true
+}
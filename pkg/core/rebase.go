@@ -0,0 +1,52 @@
+package core
+
+import (
+	"sync"
+	"time"
+)
+
+// Rebase - shift RTP timestamps of all consumer tracks, so the consumer stream
+// starts near zero, instead of big absolute camera values. One Rebase should be
+// shared by all tracks of the consumer. The first packet of any track sets the
+// start time, so tracks with different clock rates keep A/V sync.
+type Rebase struct {
+	start time.Time
+	mu    sync.Mutex
+}
+
+// Rebase - shift timestamps of sender packets before Handler
+func (s *Sender) Rebase(r *Rebase) {
+	output := s.Output
+	clockRate := s.Codec.ClockRate
+
+	var base uint32
+	var started bool
+
+	s.Output = func(packet *Packet) {
+		if !started {
+			started = true
+			base = r.base(clockRate, packet.Timestamp)
+		}
+
+		clone := *packet
+		// uint32 overflow keeps timestamps monotonic
+		clone.Timestamp -= base
+		output(&clone)
+	}
+}
+
+func (r *Rebase) base(clockRate uint32, timestamp uint32) uint32 {
+	now := time.Now()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.start.IsZero() {
+		r.start = now
+		return timestamp
+	}
+
+	// the track started later than the first one, so it starts from the offset
+	offset := uint32(now.Sub(r.start).Milliseconds() * int64(clockRate) / 1000)
+	return timestamp - offset
+}
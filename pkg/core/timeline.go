@@ -0,0 +1,44 @@
+package core
+
+import "time"
+
+// MaxJump - timestamps jump bigger than this is a discontinuity,
+// ex. HLS discontinuity, source restart or a lot of lost data
+const MaxJump = 5 * time.Second
+
+// IsJump - check if the RTP timestamps step is a discontinuity
+func IsJump(d int32, clockRate uint32) bool {
+	jump := int64(MaxJump/time.Second) * int64(clockRate)
+	return int64(d) > jump || int64(d) < -jump
+}
+
+// Timeline - continuous timestamps of the source track after the discontinuities.
+// The track continues from the expected timestamp after the jump.
+type Timeline struct {
+	ClockRate uint32
+
+	offset  uint32
+	last    uint32
+	step    uint32 // expected step after the jump
+	started bool
+}
+
+// Fix - shift timestamp of the packet, duration - of the packet sample,
+// zero if unknown, then the last positive step is used
+func (t *Timeline) Fix(packet *Packet, duration uint32) {
+	ts := packet.Timestamp + t.offset
+	if t.started {
+		if d := int32(ts - t.last); IsJump(d, t.ClockRate) {
+			t.offset += t.last + t.step - ts
+			ts = t.last + t.step
+		} else if d > 0 && duration == 0 {
+			t.step = uint32(d)
+		}
+	}
+	if duration != 0 {
+		t.step = duration
+	}
+	packet.Timestamp = ts
+	t.last = ts
+	t.started = true
+}
@@ -3,12 +3,27 @@ package core
 import (
 	"encoding/json"
 	"errors"
+	"sync/atomic"
+	"time"
 
 	"github.com/pion/rtp"
 )
 
 var ErrCantGetTrack = errors.New("can't get track")
 
+// ErrAuth - wrong or missing credentials, so retry with same source doesn't make sense
+var ErrAuth = errors.New("auth failed")
+
+// ErrNoReconnect - producer asks to stop without reconnects (fail-fast mode)
+var ErrNoReconnect = errors.New("no reconnect")
+
+// ErrReconnect - producer asks to reconnect without the backoff delay (transient error)
+var ErrReconnect = errors.New("reconnect now")
+
+// ErrEndOfStream - source finished playback (ex. VOD recording), it's not a failure,
+// so reconnect doesn't make sense
+var ErrEndOfStream = errors.New("end of stream")
+
 type Receiver struct {
 	Node
 
@@ -17,8 +32,17 @@ type Receiver struct {
 	// Deprecated: should be removed
 	ID byte `json:"-"` // Channel for RTSP, PayloadType for MPEG-TS
 
-	Bytes   int `json:"bytes,omitempty"`
-	Packets int `json:"packets,omitempty"`
+	Bytes      int `json:"bytes,omitempty"`
+	Packets    int `json:"packets,omitempty"`
+	Duplicates int `json:"duplicates,omitempty"`
+
+	// StartSeq - initial sequence from the source (ex. RTSP RTP-Info), nil if unknown
+	StartSeq *uint16 `json:"start_seq,omitempty"`
+
+	muted   atomic.Bool
+	csrc    atomic.Pointer[[]uint32] // last contributing sources, nil if source doesn't send them
+	level   atomic.Uint32            // last audio level (RFC 6464), zero if source doesn't send it
+	levelID byte
 }
 
 func NewReceiver(media *Media, codec *Codec) *Receiver {
@@ -26,9 +50,23 @@ func NewReceiver(media *Media, codec *Codec) *Receiver {
 		Node:  Node{id: NewID(), Codec: codec},
 		Media: media,
 	}
+	if media != nil && media.Kind == KindAudio {
+		r.levelID = media.ExtensionID(ExtAudioLevel)
+	}
 	r.Input = func(packet *Packet) {
 		r.Bytes += len(packet.Payload)
 		r.Packets++
+		if csrc := packet.CSRC; len(csrc) > 0 {
+			r.csrc.Store(&csrc)
+		} else if r.csrc.Load() != nil {
+			r.csrc.Store(nil)
+		}
+		if level, ok := GetAudioLevel(packet, r.levelID); ok {
+			r.level.Store(level.pack())
+		}
+		if r.muted.Load() {
+			return
+		}
 		for _, child := range r.childs {
 			child.Input(packet)
 		}
@@ -43,7 +81,7 @@ func (r *Receiver) WriteRTP(packet *rtp.Packet) {
 
 // Deprecated: should be removed
 func (r *Receiver) Senders() []*Sender {
-	if len(r.childs) > 0 {
+	if r.Len() > 0 {
 		return []*Sender{{}}
 	} else {
 		return nil
@@ -52,9 +90,47 @@ func (r *Receiver) Senders() []*Sender {
 
 // Deprecated: should be removed
 func (r *Receiver) Replace(target *Receiver) {
+	target.muted.Store(r.muted.Load())
 	MoveNode(&target.Node, &r.Node)
 }
 
+// Clock - wall clock time of the RTP timestamp of the source (ex. RTCP sender report)
+type Clock struct {
+	SSRC    uint32
+	RTPTime uint32
+	Wall    time.Time
+}
+
+// SetClock - last mapping of the source timeline to the wall clock, for consumers
+// like the MP4 recorder, it is reset by the reconnect because the track is replaced
+func (r *Receiver) SetClock(clock *Clock) {
+	r.clock.Store(clock)
+}
+
+// CSRC - last contributing sources list from the RTP header (ex. speakers
+// from the audio mixer), empty if source doesn't send it
+func (r *Receiver) CSRC() []uint32 {
+	if csrc := r.csrc.Load(); csrc != nil {
+		return *csrc
+	}
+	return nil
+}
+
+// Mute - stop forwarding packets to senders without renegotiation,
+// receiver stats are still counted
+func (r *Receiver) Mute(muted bool) {
+	r.muted.Store(muted)
+}
+
+func (r *Receiver) Muted() bool {
+	return r.muted.Load()
+}
+
+// AudioLevel - level of the last audio packet, false if source doesn't send it
+func (r *Receiver) AudioLevel() (AudioLevel, bool) {
+	return unpackAudioLevel(r.level.Load())
+}
+
 func (r *Receiver) Close() {
 	r.Node.Close()
 }
@@ -71,8 +147,12 @@ type Sender struct {
 	Packets int `json:"packets,omitempty"`
 	Drops   int `json:"drops,omitempty"`
 
-	buf  chan *Packet
-	done chan struct{}
+	buf    chan *Packet
+	done   chan struct{}
+	queued atomic.Int64 // payload bytes in the queue
+
+	prebuffer atomic.Pointer[Prebuffer]
+	filter    atomic.Pointer[PacketFilter]
 }
 
 func NewSender(media *Media, codec *Codec) *Sender {
@@ -97,16 +177,18 @@ func NewSender(media *Media, codec *Codec) *Sender {
 		buf:   buf,
 	}
 	s.Input = func(packet *Packet) {
-		s.mu.Lock()
-		// unblock write to nil chan - OK, write to closed chan - panic
-		select {
-		case s.buf <- packet:
-			s.Bytes += len(packet.Payload)
-			s.Packets++
-		default:
-			s.Drops++
+		if f := s.filter.Load(); f != nil {
+			if packet = (*f)(packet); packet == nil {
+				return
+			}
+		}
+		if p := s.prebuffer.Load(); p != nil {
+			if p.hold(s, packet) {
+				return
+			}
+			s.prebuffer.Store(nil)
 		}
-		s.mu.Unlock()
+		s.push(packet)
 	}
 	s.Output = func(packet *Packet) {
 		s.Handler(packet)
@@ -114,6 +196,51 @@ func NewSender(media *Media, codec *Codec) *Sender {
 	return s
 }
 
+func (s *Sender) push(packet *Packet) {
+	s.mu.Lock()
+	// unblock write to nil chan - OK, write to closed chan - panic
+	select {
+	case s.buf <- packet:
+		s.queued.Add(int64(len(packet.Payload)))
+		s.Bytes += len(packet.Payload)
+		s.Packets++
+	default:
+		s.Drops++
+	}
+	s.mu.Unlock()
+}
+
+// PacketFilter - returns nil if the packet shouldn't go to the consumer, or the
+// changed copy of the packet, the original packet is shared with other consumers
+type PacketFilter func(packet *Packet) *Packet
+
+// SetFilter - filter packets before the queue, ex. drop video layers, nil - disable
+func (s *Sender) SetFilter(f PacketFilter) {
+	if f == nil {
+		s.filter.Store(nil)
+	} else {
+		s.filter.Store(&f)
+	}
+}
+
+// Clock - last mapping of the source timeline from the Receiver, nil if unknown
+func (s *Sender) Clock() *Clock {
+	if root := s.root(); root != &s.Node {
+		return root.clock.Load()
+	}
+	return nil
+}
+
+// SetPrebuffer - hold packets at start, one Prebuffer is shared by all consumer senders
+func (s *Sender) SetPrebuffer(p *Prebuffer) {
+	s.prebuffer.Store(p)
+}
+
+// Prebuffer - startup buffer of the sender, nil after it is released
+func (s *Sender) Prebuffer() *Prebuffer {
+	return s.prebuffer.Load()
+}
+
 // Deprecated: should be removed
 func (s *Sender) HandleRTP(parent *Receiver) {
 	s.WithParent(parent)
@@ -140,16 +267,74 @@ func (s *Sender) Start() {
 	s.done = make(chan struct{})
 
 	// pass buf directly so that it's impossible for buf to be nil
-	go func(buf chan *Packet) {
+	go func(buf chan *Packet, done chan struct{}) {
 		for packet := range buf {
+			s.queued.Add(-int64(len(packet.Payload)))
 			s.Output(packet)
 		}
-		close(s.done)
-	}(s.buf)
+		close(done)
+	}(s.buf, s.done)
+}
+
+// SetBuffer - change the queue depth, ex. small for low latency consumers (WebRTC)
+// and big for smooth playback consumers (HLS, recording). Can be called after Start,
+// already queued packets are sent before the new ones.
+func (s *Sender) SetBuffer(size int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.buf == nil || size <= 0 || size == cap(s.buf) {
+		return
+	}
+
+	prev := s.buf
+	s.buf = make(chan *Packet, size)
+	close(prev)
+
+	if s.done == nil {
+		for packet := range prev {
+			select {
+			case s.buf <- packet:
+			default:
+				s.queued.Add(-int64(len(packet.Payload)))
+				s.Drops++
+			}
+		}
+		return
+	}
+
+	// new loop waits the previous one for the packets order
+	prevDone := s.done
+	s.done = make(chan struct{})
+
+	go func(buf chan *Packet, prevDone, done chan struct{}) {
+		<-prevDone
+		for packet := range buf {
+			s.queued.Add(-int64(len(packet.Payload)))
+			s.Output(packet)
+		}
+		close(done)
+	}(s.buf, prevDone, s.done)
+}
+
+// Queue - packets in the queue and the queue depth
+func (s *Sender) Queue() (length, size int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.buf), cap(s.buf)
+}
+
+// QueueBytes - memory of the packets in the queue
+func (s *Sender) QueueBytes() int {
+	return int(s.queued.Load())
 }
 
 func (s *Sender) Wait() {
-	if done := s.done; done != nil {
+	s.mu.Lock()
+	done := s.done
+	s.mu.Unlock()
+
+	if done != nil {
 		<-done
 	}
 }
@@ -178,16 +363,30 @@ func (s *Sender) Close() {
 
 func (r *Receiver) MarshalJSON() ([]byte, error) {
 	v := struct {
-		ID      uint32   `json:"id"`
-		Codec   *Codec   `json:"codec"`
-		Childs  []uint32 `json:"childs,omitempty"`
-		Bytes   int      `json:"bytes,omitempty"`
-		Packets int      `json:"packets,omitempty"`
+		ID         uint32      `json:"id"`
+		Codec      *Codec      `json:"codec"`
+		Childs     []uint32    `json:"childs,omitempty"`
+		Bytes      int         `json:"bytes,omitempty"`
+		Packets    int         `json:"packets,omitempty"`
+		Duplicates int         `json:"duplicates,omitempty"`
+		CSRC       []uint32    `json:"csrc,omitempty"`
+		AudioLevel *AudioLevel `json:"audio_level,omitempty"`
+		Muted      bool        `json:"muted,omitempty"`
+		Video      *VideoInfo  `json:"video,omitempty"`
 	}{
-		ID:      r.Node.id,
-		Codec:   r.Node.Codec,
-		Bytes:   r.Bytes,
-		Packets: r.Packets,
+		ID:         r.Node.id,
+		Codec:      r.Node.Codec,
+		Bytes:      r.Bytes,
+		Packets:    r.Packets,
+		Duplicates: r.Duplicates,
+		CSRC:       r.CSRC(),
+		Muted:      r.muted.Load(),
+	}
+	if level, ok := r.AudioLevel(); ok {
+		v.AudioLevel = &level
+	}
+	if r.Media != nil {
+		v.Video = r.Media.Video
 	}
 	for _, child := range r.childs {
 		v.Childs = append(v.Childs, child.id)
@@ -197,21 +396,26 @@ func (r *Receiver) MarshalJSON() ([]byte, error) {
 
 func (s *Sender) MarshalJSON() ([]byte, error) {
 	v := struct {
-		ID      uint32 `json:"id"`
-		Codec   *Codec `json:"codec"`
-		Parent  uint32 `json:"parent,omitempty"`
-		Bytes   int    `json:"bytes,omitempty"`
-		Packets int    `json:"packets,omitempty"`
-		Drops   int    `json:"drops,omitempty"`
+		ID         uint32 `json:"id"`
+		Codec      *Codec `json:"codec"`
+		Parent     uint32 `json:"parent,omitempty"`
+		Bytes      int    `json:"bytes,omitempty"`
+		Packets    int    `json:"packets,omitempty"`
+		Drops      int    `json:"drops,omitempty"`
+		Queue      int    `json:"queue,omitempty"`
+		Buffer     int    `json:"buffer,omitempty"`
+		QueueBytes int    `json:"queue_bytes,omitempty"`
 	}{
-		ID:      s.Node.id,
-		Codec:   s.Node.Codec,
-		Bytes:   s.Bytes,
-		Packets: s.Packets,
-		Drops:   s.Drops,
-	}
-	if s.parent != nil {
-		v.Parent = s.parent.id
+		ID:         s.Node.id,
+		Codec:      s.Node.Codec,
+		Bytes:      s.Bytes,
+		Packets:    s.Packets,
+		Drops:      s.Drops,
+		QueueBytes: s.QueueBytes(),
+	}
+	v.Queue, v.Buffer = s.Queue()
+	if parent := s.getParent(); parent != nil {
+		v.Parent = parent.id
 	}
 	return json.Marshal(v)
 }
@@ -2,7 +2,10 @@ package core
 
 import (
 	"testing"
+	"time"
 
+	"github.com/pion/rtp"
+	"github.com/pion/sdp/v3"
 	"github.com/stretchr/testify/require"
 )
 
@@ -51,3 +54,260 @@ func TestSenser(t *testing.T) {
 	}
 	require.False(t, ok)
 }
+
+func TestSenderBuffer(t *testing.T) {
+	recv := make(chan *Packet) // blocking receiver
+
+	sender := NewSender(nil, &Codec{Name: CodecH264, PayloadType: 96})
+	sender.Output = func(packet *Packet) {
+		recv <- packet
+	}
+
+	// before start queued packets are moved to the new queue
+	sender.Input(&Packet{Header: rtp.Header{SequenceNumber: 1}})
+	sender.SetBuffer(2)
+
+	length, size := sender.Queue()
+	require.Equal(t, 1, length)
+	require.Equal(t, 2, size)
+
+	sender.Start()
+
+	// one packet waits in the output, new queue is full after two
+	sender.Input(&Packet{Header: rtp.Header{SequenceNumber: 2}})
+	require.Equal(t, uint16(1), (<-recv).SequenceNumber)
+	require.Eventually(t, func() bool {
+		length, _ = sender.Queue()
+		return length == 0
+	}, time.Second, time.Millisecond)
+	sender.Input(&Packet{Header: rtp.Header{SequenceNumber: 3}})
+	sender.Input(&Packet{Header: rtp.Header{SequenceNumber: 4}})
+	sender.Input(&Packet{Header: rtp.Header{SequenceNumber: 5}})
+	require.Equal(t, 1, sender.Drops)
+
+	// after start old queue is sent before the new one
+	sender.SetBuffer(8)
+	sender.Input(&Packet{Header: rtp.Header{SequenceNumber: 6}})
+
+	_, size = sender.Queue()
+	require.Equal(t, 8, size)
+
+	for _, seq := range []uint16{2, 3, 4, 6} {
+		require.Equal(t, seq, (<-recv).SequenceNumber)
+	}
+
+	sender.Close()
+	sender.Wait()
+}
+
+func TestPrebuffer(t *testing.T) {
+	video := NewSender(nil, &Codec{Name: CodecH264, ClockRate: 90000, PayloadType: 96})
+	audio := NewSender(nil, &Codec{Name: CodecPCMA, ClockRate: 8000, PayloadType: 8})
+
+	p := &Prebuffer{
+		Keyframes: 1,
+		IsKeyframe: func(codec *Codec, payload []byte) bool {
+			return payload[0] == 5
+		},
+	}
+	video.SetPrebuffer(p)
+	audio.SetPrebuffer(p)
+
+	var packets []*Packet
+	write := func(sender *Sender, ts uint32, nalu byte) {
+		sender.Input(&Packet{Header: rtp.Header{Timestamp: ts}, Payload: []byte{nalu}})
+	}
+
+	write(video, 0, 1) // before the keyframe
+	write(video, 3000, 5)
+	write(video, 3000, 5) // same keyframe, second packet
+	write(audio, 3000, 0)
+	write(video, 6000, 1)
+	require.Equal(t, 0, video.Packets+audio.Packets)
+
+	// next keyframe, so the first one is complete
+	write(video, 9000, 5)
+	require.Equal(t, 5, video.Packets)
+	require.Equal(t, 1, audio.Packets)
+
+	// pass through after the release
+	write(audio, 9000, 0)
+	require.Equal(t, 2, audio.Packets)
+	require.Nil(t, audio.prebuffer.Load())
+
+	for range 5 {
+		packets = append(packets, <-video.buf)
+	}
+	require.Equal(t, uint32(9000), packets[4].Timestamp)
+
+	// duration limit
+	sender := NewSender(nil, &Codec{Name: CodecPCMA})
+	sender.SetPrebuffer(&Prebuffer{Duration: 20 * time.Millisecond})
+	write(sender, 0, 0)
+	require.Equal(t, 0, sender.Packets)
+	time.Sleep(30 * time.Millisecond)
+	write(sender, 160, 0)
+	require.Equal(t, 2, sender.Packets)
+}
+
+func TestReceiverDedup(t *testing.T) {
+	recv := NewReceiver(nil, &Codec{})
+	recv.Dedup(4)
+
+	for _, seq := range []uint16{65534, 65535, 65535, 0, 65534, 2, 1, 2, 100, 99, 100} {
+		recv.Input(&Packet{Header: rtp.Header{SequenceNumber: seq}})
+	}
+
+	require.Equal(t, 7, recv.Packets)
+	require.Equal(t, 4, recv.Duplicates)
+}
+
+func TestReceiverDedupWrap(t *testing.T) {
+	recv := NewReceiver(nil, &Codec{})
+	recv.Dedup(100)
+
+	// 65536 is not a multiple of 100, late packet before the wrap isn't a duplicate
+	for seq := uint16(65500); seq != 31; seq++ {
+		if seq != 65530 {
+			recv.Input(&Packet{Header: rtp.Header{SequenceNumber: seq}})
+		}
+	}
+	recv.Input(&Packet{Header: rtp.Header{SequenceNumber: 65530}})
+	recv.Input(&Packet{Header: rtp.Header{SequenceNumber: 65530}})
+
+	require.Equal(t, 67, recv.Packets)
+	require.Equal(t, 1, recv.Duplicates)
+}
+
+func TestRebase(t *testing.T) {
+	var rebase Rebase
+
+	var video, audio []uint32
+
+	sender1 := NewSender(nil, &Codec{Name: CodecH264, ClockRate: 90000})
+	sender1.Output = func(packet *Packet) {
+		video = append(video, packet.Timestamp)
+	}
+	sender1.Rebase(&rebase)
+
+	sender2 := NewSender(nil, &Codec{Name: CodecPCMA, ClockRate: 8000})
+	sender2.Output = func(packet *Packet) {
+		audio = append(audio, packet.Timestamp)
+	}
+	sender2.Rebase(&rebase)
+
+	sender1.Output(&Packet{Header: rtp.Header{Timestamp: 0xFFFFFF00}})
+	sender1.Output(&Packet{Header: rtp.Header{Timestamp: 0x00000100}}) // overflow
+	rebase.start = rebase.start.Add(-time.Second)                      // audio starts 1 second later
+	sender2.Output(&Packet{Header: rtp.Header{Timestamp: 123456}})
+	sender2.Output(&Packet{Header: rtp.Header{Timestamp: 123456 + 160}})
+
+	require.Equal(t, []uint32{0, 0x200}, video)
+	require.InDelta(t, 8000, audio[0], 80)
+	require.Equal(t, audio[0]+160, audio[1])
+}
+
+func TestReceiverCSRC(t *testing.T) {
+	recv := NewReceiver(nil, &Codec{})
+
+	recv.Input(&Packet{})
+	require.Nil(t, recv.CSRC())
+
+	recv.Input(&Packet{Header: rtp.Header{CSRC: []uint32{1, 2}}})
+	require.Equal(t, []uint32{1, 2}, recv.CSRC())
+
+	b, err := recv.MarshalJSON()
+	require.Nil(t, err)
+	require.Contains(t, string(b), `"csrc":[1,2]`)
+
+	// mixer stopped sending contributing sources
+	recv.Input(&Packet{})
+	require.Empty(t, recv.CSRC())
+}
+
+func TestReceiverAudioLevel(t *testing.T) {
+	md := &sdp.MediaDescription{
+		MediaName: sdp.MediaName{Media: "audio", Formats: []string{"8"}},
+		Attributes: []sdp.Attribute{
+			{Key: "extmap", Value: "1 urn:ietf:params:rtp-hdrext:sdes:mid"},
+			{Key: "extmap", Value: "3/sendonly " + ExtAudioLevel + " vad=on"},
+		},
+	}
+	media := UnmarshalMedia(md)
+	require.Equal(t, byte(3), media.ExtensionID(ExtAudioLevel))
+
+	recv := NewReceiver(media, media.Codecs[0])
+
+	recv.Input(&Packet{})
+	_, ok := recv.AudioLevel()
+	require.False(t, ok)
+
+	packet := &Packet{}
+	require.Nil(t, packet.SetExtension(3, []byte{0x80 | 30}))
+	recv.Input(packet)
+	level, ok := recv.AudioLevel()
+	require.True(t, ok)
+	require.Equal(t, AudioLevel{Level: 30, Voice: true}, level)
+
+	b, err := recv.MarshalJSON()
+	require.Nil(t, err)
+	require.Contains(t, string(b), `"audio_level":{"level":30,"voice":true}`)
+
+	// the loudest level without voice flag is a known level
+	packet = &Packet{}
+	require.Nil(t, packet.SetExtension(3, []byte{0}))
+	recv.Input(packet)
+	level, ok = recv.AudioLevel()
+	require.True(t, ok)
+	require.Equal(t, AudioLevel{}, level)
+
+	// other extension ID is not the audio level
+	packet = &Packet{}
+	require.Nil(t, packet.SetExtension(1, []byte{10}))
+	_, ok = GetAudioLevel(packet, 3)
+	require.False(t, ok)
+}
+
+func TestReceiverMute(t *testing.T) {
+	recv := NewReceiver(nil, &Codec{Name: CodecPCMA})
+
+	var packets int
+	child := &Node{}
+	child.Input = func(packet *Packet) { packets++ }
+	child.WithParent(&recv.Node)
+
+	recv.Input(&Packet{})
+	recv.Mute(true)
+	recv.Input(&Packet{})
+	require.Equal(t, 1, packets)
+	require.Equal(t, 2, recv.Packets) // stats are still counted
+
+	// new receiver after reconnect keeps the state
+	next := NewReceiver(nil, &Codec{Name: CodecPCMA})
+	recv.Replace(next)
+	require.True(t, next.Muted())
+
+	next.Mute(false)
+	next.Input(&Packet{})
+	require.Equal(t, 2, packets)
+}
+
+func TestTimeline(t *testing.T) {
+	tl := &Timeline{ClockRate: 90000}
+
+	var out []uint32
+	for _, ts := range []uint32{1000, 4000, 7000, 900000000, 900003000, 10000} {
+		packet := &Packet{Header: rtp.Header{Timestamp: ts}}
+		tl.Fix(packet, 0)
+		out = append(out, packet.Timestamp)
+	}
+	// jumps forward and back continue with the last step
+	require.Equal(t, []uint32{1000, 4000, 7000, 10000, 13000, 16000}, out)
+
+	tl = &Timeline{ClockRate: 90000}
+	packet := &Packet{Header: rtp.Header{Timestamp: 1000}}
+	tl.Fix(packet, 3600)
+	packet = &Packet{Header: rtp.Header{Timestamp: 5000000}}
+	tl.Fix(packet, 3600)
+	require.Equal(t, uint32(4600), packet.Timestamp) // continues with the sample duration
+}
@@ -0,0 +1,13 @@
+package core
+
+// Transcoder - codec adaptation when the consumer codecs don't match the source,
+// ex. pure-Go G.711 or external FFmpeg, registered in the streams module
+type Transcoder interface {
+	// Output - codec for the wanted dst codec from the src codec (dst with the missing
+	// clock rate or channels filled), nil if transcoder can't convert them
+	Output(dst, src *Codec) *Codec
+
+	// Handler - convert packets of the src codec to the out codec from Output
+	// and pass them to the next handler
+	Handler(out, src *Codec, next HandlerFunc) HandlerFunc
+}
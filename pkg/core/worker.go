@@ -1,35 +1,45 @@
 package core
 
 import (
+	"sync"
 	"time"
 )
 
+// Worker - periodic job on the runtime timer, without own goroutine while waiting,
+// so thousands of connections don't hold thousands of sleeping goroutines
 type Worker struct {
-	timer *time.Timer
-	done  chan struct{}
+	timer   *time.Timer
+	run     sync.Mutex // f is never called at the same time
+	mu      sync.Mutex
+	stopped bool
 }
 
-// NewWorker run f after d
+// NewWorker run f after d, and again after the returned duration, until it returns zero
 func NewWorker(d time.Duration, f func() time.Duration) *Worker {
-	timer := time.NewTimer(d)
-	done := make(chan struct{})
-
-	go func() {
-		for {
-			select {
-			case <-timer.C:
-				if d = f(); d > 0 {
-					timer.Reset(d)
-					continue
-				}
-			case <-done:
-				timer.Stop()
-			}
-			break
+	w := &Worker{}
+
+	w.mu.Lock()
+	w.timer = time.AfterFunc(d, func() {
+		w.run.Lock()
+		defer w.run.Unlock()
+
+		if w.isStopped() {
+			return
+		}
+
+		d := f()
+
+		w.mu.Lock()
+		if d > 0 && !w.stopped {
+			w.timer.Reset(d)
+		} else {
+			w.stopped = true
 		}
-	}()
+		w.mu.Unlock()
+	})
+	w.mu.Unlock()
 
-	return &Worker{timer: timer, done: done}
+	return w
 }
 
 // Do - instant timer run
@@ -37,16 +47,32 @@ func (w *Worker) Do() {
 	if w == nil {
 		return
 	}
-	w.timer.Reset(0)
+
+	w.mu.Lock()
+	if !w.stopped {
+		w.timer.Reset(0)
+	}
+	w.mu.Unlock()
 }
 
+// Stop - cancel next runs and wait the current run, so f never uses resources
+// closed after Stop (ex. connection), shouldn't be called from f
 func (w *Worker) Stop() {
 	if w == nil {
 		return
 	}
 
-	select {
-	case w.done <- struct{}{}:
-	default:
-	}
+	w.mu.Lock()
+	w.stopped = true
+	w.timer.Stop()
+	w.mu.Unlock()
+
+	w.run.Lock()
+	w.run.Unlock()
+}
+
+func (w *Worker) isStopped() bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.stopped
 }
@@ -0,0 +1,81 @@
+package core
+
+import (
+	"runtime"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWorker(t *testing.T) {
+	var runs atomic.Int32
+	w := NewWorker(time.Millisecond, func() time.Duration {
+		if runs.Add(1) == 3 {
+			return 0 // stop
+		}
+		return time.Millisecond
+	})
+
+	require.Eventually(t, func() bool { return runs.Load() == 3 }, time.Second, time.Millisecond)
+	w.Do() // stopped worker doesn't run
+	time.Sleep(10 * time.Millisecond)
+	require.Equal(t, int32(3), runs.Load())
+
+	runs.Store(0)
+	w = NewWorker(time.Hour, func() time.Duration {
+		runs.Add(1)
+		return time.Hour
+	})
+	w.Do()
+	require.Eventually(t, func() bool { return runs.Load() == 1 }, time.Second, time.Millisecond)
+	w.Stop()
+	w.Do()
+	time.Sleep(10 * time.Millisecond)
+	require.Equal(t, int32(1), runs.Load())
+}
+
+func TestWorkerStopWait(t *testing.T) {
+	started := make(chan struct{})
+	release := make(chan struct{})
+	var done atomic.Bool
+
+	w := NewWorker(0, func() time.Duration {
+		close(started)
+		<-release
+		done.Store(true)
+		return time.Hour
+	})
+	<-started
+
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		close(release)
+	}()
+
+	w.Stop() // waits the running f
+	require.True(t, done.Load())
+}
+
+// waiting workers don't hold goroutines
+func TestWorkerGoroutines(t *testing.T) {
+	n := runtime.NumGoroutine()
+
+	workers := make([]*Worker, 1000)
+	for i := range workers {
+		workers[i] = NewWorker(time.Hour, func() time.Duration { return time.Hour })
+	}
+	require.Less(t, runtime.NumGoroutine()-n, 10)
+
+	for _, w := range workers {
+		w.Stop()
+	}
+}
+
+func BenchmarkWorker(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		NewWorker(time.Hour, func() time.Duration { return 0 }).Stop()
+	}
+}
@@ -47,12 +47,16 @@ func (w *WriteBuffer) WriteTo(wr io.Writer) (n int64, err error) {
 }
 
 func (w *WriteBuffer) Close() error {
-	if closer, ok := w.Writer.(io.Closer); ok {
-		return closer.Close()
-	}
 	w.mu.Lock()
-	w.done()
+	closer, ok := w.Writer.(io.Closer) // Writer is changed by Reset
+	if !ok {
+		w.done()
+	}
 	w.mu.Unlock()
+
+	if ok {
+		return closer.Close()
+	}
 	return nil
 }
 
@@ -0,0 +1,178 @@
+// Package dtmf - RFC 4733 (RFC 2833) telephone-event packets for DTMF digits
+package dtmf
+
+import (
+	"encoding/binary"
+	"errors"
+	"math/rand/v2"
+	"sync"
+	"time"
+
+	"github.com/pion/rtp"
+)
+
+const (
+	Interval = 50 * time.Millisecond  // between event updates
+	Tone     = 100 * time.Millisecond // duration of the digit
+	Pause    = 100 * time.Millisecond // between digits
+	Volume   = 10                     // -10 dBm0
+	Retries  = 3                      // end of the event packets
+)
+
+var ErrDigit = errors.New("dtmf: wrong digit")
+
+// Event - event code of the DTMF digit: 0-9, *, #, A-D
+func Event(digit byte) (byte, bool) {
+	switch {
+	case digit >= '0' && digit <= '9':
+		return digit - '0', true
+	case digit == '*':
+		return 10, true
+	case digit == '#':
+		return 11, true
+	case digit >= 'A' && digit <= 'D':
+		return digit - 'A' + 12, true
+	case digit >= 'a' && digit <= 'd':
+		return digit - 'a' + 12, true
+	}
+	return 0, false
+}
+
+// Payload - event, end bit, volume and duration in timestamp units
+func Payload(event byte, end bool, duration uint16) []byte {
+	b := make([]byte, 4)
+	b[0] = event
+	b[1] = Volume
+	if end {
+		b[1] |= 0x80
+	}
+	binary.BigEndian.PutUint16(b[2:], duration)
+	return b
+}
+
+// Writer - telephone-event stream, with the SSRC, sequence numbers and timeline of
+// the audio Session, or with own ones if Session is nil
+type Writer struct {
+	PayloadType uint8
+	ClockRate   uint32
+	Write       func(packet *rtp.Packet) error
+	Session     *Session
+
+	mu sync.Mutex
+}
+
+// Session - events are a part of the audio RTP stream, so they use the same SSRC,
+// sequence numbers and timestamps clock (RFC 4733 2.1)
+type Session struct {
+	ssrc uint32
+	seq  uint16
+	ts   uint32    // timestamp of the last audio packet
+	time time.Time // local time of the last audio packet
+
+	mu sync.Mutex
+}
+
+func NewSession() *Session {
+	return &Session{
+		ssrc: rand.Uint32(),
+		seq:  uint16(rand.Uint32()),
+		ts:   rand.Uint32(),
+		time: time.Now(),
+	}
+}
+
+// Audio - change SSRC and sequence number of the outgoing audio packet to the session
+// ones, the packet should be the own copy of the sender
+func (s *Session) Audio(packet *rtp.Packet) {
+	s.mu.Lock()
+	s.seq++
+	packet.SSRC, packet.SequenceNumber = s.ssrc, s.seq
+	s.ts, s.time = packet.Timestamp, time.Now()
+	s.mu.Unlock()
+}
+
+// timestamp - current time on the audio timeline
+func (s *Session) timestamp(clockRate uint32) uint32 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.ts + uint32(time.Since(s.time)*time.Duration(clockRate)/time.Second)
+}
+
+func (s *Session) next() (ssrc uint32, seq uint16) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.seq++
+	return s.ssrc, s.seq
+}
+
+// WriteDigits - send digits one by one, blocks for the duration of all tones
+func (w *Writer) WriteDigits(digits string) error {
+	events := make([]byte, len(digits))
+	for i := 0; i < len(digits); i++ {
+		event, ok := Event(digits[i])
+		if !ok {
+			return ErrDigit
+		}
+		events[i] = event
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.Session == nil {
+		w.Session = NewSession()
+	}
+
+	for i, event := range events {
+		if i > 0 {
+			time.Sleep(Pause)
+		}
+		if err := w.writeEvent(event); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// writeEvent - updates with the growing duration, the first one with the marker, and
+// the end packets with the final duration, all with the timestamp of the event start
+func (w *Writer) writeEvent(event byte) error {
+	ts := w.Session.timestamp(w.ClockRate)
+	step := uint16(Interval * time.Duration(w.ClockRate) / time.Second)
+
+	var duration uint16
+	for i := 0; duration < uint16(Tone/Interval)*step; i++ {
+		if i > 0 {
+			time.Sleep(Interval)
+		}
+		duration += step
+		if err := w.write(i == 0, ts, Payload(event, false, duration)); err != nil {
+			return err
+		}
+	}
+
+	for range Retries {
+		time.Sleep(Interval)
+		if err := w.write(false, ts, Payload(event, true, duration)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (w *Writer) write(marker bool, ts uint32, payload []byte) error {
+	ssrc, seq := w.Session.next()
+	return w.Write(&rtp.Packet{
+		Header: rtp.Header{
+			Version:        2,
+			Marker:         marker,
+			PayloadType:    w.PayloadType,
+			SequenceNumber: seq,
+			Timestamp:      ts,
+			SSRC:           ssrc,
+		},
+		Payload: payload,
+	})
+}
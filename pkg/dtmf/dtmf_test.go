@@ -0,0 +1,77 @@
+package dtmf
+
+import (
+	"testing"
+
+	"github.com/pion/rtp"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEvent(t *testing.T) {
+	for digit, event := range map[byte]byte{'0': 0, '9': 9, '*': 10, '#': 11, 'A': 12, 'd': 15} {
+		b, ok := Event(digit)
+		require.True(t, ok)
+		require.Equal(t, event, b)
+	}
+	_, ok := Event('E')
+	require.False(t, ok)
+}
+
+func TestWriter(t *testing.T) {
+	var packets []*rtp.Packet
+	w := &Writer{
+		PayloadType: 101,
+		ClockRate:   8000,
+		Write: func(packet *rtp.Packet) error {
+			packets = append(packets, packet)
+			return nil
+		},
+	}
+
+	require.ErrorIs(t, w.WriteDigits("1x"), ErrDigit)
+	require.Nil(t, packets)
+
+	require.Nil(t, w.WriteDigits("#"))
+
+	// two updates for the tone and three end packets
+	require.Len(t, packets, 5)
+	require.True(t, packets[0].Marker)
+	require.Equal(t, []byte{11, Volume, 0x01, 0x90}, packets[0].Payload) // 400
+	require.Equal(t, []byte{11, Volume, 0x03, 0x20}, packets[1].Payload) // 800
+
+	for i, packet := range packets {
+		require.Equal(t, uint8(101), packet.PayloadType)
+		require.Equal(t, packets[0].Timestamp, packet.Timestamp)
+		require.Equal(t, packets[0].SequenceNumber+uint16(i), packet.SequenceNumber)
+		if i >= 2 {
+			require.False(t, packet.Marker)
+			require.Equal(t, []byte{11, 0x80 | Volume, 0x03, 0x20}, packet.Payload)
+		}
+	}
+}
+
+func TestSession(t *testing.T) {
+	var packets []*rtp.Packet
+	w := &Writer{
+		PayloadType: 101,
+		ClockRate:   8000,
+		Write: func(packet *rtp.Packet) error {
+			packets = append(packets, packet)
+			return nil
+		},
+		Session: NewSession(),
+	}
+
+	audio := &rtp.Packet{Header: rtp.Header{SSRC: 1, SequenceNumber: 100, Timestamp: 16000}}
+	w.Session.Audio(audio)
+	require.NotEqual(t, uint32(1), audio.SSRC)
+
+	require.Nil(t, w.WriteDigits("5"))
+	require.Equal(t, audio.SSRC, packets[0].SSRC)
+	require.Equal(t, audio.SequenceNumber+1, packets[0].SequenceNumber)
+	require.Less(t, packets[0].Timestamp-audio.Timestamp, uint32(800)) // less than 100ms after the audio
+
+	// audio after the event continues the sequence
+	w.Session.Audio(audio)
+	require.Equal(t, packets[len(packets)-1].SequenceNumber+1, audio.SequenceNumber)
+}
@@ -0,0 +1,71 @@
+package h264
+
+import (
+	"io"
+
+	"github.com/AlexxIT/go2rtc/pkg/core"
+	"github.com/AlexxIT/go2rtc/pkg/h264/annexb"
+	"github.com/pion/rtp"
+)
+
+// Consumer - raw Annex-B bitstream, starts from keyframe with SPS/PPS
+type Consumer struct {
+	core.Connection
+	wr *core.WriteBuffer
+}
+
+func NewConsumer() *Consumer {
+	medias := []*core.Media{
+		{
+			Kind:      core.KindVideo,
+			Direction: core.DirectionSendonly,
+			Codecs: []*core.Codec{
+				{Name: core.CodecH264},
+			},
+		},
+	}
+	wr := core.NewWriteBuffer(nil)
+	return &Consumer{
+		Connection: core.Connection{
+			ID:         core.NewID(),
+			FormatName: "h264",
+			Medias:     medias,
+			Transport:  wr,
+		},
+		wr: wr,
+	}
+}
+
+func (c *Consumer) AddTrack(media *core.Media, codec *core.Codec, track *core.Receiver) error {
+	sender := core.NewSender(media, track.Codec)
+
+	var start bool
+
+	sender.Handler = func(pkt *rtp.Packet) {
+		if !start {
+			if !IsKeyframe(pkt.Payload) {
+				return
+			}
+			start = true
+		}
+
+		b := annexb.DecodeAVCC(pkt.Payload, true)
+		if n, err := c.wr.Write(b); err == nil {
+			c.Send += n
+		}
+	}
+
+	if track.Codec.IsRTP() {
+		sender.Handler = RTPDepay(track.Codec, sender.Handler)
+	} else {
+		sender.Handler = RepairAVCC(track.Codec, sender.Handler)
+	}
+
+	sender.HandleRTP(track)
+	c.Senders = append(c.Senders, sender)
+	return nil
+}
+
+func (c *Consumer) WriteTo(wr io.Writer) (int64, error) {
+	return c.wr.WriteTo(wr)
+}
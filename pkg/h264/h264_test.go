@@ -108,3 +108,41 @@ func TestAVCCToCodec(t *testing.T) {
 	codec := AVCCToCodec(b)
 	require.Equal(t, "packetization-mode=1;profile-level-id=64001f;sprop-parameter-sets=Z2QAH6wkhAFAFuwEQAAAAwBAAAAMI8YMkg==,aO4yyLA=", codec.FmtpLine)
 }
+
+func TestTemporalID(t *testing.T) {
+	// prefix NAL unit with temporal_id=2 and the base layer slice
+	prefix := []byte{0x6E, 0x80, 0x00, 0x40 | 2<<5}
+	slice := []byte{0x41, 0x9a, 0x02}
+
+	tid, ok := TemporalID(prefix)
+	require.True(t, ok)
+	require.Equal(t, byte(2), tid)
+
+	_, ok = TemporalID(slice)
+	require.False(t, ok)
+
+	tid, ok = AUTemporalID(JoinNALU(prefix, slice))
+	require.True(t, ok)
+	require.Equal(t, byte(2), tid)
+
+	// STAP-A
+	tid, ok = RTPTemporalID([]byte{0x78, 0x00, 0x04, 0x6E, 0x80, 0x00, 0x20, 0x00, 0x03, 0x41, 0x9a, 0x02})
+	require.True(t, ok)
+	require.Equal(t, byte(1), tid)
+
+	// FU-A start of the slice extension
+	tid, ok = RTPTemporalID([]byte{0x7C, 0x80 | NALUTypeSliceExt, 0x80, 0x00, 0x60})
+	require.True(t, ok)
+	require.Equal(t, byte(3), tid)
+}
+
+func TestIsKeyframeRTP(t *testing.T) {
+	require.True(t, IsKeyframeRTP([]byte{0x65, 0x88, 0x84}))                                // IDR
+	require.True(t, IsKeyframeRTP([]byte{0x78, 0x00, 0x01, 0x68, 0x00, 0x02, 0x65, 0x88}))  // STAP-A with PPS and IDR
+	require.True(t, IsKeyframeRTP([]byte{0x7C, 0x85, 0x88}))                                // FU-A IDR start
+	require.False(t, IsKeyframeRTP([]byte{0x7C, 0x05, 0x88}))                               // FU-A IDR middle
+	require.False(t, IsKeyframeRTP([]byte{0x67, 0x64, 0x00}))                               // SPS
+	require.True(t, IsParamSetRTP([]byte{0x67, 0x64, 0x00}))                                // SPS
+	require.True(t, IsParamSetRTP([]byte{0x78, 0x00, 0x02, 0x67, 0x64, 0x00, 0x01, 0x68}))  // STAP-A with SPS and PPS
+	require.False(t, IsParamSetRTP([]byte{0x78, 0x00, 0x01, 0x68, 0x00, 0x02, 0x65, 0x88})) // STAP-A with PPS and IDR
+}
@@ -135,3 +135,39 @@ func RTPPay(mtu uint16, handler core.HandlerFunc) core.HandlerFunc {
 		}
 	}
 }
+
+// IsKeyframeRTP - check if RTP payload starts IDR slice: single NAL unit,
+// any unit of STAP-A or the first fragment of FU-A
+func IsKeyframeRTP(payload []byte) bool {
+	return hasTypeRTP(payload, NALUTypeIFrame)
+}
+
+// IsParamSetRTP - check if RTP payload has SPS: single NAL unit or any unit of STAP-A
+func IsParamSetRTP(payload []byte) bool {
+	return hasTypeRTP(payload, NALUTypeSPS)
+}
+
+func hasTypeRTP(payload []byte, typ byte) bool {
+	if len(payload) < 3 {
+		return false
+	}
+
+	switch payload[0] & 0x1F {
+	case 24: // STAP-A
+		for b := payload[1:]; len(b) > 2; {
+			if b[2]&0x1F == typ {
+				return true
+			}
+			size := int(binary.BigEndian.Uint16(b))
+			if size == 0 || 2+size > len(b) {
+				return false
+			}
+			b = b[2+size:]
+		}
+		return false
+	case 28: // FU-A
+		return payload[1]&0x80 != 0 && payload[1]&0x1F == typ
+	}
+
+	return payload[0]&0x1F == typ
+}
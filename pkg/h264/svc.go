@@ -0,0 +1,69 @@
+package h264
+
+import "encoding/binary"
+
+const (
+	NALUTypePrefix   = 14 // Prefix NAL unit (SVC)
+	NALUTypeSliceExt = 20 // Coded slice extension (SVC)
+)
+
+// TemporalID - temporal_id from the SVC header extension of one NAL unit (without
+// the size prefix), false for NAL units without the extension
+func TemporalID(nalu []byte) (byte, bool) {
+	if len(nalu) < 4 {
+		return 0, false
+	}
+	switch nalu[0] & 0x1F {
+	case NALUTypePrefix, NALUTypeSliceExt:
+		return nalu[3] >> 5, true
+	}
+	return 0, false
+}
+
+// AUTemporalID - temporal_id of the AVCC access unit, false if AU doesn't have SVC NAL units
+func AUTemporalID(avcc []byte) (byte, bool) {
+	for len(avcc) > 4 {
+		size := int(binary.BigEndian.Uint32(avcc)) + 4
+		if size > len(avcc) {
+			break
+		}
+		if tid, ok := TemporalID(avcc[4:size]); ok {
+			return tid, true
+		}
+		avcc = avcc[size:]
+	}
+	return 0, false
+}
+
+// RTPTemporalID - temporal_id from RTP payload: single NAL unit, STAP-A or the first
+// FU-A fragment, false if the payload doesn't have it
+func RTPTemporalID(payload []byte) (byte, bool) {
+	if len(payload) < 2 {
+		return 0, false
+	}
+
+	switch payload[0] & 0x1F {
+	case 24: // STAP-A
+		for b := payload[1:]; len(b) > 2; {
+			size := int(binary.BigEndian.Uint16(b)) + 2
+			if size > len(b) {
+				break
+			}
+			if tid, ok := TemporalID(b[2:size]); ok {
+				return tid, true
+			}
+			b = b[size:]
+		}
+		return 0, false
+	case 28: // FU-A, header extension goes after the FU header
+		if payload[1]&0x80 != 0 && len(payload) >= 5 {
+			switch payload[1] & 0x1F {
+			case NALUTypePrefix, NALUTypeSliceExt:
+				return payload[4] >> 5, true
+			}
+		}
+		return 0, false
+	}
+
+	return TemporalID(payload)
+}
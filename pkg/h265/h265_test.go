@@ -16,4 +16,23 @@ func TestDecodeSPS(t *testing.T) {
 	require.NotNil(t, sps)
 	require.Equal(t, uint16(5120), sps.Width())
 	require.Equal(t, uint16(1440), sps.Height())
+	require.Equal(t, 20.0, sps.FrameRate()) // VUI timing 27000000/1350000
+}
+
+func TestTemporalID(t *testing.T) {
+	require.Equal(t, byte(0), TemporalID([]byte{0x02, 0x01})) // TRAIL_R, base layer
+	require.Equal(t, byte(2), TemporalID([]byte{0x00, 0x03})) // TRAIL_N
+
+	avcc := []byte{0, 0, 0, 2, 0x02, 0x01, 0, 0, 0, 2, 0x02, 0x02}
+	require.Equal(t, byte(1), AUTemporalID(avcc))
+}
+
+func TestIsKeyframeRTP(t *testing.T) {
+	require.True(t, IsKeyframeRTP([]byte{0x26, 0x01, 0xAF}))                                           // IDR_W_RADL
+	require.True(t, IsKeyframeRTP([]byte{0x62, 0x01, 0x93}))                                           // FU IDR start
+	require.False(t, IsKeyframeRTP([]byte{0x62, 0x01, 0x13}))                                          // FU IDR middle
+	require.True(t, IsKeyframeRTP([]byte{0x60, 0x01, 0x00, 0x02, 0x44, 0x01, 0x00, 0x02, 0x26, 0x01})) // AP with PPS and IDR
+	require.False(t, IsKeyframeRTP([]byte{0x02, 0x01, 0xD0}))                                          // TRAIL_R
+	require.True(t, IsParamSetRTP([]byte{0x40, 0x01, 0x0C}))                                           // VPS
+	require.False(t, IsParamSetRTP([]byte{0x44, 0x01, 0xC1}))                                          // PPS
 }
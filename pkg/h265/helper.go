@@ -74,3 +74,25 @@ func GetParameterSet(fmtp string) (vps, sps, pps []byte) {
 
 	return
 }
+
+// TemporalID - TemporalId from the NAL unit header (without the size prefix),
+// same for the RTP payload header of aggregation and fragmentation units
+func TemporalID(nalu []byte) byte {
+	if len(nalu) < 2 || nalu[1]&0x07 == 0 {
+		return 0
+	}
+	return nalu[1]&0x07 - 1
+}
+
+// AUTemporalID - the highest TemporalId of the AVCC access unit
+func AUTemporalID(avcc []byte) (tid byte) {
+	for len(avcc) > 4 {
+		size := int(binary.BigEndian.Uint32(avcc)) + 4
+		if size > len(avcc) {
+			break
+		}
+		tid = max(tid, TemporalID(avcc[4:size]))
+		avcc = avcc[size:]
+	}
+	return
+}
@@ -2,6 +2,7 @@ package h265
 
 import (
 	"encoding/binary"
+	"slices"
 
 	"github.com/AlexxIT/go2rtc/pkg/core"
 	"github.com/AlexxIT/go2rtc/pkg/h264"
@@ -219,3 +220,39 @@ func SafariPay(mtu uint16, handler core.HandlerFunc) core.HandlerFunc {
 		}
 	}
 }
+
+// IsKeyframeRTP - check if RTP payload starts IRAP picture: single NAL unit,
+// any unit of AP or the first fragment of FU
+func IsKeyframeRTP(payload []byte) bool {
+	return hasTypeRTP(payload, NALUTypeIFrame, NALUTypeIFrame2, NALUTypeIFrame3)
+}
+
+// IsParamSetRTP - check if RTP payload has VPS or SPS: single NAL unit or any unit of AP
+func IsParamSetRTP(payload []byte) bool {
+	return hasTypeRTP(payload, NALUTypeVPS, NALUTypeSPS)
+}
+
+func hasTypeRTP(payload []byte, types ...byte) bool {
+	if len(payload) < 3 {
+		return false
+	}
+
+	switch nalType := (payload[0] >> 1) & 0x3F; nalType {
+	case 48: // AP
+		for b := payload[2:]; len(b) > 2; {
+			if slices.Contains(types, (b[2]>>1)&0x3F) {
+				return true
+			}
+			size := int(binary.BigEndian.Uint16(b))
+			if size == 0 || 2+size > len(b) {
+				return false
+			}
+			b = b[2+size:]
+		}
+		return false
+	case NALUTypeFU:
+		return payload[2]&0x80 != 0 && slices.Contains(types, payload[2]&0x3F)
+	default:
+		return slices.Contains(types, nalType)
+	}
+}
@@ -29,6 +29,12 @@ type SPS struct {
 
 	pic_width_in_luma_samples  uint32
 	pic_height_in_luma_samples uint32
+
+	log2_max_pic_order_cnt_lsb_minus4 uint32
+
+	vui_timing_info_present_flag byte
+	vui_num_units_in_tick        uint32
+	vui_time_scale               uint32
 }
 
 func (s *SPS) Width() uint16 {
@@ -39,6 +45,14 @@ func (s *SPS) Height() uint16 {
 	return uint16(s.pic_height_in_luma_samples)
 }
 
+// FrameRate - from VUI timing info, zero if SPS doesn't have it
+func (s *SPS) FrameRate() float64 {
+	if s.vui_timing_info_present_flag == 0 || s.vui_num_units_in_tick == 0 {
+		return 0
+	}
+	return float64(s.vui_time_scale) / float64(s.vui_num_units_in_tick)
+}
+
 func DecodeSPS(nalu []byte) *SPS {
 	rbsp := bytes.ReplaceAll(nalu[2:], []byte{0, 0, 3}, []byte{0, 0})
 
@@ -62,15 +76,195 @@ func DecodeSPS(nalu []byte) *SPS {
 	s.pic_width_in_luma_samples = r.ReadUEGolomb()
 	s.pic_height_in_luma_samples = r.ReadUEGolomb()
 
-	//...
-
 	if r.EOF {
 		return nil
 	}
 
+	// timing info is optional, broken tail doesn't break the size
+	s.vui_timing_info(r)
+
 	return s
 }
 
+// vui_timing_info - skip all fields until VUI and read the timing info
+//
+//goland:noinspection GoSnakeCaseUsage
+func (s *SPS) vui_timing_info(r *bits.Reader) {
+	if conformance_window_flag := r.ReadBit(); conformance_window_flag != 0 {
+		for i := 0; i < 4; i++ {
+			_ = r.ReadUEGolomb() // conf_win_offset
+		}
+	}
+
+	_ = r.ReadUEGolomb() // bit_depth_luma_minus8
+	_ = r.ReadUEGolomb() // bit_depth_chroma_minus8
+	s.log2_max_pic_order_cnt_lsb_minus4 = r.ReadUEGolomb()
+
+	i := s.sps_max_sub_layers_minus1
+	if sps_sub_layer_ordering_info_present_flag := r.ReadBit(); sps_sub_layer_ordering_info_present_flag != 0 {
+		i = 0
+	}
+	for ; i <= s.sps_max_sub_layers_minus1; i++ {
+		_ = r.ReadUEGolomb() // sps_max_dec_pic_buffering_minus1
+		_ = r.ReadUEGolomb() // sps_max_num_reorder_pics
+		_ = r.ReadUEGolomb() // sps_max_latency_increase_plus1
+	}
+
+	for i := 0; i < 6; i++ {
+		_ = r.ReadUEGolomb() // coding and transform block sizes, hierarchy depths
+	}
+
+	if scaling_list_enabled_flag := r.ReadBit(); scaling_list_enabled_flag != 0 {
+		if sps_scaling_list_data_present_flag := r.ReadBit(); sps_scaling_list_data_present_flag != 0 {
+			scaling_list_data(r)
+		}
+	}
+
+	_ = r.ReadBit() // amp_enabled_flag
+	_ = r.ReadBit() // sample_adaptive_offset_enabled_flag
+
+	if pcm_enabled_flag := r.ReadBit(); pcm_enabled_flag != 0 {
+		_ = r.ReadBits8(4)   // pcm_sample_bit_depth_luma_minus1
+		_ = r.ReadBits8(4)   // pcm_sample_bit_depth_chroma_minus1
+		_ = r.ReadUEGolomb() // log2_min_pcm_luma_coding_block_size_minus3
+		_ = r.ReadUEGolomb() // log2_diff_max_min_pcm_luma_coding_block_size
+		_ = r.ReadBit()      // pcm_loop_filter_disabled_flag
+	}
+
+	num_short_term_ref_pic_sets := r.ReadUEGolomb()
+	if num_short_term_ref_pic_sets > 64 {
+		return // broken SPS
+	}
+	num_delta_pocs := make([]uint32, num_short_term_ref_pic_sets)
+	for idx := range num_delta_pocs {
+		num_delta_pocs[idx] = st_ref_pic_set(r, idx, num_delta_pocs)
+	}
+
+	if long_term_ref_pics_present_flag := r.ReadBit(); long_term_ref_pics_present_flag != 0 {
+		num_long_term_ref_pics_sps := r.ReadUEGolomb()
+		for j := uint32(0); j < num_long_term_ref_pics_sps && !r.EOF; j++ {
+			_ = r.ReadBits(byte(s.log2_max_pic_order_cnt_lsb_minus4 + 4)) // lt_ref_pic_poc_lsb_sps
+			_ = r.ReadBit()                                               // used_by_curr_pic_lt_sps_flag
+		}
+	}
+
+	_ = r.ReadBit() // sps_temporal_mvp_enabled_flag
+	_ = r.ReadBit() // strong_intra_smoothing_enabled_flag
+
+	if vui_parameters_present_flag := r.ReadBit(); vui_parameters_present_flag == 0 {
+		return
+	}
+
+	if aspect_ratio_info_present_flag := r.ReadBit(); aspect_ratio_info_present_flag != 0 {
+		if aspect_ratio_idc := r.ReadBits8(8); aspect_ratio_idc == 255 {
+			_ = r.ReadBits16(16) // sar_width
+			_ = r.ReadBits16(16) // sar_height
+		}
+	}
+
+	if overscan_info_present_flag := r.ReadBit(); overscan_info_present_flag != 0 {
+		_ = r.ReadBit() // overscan_appropriate_flag
+	}
+
+	if video_signal_type_present_flag := r.ReadBit(); video_signal_type_present_flag != 0 {
+		_ = r.ReadBits8(3) // video_format
+		_ = r.ReadBit()    // video_full_range_flag
+		if colour_description_present_flag := r.ReadBit(); colour_description_present_flag != 0 {
+			_ = r.ReadBits8(8) // colour_primaries
+			_ = r.ReadBits8(8) // transfer_characteristics
+			_ = r.ReadBits8(8) // matrix_coeffs
+		}
+	}
+
+	if chroma_loc_info_present_flag := r.ReadBit(); chroma_loc_info_present_flag != 0 {
+		_ = r.ReadUEGolomb() // chroma_sample_loc_type_top_field
+		_ = r.ReadUEGolomb() // chroma_sample_loc_type_bottom_field
+	}
+
+	_ = r.ReadBit() // neutral_chroma_indication_flag
+	_ = r.ReadBit() // field_seq_flag
+	_ = r.ReadBit() // frame_field_info_present_flag
+
+	if default_display_window_flag := r.ReadBit(); default_display_window_flag != 0 {
+		for i := 0; i < 4; i++ {
+			_ = r.ReadUEGolomb() // def_disp_win_offset
+		}
+	}
+
+	vui_timing_info_present_flag := r.ReadBit()
+	if vui_timing_info_present_flag == 0 {
+		return
+	}
+	vui_num_units_in_tick := r.ReadBits(32)
+	vui_time_scale := r.ReadBits(32)
+
+	if r.EOF {
+		return
+	}
+
+	s.vui_timing_info_present_flag = vui_timing_info_present_flag
+	s.vui_num_units_in_tick = vui_num_units_in_tick
+	s.vui_time_scale = vui_time_scale
+}
+
+//goland:noinspection GoSnakeCaseUsage
+func scaling_list_data(r *bits.Reader) {
+	for sizeId := 0; sizeId < 4; sizeId++ {
+		step := 1
+		if sizeId == 3 {
+			step = 3
+		}
+		for matrixId := 0; matrixId < 6; matrixId += step {
+			if scaling_list_pred_mode_flag := r.ReadBit(); scaling_list_pred_mode_flag == 0 {
+				_ = r.ReadUEGolomb() // scaling_list_pred_matrix_id_delta
+				continue
+			}
+			coefNum := min(64, 1<<(4+(sizeId<<1)))
+			if sizeId > 1 {
+				_ = r.ReadSEGolomb() // scaling_list_dc_coef_minus8
+			}
+			for i := 0; i < coefNum; i++ {
+				_ = r.ReadSEGolomb() // scaling_list_delta_coef
+			}
+		}
+	}
+}
+
+// st_ref_pic_set - skip the short-term reference picture set, returns NumDeltaPocs
+//
+//goland:noinspection GoSnakeCaseUsage
+func st_ref_pic_set(r *bits.Reader, idx int, num_delta_pocs []uint32) (n uint32) {
+	if idx != 0 {
+		if inter_ref_pic_set_prediction_flag := r.ReadBit(); inter_ref_pic_set_prediction_flag != 0 {
+			// delta_idx_minus1 is only in the slice header, so the reference is the previous set
+			_ = r.ReadBit()      // delta_rps_sign
+			_ = r.ReadUEGolomb() // abs_delta_rps_minus1
+			for j := uint32(0); j <= num_delta_pocs[idx-1] && !r.EOF; j++ {
+				used_by_curr_pic_flag := r.ReadBit()
+				use_delta_flag := byte(1)
+				if used_by_curr_pic_flag == 0 {
+					use_delta_flag = r.ReadBit()
+				}
+				if used_by_curr_pic_flag != 0 || use_delta_flag != 0 {
+					n++
+				}
+			}
+			return
+		}
+	}
+
+	num_negative_pics := r.ReadUEGolomb()
+	num_positive_pics := r.ReadUEGolomb()
+	if num_negative_pics > 16 || num_positive_pics > 16 {
+		return 0 // broken SPS, the reader will stop on EOF
+	}
+	for j := uint32(0); j < num_negative_pics+num_positive_pics; j++ {
+		_ = r.ReadUEGolomb() // delta_poc_s0_minus1 or delta_poc_s1_minus1
+		_ = r.ReadBit()      // used_by_curr_pic_s0_flag or used_by_curr_pic_s1_flag
+	}
+	return num_negative_pics + num_positive_pics
+}
+
 // profile_tier_level supports ONLY general_profile_idc == 1
 // over variants very complicated...
 //
@@ -0,0 +1,117 @@
+package hls
+
+import (
+	"strings"
+	"time"
+
+	"github.com/AlexxIT/go2rtc/pkg/core"
+)
+
+// playlist - media playlist, only tags important for the live stream
+type playlist struct {
+	targetDuration time.Duration
+	segments       []*segment
+	end            bool // EXT-X-ENDLIST, no more segments
+}
+
+type segment struct {
+	uri           string
+	sequence      int
+	init          string // EXT-X-MAP URI, ex. fMP4 init segment
+	discontinuity bool
+}
+
+func parsePlaylist(b []byte) *playlist {
+	p := &playlist{}
+
+	var sequence int
+	var init string
+	var discontinuity, inf bool
+
+	for _, line := range strings.Split(string(b), "\n") {
+		line = strings.TrimSpace(line)
+		switch {
+		case line == "":
+		case strings.HasPrefix(line, "#EXT-X-TARGETDURATION:"):
+			p.targetDuration = time.Duration(core.Atoi(line[22:])) * time.Second
+		case strings.HasPrefix(line, "#EXT-X-MEDIA-SEQUENCE:"):
+			sequence = core.Atoi(line[22:])
+		case strings.HasPrefix(line, "#EXT-X-MAP:"):
+			init = attributes(line)["URI"]
+		case line == "#EXT-X-DISCONTINUITY":
+			discontinuity = true
+		case line == "#EXT-X-ENDLIST":
+			p.end = true
+		case strings.HasPrefix(line, "#EXTINF:"):
+			inf = true
+		case line[0] == '#':
+			// skip other tags
+		case inf:
+			p.segments = append(p.segments, &segment{
+				uri:           line,
+				sequence:      sequence,
+				init:          init,
+				discontinuity: discontinuity,
+			})
+			sequence++
+			discontinuity, inf = false, false
+		}
+	}
+
+	return p
+}
+
+// variant - URI of the first variant stream with supported codecs (or just the first),
+// empty for the media playlist
+func variant(b []byte) (uri string) {
+	lines := strings.Split(string(b), "\n")
+	for i := 0; i < len(lines)-1; i++ {
+		line := strings.TrimSpace(lines[i])
+		if !strings.HasPrefix(line, "#EXT-X-STREAM-INF:") {
+			continue
+		}
+		next := strings.TrimSpace(lines[i+1])
+		if supported(attributes(line)["CODECS"]) {
+			return next
+		}
+		if uri == "" {
+			uri = next
+		}
+	}
+	return
+}
+
+// supported - RFC 6381 codecs list, ex. "avc1.64001f,mp4a.40.2", empty list is unknown
+func supported(codecs string) bool {
+	if codecs == "" {
+		return false
+	}
+	for _, codec := range strings.Split(codecs, ",") {
+		name, _, _ := strings.Cut(strings.TrimSpace(codec), ".")
+		switch name {
+		case "avc1", "avc3", "hvc1", "hev1", "mp4a", "Opus", "opus":
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// attributes - ex. #EXT-X-STREAM-INF:BANDWIDTH=1280000,CODECS="avc1.4d401f,mp4a.40.2"
+func attributes(line string) map[string]string {
+	_, s, _ := strings.Cut(line, ":")
+
+	attrs := map[string]string{}
+	for s != "" {
+		var key, value string
+		key, s, _ = strings.Cut(s, "=")
+		if strings.HasPrefix(s, `"`) {
+			value, s, _ = strings.Cut(s[1:], `"`)
+			_, s, _ = strings.Cut(s, ",")
+		} else {
+			value, s, _ = strings.Cut(s, ",")
+		}
+		attrs[key] = value
+	}
+	return attrs
+}
@@ -4,19 +4,35 @@ import (
 	"io"
 	"net/url"
 
+	"github.com/AlexxIT/go2rtc/pkg/core"
+	"github.com/AlexxIT/go2rtc/pkg/mp4"
 	"github.com/AlexxIT/go2rtc/pkg/mpegts"
 )
 
-func OpenURL(u *url.URL, body io.ReadCloser) (*mpegts.Producer, error) {
-	rd, err := NewReader(u, body)
+// OpenURL - HLS with TS or fMP4 segments, codecs come from the first segments or
+// the init segment
+func OpenURL(u *url.URL, body io.ReadCloser) (core.Producer, error) {
+	rd, err := newReader(u, body)
 	if err != nil {
 		return nil, err
 	}
+
+	if rd.fmp4 {
+		prod, err := mp4.Open(rd)
+		if err != nil {
+			return nil, err
+		}
+		prod.FormatName = "hls/mp4"
+		prod.RemoteAddr = u.Host
+		return prod, nil
+	}
+
 	prod, err := mpegts.Open(rd)
 	if err != nil {
 		return nil, err
 	}
 	prod.FormatName = "hls/mpegts"
+	prod.Discontinuity = true
 	prod.RemoteAddr = u.Host
 	return prod, nil
 }
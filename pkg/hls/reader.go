@@ -1,64 +1,85 @@
 package hls
 
 import (
-	"bytes"
+	"errors"
 	"io"
 	"net/http"
 	"net/url"
-	"regexp"
 	"time"
 
 	"github.com/AlexxIT/go2rtc/pkg/core"
 )
 
 type reader struct {
-	client  *http.Client
-	request *http.Request
+	client *http.Client
+	url    *url.URL // media playlist
 
-	playlist    []byte
-	lastSegment []byte
-	lastTime    time.Time
+	playlist *playlist
+	loadTime time.Time
+	updated  bool // last reload has new segments
+	stalls   int
+
+	sequence int    // next segment
+	init     string // last sent init segment
+	cache    map[string][]byte
+	fmp4     bool
 
 	buf []byte
 }
 
 func NewReader(u *url.URL, body io.ReadCloser) (io.Reader, error) {
+	return newReader(u, body)
+}
+
+func newReader(u *url.URL, body io.ReadCloser) (*reader, error) {
 	b, err := io.ReadAll(body)
+	_ = body.Close()
 	if err != nil {
 		return nil, err
 	}
 
-	var rawURL string
+	r := &reader{
+		client: &http.Client{Timeout: core.ConnDialTimeout},
+		url:    u,
+		cache:  map[string][]byte{},
+	}
 
-	re := regexp.MustCompile(`#EXT-X-STREAM-INF.+?\n(\S+)`)
-	m := re.FindSubmatch(b)
-	if m != nil {
-		ref, err := url.Parse(string(m[1]))
-		if err != nil {
+	// master playlist, switch to the media playlist of the variant stream
+	if uri := variant(b); uri != "" {
+		if r.url, err = r.resolve(uri); err != nil {
+			return nil, err
+		}
+		if b, err = r.get(r.url); err != nil {
 			return nil, err
 		}
-
-		rawURL = u.ResolveReference(ref).String()
-	} else {
-		rawURL = u.String()
 	}
 
-	req, err := http.NewRequest("GET", rawURL, nil)
-	if err != nil {
-		return nil, err
+	r.playlist = parsePlaylist(b)
+	r.loadTime = time.Now()
+
+	if len(r.playlist.segments) == 0 {
+		return nil, errors.New("hls: empty playlist")
 	}
 
-	rd := &reader{
-		client:  &http.Client{Timeout: core.ConnDialTimeout},
-		request: req,
+	first := r.playlist.segments[0]
+	r.sequence = first.sequence
+
+	if first.init != "" {
+		init, err := r.initSegment(first.init)
+		if err != nil {
+			return nil, err
+		}
+		// EXT-X-MAP can be used also for TS segments with PAT and PMT
+		r.fmp4 = len(init) >= 8 && (string(init[4:8]) == "ftyp" || string(init[4:8]) == "moov")
 	}
-	return rd, nil
+
+	return r, nil
 }
 
 func (r *reader) Read(dst []byte) (n int, err error) {
 	// 1. Check temporary tempbuffer
 	if len(r.buf) == 0 {
-		src, err2 := r.getSegment()
+		src, err2 := r.next()
 		if err2 != nil {
 			return 0, err2
 		}
@@ -87,79 +108,134 @@ func (r *reader) RoundTrip(_ *http.Request) (*http.Response, error) {
 	return nil, io.EOF
 }
 
-func (r *reader) getSegment() ([]byte, error) {
-	for i := 0; i < 10; i++ {
-		if r.playlist == nil {
-			if wait := time.Second - time.Since(r.lastTime); wait > 0 {
-				time.Sleep(wait)
-			}
+// maxStalls - playlist reloads without new segments before the error
+const maxStalls = 10
 
-			// 1. Load playlist
-			res, err := r.client.Do(r.request)
-			if err != nil {
-				return nil, err
+func (r *reader) next() ([]byte, error) {
+	for {
+		for _, seg := range r.playlist.segments {
+			if seg.sequence >= r.sequence {
+				r.sequence = seg.sequence + 1
+				return r.segment(seg)
 			}
+		}
 
-			r.playlist, err = io.ReadAll(res.Body)
-			if err != nil {
-				return nil, err
-			}
+		if r.playlist.end {
+			return nil, io.EOF
+		}
 
-			r.lastTime = time.Now()
+		if r.stalls++; r.stalls > maxStalls {
+			return nil, errors.New("hls: playlist not updated")
+		}
 
-			//log.Printf("[hls] load playlist\n%s", r.playlist)
+		if err := r.reload(); err != nil {
+			return nil, err
 		}
+	}
+}
 
-		for r.playlist != nil {
-			// 2. Remove all previous segments from playlist
-			if i := bytes.Index(r.playlist, r.lastSegment); i > 0 {
-				r.playlist = r.playlist[i:]
-			}
+// reload - wait the target duration after the updated playlist and the half of it
+// after the same playlist, like RFC 8216 6.3.4 says
+func (r *reader) reload() error {
+	wait := r.playlist.targetDuration
+	if !r.updated {
+		wait /= 2
+	}
+	wait = max(wait, time.Second)
 
-			// 3. Get link to new segment
-			segment := getSegment(r.playlist)
-			if segment == nil {
-				r.playlist = nil
-				break
-			}
+	if wait -= time.Since(r.loadTime); wait > 0 {
+		time.Sleep(wait)
+	}
 
-			//log.Printf("[hls] load segment: %s", segment)
+	b, err := r.get(r.url)
+	if err != nil {
+		return err
+	}
 
-			ref, err := url.Parse(string(segment))
-			if err != nil {
-				return nil, err
-			}
+	p := parsePlaylist(b)
+	r.loadTime = time.Now()
 
-			ref = r.request.URL.ResolveReference(ref)
-			res, err := r.client.Get(ref.String())
-			if err != nil {
-				return nil, err
-			}
+	if n := len(p.segments); n > 0 {
+		last := p.segments[n-1].sequence
+		if last+1 < r.sequence {
+			// media sequence from the start, ex. after the source restart
+			r.sequence = p.segments[0].sequence
+			p.segments[0].discontinuity = true
+		}
+		r.updated = last >= r.sequence
+	} else {
+		r.updated = false
+	}
 
-			r.lastSegment = segment
+	r.playlist = p
+	return nil
+}
 
-			return io.ReadAll(res.Body)
+// segment - media segment with the init segment before it on the start, on the init
+// change and after the discontinuity, fMP4 demuxer checks codecs and timestamps of it
+func (r *reader) segment(seg *segment) ([]byte, error) {
+	u, err := r.resolve(seg.uri)
+	if err != nil {
+		return nil, err
+	}
+
+	b, err := r.get(u)
+	if err != nil {
+		return nil, err
+	}
+
+	r.stalls = 0
+
+	if seg.init != "" && (seg.init != r.init || seg.discontinuity) {
+		init, err := r.initSegment(seg.init)
+		if err != nil {
+			return nil, err
 		}
+		r.init = seg.init
+		b = append(init[:len(init):len(init)], b...)
 	}
 
-	return nil, io.EOF
+	return b, nil
 }
 
-func getSegment(src []byte) []byte {
-	for ok := false; !ok; {
-		ok = bytes.HasPrefix(src, []byte("#EXTINF"))
+func (r *reader) initSegment(uri string) ([]byte, error) {
+	if b, ok := r.cache[uri]; ok {
+		return b, nil
+	}
+
+	u, err := r.resolve(uri)
+	if err != nil {
+		return nil, err
+	}
 
-		i := bytes.IndexByte(src, '\n') + 1
-		if i == 0 {
-			return nil
-		}
+	b, err := r.get(u)
+	if err != nil {
+		return nil, err
+	}
 
-		src = src[i:]
+	clear(r.cache) // keep only the last init segment
+	r.cache[uri] = b
+	return b, nil
+}
+
+func (r *reader) resolve(uri string) (*url.URL, error) {
+	ref, err := url.Parse(uri)
+	if err != nil {
+		return nil, err
+	}
+	return r.url.ResolveReference(ref), nil
+}
+
+func (r *reader) get(u *url.URL) ([]byte, error) {
+	res, err := r.client.Get(u.String())
+	if err != nil {
+		return nil, err
 	}
+	defer res.Body.Close()
 
-	if i := bytes.IndexByte(src, '\n'); i > 0 {
-		return src[:i]
+	if res.StatusCode != http.StatusOK {
+		return nil, errors.New("hls: " + res.Status)
 	}
 
-	return src
+	return io.ReadAll(res.Body)
 }
@@ -0,0 +1,88 @@
+package hls
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParsePlaylist(t *testing.T) {
+	p := parsePlaylist([]byte(`#EXTM3U
+#EXT-X-VERSION:7
+#EXT-X-TARGETDURATION:2
+#EXT-X-MEDIA-SEQUENCE:100
+#EXT-X-MAP:URI="init.mp4"
+#EXTINF:2.000,
+seg100.m4s
+#EXT-X-DISCONTINUITY
+#EXT-X-MAP:URI="init2.mp4",BYTERANGE="800@0"
+#EXTINF:2.000,
+seg101.m4s
+#EXT-X-ENDLIST
+`))
+	require.Equal(t, 2*time.Second, p.targetDuration)
+	require.True(t, p.end)
+	require.Equal(t, []*segment{
+		{uri: "seg100.m4s", sequence: 100, init: "init.mp4"},
+		{uri: "seg101.m4s", sequence: 101, init: "init2.mp4", discontinuity: true},
+	}, p.segments)
+
+	master := []byte(`#EXTM3U
+#EXT-X-STREAM-INF:BANDWIDTH=2000000,CODECS="av01.0.04M.08,mp4a.40.2"
+av1.m3u8
+#EXT-X-STREAM-INF:BANDWIDTH=1000000,CODECS="avc1.64001f,mp4a.40.2"
+h264.m3u8
+`)
+	require.Equal(t, "h264.m3u8", variant(master))
+	require.Equal(t, "", variant([]byte("#EXTM3U\n#EXTINF:2,\nseg.ts\n")))
+}
+
+func TestReader(t *testing.T) {
+	files := map[string]string{
+		"/master.m3u8": "#EXTM3U\n#EXT-X-STREAM-INF:BANDWIDTH=1000000\nlive/index.m3u8\n",
+		"/live/index.m3u8": `#EXTM3U
+#EXT-X-TARGETDURATION:1
+#EXT-X-MEDIA-SEQUENCE:5
+#EXT-X-MAP:URI="init.mp4"
+#EXTINF:1.0,
+5.m4s
+#EXTINF:1.0,
+6.m4s
+#EXT-X-DISCONTINUITY
+#EXTINF:1.0,
+7.m4s
+#EXT-X-ENDLIST
+`,
+		"/live/init.mp4": "\x00\x00\x00\x08ftyp",
+		"/live/5.m4s":    "[5]",
+		"/live/6.m4s":    "[6]",
+		"/live/7.m4s":    "[7]",
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s, ok := files[r.URL.Path]; ok {
+			_, _ = w.Write([]byte(s))
+		} else {
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	u, _ := url.Parse(server.URL + "/master.m3u8")
+	rd, err := newReader(u, io.NopCloser(strings.NewReader(files["/master.m3u8"])))
+	require.Nil(t, err)
+	require.True(t, rd.fmp4)
+
+	b, err := io.ReadAll(rd)
+	require.Nil(t, err)
+
+	// init segment on the start and after the discontinuity
+	init := files["/live/init.mp4"]
+	require.Equal(t, init+"[5][6]"+init+"[7]", string(b))
+}
@@ -261,6 +261,8 @@ func (m *Movie) WriteAudioTrack(id uint32, codec string, timescale uint32, chann
 }
 
 const (
+	TfhdBaseDataOffset        = 0x000001
+	TfhdSampleDescription     = 0x000002
 	TfhdDefaultSampleDuration = 0x000008
 	TfhdDefaultSampleSize     = 0x000010
 	TfhdDefaultSampleFlags    = 0x000020
@@ -10,8 +10,10 @@ import (
 	"github.com/AlexxIT/go2rtc/pkg/core"
 	"github.com/AlexxIT/go2rtc/pkg/flv"
 	"github.com/AlexxIT/go2rtc/pkg/h264/annexb"
+	"github.com/AlexxIT/go2rtc/pkg/iso"
 	"github.com/AlexxIT/go2rtc/pkg/magic/bitstream"
 	"github.com/AlexxIT/go2rtc/pkg/magic/mjpeg"
+	"github.com/AlexxIT/go2rtc/pkg/mp4"
 	"github.com/AlexxIT/go2rtc/pkg/mpegts"
 	"github.com/AlexxIT/go2rtc/pkg/mpjpeg"
 	"github.com/AlexxIT/go2rtc/pkg/wav"
@@ -54,6 +56,16 @@ func Open(r io.Reader) (core.Producer, error) {
 		return mpegts.Open(rd)
 	}
 
+	// fragmented MP4 starts with the atom size and name
+	if b, err = rd.Peek(8); err != nil {
+		return nil, err
+	}
+
+	switch string(b[4:]) {
+	case iso.Ftyp, iso.Moov, "styp":
+		return mp4.Open(rd)
+	}
+
 	// support MJPEG with trash on start
 	// https://github.com/AlexxIT/go2rtc/issues/747
 	if b, err = rd.Peek(4096); err != nil {
@@ -0,0 +1,67 @@
+package mjpeg
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/jpeg"
+	"sort"
+)
+
+// Decoder - decode the H264/H265 keyframe in Annex B format to the image
+type Decoder func(codec string, b []byte) (image.Image, error)
+
+var decoders = map[string]Decoder{}
+
+// RegisterDecoder - add the built-in decoder backend, ex. "libav" with the build tag
+func RegisterDecoder(name string, decoder Decoder) {
+	decoders[name] = decoder
+}
+
+func GetDecoder(name string) Decoder {
+	return decoders[name]
+}
+
+// Decoders - names of the registered backends
+func Decoders() []string {
+	names := make([]string, 0, len(decoders))
+	for name := range decoders {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// DecodeKeyframe - keyframe to JPEG with the named backend, scaled to the width
+// and/or height (-1 or 0 - from the aspect ratio)
+func DecodeKeyframe(name, codec string, b []byte, width, height int) ([]byte, error) {
+	decoder := decoders[name]
+	if decoder == nil {
+		return nil, errors.New("mjpeg: unknown decoder " + name)
+	}
+
+	img, err := decoder(codec, b)
+	if err != nil {
+		return nil, err
+	}
+
+	size := img.Bounds().Size()
+	if size.X <= 0 || size.Y <= 0 {
+		return nil, errors.New("mjpeg: empty image from decoder " + name)
+	}
+
+	if width > 0 || height > 0 {
+		if width <= 0 {
+			width = (height*size.X/size.Y + 1) &^ 1
+		} else if height <= 0 {
+			height = (width*size.Y/size.X + 1) &^ 1
+		}
+		img = scale(img, width, height)
+	}
+
+	buf := bytes.NewBuffer(nil)
+	if err = jpeg.Encode(buf, img, nil); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,104 @@
+//go:build libav
+
+package mjpeg
+
+/*
+#cgo pkg-config: libavcodec libavutil
+#include <stdlib.h>
+#include <libavcodec/avcodec.h>
+#include <libavutil/frame.h>
+*/
+import "C"
+
+import (
+	"errors"
+	"image"
+	"unsafe"
+
+	"github.com/AlexxIT/go2rtc/pkg/core"
+)
+
+// libav decoder is optional, build with: go build -tags libav
+func init() {
+	RegisterDecoder("libav", decodeLibav)
+}
+
+func decodeLibav(codec string, b []byte) (image.Image, error) {
+	var id C.enum_AVCodecID
+	switch codec {
+	case core.CodecH264:
+		id = C.enum_AVCodecID(C.AV_CODEC_ID_H264)
+	case core.CodecH265:
+		id = C.enum_AVCodecID(C.AV_CODEC_ID_HEVC)
+	default:
+		return nil, errors.New("mjpeg: libav unsupported codec " + codec)
+	}
+
+	dec := C.avcodec_find_decoder(id)
+	if dec == nil {
+		return nil, errors.New("mjpeg: libav can't find decoder " + codec)
+	}
+
+	ctx := C.avcodec_alloc_context3(dec)
+	defer C.avcodec_free_context(&ctx)
+
+	if C.avcodec_open2(ctx, dec, nil) < 0 {
+		return nil, errors.New("mjpeg: libav can't open decoder " + codec)
+	}
+
+	// decoder reads input with padding
+	buf := make([]byte, len(b)+C.AV_INPUT_BUFFER_PADDING_SIZE)
+	copy(buf, b)
+	data := C.CBytes(buf)
+	defer C.free(data)
+
+	pkt := C.av_packet_alloc()
+	defer C.av_packet_free(&pkt)
+
+	pkt.data = (*C.uint8_t)(data)
+	pkt.size = C.int(len(b))
+
+	if C.avcodec_send_packet(ctx, pkt) < 0 {
+		return nil, errors.New("mjpeg: libav can't decode keyframe")
+	}
+	C.avcodec_send_packet(ctx, nil) // flush, single frame
+
+	frame := C.av_frame_alloc()
+	defer C.av_frame_free(&frame)
+
+	if C.avcodec_receive_frame(ctx, frame) < 0 {
+		return nil, errors.New("mjpeg: libav can't decode keyframe")
+	}
+
+	return frameImage(frame)
+}
+
+func frameImage(frame *C.AVFrame) (image.Image, error) {
+	var ratio image.YCbCrSubsampleRatio
+	switch frame.format {
+	case C.int(C.AV_PIX_FMT_YUV420P), C.int(C.AV_PIX_FMT_YUVJ420P):
+		ratio = image.YCbCrSubsampleRatio420
+	case C.int(C.AV_PIX_FMT_YUV422P), C.int(C.AV_PIX_FMT_YUVJ422P):
+		ratio = image.YCbCrSubsampleRatio422
+	case C.int(C.AV_PIX_FMT_YUV444P), C.int(C.AV_PIX_FMT_YUVJ444P):
+		ratio = image.YCbCrSubsampleRatio444
+	default:
+		return nil, errors.New("mjpeg: libav unsupported pixel format")
+	}
+
+	img := image.NewYCbCr(image.Rect(0, 0, int(frame.width), int(frame.height)), ratio)
+
+	rows := len(img.Cb) / img.CStride
+	copyPlane(img.Y, img.YStride, frame.data[0], frame.linesize[0], int(frame.height))
+	copyPlane(img.Cb, img.CStride, frame.data[1], frame.linesize[1], rows)
+	copyPlane(img.Cr, img.CStride, frame.data[2], frame.linesize[2], rows)
+
+	return img, nil
+}
+
+func copyPlane(dst []byte, stride int, data *C.uint8_t, linesize C.int, rows int) {
+	src := unsafe.Slice((*byte)(unsafe.Pointer(data)), int(linesize)*rows)
+	for y := 0; y < rows; y++ {
+		copy(dst[y*stride:(y+1)*stride], src[y*int(linesize):])
+	}
+}
@@ -1,7 +1,11 @@
 package mjpeg
 
 import (
+	"bytes"
+	"image"
+	"image/jpeg"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
 )
@@ -11,3 +15,70 @@ func TestRFC2435(t *testing.T) {
 	require.Equal(t, byte(9), lqt[0])
 	require.Equal(t, byte(10), cqt[0])
 }
+
+func TestSprite(t *testing.T) {
+	buf := bytes.NewBuffer(nil)
+	err := jpeg.Encode(buf, image.NewGray(image.Rect(0, 0, 1280, 720)), nil)
+	require.Nil(t, err)
+
+	s := NewSprite(2, 2, 160)
+	require.Nil(t, s.JPEG())
+
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < 5; i++ {
+		err = s.Add(buf.Bytes(), start.Add(time.Duration(i)*10*time.Second))
+		require.Nil(t, err)
+	}
+
+	cfg, err := jpeg.DecodeConfig(bytes.NewReader(s.JPEG()))
+	require.Nil(t, err)
+	require.Equal(t, 320, cfg.Width)
+	require.Equal(t, 180, cfg.Height)
+
+	// first frame dropped from the rolling window
+	vtt := `WEBVTT
+
+NOTE start 2024-01-01T00:00:10Z
+
+00:00:00.000 --> 00:00:10.000
+sheet.jpg#xywh=0,0,160,90
+
+00:00:10.000 --> 00:00:20.000
+sheet.jpg#xywh=160,0,160,90
+
+00:00:20.000 --> 00:00:30.000
+sheet.jpg#xywh=0,90,160,90
+
+00:00:30.000 --> 00:00:40.000
+sheet.jpg#xywh=160,90,160,90
+`
+	require.Equal(t, vtt, string(s.WebVTT("sheet.jpg", 10*time.Second)))
+}
+
+func TestDecodeKeyframe(t *testing.T) {
+	RegisterDecoder("test", func(codec string, b []byte) (image.Image, error) {
+		return image.NewGray(image.Rect(0, 0, 1280, 720)), nil
+	})
+	defer delete(decoders, "test")
+
+	require.Contains(t, Decoders(), "test")
+
+	b, err := DecodeKeyframe("test", "H264", nil, 320, -1)
+	require.Nil(t, err)
+
+	cfg, err := jpeg.DecodeConfig(bytes.NewReader(b))
+	require.Nil(t, err)
+	require.Equal(t, 320, cfg.Width)
+	require.Equal(t, 180, cfg.Height)
+
+	_, err = DecodeKeyframe("unknown", "H264", nil, -1, -1)
+	require.NotNil(t, err)
+
+	RegisterDecoder("empty", func(codec string, b []byte) (image.Image, error) {
+		return image.NewGray(image.Rect(0, 0, 0, 0)), nil
+	})
+	defer delete(decoders, "empty")
+
+	_, err = DecodeKeyframe("empty", "H264", nil, -1, 240)
+	require.NotNil(t, err)
+}
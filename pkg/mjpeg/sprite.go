@@ -0,0 +1,127 @@
+package mjpeg
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/jpeg"
+	"sync"
+	"time"
+)
+
+// Sprite - sheet of the last thumbnails in the grid (rolling window)
+// with WebVTT track for the timeline scrubbing UI
+type Sprite struct {
+	Cols  int
+	Rows  int
+	Width int
+
+	height int
+	frames []*image.RGBA
+	times  []time.Time
+	sheet  []byte
+	mu     sync.Mutex
+}
+
+func NewSprite(cols, rows, width int) *Sprite {
+	return &Sprite{Cols: cols, Rows: rows, Width: width}
+}
+
+// Add - decode JPEG, scale it to the thumbnail size and rebuild the sheet
+func (s *Sprite) Add(b []byte, ts time.Time) error {
+	img, err := jpeg.Decode(bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.height == 0 {
+		// height from the aspect ratio of the first frame, rounded to even
+		size := img.Bounds().Size()
+		s.height = (s.Width*size.Y/size.X + 1) &^ 1
+	}
+
+	s.frames = append(s.frames, scale(img, s.Width, s.height))
+	s.times = append(s.times, ts)
+
+	if n := len(s.frames) - s.Cols*s.Rows; n > 0 {
+		s.frames = s.frames[n:]
+		s.times = s.times[n:]
+	}
+
+	sheet := image.NewRGBA(image.Rect(0, 0, s.Cols*s.Width, s.Rows*s.height))
+	for i, frame := range s.frames {
+		x, y := s.position(i)
+		draw.Draw(sheet, frame.Bounds().Add(image.Pt(x, y)), frame, image.Point{}, draw.Src)
+	}
+
+	buf := bytes.NewBuffer(nil)
+	if err = jpeg.Encode(buf, sheet, nil); err != nil {
+		return err
+	}
+	s.sheet = buf.Bytes()
+
+	return nil
+}
+
+// JPEG - current sprite sheet, nil if no thumbnails yet
+func (s *Sprite) JPEG() []byte {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.sheet
+}
+
+// WebVTT - thumbnails track, cue times relative to the oldest thumbnail,
+// each cue ends with the next thumbnail or after the interval for the last one
+func (s *Sprite) WebVTT(sheetURL string, interval time.Duration) []byte {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	b := []byte("WEBVTT\n")
+	if len(s.times) == 0 {
+		return b
+	}
+
+	start := s.times[0]
+	b = fmt.Appendf(b, "\nNOTE start %s\n", start.UTC().Format(time.RFC3339))
+
+	for i, ts := range s.times {
+		end := ts.Add(interval)
+		if i+1 < len(s.times) {
+			end = s.times[i+1]
+		}
+
+		x, y := s.position(i)
+		b = fmt.Appendf(
+			b, "\n%s --> %s\n%s#xywh=%d,%d,%d,%d\n",
+			vttTime(ts.Sub(start)), vttTime(end.Sub(start)), sheetURL, x, y, s.Width, s.height,
+		)
+	}
+
+	return b
+}
+
+func (s *Sprite) position(i int) (x, y int) {
+	return i % s.Cols * s.Width, i / s.Cols * s.height
+}
+
+// scale - nearest neighbour scaling, good enough for small thumbnails
+func scale(src image.Image, width, height int) *image.RGBA {
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	r := src.Bounds()
+	for y := 0; y < height; y++ {
+		sy := r.Min.Y + y*r.Dy()/height
+		for x := 0; x < width; x++ {
+			dst.Set(x, y, src.At(r.Min.X+x*r.Dx()/width, sy))
+		}
+	}
+	return dst
+}
+
+func vttTime(d time.Duration) string {
+	ms := d.Milliseconds()
+	return fmt.Sprintf("%02d:%02d:%02d.%03d", ms/3600000, ms/60000%60, ms/1000%60, ms%1000)
+}
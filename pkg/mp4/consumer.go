@@ -9,6 +9,7 @@ import (
 	"github.com/AlexxIT/go2rtc/pkg/core"
 	"github.com/AlexxIT/go2rtc/pkg/h264"
 	"github.com/AlexxIT/go2rtc/pkg/h265"
+	"github.com/AlexxIT/go2rtc/pkg/opus"
 	"github.com/AlexxIT/go2rtc/pkg/pcm"
 	"github.com/pion/rtp"
 )
@@ -85,12 +86,6 @@ func (c *Consumer) AddTrack(media *core.Media, _ *core.Codec, track *core.Receiv
 			c.mu.Unlock()
 		}
 
-		if track.Codec.IsRTP() {
-			handler.Handler = h264.RTPDepay(track.Codec, handler.Handler)
-		} else {
-			handler.Handler = h264.RepairAVCC(track.Codec, handler.Handler)
-		}
-
 	case core.CodecH265:
 		handler.Handler = func(packet *rtp.Packet) {
 			if !c.start {
@@ -109,12 +104,6 @@ func (c *Consumer) AddTrack(media *core.Media, _ *core.Codec, track *core.Receiv
 			c.mu.Unlock()
 		}
 
-		if track.Codec.IsRTP() {
-			handler.Handler = h265.RTPDepay(track.Codec, handler.Handler)
-		} else {
-			handler.Handler = h265.RepairAVCC(track.Codec, handler.Handler)
-		}
-
 	default:
 		handler.Handler = func(packet *rtp.Packet) {
 			if !c.start {
@@ -129,27 +118,10 @@ func (c *Consumer) AddTrack(media *core.Media, _ *core.Codec, track *core.Receiv
 			}
 			c.mu.Unlock()
 		}
-
-		switch track.Codec.Name {
-		case core.CodecAAC:
-			if track.Codec.IsRTP() {
-				handler.Handler = aac.RTPDepay(handler.Handler)
-			}
-		case core.CodecOpus, core.CodecMP3: // no changes
-		case core.CodecPCMA, core.CodecPCMU, core.CodecPCM, core.CodecPCML:
-			codec.Name = core.CodecFLAC
-			if codec.Channels == 2 {
-				// hacky way for support two channels audio
-				codec.Channels = 1
-				codec.ClockRate *= 2
-			}
-			handler.Handler = pcm.FLACEncoder(track.Codec.Name, codec.ClockRate, handler.Handler)
-
-		default:
-			handler.Handler = nil
-		}
 	}
 
+	handler.Handler = wrapHandler(track.Codec, codec, handler.Handler)
+
 	if handler.Handler == nil {
 		s := "mp4: unsupported codec: " + track.Codec.String()
 		println(s)
@@ -164,6 +136,46 @@ func (c *Consumer) AddTrack(media *core.Media, _ *core.Codec, track *core.Receiv
 	return nil
 }
 
+// wrapHandler - convert packets from the track to MP4 samples, codec may be
+// changed for the MP4 track (ex. PCM to FLAC), nil for unsupported codec
+func wrapHandler(trackCodec, codec *core.Codec, handler core.HandlerFunc) core.HandlerFunc {
+	switch trackCodec.Name {
+	case core.CodecH264:
+		if trackCodec.IsRTP() {
+			return h264.RTPDepay(trackCodec, handler)
+		}
+		return h264.RepairAVCC(trackCodec, handler)
+	case core.CodecH265:
+		if trackCodec.IsRTP() {
+			return h265.RTPDepay(trackCodec, handler)
+		}
+		return h265.RepairAVCC(trackCodec, handler)
+	case core.CodecAAC:
+		if trackCodec.IsRTP() {
+			return aac.RTPDepay(handler)
+		}
+		return handler
+	case core.CodecOpus:
+		if trackCodec.IsRTP() {
+			// rtpmap is always opus/48000/2, real channels are in fmtp
+			codec.Channels = opus.ParseFmtp(trackCodec.FmtpLine).Channels
+			return opus.RTPDepay(handler)
+		}
+		return handler
+	case core.CodecMP3: // no changes
+		return handler
+	case core.CodecPCMA, core.CodecPCMU, core.CodecPCM, core.CodecPCML:
+		codec.Name = core.CodecFLAC
+		if codec.Channels == 2 {
+			// hacky way for support two channels audio
+			codec.Channels = 1
+			codec.ClockRate *= 2
+		}
+		return pcm.FLACEncoder(trackCodec.Name, codec.ClockRate, handler)
+	}
+	return nil
+}
+
 func (c *Consumer) WriteTo(wr io.Writer) (int64, error) {
 	if len(c.Senders) == 1 && c.Senders[0].Codec.IsAudio() {
 		c.start = true
@@ -1,16 +1,20 @@
 package mp4
 
 import (
+	"slices"
+
 	"github.com/AlexxIT/go2rtc/pkg/aac"
 	"github.com/AlexxIT/go2rtc/pkg/core"
 	"github.com/AlexxIT/go2rtc/pkg/h264"
+	"github.com/AlexxIT/go2rtc/pkg/h265"
 	"github.com/AlexxIT/go2rtc/pkg/iso"
 	"github.com/pion/rtp"
 )
 
 type Demuxer struct {
-	codecs     map[uint32]*core.Codec
-	timeScales map[uint32]float32
+	codecs      map[uint32]*core.Codec
+	timeScales  map[uint32]float32
+	mediaScales map[uint32]uint32 // track timescale from mdhd
 }
 
 func (d *Demuxer) Probe(init []byte) (medias []*core.Media) {
@@ -19,6 +23,7 @@ func (d *Demuxer) Probe(init []byte) (medias []*core.Media) {
 	if d.codecs == nil {
 		d.codecs = make(map[uint32]*core.Codec)
 		d.timeScales = make(map[uint32]float32)
+		d.mediaScales = make(map[uint32]uint32)
 	}
 
 	atoms, _ := iso.DecodeAtoms(init)
@@ -34,6 +39,8 @@ func (d *Demuxer) Probe(init []byte) (medias []*core.Media) {
 			switch atom.Name {
 			case "avc1":
 				codec = h264.ConfigToCodec(atom.Config)
+			case "hev1", "hvc1":
+				codec = h265.ConfigToCodec(atom.Config)
 			}
 		case *iso.AtomAudio:
 			switch atom.Name {
@@ -45,6 +52,7 @@ func (d *Demuxer) Probe(init []byte) (medias []*core.Media) {
 		if codec != nil {
 			d.codecs[trackID] = codec
 			d.timeScales[trackID] = float32(codec.ClockRate) / float32(timeScale)
+			d.mediaScales[trackID] = timeScale
 
 			medias = append(medias, &core.Media{
 				Kind:      codec.Kind(),
@@ -114,3 +122,75 @@ func (d *Demuxer) Demux(data2 []byte) (trackID uint32, packets []*core.Packet) {
 
 	return
 }
+
+// Fragment - samples of all tracks from the moof and mdat atoms (with headers),
+// duration is in the codec clock rate units
+func (d *Demuxer) Fragment(moof, mdat []byte, f func(trackID uint32, packet *core.Packet, duration uint32)) {
+	atoms, err := iso.DecodeAtoms(moof)
+	if err != nil || len(mdat) < 8 {
+		return
+	}
+
+	// samples data offset is relative to the moof start, without the offset
+	// data continues after previous run, first run from the mdat start
+	data := slices.Concat(moof, mdat)
+	pos := len(moof) + 8
+
+	var trackID uint32
+	var tfhd *iso.AtomTfhd
+	var dts uint64
+
+	for _, atom := range atoms {
+		switch atom := atom.(type) {
+		case *iso.AtomTfhd:
+			trackID = atom.TrackID
+			tfhd = atom
+			dts = 0
+		case *iso.AtomTfdt:
+			dts = atom.DecodeTime
+		case *iso.AtomTrun:
+			if tfhd == nil {
+				return
+			}
+
+			if atom.DataOffset != 0 {
+				pos = int(int32(atom.DataOffset))
+			}
+
+			codec := d.codecs[trackID]
+			scale := uint64(d.mediaScales[trackID])
+
+			for i := 0; i < int(atom.SampleCount); i++ {
+				size, duration := tfhd.SampleSize, tfhd.SampleDuration
+				if i < len(atom.SamplesSize) {
+					size = atom.SamplesSize[i]
+				}
+				if i < len(atom.SamplesDuration) {
+					duration = atom.SamplesDuration[i]
+				}
+
+				if pos < 0 || pos+int(size) > len(data) {
+					return // broken fragment
+				}
+
+				if codec != nil && scale != 0 {
+					ts := dts
+					if i < len(atom.SamplesCTS) {
+						ts += uint64(int32(atom.SamplesCTS[i])) // negative for trun version 1
+					}
+
+					rate := uint64(codec.ClockRate)
+					packet := &rtp.Packet{
+						// without overflow for the big decode time
+						Header:  rtp.Header{Timestamp: uint32(ts/scale*rate + ts%scale*rate/scale)},
+						Payload: data[pos : pos+int(size)],
+					}
+					f(trackID, packet, uint32(uint64(duration)*rate/scale))
+				}
+
+				pos += int(size)
+				dts += uint64(duration)
+			}
+		}
+	}
+}
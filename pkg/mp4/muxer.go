@@ -118,6 +118,13 @@ func (m *Muxer) Reset() {
 	}
 }
 
+// Rebase - set decode time for the next sample of the track, ex. after the
+// timestamps discontinuity, timestamp is the RTP time of the next sample
+func (m *Muxer) Rebase(trackID byte, dts uint64, timestamp uint32) {
+	m.dts[trackID] = dts
+	m.pts[trackID] = timestamp
+}
+
 func (m *Muxer) GetPayload(trackID byte, packet *rtp.Packet) []byte {
 	codec := m.codecs[trackID]
 
@@ -0,0 +1,157 @@
+package mp4
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+
+	"github.com/AlexxIT/go2rtc/pkg/core"
+	"github.com/AlexxIT/go2rtc/pkg/iso"
+)
+
+// Producer - fragmented MP4 stream, ex. low latency chunked HTTP from the camera:
+// init segment (moov) and then moof + mdat fragments
+type Producer struct {
+	core.Connection
+	rd  io.Reader
+	dem *Demuxer
+}
+
+func Open(r io.Reader) (*Producer, error) {
+	prod := &Producer{
+		Connection: core.Connection{
+			ID:         core.NewID(),
+			FormatName: "mp4",
+			Transport:  r,
+		},
+		rd:  r,
+		dem: &Demuxer{},
+	}
+	if err := prod.probe(); err != nil {
+		return nil, err
+	}
+	return prod, nil
+}
+
+var errInit = errors.New("mp4: init segment changed")
+
+func (p *Producer) Start() error {
+	receivers := make(map[uint32]*core.Receiver)
+	for _, receiver := range p.Receivers {
+		trackID := p.dem.GetTrackID(receiver.Codec)
+		receivers[trackID] = receiver
+	}
+
+	tracks := map[uint32]*core.Timeline{}
+
+	var moof []byte
+
+	for {
+		name, b, err := readAtom(p.rd)
+		if err != nil {
+			return err
+		}
+
+		p.Recv += len(b)
+
+		switch name {
+		case iso.Moov:
+			// same init segment can be repeated, ex. after the encoder restart
+			if !p.sameInit(b) {
+				return errInit
+			}
+		case iso.Moof:
+			moof = b
+		case iso.Mdat:
+			if moof == nil {
+				continue
+			}
+			p.dem.Fragment(moof, b, func(trackID uint32, packet *core.Packet, duration uint32) {
+				receiver := receivers[trackID]
+				if receiver == nil {
+					return
+				}
+				t := tracks[trackID]
+				if t == nil {
+					// decode time jumps (ex. source restart) with the same init segment
+					t = &core.Timeline{ClockRate: receiver.Codec.ClockRate}
+					tracks[trackID] = t
+				}
+				t.Fix(packet, duration)
+				receiver.WriteRTP(packet)
+			})
+			moof = nil
+		}
+	}
+}
+
+func (p *Producer) probe() error {
+	for {
+		name, b, err := readAtom(p.rd)
+		if err != nil {
+			return err
+		}
+
+		switch name {
+		case iso.Moov:
+			if p.Medias = p.dem.Probe(b); p.Medias == nil {
+				return errors.New("mp4: unsupported codecs")
+			}
+			return nil
+		case iso.Moof, iso.Mdat:
+			return errors.New("mp4: fragment before init segment")
+		}
+		// skip ftyp, styp, sidx and others
+	}
+}
+
+// sameInit - new init segment has the same codecs of the tracks
+func (p *Producer) sameInit(init []byte) bool {
+	dem := &Demuxer{}
+	dem.Probe(init)
+	if len(dem.codecs) != len(p.dem.codecs) {
+		return false
+	}
+	for trackID, codec := range dem.codecs {
+		old := p.dem.codecs[trackID]
+		if old == nil || old.Name != codec.Name || old.FmtpLine != codec.FmtpLine {
+			return false
+		}
+	}
+	return true
+}
+
+// maxAtom - memory protection from the broken stream
+const maxAtom = 64 << 20
+
+// readAtom - whole atom with the 32-bit size header
+func readAtom(r io.Reader) (name string, b []byte, err error) {
+	header := make([]byte, 8, 16)
+	if _, err = io.ReadFull(r, header); err != nil {
+		return
+	}
+
+	size := uint64(binary.BigEndian.Uint32(header))
+	name = string(header[4:])
+
+	switch size {
+	case 0:
+		return "", nil, errors.New("mp4: atom till the end of stream")
+	case 1:
+		// 64-bit size after the name
+		if _, err = io.ReadFull(r, header[8:16]); err != nil {
+			return
+		}
+		size = binary.BigEndian.Uint64(header[8:]) - 8
+	}
+
+	if size < 8 || size > maxAtom {
+		return "", nil, errors.New("mp4: wrong atom size")
+	}
+
+	b = make([]byte, size)
+	copy(b, header[:8])
+	binary.BigEndian.PutUint32(b, uint32(size))
+	_, err = io.ReadFull(r, b[8:])
+	return
+}
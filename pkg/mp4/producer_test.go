@@ -0,0 +1,76 @@
+package mp4
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/AlexxIT/go2rtc/pkg/core"
+	"github.com/AlexxIT/go2rtc/pkg/h264"
+	"github.com/pion/rtp"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProducer(t *testing.T) {
+	sps := []byte{0x67, 0x42, 0x00, 0x0a, 0xf8, 0x41, 0xa2}
+	pps := []byte{0x68, 0xce, 0x38, 0x80}
+	codec := h264.AVCCToCodec(h264.JoinNALU(sps, pps))
+
+	muxer := &Muxer{}
+	muxer.AddTrack(codec)
+	init, err := muxer.GetInit()
+	require.Nil(t, err)
+
+	idr := h264.JoinNALU([]byte{0x65, 0x88, 0x84})
+	pframe := h264.JoinNALU([]byte{0x41, 0x9a, 0x02})
+
+	var stream bytes.Buffer
+	stream.Write(init)
+	stream.Write(muxer.GetPayload(0, &rtp.Packet{Header: rtp.Header{Timestamp: 3000}, Payload: idr}))
+	stream.Write(muxer.GetPayload(0, &rtp.Packet{Header: rtp.Header{Timestamp: 6000}, Payload: pframe}))
+	stream.Write(muxer.GetPayload(0, &rtp.Packet{Header: rtp.Header{Timestamp: 9000}, Payload: pframe}))
+
+	// same init segment and the decode time jump, ex. after the encoder restart
+	stream.Write(init)
+	muxer.Rebase(0, 100*90000, 0)
+	stream.Write(muxer.GetPayload(0, &rtp.Packet{Header: rtp.Header{Timestamp: 3000}, Payload: idr}))
+
+	prod, err := Open(&stream)
+	require.Nil(t, err)
+	require.Len(t, prod.Medias, 1)
+
+	media := prod.Medias[0]
+	require.Equal(t, core.KindVideo, media.Kind)
+	require.Equal(t, core.CodecH264, media.Codecs[0].Name)
+
+	receiver, err := prod.GetTrack(media, media.Codecs[0])
+	require.Nil(t, err)
+
+	var packets []*rtp.Packet
+	receiver.Input = func(packet *rtp.Packet) {
+		packets = append(packets, packet)
+	}
+
+	require.ErrorIs(t, prod.Start(), io.EOF)
+	require.Len(t, packets, 4)
+	require.Equal(t, idr, packets[0].Payload)
+	require.Equal(t, pframe, packets[1].Payload)
+	require.Equal(t, uint32(3000), packets[1].Timestamp)
+
+	// timestamps continue after the jump
+	require.Equal(t, uint32(9000), packets[3].Timestamp)
+	require.Equal(t, idr, packets[3].Payload)
+
+	// other codecs in the new init segment
+	muxer = &Muxer{}
+	muxer.AddTrack(h264.AVCCToCodec(h264.JoinNALU([]byte{0x67, 0x42, 0x00, 0x1e, 0xf8, 0x41, 0xa2}, pps)))
+	init2, err := muxer.GetInit()
+	require.Nil(t, err)
+
+	prod, err = Open(io.MultiReader(bytes.NewReader(init), bytes.NewReader(init2)))
+	require.Nil(t, err)
+	require.Equal(t, errInit, prod.Start())
+
+	_, err = Open(bytes.NewReader(muxer.GetPayload(0, &rtp.Packet{Payload: idr})))
+	require.NotNil(t, err)
+}
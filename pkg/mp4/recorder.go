@@ -0,0 +1,366 @@
+package mp4
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/AlexxIT/go2rtc/pkg/core"
+	"github.com/AlexxIT/go2rtc/pkg/h264"
+	"github.com/AlexxIT/go2rtc/pkg/h265"
+	"github.com/pion/rtp"
+)
+
+// Recorder - consumer that writes fragmented MP4 files on disk without FFmpeg.
+// New file starts on the video keyframe after the segment duration and on any
+// parameter sets change. Timestamps discontinuity starts a new fragment with
+// the decode time from the wall clock. Wall clock time of the samples is from
+// the RTCP sender reports of the source if they are close to the local clock.
+type Recorder struct {
+	core.Connection
+
+	Filename func(ts time.Time) string // path for the new file, default - local time in the current dir
+	Segment  time.Duration             // default - 1 minute
+
+	muxer  *Muxer
+	clocks []*clock
+	params [][]byte // current parameter sets for each video track
+
+	file   *os.File
+	fileTS time.Time // wall clock time of the first sample in the file
+	start  bool
+
+	done chan struct{}
+	mu   sync.Mutex
+}
+
+// clock - mapping between RTP timestamps and wall clock time for the track
+type clock struct {
+	rate    uint32
+	rtpTS   uint32
+	wallTS  time.Time
+	last    uint32
+	started bool // track has samples in the current file
+
+	sender *core.Sender
+	report *core.Clock // last checked sender report
+}
+
+func NewRecorder(medias []*core.Media) *Recorder {
+	if medias == nil {
+		medias = []*core.Media{
+			{
+				Kind:      core.KindVideo,
+				Direction: core.DirectionSendonly,
+				Codecs: []*core.Codec{
+					{Name: core.CodecH264},
+					{Name: core.CodecH265},
+				},
+			},
+			{
+				Kind:      core.KindAudio,
+				Direction: core.DirectionSendonly,
+				Codecs: []*core.Codec{
+					{Name: core.CodecAAC},
+					{Name: core.CodecOpus},
+					{Name: core.CodecPCMA},
+					{Name: core.CodecPCMU},
+				},
+			},
+		}
+	}
+
+	return &Recorder{
+		Connection: core.Connection{
+			ID:         core.NewID(),
+			FormatName: "mp4",
+			Protocol:   "file",
+			Medias:     medias,
+		},
+		Segment: time.Minute,
+		muxer:   &Muxer{},
+		done:    make(chan struct{}),
+	}
+}
+
+func (r *Recorder) AddTrack(media *core.Media, _ *core.Codec, track *core.Receiver) error {
+	trackID := byte(len(r.Senders))
+
+	codec := track.Codec.Clone()
+	handler := core.NewSender(media, codec)
+
+	handler.Handler = func(packet *rtp.Packet) {
+		r.mu.Lock()
+		r.write(trackID, packet)
+		r.mu.Unlock()
+	}
+	handler.Handler = wrapHandler(track.Codec, codec, handler.Handler)
+
+	if handler.Handler == nil {
+		return errors.New("mp4: unsupported codec: " + track.Codec.String())
+	}
+
+	r.muxer.AddTrack(codec)
+	r.clocks = append(r.clocks, &clock{rate: codec.ClockRate, sender: handler})
+	r.params = append(r.params, paramSets(codec.Name, codec.FmtpLine))
+
+	handler.HandleRTP(track)
+	r.Senders = append(r.Senders, handler)
+
+	return nil
+}
+
+func (r *Recorder) write(trackID byte, packet *rtp.Packet) {
+	if r.done == nil {
+		return // stopped
+	}
+
+	now := time.Now()
+	codec := r.muxer.codecs[trackID]
+	clk := r.clocks[trackID]
+
+	if clk.wallTS.IsZero() {
+		clk.rtpTS, clk.wallTS = packet.Timestamp, now
+	} else if d := int32(packet.Timestamp - clk.last); d < 0 || core.IsJump(d, clk.rate) {
+		// source restarted or lost a lot of data, sync with the wall clock
+		clk.rtpTS, clk.wallTS = packet.Timestamp, now
+		if clk.started {
+			r.rebase(trackID, packet.Timestamp)
+		}
+	}
+	clk.last = packet.Timestamp
+	clk.syncReport(packet, now)
+
+	ts := clk.wall(packet.Timestamp)
+
+	if codec.IsVideo() {
+		if !isKeyframe(codec.Name, packet.Payload) {
+			if !r.start {
+				return
+			}
+		} else {
+			params := paramSetsAVCC(codec.Name, packet.Payload)
+			changed := params != nil && !bytes.Equal(params, r.params[trackID])
+			if changed {
+				r.params[trackID] = params
+				r.updateCodec(codec, packet.Payload)
+			}
+			if !r.start || changed || ts.Sub(r.fileTS) >= r.Segment {
+				if err := r.nextFile(ts); err != nil {
+					return
+				}
+			}
+		}
+	} else if !r.start {
+		if r.hasVideo() {
+			return // wait video keyframe
+		}
+		if err := r.nextFile(ts); err != nil {
+			return
+		}
+	} else if !r.hasVideo() && ts.Sub(r.fileTS) >= r.Segment {
+		if err := r.nextFile(ts); err != nil {
+			return
+		}
+	}
+
+	if !clk.started {
+		clk.started = true
+		r.rebase(trackID, packet.Timestamp)
+	}
+
+	b := r.muxer.GetPayload(trackID, packet)
+	if n, err := r.file.Write(b); err == nil {
+		r.Send += n
+	}
+}
+
+// rebase - decode time of the next sample from the wall clock relative to the file start
+func (r *Recorder) rebase(trackID byte, timestamp uint32) {
+	clk := r.clocks[trackID]
+
+	var dts uint64
+	if d := clk.wall(timestamp).Sub(r.fileTS); d > 0 {
+		dts = uint64(d) * uint64(clk.rate) / uint64(time.Second)
+	}
+
+	r.muxer.Rebase(trackID, dts, timestamp)
+}
+
+func (r *Recorder) nextFile(ts time.Time) error {
+	r.closeFile()
+
+	name := r.filename(ts)
+	if err := os.MkdirAll(filepath.Dir(name), 0755); err != nil {
+		return err
+	}
+
+	f, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+
+	r.muxer.Reset()
+	for _, clk := range r.clocks {
+		clk.started = false
+	}
+
+	init, err := r.muxer.GetInit()
+	if err != nil {
+		_ = f.Close()
+		return err
+	}
+	if _, err = f.Write(init); err != nil {
+		_ = f.Close()
+		return err
+	}
+
+	r.file = f
+	r.fileTS = ts
+	r.start = true
+	r.URL = name
+
+	return nil
+}
+
+func (r *Recorder) closeFile() {
+	if r.file != nil {
+		_ = r.file.Close()
+		r.file = nil
+	}
+}
+
+func (r *Recorder) filename(ts time.Time) string {
+	if r.Filename != nil {
+		return r.Filename(ts)
+	}
+	return ts.Format("20060102_150405") + ".mp4"
+}
+
+func (r *Recorder) hasVideo() bool {
+	for _, codec := range r.muxer.codecs {
+		if codec.IsVideo() {
+			return true
+		}
+	}
+	return false
+}
+
+// updateCodec - new parameter sets for the init segment of the next file
+func (r *Recorder) updateCodec(codec *core.Codec, avcc []byte) {
+	switch codec.Name {
+	case core.CodecH264:
+		codec.FmtpLine = h264.AVCCToCodec(avcc).FmtpLine
+	case core.CodecH265:
+		codec.FmtpLine = h265.AVCCToCodec(avcc).FmtpLine
+	}
+}
+
+// Wait - block until the recorder is stopped
+func (r *Recorder) Wait() {
+	r.mu.Lock()
+	done := r.done
+	r.mu.Unlock()
+	if done != nil {
+		<-done
+	}
+}
+
+func (r *Recorder) Stop() error {
+	_ = r.Connection.Stop()
+
+	r.mu.Lock()
+	r.closeFile()
+	if r.done != nil {
+		close(r.done)
+		r.done = nil
+	}
+	r.mu.Unlock()
+
+	return nil
+}
+
+// syncReport - use the new sender report of the source for the mapping, skip the
+// report if the source clock is far from the local clock or from another timeline
+func (c *clock) syncReport(packet *rtp.Packet, now time.Time) {
+	report := c.sender.Clock()
+	if report == nil || report == c.report || report.SSRC != packet.SSRC {
+		return
+	}
+	c.report = report
+
+	sr := clock{rate: c.rate, rtpTS: report.RTPTime, wallTS: report.Wall}
+	if d := sr.wall(packet.Timestamp).Sub(now); d > -core.MaxJump && d < core.MaxJump {
+		c.rtpTS, c.wallTS = report.RTPTime, report.Wall
+	}
+}
+
+func (c *clock) wall(timestamp uint32) time.Time {
+	d := int32(timestamp - c.rtpTS)
+	return c.wallTS.Add(time.Duration(d) * time.Second / time.Duration(c.rate))
+}
+
+// Filename - file path from the pattern with strftime like time fields:
+// %Y, %m, %d, %H, %M, %S and %s (Unix time)
+func Filename(pattern string, ts time.Time) string {
+	return strings.NewReplacer(
+		"%Y", ts.Format("2006"),
+		"%m", ts.Format("01"),
+		"%d", ts.Format("02"),
+		"%H", ts.Format("15"),
+		"%M", ts.Format("04"),
+		"%S", ts.Format("05"),
+		"%s", strconv.FormatInt(ts.Unix(), 10),
+	).Replace(pattern)
+}
+
+func isKeyframe(name string, payload []byte) bool {
+	switch name {
+	case core.CodecH264:
+		return h264.IsKeyframe(payload)
+	case core.CodecH265:
+		return h265.IsKeyframe(payload)
+	}
+	return false
+}
+
+// paramSets - parameter sets from the codec fmtp line in the AVCC format
+func paramSets(name, fmtp string) []byte {
+	switch name {
+	case core.CodecH264:
+		sps, pps := h264.GetParameterSet(fmtp)
+		return h264.JoinNALU(sps, pps)
+	case core.CodecH265:
+		vps, sps, pps := h265.GetParameterSet(fmtp)
+		return h264.JoinNALU(vps, sps, pps)
+	}
+	return nil
+}
+
+// paramSetsAVCC - parameter sets from the keyframe, nil if keyframe doesn't have them
+func paramSetsAVCC(name string, avcc []byte) []byte {
+	var params [][]byte
+	for _, nalu := range h264.SplitNALU(avcc) {
+		switch name {
+		case core.CodecH264:
+			switch h264.NALUType(nalu) {
+			case h264.NALUTypeSPS, h264.NALUTypePPS:
+				params = append(params, nalu[4:])
+			}
+		case core.CodecH265:
+			switch h265.NALUType(nalu) {
+			case h265.NALUTypeVPS, h265.NALUTypeSPS, h265.NALUTypePPS:
+				params = append(params, nalu[4:])
+			}
+		}
+	}
+	if params == nil {
+		return nil
+	}
+	return h264.JoinNALU(params...)
+}
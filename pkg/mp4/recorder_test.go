@@ -0,0 +1,126 @@
+package mp4
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/AlexxIT/go2rtc/pkg/core"
+	"github.com/AlexxIT/go2rtc/pkg/h264"
+	"github.com/pion/rtp"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecorder(t *testing.T) {
+	dir := t.TempDir()
+
+	var files []string
+	rec := NewRecorder(nil)
+	rec.Segment = time.Second
+	rec.Filename = func(ts time.Time) string {
+		name := filepath.Join(dir, strconv.Itoa(len(files))+".mp4")
+		files = append(files, name)
+		return name
+	}
+
+	media := rec.Medias[0]
+	codec := &core.Codec{Name: core.CodecH264, ClockRate: 90000, PayloadType: core.PayloadTypeRAW}
+	receiver := core.NewReceiver(media, codec)
+	require.Nil(t, rec.AddTrack(media, codec, receiver))
+
+	sps1 := []byte{0x67, 0x42, 0x00, 0x0a, 0xf8, 0x41, 0xa2}
+	sps2 := []byte{0x67, 0x42, 0x00, 0x1e, 0xf8, 0x41, 0xa2}
+	pps := []byte{0x68, 0xce, 0x38, 0x80}
+	idr := []byte{0x65, 0x88, 0x84}
+	pframe := []byte{0x41, 0x9a, 0x02}
+
+	samples := []struct {
+		ts      uint32
+		payload []byte
+	}{
+		{0, h264.JoinNALU(pframe)}, // skip before the first keyframe
+		{0, h264.JoinNALU(sps1, pps, idr)},
+		{45000, h264.JoinNALU(pframe)},
+		{90000, h264.JoinNALU(sps1, pps, idr)},  // segment duration
+		{135000, h264.JoinNALU(sps2, pps, idr)}, // new parameter sets
+		{180000, h264.JoinNALU(pframe)},
+	}
+	for _, sample := range samples {
+		receiver.WriteRTP(&rtp.Packet{Header: rtp.Header{Timestamp: sample.ts}, Payload: sample.payload})
+	}
+
+	// wait all queued packets
+	rec.Senders[0].Close()
+	rec.Senders[0].Wait()
+
+	require.Nil(t, rec.Stop())
+	rec.Wait()
+
+	require.Len(t, files, 3)
+
+	b, err := os.ReadFile(files[2])
+	require.Nil(t, err)
+	require.True(t, bytes.Contains(b, sps2))
+	require.Equal(t, "ftyp", string(b[4:8]))
+}
+
+func TestRecorderSenderReport(t *testing.T) {
+	dir := t.TempDir()
+
+	var times []time.Time
+	rec := NewRecorder(nil)
+	rec.Filename = func(ts time.Time) string {
+		times = append(times, ts)
+		return filepath.Join(dir, strconv.Itoa(len(times))+".mp4")
+	}
+
+	media := rec.Medias[0]
+	codec := &core.Codec{Name: core.CodecH264, ClockRate: 90000, PayloadType: core.PayloadTypeRAW}
+	receiver := core.NewReceiver(media, codec)
+	require.Nil(t, rec.AddTrack(media, codec, receiver))
+
+	// camera clock is 2 seconds ahead, first sample is 1 second after the report
+	now := time.Now()
+	receiver.SetClock(&core.Clock{SSRC: 1, RTPTime: 0, Wall: now.Add(time.Second)})
+
+	sps := []byte{0x67, 0x42, 0x00, 0x0a, 0xf8, 0x41, 0xa2}
+	pps := []byte{0x68, 0xce, 0x38, 0x80}
+	idr := h264.JoinNALU(sps, pps, []byte{0x65, 0x88, 0x84})
+	receiver.WriteRTP(&rtp.Packet{Header: rtp.Header{SSRC: 1, Timestamp: 90000}, Payload: idr})
+
+	rec.Senders[0].Close()
+	rec.Senders[0].Wait()
+	require.Nil(t, rec.Stop())
+
+	require.Len(t, times, 1)
+	require.WithinDuration(t, now.Add(2*time.Second), times[0], 100*time.Millisecond)
+
+	// camera clock is far from the local clock, so the wall clock is used
+	clk := &clock{rate: 90000, sender: rec.Senders[0]}
+	receiver.SetClock(&core.Clock{SSRC: 1, Wall: now.Add(time.Hour)})
+	clk.rtpTS, clk.wallTS = 90000, now
+	clk.syncReport(&rtp.Packet{Header: rtp.Header{SSRC: 1, Timestamp: 90000}}, now)
+	require.Equal(t, now, clk.wallTS)
+}
+
+func TestFilename(t *testing.T) {
+	ts := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	require.Equal(t, "/media/cam/2024-01-02/03-04-05.mp4", Filename("/media/cam/%Y-%m-%d/%H-%M-%S.mp4", ts))
+	require.Equal(t, "1704164645.mp4", Filename("%s.mp4", ts))
+}
+
+func TestOpusChannels(t *testing.T) {
+	track := &core.Codec{Name: core.CodecOpus, ClockRate: 48000, Channels: 2, PayloadType: 111}
+
+	codec := track.Clone()
+	require.NotNil(t, wrapHandler(track, codec, func(*rtp.Packet) {}))
+	require.Equal(t, uint8(1), codec.Channels) // mono without sprop-stereo
+
+	track.FmtpLine = "minptime=10;useinbandfec=1;sprop-stereo=1"
+	codec = track.Clone()
+	require.NotNil(t, wrapHandler(track, codec, func(*rtp.Packet) {}))
+	require.Equal(t, uint8(2), codec.Channels)
+}
@@ -167,8 +167,12 @@ func (d *Demuxer) readPMT() {
 		size = d.readBits(10)      // ES Info length
 		info := d.readBytes(byte(size))
 
-		if streamType == StreamTypePrivate && bytes.HasPrefix(info, opusInfo) {
-			streamType = StreamTypePrivateOPUS
+		if streamType == StreamTypePrivate {
+			if bytes.HasPrefix(info, opusInfo) {
+				streamType = StreamTypePrivateOPUS
+			} else if bytes.Contains(info, klvInfo) {
+				streamType = StreamTypeKLV
+			}
 		}
 
 		d.pes[pid] = &PES{StreamType: streamType}
@@ -327,6 +331,7 @@ const (
 	StreamTypeMetadata    = 0    // Reserved
 	StreamTypePrivate     = 0x06 // PCMU or PCMA or FLAC from FFmpeg
 	StreamTypeAAC         = 0x0F
+	StreamTypeKLV         = 0x15 // Metadata in PES (SMPTE 336M KLV)
 	StreamTypeH264        = 0x1B
 	StreamTypeH265        = 0x24
 	StreamTypePCMATapo    = 0x90
@@ -334,6 +339,13 @@ const (
 	StreamTypePrivateOPUS = 0xEB
 )
 
+// MISB ST 1402 - KLV metadata in private stream
+var klvInfo = []byte{ // registration_descriptor
+	0x05,               // descriptor_tag
+	0x04,               // descriptor_length
+	'K', 'L', 'V', 'A', // format_identifier
+}
+
 // PES - Packetized Elementary Stream
 type PES struct {
 	StreamID   byte   // from each PES header
@@ -410,6 +422,20 @@ func (p *PES) GetPacket() (pkt *rtp.Packet) {
 
 		//p.Timestamp += uint32(len(p.Payload)) // update next timestamp!
 
+	case StreamTypeKLV:
+		p.Sequence++
+
+		pkt = &rtp.Packet{
+			Header: rtp.Header{
+				Version:        2,
+				Marker:         true,
+				PayloadType:    p.StreamType,
+				SequenceNumber: p.Sequence,
+				Timestamp:      p.PTS,
+			},
+			Payload: p.Payload,
+		}
+
 	case StreamTypePrivateOPUS:
 		p.Sequence++
 
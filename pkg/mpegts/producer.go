@@ -15,6 +15,8 @@ import (
 type Producer struct {
 	core.Connection
 	rd *core.ReadBuffer
+
+	Discontinuity bool // fix timestamps jumps of the tracks, ex. HLS discontinuity
 }
 
 func Open(rd io.Reader) (*Producer, error) {
@@ -41,6 +43,8 @@ func (c *Producer) GetTrack(media *core.Media, codec *core.Codec) (*core.Receive
 func (c *Producer) Start() error {
 	rd := NewDemuxer()
 
+	tracks := map[byte]*core.Timeline{}
+
 	for {
 		pkt, err := rd.ReadPacket(c.rd)
 		if err != nil {
@@ -54,6 +58,16 @@ func (c *Producer) Start() error {
 		for _, receiver := range c.Receivers {
 			if receiver.ID == pkt.PayloadType {
 				TimestampToRTP(pkt, receiver.Codec)
+
+				if c.Discontinuity {
+					t := tracks[pkt.PayloadType]
+					if t == nil {
+						t = &core.Timeline{ClockRate: receiver.Codec.ClockRate}
+						tracks[pkt.PayloadType] = t
+					}
+					t.Fix(pkt, 0)
+				}
+
 				receiver.WriteRTP(pkt)
 				break
 			}
@@ -93,6 +107,18 @@ func (c *Producer) probe() error {
 				switch streamType {
 				case StreamTypeH264, StreamTypeH265, StreamTypeAAC, StreamTypePrivateOPUS:
 					waitType = append(waitType, streamType)
+				case StreamTypeKLV:
+					// don't wait for metadata, it can be very rare
+					codec := &core.Codec{
+						Name:      core.CodecKLV,
+						ClockRate: ClockRate,
+					}
+					media := &core.Media{
+						Kind:      core.KindData,
+						Direction: core.DirectionRecvonly,
+						Codecs:    []*core.Codec{codec},
+					}
+					c.Medias = append(c.Medias, media)
 				}
 			}
 
@@ -153,6 +179,8 @@ func StreamType(codec *core.Codec) uint8 {
 		return StreamTypePCMATapo
 	case core.CodecOpus:
 		return StreamTypePrivateOPUS
+	case core.CodecKLV:
+		return StreamTypeKLV
 	}
 	return 0
 }
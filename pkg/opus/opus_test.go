@@ -0,0 +1,32 @@
+package opus
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pion/rtp"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseFmtp(t *testing.T) {
+	f := ParseFmtp("minptime=10;useinbandfec=1")
+	require.Equal(t, &Fmtp{SampleRate: 48000, Channels: 1, MinPTime: 10 * time.Millisecond, PTime: 20 * time.Millisecond, FEC: true}, f)
+
+	f = ParseFmtp("sprop-stereo=1; sprop-maxcapturerate=16000; usedtx=1; ptime=40")
+	require.Equal(t, &Fmtp{SampleRate: 16000, Channels: 2, PTime: 40 * time.Millisecond, DTX: true}, f)
+}
+
+func TestRTPDepay(t *testing.T) {
+	var payloads [][]byte
+	handler := RTPDepay(func(packet *rtp.Packet) {
+		payloads = append(payloads, packet.Payload)
+	})
+
+	handler(&rtp.Packet{Payload: []byte{0xFC, 0xFF, 0xFE}})
+	handler(&rtp.Packet{Payload: nil})          // empty
+	handler(&rtp.Packet{Payload: []byte{0xF8}}) // DTX
+
+	require.Len(t, payloads, 2)
+	require.True(t, IsDTX(payloads[1]))
+	require.False(t, IsDTX(payloads[0]))
+}
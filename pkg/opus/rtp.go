@@ -0,0 +1,70 @@
+package opus
+
+import (
+	"strings"
+	"time"
+
+	"github.com/AlexxIT/go2rtc/pkg/core"
+	"github.com/pion/rtp"
+)
+
+// Fmtp - Opus RTP parameters https://datatracker.ietf.org/doc/html/rfc7587#section-6.1
+// RTP clock rate is always 48000 and rtpmap channels always 2, real values are in fmtp.
+type Fmtp struct {
+	SampleRate uint32        // sprop-maxcapturerate, default 48000
+	Channels   byte          // sprop-stereo, default 1
+	MinPTime   time.Duration // minptime (WebRTC), default 0
+	PTime      time.Duration // ptime, default 20ms
+	FEC        bool          // useinbandfec
+	DTX        bool          // usedtx, sender skips packets with silence
+}
+
+func ParseFmtp(fmtp string) *Fmtp {
+	f := &Fmtp{SampleRate: 48000, Channels: 1, PTime: 20 * time.Millisecond}
+
+	for _, kv := range strings.Split(fmtp, ";") {
+		k, v, _ := strings.Cut(strings.TrimSpace(kv), "=")
+		switch k {
+		case "sprop-maxcapturerate":
+			if i := core.Atoi(v); i > 0 {
+				f.SampleRate = uint32(i)
+			}
+		case "sprop-stereo":
+			if v == "1" {
+				f.Channels = 2
+			}
+		case "minptime":
+			f.MinPTime = time.Duration(core.Atoi(v)) * time.Millisecond
+		case "ptime":
+			if i := core.Atoi(v); i > 0 {
+				f.PTime = time.Duration(i) * time.Millisecond
+			}
+		case "useinbandfec":
+			f.FEC = v == "1"
+		case "usedtx":
+			f.DTX = v == "1"
+		}
+	}
+
+	return f
+}
+
+// IsDTX - packet with TOC byte only (code 0, zero length frame), decoder
+// generates comfort noise or packet loss concealment for it
+// https://datatracker.ietf.org/doc/html/rfc6716#section-3.2.1
+func IsDTX(b []byte) bool {
+	return len(b) == 1 && b[0]&0b11 == 0
+}
+
+// RTPDepay - each RTP packet has exactly one Opus packet, so payload is
+// the packet as is. Only packets without payload are dropped (some cameras
+// send them during silence). With DTX timestamps have gaps, so consumers
+// should use timestamps, not packets count.
+func RTPDepay(handler core.HandlerFunc) core.HandlerFunc {
+	return func(packet *rtp.Packet) {
+		if len(packet.Payload) == 0 {
+			return
+		}
+		handler(packet)
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"testing"
 
 	"github.com/AlexxIT/go2rtc/pkg/core"
+	"github.com/pion/rtp"
 	"github.com/stretchr/testify/require"
 )
 
@@ -77,3 +78,27 @@ func TestTranscode(t *testing.T) {
 		})
 	}
 }
+
+func TestTranscoder(t *testing.T) {
+	src := &core.Codec{Name: core.CodecPCML, ClockRate: 16000}
+
+	var tr Transcoder
+	require.Nil(t, tr.Output(&core.Codec{Name: core.CodecOpus}, src))
+	require.Nil(t, tr.Output(&core.Codec{Name: core.CodecPCMA}, &core.Codec{Name: core.CodecPCMA}))
+
+	out := tr.Output(&core.Codec{Name: core.CodecPCMU}, src)
+	require.Equal(t, &core.Codec{Name: core.CodecPCMU, ClockRate: 8000}, out)
+
+	var packets []*core.Packet
+	handler := tr.Handler(out, src, func(packet *core.Packet) {
+		packets = append(packets, packet)
+	})
+
+	// timestamps are rescaled with the gap of the source
+	handler(&core.Packet{Header: rtp.Header{Timestamp: 1000}, Payload: make([]byte, 640)})
+	handler(&core.Packet{Header: rtp.Header{Timestamp: 1320}, Payload: make([]byte, 640)})
+	handler(&core.Packet{Header: rtp.Header{Timestamp: 2000}, Payload: make([]byte, 640)})
+
+	require.Len(t, packets[0].Payload, 160)
+	require.Equal(t, []uint32{0, 160, 500}, []uint32{packets[0].Timestamp, packets[1].Timestamp, packets[2].Timestamp})
+}
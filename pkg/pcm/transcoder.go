@@ -0,0 +1,52 @@
+package pcm
+
+import (
+	"github.com/AlexxIT/go2rtc/pkg/core"
+	"github.com/pion/rtp"
+)
+
+// Transcoder - pure-Go conversion between PCMA, PCMU, PCM and PCML with any clock rates,
+// output is always mono
+type Transcoder struct{}
+
+func (Transcoder) Output(dst, src *core.Codec) *core.Codec {
+	if BytesPerSample(dst) == 0 || BytesPerSample(src) == 0 || src.ClockRate == 0 {
+		return nil
+	}
+	if dst.Channels > 1 {
+		return nil
+	}
+	if dst.ClockRate != 0 {
+		return dst
+	}
+
+	out := *dst
+	switch dst.Name {
+	case core.CodecPCMA, core.CodecPCMU:
+		out.ClockRate = 8000
+	default:
+		out.ClockRate = src.ClockRate
+	}
+	return &out
+}
+
+func (Transcoder) Handler(out, src *core.Codec, next core.HandlerFunc) core.HandlerFunc {
+	transcode := Transcode(out, src)
+
+	// timestamps are rescaled from the source, so gaps in the source are kept
+	var last uint32
+	var elapsed int64
+	var started bool
+
+	return func(packet *rtp.Packet) {
+		if started {
+			elapsed += int64(int32(packet.Timestamp - last))
+		}
+		last, started = packet.Timestamp, true
+
+		clone := *packet
+		clone.Payload = transcode(packet.Payload)
+		clone.Timestamp = uint32(elapsed * int64(out.ClockRate) / int64(src.ClockRate))
+		next(&clone)
+	}
+}
@@ -0,0 +1,137 @@
+package rtp
+
+import (
+	"bytes"
+	"errors"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/AlexxIT/go2rtc/pkg/core"
+	"github.com/pion/rtp"
+)
+
+// Timeout - stop the producer without incoming RTP, so the stream reconnects
+var Timeout = 10 * time.Second
+
+// Producer - RTP from the plain UDP port without any signaling (ex. simple encoders
+// and ffmpeg rtp output), the codec is known from the config, because there is no SDP
+type Producer struct {
+	core.Connection
+	conn *net.UDPConn
+	rtcp *net.UDPConn // optional RTCP on the next port
+	mu   sync.Mutex   // Recv from the parallel readers
+}
+
+// NewProducer - listen the address (unicast or multicast group) for RTP of the codec,
+// RTCP on the next port is received only for the senders that need the open port
+func NewProducer(address string, codec *core.Codec, withRTCP bool) (*Producer, error) {
+	kind := core.GetKind(codec.Name)
+	if kind == "" {
+		return nil, errors.New("rtp: unsupported codec: " + codec.Name)
+	}
+
+	addr, err := net.ResolveUDPAddr("udp", address)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &Producer{
+		Connection: core.Connection{
+			ID:         core.NewID(),
+			FormatName: "rtp",
+			Protocol:   "udp",
+			Medias: []*core.Media{
+				{Kind: kind, Direction: core.DirectionRecvonly, Codecs: []*core.Codec{codec}},
+			},
+		},
+	}
+
+	if p.conn, err = listen(addr); err != nil {
+		return nil, err
+	}
+
+	if withRTCP {
+		port := p.conn.LocalAddr().(*net.UDPAddr).Port + 1
+		if p.rtcp, err = listen(&net.UDPAddr{IP: addr.IP, Port: port}); err != nil {
+			_ = p.conn.Close()
+			return nil, err
+		}
+	}
+
+	p.Transport = p.conn
+	return p, nil
+}
+
+func listen(addr *net.UDPAddr) (*net.UDPConn, error) {
+	if addr.IP.IsMulticast() {
+		return net.ListenMulticastUDP("udp", nil, addr)
+	}
+	return net.ListenUDP("udp", addr)
+}
+
+func (p *Producer) Start() error {
+	if p.rtcp != nil {
+		go p.readRTCP()
+	}
+
+	// tracks are known before Start
+	var receiver *core.Receiver
+	if len(p.Receivers) > 0 {
+		receiver = p.Receivers[0]
+	}
+
+	b := make([]byte, 64*1024)
+	for {
+		_ = p.conn.SetReadDeadline(time.Now().Add(Timeout))
+
+		n, addr, err := p.conn.ReadFromUDP(b)
+		if err != nil {
+			return err
+		}
+
+		p.mu.Lock()
+		if p.RemoteAddr == "" {
+			p.RemoteAddr = addr.String()
+		}
+		p.Recv += n
+		p.mu.Unlock()
+
+		// RTCP on the RTP port (RFC 5761), payload types 200-204 with the marker
+		if n >= 2 && b[1] >= 200 && b[1] <= 204 {
+			continue
+		}
+
+		// new memory for each packet, consumers can keep it in queues
+		packet := &rtp.Packet{}
+		if err = packet.Unmarshal(bytes.Clone(b[:n])); err != nil {
+			continue
+		}
+
+		if receiver != nil && packet.PayloadType == receiver.Codec.PayloadType {
+			receiver.WriteRTP(packet)
+		}
+	}
+}
+
+// readRTCP - sender reports are not used, the port is open, so the sender doesn't
+// get ICMP port unreachable
+func (p *Producer) readRTCP() {
+	b := make([]byte, 1500)
+	for {
+		n, err := p.rtcp.Read(b)
+		if err != nil {
+			return
+		}
+		p.mu.Lock()
+		p.Recv += n
+		p.mu.Unlock()
+	}
+}
+
+func (p *Producer) Stop() error {
+	if p.rtcp != nil {
+		_ = p.rtcp.Close()
+	}
+	return p.Connection.Stop()
+}
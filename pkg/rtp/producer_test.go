@@ -0,0 +1,66 @@
+package rtp
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/AlexxIT/go2rtc/pkg/core"
+	"github.com/pion/rtp"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProducer(t *testing.T) {
+	codec := &core.Codec{Name: core.CodecPCMA, ClockRate: 8000, PayloadType: 8}
+
+	prod, err := NewProducer("127.0.0.1:0", codec, true)
+	require.Nil(t, err)
+	require.Len(t, prod.Medias, 1)
+	require.Equal(t, core.KindAudio, prod.Medias[0].Kind)
+
+	receiver, err := prod.GetTrack(prod.Medias[0], codec)
+	require.Nil(t, err)
+
+	packets := make(chan *rtp.Packet, 10)
+	receiver.Input = func(packet *rtp.Packet) {
+		packets <- packet
+	}
+
+	errs := make(chan error, 1)
+	go func() { errs <- prod.Start() }()
+
+	addr := prod.conn.LocalAddr().(*net.UDPAddr)
+	conn, err := net.DialUDP("udp", nil, addr)
+	require.Nil(t, err)
+	defer conn.Close()
+
+	write := func(payloadType uint8, seq uint16) {
+		b, _ := (&rtp.Packet{
+			Header:  rtp.Header{Version: 2, PayloadType: payloadType, SequenceNumber: seq},
+			Payload: []byte{1, 2, 3},
+		}).Marshal()
+		_, err = conn.Write(b)
+		require.Nil(t, err)
+	}
+
+	write(96, 1) // other payload type
+	write(8, 2)
+
+	select {
+	case packet := <-packets:
+		require.Equal(t, uint16(2), packet.SequenceNumber)
+		require.Equal(t, []byte{1, 2, 3}, packet.Payload)
+	case <-time.After(time.Second):
+		require.Fail(t, "no packet")
+	}
+	require.Equal(t, conn.LocalAddr().String(), prod.RemoteAddr)
+
+	// RTCP port is open
+	require.Equal(t, addr.Port+1, prod.rtcp.LocalAddr().(*net.UDPAddr).Port)
+
+	require.Nil(t, prod.Stop())
+	require.NotNil(t, <-errs)
+
+	_, err = NewProducer("127.0.0.1:0", &core.Codec{Name: "VND.ONVIF.METADATA"}, false)
+	require.NotNil(t, err)
+}
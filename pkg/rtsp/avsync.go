@@ -0,0 +1,209 @@
+package rtsp
+
+import (
+	"math"
+	"sync"
+	"time"
+
+	"github.com/AlexxIT/go2rtc/pkg/core"
+	"github.com/pion/rtcp"
+	"github.com/pion/rtp"
+)
+
+// AVSync - audio drifted from video by the RTCP sender reports more than the AVSync
+// option and audio timestamps were corrected, fired for each correction
+type AVSync struct {
+	Media *core.Media
+	Drift time.Duration // positive - audio was ahead of video
+}
+
+// syncTrack - timeline of one media, timestamps are unwrapped, so the long sessions
+// never overflow
+type syncTrack struct {
+	media     *core.Media
+	clockRate uint32
+	video     bool
+
+	ssrc    uint32
+	last    uint32 // last incoming timestamp
+	elapsed int64  // ticks from the first packet
+	started bool
+
+	// base - media time minus NTP time of the last sender report, in seconds,
+	// difference of audio and video base is the A/V offset
+	base    float64
+	hasBase bool
+
+	// audio only
+	offset0   float64 // A/V offset at the start of the sync
+	hasOffset bool
+	shift     int64  // correction of the timestamps, in ticks
+	lastOut   uint32 // last outgoing timestamp
+}
+
+// avSync - A/V offset of the session, RTCP and UDP readers work in parallel
+type avSync struct {
+	threshold time.Duration
+	tracks    map[byte]*syncTrack
+	mu        sync.Mutex
+}
+
+// syncState - created before the read loop, only for sessions with video and audio
+func (c *Conn) syncState() *avSync {
+	if c.AVSync <= 0 {
+		return nil
+	}
+
+	s := &avSync{threshold: c.AVSync, tracks: map[byte]*syncTrack{}}
+
+	var video, audio bool
+	for _, receiver := range c.Receivers {
+		if receiver.Codec.ClockRate == 0 {
+			continue
+		}
+		track := &syncTrack{media: receiver.Media, clockRate: receiver.Codec.ClockRate}
+		switch {
+		case receiver.Codec.IsVideo():
+			if video {
+				continue // first video is the reference
+			}
+			track.video, video = true, true
+		case receiver.Codec.IsAudio():
+			audio = true
+		default:
+			continue
+		}
+		s.tracks[receiver.ID] = track
+	}
+
+	if !video || !audio {
+		return nil
+	}
+	return s
+}
+
+// checkSync - correct the audio timestamp, false if the audio packet should be dropped
+// because audio was ahead of video
+func (c *Conn) checkSync(receiver *core.Receiver, packet *rtp.Packet) bool {
+	s := c.sync
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	track := s.tracks[receiver.ID]
+	if track == nil {
+		return true
+	}
+
+	if !track.started || packet.SSRC != track.ssrc {
+		// new source has own timeline, start the sync again
+		if track.started {
+			s.reset(track)
+		}
+		track.ssrc, track.last, track.elapsed, track.started = packet.SSRC, packet.Timestamp, 0, true
+	} else if d := int32(packet.Timestamp - track.last); d > 0 {
+		track.elapsed += int64(d)
+		track.last = packet.Timestamp
+	}
+
+	if track.video {
+		return true
+	}
+
+	ts := packet.Timestamp + uint32(track.shift)
+	if track.shift != 0 && int32(ts-track.lastOut) <= 0 {
+		return false // drop audio till it reaches the corrected timeline
+	}
+	packet.Timestamp = ts
+	track.lastOut = ts
+	return true
+}
+
+// reset - sync of all audio tracks starts again after the change of the track source
+func (s *avSync) reset(track *syncTrack) {
+	track.hasBase = false
+	for _, t := range s.tracks {
+		if (track.video || t == track) && !t.video {
+			t.hasOffset, t.shift, t.lastOut = false, 0, 0
+		}
+	}
+}
+
+// syncReport - update the timeline with the RTCP sender report of the media,
+// fire *AVSync for each correction
+func (c *Conn) syncReport(rtpChannel byte, buf []byte) {
+	packets, err := rtcp.Unmarshal(buf)
+	if err != nil {
+		return
+	}
+
+	for _, packet := range packets {
+		sr, ok := packet.(*rtcp.SenderReport)
+		if !ok {
+			continue
+		}
+		for _, fix := range c.sync.report(rtpChannel, sr) {
+			c.Fire(fix)
+		}
+	}
+}
+
+func (s *avSync) report(rtpChannel byte, sr *rtcp.SenderReport) (fixes []*AVSync) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	track := s.tracks[rtpChannel]
+	if track == nil || !track.started || sr.SSRC != track.ssrc {
+		return nil
+	}
+
+	elapsed := track.elapsed + int64(int32(sr.RTPTime-track.last))
+	track.base = float64(elapsed)/float64(track.clockRate) - ntpSeconds(sr.NTPTime)
+	track.hasBase = true
+
+	var video *syncTrack
+	for _, t := range s.tracks {
+		if t.video {
+			video = t
+		}
+	}
+	if !video.hasBase {
+		return nil
+	}
+
+	for _, t := range s.tracks {
+		if t.video || !t.hasBase {
+			continue
+		}
+
+		offset := t.base - video.base
+		if !t.hasOffset {
+			t.offset0, t.hasOffset = offset, true
+			continue
+		}
+
+		// drift of the outgoing audio with the current correction
+		drift := offset + float64(t.shift)/float64(t.clockRate) - t.offset0
+		if math.Abs(drift) < s.threshold.Seconds() {
+			continue
+		}
+
+		t.shift -= int64(math.Round(drift * float64(t.clockRate)))
+		fixes = append(fixes, &AVSync{
+			Media: t.media,
+			Drift: time.Duration(drift * float64(time.Second)),
+		})
+	}
+	return
+}
+
+// ntpSeconds - NTP timestamp (seconds since 1900 and the fraction) in seconds
+func ntpSeconds(ntp uint64) float64 {
+	return float64(ntp>>32) + float64(ntp&0xFFFFFFFF)/(1<<32)
+}
+
+// ntpTime - NTP timestamp in the wall clock time
+func ntpTime(ntp uint64) time.Time {
+	const ntpEpoch = 2208988800 // seconds from 1900 to 1970
+	return time.Unix(int64(ntp>>32)-ntpEpoch, int64((ntp&0xFFFFFFFF)*1_000_000_000>>32))
+}
@@ -0,0 +1,41 @@
+package rtsp
+
+import "github.com/pion/rtp"
+
+const (
+	chunkSize   = 64 * 1024 // 64KB
+	chunkPacket = 64
+)
+
+// recvBuffer - cut memory for incoming packets from big chunks, instead of
+// allocation for each packet. Packets go to all consumers without copying and
+// can live in their queues, so chunks are never reused, only replaced.
+// Not safe for concurrent use.
+type recvBuffer struct {
+	data    []byte
+	packets []rtp.Packet
+}
+
+func (r *recvBuffer) Bytes(size int) []byte {
+	if size > len(r.data) {
+		if size > chunkSize/4 {
+			return make([]byte, size)
+		}
+		r.data = make([]byte, chunkSize)
+	}
+
+	// limit cap, so append to payload can't overwrite next packet
+	b := r.data[:size:size]
+	r.data = r.data[size:]
+	return b
+}
+
+func (r *recvBuffer) Packet() *rtp.Packet {
+	if len(r.packets) == 0 {
+		r.packets = make([]rtp.Packet, chunkPacket)
+	}
+
+	packet := &r.packets[0]
+	r.packets = r.packets[1:]
+	return packet
+}
@@ -26,7 +26,8 @@ func NewClient(uri string) *Conn {
 			ID:         core.NewID(),
 			FormatName: "rtsp",
 		},
-		uri: uri,
+		NoDelay: true,
+		uri:     uri,
 	}
 }
 
@@ -35,8 +36,35 @@ func (c *Conn) Dial() (err error) {
 		return
 	}
 
-	var conn net.Conn
+	conn, err := c.dialConn()
+	if err != nil {
+		return
+	}
+
+	// remove UserInfo from URL, reuse the last challenge for this host
+	c.auth = tcp.CachedAuth(c.URL.Host, c.URL.User)
+	c.URL.User = nil
+
+	c.conn = conn
+	c.reader = bufio.NewReaderSize(conn, core.BufferSize)
+	c.session = ""
+	c.sequence = 0
+	c.state = StateConn
+	c.closing = false
+
+	c.udpConn = nil
+	c.udpAddr = nil
+	c.channels = nil
+	c.rtcpMap = nil
 
+	c.Connection.RemoteAddr = conn.RemoteAddr().String()
+	c.Connection.Transport = conn
+	c.Connection.URL = c.uri
+
+	return nil
+}
+
+func (c *Conn) dialConn() (conn net.Conn, err error) {
 	switch c.Transport {
 	case "", "tcp", "udp":
 		var timeout time.Duration
@@ -45,7 +73,14 @@ func (c *Conn) Dial() (err error) {
 		} else {
 			timeout = core.ConnDialTimeout
 		}
-		conn, err = tcp.Dial(c.URL, timeout)
+		if conn, err = c.Filter.DialNetwork(c.network("tcp"), c.dialURL(), timeout); err == nil {
+			if err = c.checkFingerprint(conn); err == nil {
+				err = tcp.SetOptions(conn, c.NoDelay, c.ReadBuffer, c.WriteBuffer)
+			}
+			if err != nil {
+				_ = conn.Close()
+			}
+		}
 
 		if c.Transport != "udp" {
 			c.Protocol = "rtsp+tcp"
@@ -53,48 +88,91 @@ func (c *Conn) Dial() (err error) {
 			c.Protocol = "rtsp+udp"
 		}
 	default:
-		conn, err = websocket.Dial(c.Transport)
+		if err = c.Filter.CheckURL(c.Transport); err == nil {
+			conn, err = websocket.Dial(c.Transport)
+		}
 		c.Protocol = "ws"
 	}
+	return
+}
+
+// reopen - new connection for the same session, because the camera closes
+// the socket after the response with Connection: close
+func (c *Conn) reopen() error {
+	c.Fire(EventClose)
+
+	_ = c.conn.Close()
+
+	conn, err := c.dialConn()
 	if err != nil {
-		return
+		return err
 	}
 
-	// remove UserInfo from URL
-	c.auth = tcp.NewAuth(c.URL.User)
-	c.URL.User = nil
-
 	c.conn = conn
 	c.reader = bufio.NewReaderSize(conn, core.BufferSize)
-	c.session = ""
-	c.sequence = 0
-	c.state = StateConn
-
-	c.udpConn = nil
-	c.udpAddr = nil
+	c.closing = false
 
 	c.Connection.RemoteAddr = conn.RemoteAddr().String()
 	c.Connection.Transport = conn
-	c.Connection.URL = c.uri
 
 	return nil
 }
 
+// isClose - server will close the connection after this response
+func isClose(res *tcp.Response) bool {
+	return strings.EqualFold(res.Header.Get("Connection"), "close")
+}
+
+// dialURL - the pinned fingerprint replaces the CA check, so self-signed
+// camera certificates work without skipping the verification entirely
+func (c *Conn) dialURL() *url.URL {
+	if c.Fingerprint == "" || c.URL.Scheme != "rtsps" {
+		return c.URL
+	}
+	u := *c.URL
+	u.Scheme = "rtspx"
+	return &u
+}
+
+func (c *Conn) checkFingerprint(conn net.Conn) error {
+	c.fprint = tcp.Fingerprint(conn)
+	if c.Fingerprint == "" {
+		return nil
+	}
+	return tcp.CheckFingerprint(conn, c.Fingerprint)
+}
+
 // Do send WriteRequest and receive and process WriteResponse
 func (c *Conn) Do(req *tcp.Request) (*tcp.Response, error) {
+	if c.closing {
+		if err := c.reopen(); err != nil {
+			return nil, err
+		}
+	}
+
 	if err := c.WriteRequest(req); err != nil {
 		return nil, err
 	}
 
 	res, err := c.ReadResponse()
 	if err != nil {
+		if errors.Is(err, ErrTimeout) {
+			return nil, fmt.Errorf("%s: %w", req.Method, err)
+		}
 		return nil, err
 	}
 
 	c.Fire(res)
 
+	c.closing = isClose(res)
+
+	if err = c.statusError(req, res); err != nil {
+		return res, err
+	}
+
 	switch res.StatusCode {
 	case http.StatusOK:
+		tcp.CacheAuth(c.URL.Host, c.auth)
 		return res, nil
 
 	case http.StatusMovedPermanently, http.StatusFound:
@@ -121,25 +199,80 @@ func (c *Conn) Do(req *tcp.Request) (*tcp.Response, error) {
 
 		return c.Do(req)
 
+	case StatusNotEnoughBandwidth:
+		return res, fmt.Errorf("%w on %s", ErrNotEnoughBandwidth, req.Method)
+
+	case StatusOptionNotSupported:
+		// retry without unsupported options
+		if c.removeOptions(req, res.Header.Get("Unsupported")) {
+			return c.Do(req)
+		}
+
 	case http.StatusUnauthorized:
 		switch c.auth.Method {
 		case tcp.AuthNone:
 			if c.auth.ReadNone(res) {
 				return c.Do(req)
 			}
-			return nil, errors.New("user/pass not provided")
+			return nil, fmt.Errorf("%w: user/pass not provided", core.ErrAuth)
 		case tcp.AuthUnknown:
 			if c.auth.Read(res) {
 				return c.Do(req)
 			}
 		default:
-			return nil, errors.New("wrong user/pass")
+			// cached challenge or stale nonce, repeat with the new challenge
+			if c.auth.ReadAgain(res) {
+				return c.Do(req)
+			}
+			return nil, fmt.Errorf("%w: wrong user/pass", core.ErrAuth)
 		}
 	}
 
 	return res, fmt.Errorf("wrong response on %s", req.Method)
 }
 
+// removeOptions - remove options from Require and Supported headers, return true if any removed
+func (c *Conn) removeOptions(req *tcp.Request, unsupported string) (ok bool) {
+	for _, option := range strings.Split(unsupported, ",") {
+		if option = strings.TrimSpace(option); option == "" {
+			continue
+		}
+
+		if i := core.Index(c.Supported, option); i >= 0 {
+			c.Supported = append(c.Supported[:i], c.Supported[i+1:]...)
+			ok = true
+		}
+
+		for _, key := range []string{"Require", "Supported"} {
+			var values []string
+			var found bool
+			for _, value := range strings.Split(req.Header.Get(key), ",") {
+				if value = strings.TrimSpace(value); value == option {
+					found = true
+				} else if value != "" {
+					values = append(values, value)
+				}
+			}
+			if !found {
+				continue
+			}
+			if values != nil {
+				req.Header.Set(key, strings.Join(values, ", "))
+			} else {
+				req.Header.Del(key)
+			}
+			ok = true
+		}
+
+		if option == requireBackchannel {
+			c.Backchannel = false
+		}
+
+		c.Fire("RTSP unsupported option: " + option)
+	}
+	return
+}
+
 func (c *Conn) Options() error {
 	req := &tcp.Request{Method: MethodOptions, URL: c.URL}
 
@@ -170,7 +303,7 @@ func (c *Conn) Describe() error {
 	}
 
 	if c.Backchannel {
-		req.Header.Set("Require", "www.onvif.org/ver20/backchannel")
+		req.Header.Set("Require", requireBackchannel)
 	}
 
 	if c.UserAgent != "" {
@@ -181,23 +314,50 @@ func (c *Conn) Describe() error {
 
 	res, err := c.Do(req)
 	if err != nil {
+		// main stream is not available, try the substream with the new session
+		if errors.Is(err, ErrSubstream) && c.switchFallback() {
+			_ = c.conn.Close()
+			if err = c.Dial(); err != nil {
+				return err
+			}
+			return c.Describe()
+		}
 		return err
 	}
 
+	// RFC 2326 C.1.1: base URL is Content-Base, Content-Location or request URL
 	if val := res.Header.Get("Content-Base"); val != "" {
 		c.URL, err = urlParse(val)
 		if err != nil {
 			return err
 		}
+	} else if val = res.Header.Get("Content-Location"); val != "" {
+		c.URL, err = resolveControl(c.URL, val)
+		if err != nil {
+			return err
+		}
+	}
+
+	// session level control is aggregate control URL for PLAY and base for medias
+	if control := sessionControl(res.Body); control != "" {
+		c.URL, err = resolveControl(c.URL, control)
+		if err != nil {
+			return err
+		}
 	}
 
 	c.SDP = string(res.Body) // for info
+	c.rawSDP = res.Body
 
 	medias, err := UnmarshalSDP(res.Body)
 	if err != nil {
 		return err
 	}
 
+	if c.RTPMap != nil {
+		applyRTPMap(medias, res.Body, c.RTPMap)
+	}
+
 	if c.Media != "" {
 		clone := make([]*core.Media, 0, len(medias))
 		for _, media := range medias {
@@ -208,6 +368,17 @@ func (c *Conn) Describe() error {
 		medias = clone
 	}
 
+	if c.Lang != "" {
+		medias = selectLang(medias, c.Lang)
+	}
+
+	if medias, c.Skipped, err = filterUnknown(medias, c.Unknown); err != nil {
+		return err
+	}
+	for _, skipped := range c.Skipped {
+		c.Fire("RTSP skip media: " + skipped.Media)
+	}
+
 	// TODO: rewrite more smart
 	if c.Medias == nil {
 		c.Medias = medias
@@ -255,7 +426,7 @@ func (c *Conn) SetupMedia(media *core.Media) (byte, error) {
 	var transport string
 
 	if c.Transport == "udp" {
-		conn1, conn2, err := ListenUDPPair()
+		conn1, conn2, err := ListenUDPPairNetwork(c.network("udp"))
 		if err != nil {
 			return 0, err
 		}
@@ -264,34 +435,15 @@ func (c *Conn) SetupMedia(media *core.Media) (byte, error) {
 
 		port := conn1.LocalAddr().(*net.UDPAddr).Port
 		transport = fmt.Sprintf("RTP/AVP;unicast;client_port=%d-%d", port, port+1)
-	} else {
-		// try to use media position as channel number
-		for i, m := range c.Medias {
-			if m.Equal(media) {
-				transport = fmt.Sprintf(
-					// i   - RTP (data channel)
-					// i+1 - RTCP (control channel)
-					"RTP/AVP/TCP;unicast;interleaved=%d-%d", i*2, i*2+1,
-				)
-				break
-			}
-		}
+	} else if channel := c.interleavedChannel(media); channel != noChannel {
+		transport = interleavedTransport(channel)
 	}
 
 	if transport == "" {
 		return 0, fmt.Errorf("wrong media: %v", media)
 	}
 
-	rawURL := media.ID // control
-	if !strings.Contains(rawURL, "://") {
-		rawURL = c.URL.String()
-		// prefix check for https://github.com/AlexxIT/go2rtc/issues/1236
-		if !strings.HasSuffix(rawURL, "/") && !strings.HasPrefix(media.ID, "/") {
-			rawURL += "/"
-		}
-		rawURL += media.ID
-	}
-	trackURL, err := urlParse(rawURL)
+	trackURL, err := resolveControl(c.URL, media.ID)
 	if err != nil {
 		return 0, err
 	}
@@ -306,6 +458,14 @@ func (c *Conn) SetupMedia(media *core.Media) (byte, error) {
 
 	res, err := c.Do(req)
 	if err != nil {
+		// camera at its session limit, try lower bandwidth substream
+		if isFallback(err) && c.switchFallback() {
+			if err = c.Reconnect(); err != nil {
+				return 0, err
+			}
+			return c.SetupMedia(media)
+		}
+
 		// some Dahua/Amcrest cameras fail here because two simultaneous
 		// backchannel connections
 		if c.Backchannel {
@@ -379,13 +539,223 @@ func (c *Conn) SetupMedia(media *core.Media) (byte, error) {
 			return 0, fmt.Errorf("wrong transport: %s", transport)
 		}
 
+		// RTCP channel usually is the next one, remember others
+		s = core.Between(transport, "interleaved="+s+"-", ";")
+		if j, err := strconv.Atoi(s); err == nil && j != i+1 && j != i && j < 256 {
+			if c.rtcpMap == nil {
+				c.rtcpMap = map[byte]byte{}
+			}
+			c.rtcpMap[byte(j)] = byte(i)
+		}
+
+		if err = c.checkChannel(media, byte(i)); err != nil {
+			return 0, err
+		}
+
 		return byte(i), nil
 	}
 }
 
+// checkChannel - RTP and RTCP channels of the media shouldn't overlap with channels
+// of other medias in the session, otherwise packets will be routed to the wrong track
+func (c *Conn) checkChannel(media *core.Media, channel byte) error {
+	for id, other := range c.channels {
+		if other.Equal(media) {
+			continue
+		}
+		if id == channel || id+1 == channel || channel+1 == id {
+			return fmt.Errorf("%w: %d-%d for %s, already used by %s",
+				ErrChannelCollision, channel, channel+1, media, other)
+		}
+	}
+
+	if c.channels == nil {
+		c.channels = map[byte]*core.Media{}
+	}
+	c.channels[channel] = media
+	return nil
+}
+
+// switchFallback - change source URL to fallback URL, only once
+func (c *Conn) switchFallback() bool {
+	if c.Fallback == "" || c.uri == c.Fallback {
+		return false
+	}
+
+	u, err := url.Parse(c.Fallback)
+	if err != nil {
+		return false
+	}
+	if u.User == nil {
+		u.User = c.auth.UserInfo() // same auth for substream
+	}
+
+	c.uri = u.String()
+	c.Fallback = c.uri
+	c.Fire(EventFallback)
+	return true
+}
+
+const noChannel = 0xFF
+
+// interleavedChannel - try to use media position as channel number
+func (c *Conn) interleavedChannel(media *core.Media) byte {
+	for i, m := range c.Medias {
+		if m.Equal(media) {
+			return byte(i * 2)
+		}
+	}
+	return noChannel
+}
+
+// deferSetup - for fast start we PLAY video first and SETUP audio later
+func (c *Conn) deferSetup(media *core.Media) bool {
+	if !c.FastStart || c.Transport == "udp" || media.Kind != core.KindAudio {
+		return false
+	}
+	if c.interleavedChannel(media) == noChannel {
+		return false
+	}
+	for _, receiver := range c.Receivers {
+		if receiver.Codec.IsVideo() {
+			return true
+		}
+	}
+	return false
+}
+
+// ErrFastStart - fired if the deferred medias of the fast start fail, video continues without them
+var ErrFastStart = errors.New("rtsp: fast start deferred setup")
+
+// playPending - SETUP and PLAY deferred medias in the PLAY state. Responses are
+// read by the Handle loop, so each request waits own response by CSeq.
+func (c *Conn) playPending(receivers []*core.Receiver) error {
+	for _, receiver := range receivers {
+		trackURL, err := resolveControl(c.URL, receiver.Media.ID)
+		if err != nil {
+			return err
+		}
+
+		req := &tcp.Request{
+			Method: MethodSetup,
+			URL:    trackURL,
+			Header: map[string][]string{"Transport": {interleavedTransport(receiver.ID)}},
+		}
+		res, err := c.doPending(req)
+		if err != nil {
+			return err
+		}
+
+		// the read loop already routes packets by our channel, so another one can't be used
+		transport := res.Header.Get("Transport")
+		if s := core.Between(transport, "interleaved=", "-"); s != strconv.Itoa(int(receiver.ID)) {
+			return fmt.Errorf("%w: %s for %s", ErrChannelCollision, transport, receiver.Media)
+		}
+	}
+
+	_, err := c.doPending(c.playRequest())
+	return err
+}
+
+// pendingResponse - response for the request from the PLAY state
+type pendingResponse struct {
+	cseq string
+	ch   chan *tcp.Response
+}
+
+// doPending - same as Do, but the response is read by the Handle loop
+func (c *Conn) doPending(req *tcp.Request) (*tcp.Response, error) {
+	wait := &pendingResponse{ch: make(chan *tcp.Response, 1)}
+	defer c.setPending(nil)
+
+	if err := c.writeRequest(req, wait); err != nil {
+		return nil, err
+	}
+
+	timeout := Timeout
+	if c.CommandTimeout > 0 {
+		timeout = c.CommandTimeout
+	}
+
+	select {
+	case res := <-wait.ch:
+		if res.StatusCode != http.StatusOK {
+			return res, fmt.Errorf("%s: status %d", req.Method, res.StatusCode)
+		}
+		return res, nil
+	case <-time.After(timeout):
+		return nil, fmt.Errorf("%s: %w", req.Method, ErrTimeout)
+	}
+}
+
+func (c *Conn) setPending(wait *pendingResponse) {
+	c.waitMu.Lock()
+	c.waiting = wait
+	c.waitMu.Unlock()
+}
+
+// resolvePending - pass the response to the waiting request, called from the Handle loop
+func (c *Conn) resolvePending(res *tcp.Response) {
+	c.waitMu.Lock()
+	if c.waiting != nil && c.waiting.cseq == res.Header.Get("CSeq") {
+		c.waiting.ch <- res
+		c.waiting = nil
+	}
+	c.waitMu.Unlock()
+}
+
+// interleavedTransport - Transport header of SETUP for the TCP interleaved channels
+func interleavedTransport(channel byte) string {
+	// i   - RTP (data channel)
+	// i+1 - RTCP (control channel)
+	return fmt.Sprintf("RTP/AVP/TCP;unicast;interleaved=%d-%d", channel, channel+1)
+}
+
 func (c *Conn) Play() (err error) {
+	return c.WriteRequest(c.playRequest())
+}
+
+func (c *Conn) playRequest() *tcp.Request {
 	req := &tcp.Request{Method: MethodPlay, URL: c.URL}
-	return c.WriteRequest(req)
+	if c.useScale() {
+		req.Header = map[string][]string{"Scale": {formatScale(c.Scale)}}
+	}
+	return req
+}
+
+// applyRTPInfo - seed receivers with initial seq from PLAY response
+func (c *Conn) applyRTPInfo(infos []rtpInfo) {
+	for _, receiver := range c.Receivers {
+		if receiver.Media == nil {
+			continue
+		}
+
+		for _, info := range infos {
+			// some cameras send one entry with session URL for the single media
+			if len(infos) == 1 && len(c.Medias) == 1 || c.matchControl(receiver.Media.ID, info.URL) {
+				receiver.StartSeq = info.Seq
+				break
+			}
+		}
+	}
+}
+
+// matchControl - check if RTP-Info url (absolute or relative) is for the media control
+func (c *Conn) matchControl(control, rawURL string) bool {
+	if control == "" || control == "*" {
+		return false
+	}
+
+	u1, err := resolveControl(c.URL, control)
+	if err != nil {
+		return false
+	}
+	u2, err := resolveControl(c.URL, rawURL)
+	if err != nil {
+		return false
+	}
+
+	return u1.String() == u2.String() || strings.HasSuffix(rawURL, "/"+control)
 }
 
 func (c *Conn) Teardown() (err error) {
@@ -415,13 +785,27 @@ const listenUDPAttemps = 10
 
 var listenUDPMu sync.Mutex
 
+// network - "tcp" or "udp" with the IP version suffix, if it's forced
+func (c *Conn) network(proto string) string {
+	switch c.IPVersion {
+	case "4", "6":
+		return proto + c.IPVersion
+	}
+	return proto
+}
+
 func ListenUDPPair() (*net.UDPConn, *net.UDPConn, error) {
+	return ListenUDPPairNetwork("udp")
+}
+
+// ListenUDPPairNetwork - same as ListenUDPPair, "udp4" or "udp6" network forces the address family
+func ListenUDPPairNetwork(network string) (*net.UDPConn, *net.UDPConn, error) {
 	listenUDPMu.Lock()
 	defer listenUDPMu.Unlock()
 
 	for i := 0; i < listenUDPAttemps; i++ {
 		// Get a random even port from the OS
-		ln1, err := net.ListenUDP("udp", &net.UDPAddr{IP: nil, Port: 0})
+		ln1, err := net.ListenUDP(network, &net.UDPAddr{IP: nil, Port: 0})
 		if err != nil {
 			continue
 		}
@@ -439,7 +823,7 @@ func ListenUDPPair() (*net.UDPConn, *net.UDPConn, error) {
 			port2 = port1 + 1
 		}
 
-		ln2, err := net.ListenUDP("udp", &net.UDPAddr{IP: nil, Port: port2})
+		ln2, err := net.ListenUDP(network, &net.UDPAddr{IP: nil, Port: port2})
 		if err != nil {
 			_ = ln1.Close()
 			continue
@@ -1,11 +1,24 @@
 package rtsp
 
 import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
 	"net"
+	"net/textproto"
+	"net/url"
 	"os"
+	"runtime"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/AlexxIT/go2rtc/pkg/core"
+	"github.com/AlexxIT/go2rtc/pkg/tcp"
+	"github.com/pion/rtp"
 	"github.com/stretchr/testify/require"
 )
 
@@ -31,14 +44,24 @@ func TestMissedControl(t *testing.T) {
 	ln, err := net.Listen("tcp", "localhost:0")
 	require.Nil(t, err)
 
+	// the server can fail after the test, when the client is closed by GC,
+	// so errors are checked only on the test goroutine
+	errs := make(chan error, 1)
+
 	go func() {
 		conn, err := ln.Accept()
-		require.Nil(t, err)
+		if err != nil {
+			errs <- err
+			return
+		}
 
 		b := make([]byte, 8192)
 		for {
 			n, err := conn.Read(b)
-			require.Nil(t, err)
+			if err != nil {
+				errs <- err
+				return
+			}
 
 			req := string(b[:n])
 
@@ -74,7 +97,8 @@ Session: 1
 `))
 
 			default:
-				t.Fail()
+				errs <- errors.New("unexpected request: " + req)
+				return
 			}
 		}
 	}()
@@ -92,4 +116,1151 @@ Session: 1
 	ch, err := client.SetupMedia(client.Medias[2])
 	require.Nil(t, err)
 	require.Equal(t, ch, byte(4))
+
+	select {
+	case err = <-errs:
+		require.Nil(t, err)
+	default:
+	}
+}
+
+func TestOptionNotSupported(t *testing.T) {
+	ln, err := net.Listen("tcp", "localhost:0")
+	require.Nil(t, err)
+
+	errs := make(chan error, 1)
+
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			errs <- err
+			return
+		}
+
+		b := make([]byte, 8192)
+		for {
+			n, err := conn.Read(b)
+			if err != nil {
+				return
+			}
+
+			if strings.Contains(string(b[:n]), "play.scale") {
+				_, _ = conn.Write([]byte("RTSP/1.0 551 Option not supported\r\nCSeq: 1\r\nUnsupported: play.scale\r\n\r\n"))
+			} else {
+				_, _ = conn.Write([]byte("RTSP/1.0 200 OK\r\nCSeq: 2\r\n\r\n"))
+			}
+		}
+	}()
+
+	client := NewClient("rtsp://" + ln.Addr().String() + "/stream")
+	client.Supported = []string{"play.basic", "play.scale"}
+
+	err = client.Dial()
+	require.Nil(t, err)
+
+	err = client.Options()
+	require.Nil(t, err)
+	require.Equal(t, []string{"play.basic"}, client.Supported)
+
+	select {
+	case err = <-errs:
+		require.Nil(t, err)
+	default:
+	}
+}
+
+func TestSetupTimeout(t *testing.T) {
+	conn1, conn2 := net.Pipe()
+
+	go func() {
+		_, _ = conn2.Write([]byte("SETUP rtsp://localhost/stream/trackID=0 RTSP/1.0\r\nCSeq: 1\r\nTransport: RTP/AVP/TCP;unicast;interleaved=0-1\r\n\r\n"))
+
+		b := make([]byte, 8192)
+		for {
+			if _, err := conn2.Read(b); err != nil {
+				return
+			}
+		}
+	}()
+
+	server := NewServer(conn1)
+	server.SetupTimeout = time.Millisecond
+
+	err := server.Accept()
+	require.ErrorIs(t, err, ErrSetupTimeout)
+}
+
+func TestCommandTimeout(t *testing.T) {
+	ln, err := net.Listen("tcp", "localhost:0")
+	require.Nil(t, err)
+	defer ln.Close()
+
+	errs := make(chan error, 1)
+
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			errs <- err
+			return
+		}
+		defer conn.Close()
+
+		// camera reads request, but never responds
+		b := make([]byte, 8192)
+		for {
+			if _, err = conn.Read(b); err != nil {
+				return
+			}
+		}
+	}()
+
+	client := NewClient("rtsp://" + ln.Addr().String() + "/stream")
+	client.CommandTimeout = time.Millisecond
+	require.Nil(t, client.Dial())
+	defer client.Close()
+
+	err = client.Describe()
+	require.ErrorIs(t, err, ErrTimeout)
+	require.ErrorIs(t, err, os.ErrDeadlineExceeded)
+
+	select {
+	case err = <-errs:
+		require.Nil(t, err)
+	default:
+	}
+}
+
+func TestServerBackchannel(t *testing.T) {
+	conn1, conn2 := net.Pipe()
+
+	// backchannel request and track error from the server goroutine
+	var backchannel bool
+	var trackErr error
+
+	server := NewServer(conn1)
+	server.Listen(func(msg any) {
+		if msg != MethodDescribe {
+			return
+		}
+		backchannel = server.Backchannel
+
+		// video to client and backchannel audio from client
+		video := &core.Media{Kind: core.KindVideo, Direction: core.DirectionSendonly}
+		sender := core.NewSender(video, &core.Codec{Name: core.CodecH264, ClockRate: 90000, PayloadType: 96})
+		server.Senders = append(server.Senders, sender)
+
+		audio := &core.Media{Kind: core.KindAudio, Direction: core.DirectionRecvonly}
+		_, trackErr = server.GetTrack(audio, &core.Codec{Name: core.CodecPCMA, ClockRate: 8000})
+	})
+
+	go func() {
+		_ = server.Accept()
+	}()
+
+	_, _ = conn2.Write([]byte("DESCRIBE rtsp://localhost/camera1 RTSP/1.0\r\nCSeq: 1\r\nRequire: www.onvif.org/ver20/backchannel\r\n\r\n"))
+
+	// the response is written after the listener
+	res, err := tcp.ReadResponse(bufio.NewReader(conn2))
+	require.Nil(t, err)
+	require.True(t, backchannel)
+	require.Nil(t, trackErr)
+	require.Contains(t, string(res.Body), "a=sendonly")
+	require.Equal(t, byte(2), server.Receivers[0].ID) // trackID=1
+
+	_ = conn2.Close()
+}
+
+func TestServerKeepalive(t *testing.T) {
+	// before PLAY
+	conn1, conn2 := net.Pipe()
+	server := NewServer(conn1)
+	go func() {
+		_ = server.Accept()
+	}()
+
+	_, _ = conn2.Write([]byte("GET_PARAMETER rtsp://localhost/camera1 RTSP/1.0\r\nCSeq: 2\r\n\r\n"))
+
+	res, err := tcp.ReadResponse(bufio.NewReader(conn2))
+	require.Nil(t, err)
+	require.Equal(t, 200, res.StatusCode)
+	require.Equal(t, "2", res.Header.Get("CSeq"))
+	_ = conn2.Close()
+
+	// while playing
+	conn1, conn2 = net.Pipe()
+	server = NewServer(conn1)
+	server.mode = core.ModePassiveConsumer
+	server.state = StatePlay
+	go func() {
+		_ = server.Handle()
+	}()
+
+	_, _ = conn2.Write([]byte("GET_PARAMETER rtsp://localhost/camera1 RTSP/1.0\r\nCSeq: 5\r\n\r\n"))
+
+	res, err = tcp.ReadResponse(bufio.NewReader(conn2))
+	require.Nil(t, err)
+	require.Equal(t, 200, res.StatusCode)
+	require.Equal(t, "5", res.Header.Get("CSeq"))
+	_ = conn2.Close()
+}
+
+func TestDescribeRTPMap(t *testing.T) {
+	server := newFakeServer(t)
+	server.SDP = `v=0
+o=- 0 0 IN IP4 0.0.0.0
+s=-
+t=0 0
+m=video 0 RTP/AVP 96
+a=control:trackID=0
+m=audio 0 RTP/AVP 0
+a=control:trackID=1
+m=audio 0 RTP/AVP 97
+a=rtpmap:97 MPEG4-GENERIC/16000
+a=control:trackID=2
+m=video 0 RTP/AVP 98
+a=rtpmap:98 H264/90000
+a=control:trackID=3
+m=audio 0 RTP/AVP 98
+a=control:trackID=4
+`
+
+	client := fakeDial(t, server.URL())
+	client.RTPMap = map[uint8]*core.Codec{
+		96: {Name: core.CodecH265, ClockRate: 90000},
+		0:  {Name: core.CodecPCMA, ClockRate: 8000},
+		97: {Name: core.CodecOpus, ClockRate: 48000, Channels: 2},
+		98: {Name: core.CodecPCMU, ClockRate: 8000},
+	}
+	require.Nil(t, client.Describe())
+	require.Len(t, client.Medias, 5)
+
+	// override for the dynamic type without rtpmap
+	require.Equal(t, core.CodecH265, client.Medias[0].Codecs[0].Name)
+	require.Equal(t, uint32(90000), client.Medias[0].Codecs[0].ClockRate)
+
+	// override replaces the guess by the static type
+	require.Equal(t, core.CodecPCMA, client.Medias[1].Codecs[0].Name)
+
+	// rtpmap from SDP has priority
+	require.Equal(t, core.CodecAAC, client.Medias[2].Codecs[0].Name)
+	require.Equal(t, uint32(16000), client.Medias[2].Codecs[0].ClockRate)
+
+	// rtpmap of another media doesn't block the override
+	require.Equal(t, core.CodecH264, client.Medias[3].Codecs[0].Name)
+	require.Equal(t, core.CodecPCMU, client.Medias[4].Codecs[0].Name)
+}
+
+func TestDescribeUnknown(t *testing.T) {
+	server := newFakeServer(t)
+	server.SDP = `v=0
+o=- 0 0 IN IP4 0.0.0.0
+s=-
+t=0 0
+m=video 0 RTP/AVP 96
+a=rtpmap:96 H264/90000
+a=control:trackID=0
+m=application 0 RTP/AVP 107
+a=rtpmap:107 vnd.onvif.metadata/90000
+a=control:trackID=1
+`
+
+	client := fakeDial(t, server.URL())
+	require.Nil(t, client.Describe())
+	require.Len(t, client.Medias, 2)
+	require.Nil(t, client.Skipped)
+
+	client = fakeDial(t, server.URL())
+	client.Unknown = UnknownSkip
+	require.Nil(t, client.Describe())
+	require.Len(t, client.Medias, 1)
+	require.Len(t, client.Skipped, 1)
+	require.Equal(t, "application, recvonly, VND.ONVIF.METADATA", client.Skipped[0].Media)
+
+	b, err := client.MarshalJSON()
+	require.Nil(t, err)
+	require.Contains(t, string(b), `"skipped_medias":[{"media":"application, recvonly, VND.ONVIF.METADATA","reason":"unsupported codecs"}]`)
+
+	client = fakeDial(t, server.URL())
+	client.Unknown = UnknownFail
+	require.ErrorContains(t, client.Describe(), "unsupported media")
+
+	// nothing to play
+	server.SDP = `v=0
+o=- 0 0 IN IP4 0.0.0.0
+s=-
+t=0 0
+m=application 0 RTP/AVP 107
+a=rtpmap:107 vnd.onvif.metadata/90000
+`
+	client = fakeDial(t, server.URL())
+	client.Unknown = UnknownSkip
+	require.ErrorContains(t, client.Describe(), "no playable medias")
+}
+
+func TestProbe(t *testing.T) {
+	server := newFakeServer(t)
+	server.SDP = `v=0
+o=- 0 0 IN IP4 0.0.0.0
+s=-
+t=0 0
+m=video 0 RTP/AVP 96
+a=rtpmap:96 H264/90000
+a=fmtp:96 packetization-mode=1
+a=control:trackID=0
+m=audio 0 RTP/AVP 97
+a=rtpmap:97 MPEG4-GENERIC/16000
+a=control:trackID=1
+m=application 0 RTP/AVP 107
+a=rtpmap:107 vnd.onvif.metadata/90000
+a=control:trackID=2
+`
+
+	client := fakeDial(t, server.URL())
+	require.Nil(t, client.Describe())
+
+	probes := client.Probe()
+	require.Len(t, probes, 3)
+
+	require.Equal(t, core.CodecH264, probes[0].Codec)
+	require.Equal(t, "packetization-mode=1", probes[0].FmtpLine)
+	require.True(t, probes[0].Supported)
+	require.NotEmpty(t, probes[0].Note) // no sprop-parameter-sets
+
+	require.Equal(t, core.CodecAAC, probes[1].Codec)
+	require.Equal(t, uint32(16000), probes[1].ClockRate)
+	require.False(t, probes[1].Supported) // no config
+
+	require.Equal(t, "trackID=2", probes[2].Control)
+	require.False(t, probes[2].Supported)
+}
+
+func TestDescribeLang(t *testing.T) {
+	server := newFakeServer(t)
+	server.SDP = `v=0
+o=- 0 0 IN IP4 0.0.0.0
+s=-
+t=0 0
+m=video 0 RTP/AVP 96
+a=rtpmap:96 H264/90000
+a=control:trackID=0
+m=audio 0 RTP/AVP 97
+a=rtpmap:97 MPEG4-GENERIC/48000/2
+a=lang:en-US
+a=control:trackID=1
+m=audio 0 RTP/AVP 98
+a=rtpmap:98 MPEG4-GENERIC/48000/2
+a=lang:de
+a=control:trackID=2
+`
+
+	client := fakeDial(t, server.URL())
+	client.Lang = "de"
+	require.Nil(t, client.Describe())
+	require.Len(t, client.Medias, 2)
+	require.Equal(t, "trackID=2", client.Medias[1].ID)
+	require.Equal(t, "de", client.Medias[1].Lang)
+
+	client = fakeDial(t, server.URL())
+	client.Lang = "en"
+	require.Nil(t, client.Describe())
+	require.Len(t, client.Medias, 2)
+	require.Equal(t, "trackID=1", client.Medias[1].ID)
+
+	// fallback to the first audio
+	client = fakeDial(t, server.URL())
+	client.Lang = "fr"
+	require.Nil(t, client.Describe())
+	require.Len(t, client.Medias, 2)
+	require.Equal(t, "trackID=1", client.Medias[1].ID)
+
+	client = fakeDial(t, server.URL())
+	require.Nil(t, client.Describe())
+	require.Len(t, client.Medias, 3)
+}
+
+func TestSetupChannelCollision(t *testing.T) {
+	server := newFakeServer(t)
+	server.SDP = `v=0
+o=- 0 0 IN IP4 0.0.0.0
+s=-
+t=0 0
+m=video 0 RTP/AVP 96
+a=rtpmap:96 H264/90000
+a=control:trackID=0
+m=audio 0 RTP/AVP 8
+a=rtpmap:8 PCMA/8000
+a=control:trackID=1
+`
+
+	client := fakeDial(t, server.URL())
+	require.Nil(t, client.Describe())
+
+	// camera answers with the same channel for all medias
+	_, err := client.SetupMedia(client.Medias[0])
+	require.Nil(t, err)
+	_, err = client.SetupMedia(client.Medias[1])
+	require.ErrorIs(t, err, ErrChannelCollision)
+	require.ErrorContains(t, err, "0-1 for audio, recvonly, PCMA/8000, already used by video, recvonly, H264")
+
+	// SETUP of the same media again is OK
+	_, err = client.SetupMedia(client.Medias[0])
+	require.Nil(t, err)
+}
+
+func TestAuthCache(t *testing.T) {
+	server := newFakeServer(t)
+	server.User = "admin"
+	server.Pass = "secret"
+
+	rawURL := "rtsp://admin:secret@" + server.ln.Addr().String() + "/stream"
+
+	client := fakeDial(t, rawURL)
+	require.Nil(t, client.Describe())
+	require.Len(t, server.Requests(MethodDescribe), 2)
+
+	// reconnect sends credentials with the first request
+	client = fakeDial(t, rawURL)
+	require.Nil(t, client.Describe())
+	require.Len(t, server.Requests(MethodDescribe), 3)
+
+	// cache is not used for other credentials
+	client = fakeDial(t, "rtsp://admin:wrong@"+server.ln.Addr().String()+"/stream")
+	require.ErrorIs(t, client.Describe(), core.ErrAuth)
+}
+
+func TestAuthCacheStale(t *testing.T) {
+	server := newFakeServer(t)
+	server.User = "admin"
+	server.Pass = "secret"
+	server.Nonce = "nonce1"
+
+	rawURL := "rtsp://admin:secret@" + server.ln.Addr().String() + "/stream"
+
+	client := fakeDial(t, rawURL)
+	require.Nil(t, client.Describe())
+	require.Len(t, server.Requests(MethodDescribe), 2)
+
+	client = fakeDial(t, rawURL)
+	require.Nil(t, client.Describe())
+	require.Len(t, server.Requests(MethodDescribe), 3)
+
+	// server expired the nonce: cached challenge, stale=true, new challenge
+	server.SetNonce("nonce2")
+
+	client = fakeDial(t, rawURL)
+	require.Nil(t, client.Describe())
+	require.Len(t, server.Requests(MethodDescribe), 5)
+
+	// stale nonce for the same connection
+	server.SetNonce("nonce3")
+	require.Nil(t, client.Describe())
+	require.Len(t, server.Requests(MethodDescribe), 7)
+}
+
+func TestPlayCSRC(t *testing.T) {
+	server := newFakeServer(t)
+	server.Handle(MethodPlay, func(req *tcp.Request) *fakeResponse {
+		return &fakeResponse{Packets: []*rtp.Packet{{
+			Header:  rtp.Header{Version: 2, PayloadType: 96, SequenceNumber: 1, CSRC: []uint32{0x1111, 0x2222}},
+			Payload: []byte{0x65, 0x88, 0x84},
+		}}}
+	})
+
+	client := fakeDial(t, server.URL())
+	require.Nil(t, client.Describe())
+
+	media := client.Medias[0]
+	receiver, err := client.GetTrack(media, media.Codecs[0])
+	require.Nil(t, err)
+
+	packets := make(chan *rtp.Packet, 1)
+	sender := core.NewSender(media, receiver.Codec)
+	sender.Handler = func(packet *rtp.Packet) {
+		packets <- packet
+	}
+	sender.HandleRTP(receiver)
+
+	go func() {
+		_ = client.Start()
+	}()
+
+	select {
+	case packet := <-packets:
+		require.Equal(t, []uint32{0x1111, 0x2222}, packet.CSRC)
+		require.Equal(t, []uint32{0x1111, 0x2222}, receiver.CSRC())
+	case <-time.After(time.Second):
+		require.FailNow(t, "packet timeout")
+	}
+}
+
+func TestStopDuringPlay(t *testing.T) {
+	server := newFakeServer(t)
+
+	client := fakeDial(t, server.URL())
+	require.Nil(t, client.Describe())
+
+	media := client.Medias[0]
+	_, err := client.GetTrack(media, media.Codecs[0])
+	require.Nil(t, err)
+	require.Nil(t, client.Play())
+	client.state = StatePlay
+
+	errs := make(chan error, 1)
+	go func() {
+		errs <- client.Handle()
+	}()
+
+	// wait the read loop start
+	require.Eventually(t, func() bool {
+		client.stateMu.Lock()
+		defer client.stateMu.Unlock()
+		return client.handling != nil
+	}, time.Second, time.Millisecond)
+
+	// only the read loop, keepalive is on the timer
+	require.Equal(t, int32(1), client.activity.workers.Load())
+
+	require.Nil(t, client.Stop())
+
+	// read loop exits without "use of closed network connection" error
+	select {
+	case err = <-errs:
+		require.Nil(t, err)
+	case <-time.After(time.Second):
+		require.FailNow(t, "handle timeout")
+	}
+}
+
+// BenchmarkPlayGoroutines - client goroutines of the playing TCP connections
+// with RTCP reports, fake server goroutines are not counted
+func BenchmarkPlayGoroutines(b *testing.B) {
+	const conns = 100
+
+	server := newFakeServer(b)
+
+	for i := 0; i < b.N; i++ {
+		clients := make([]*Conn, conns)
+		for j := range clients {
+			client := fakeDial(b, server.URL())
+			client.RTCPReports = true
+			require.Nil(b, client.Describe())
+
+			media := client.Medias[0]
+			_, err := client.GetTrack(media, media.Codecs[0])
+			require.Nil(b, err)
+			require.Nil(b, client.Play())
+			client.state = StatePlay
+			clients[j] = client
+		}
+
+		// server goroutines are already running and stay the same
+		n := runtime.NumGoroutine()
+
+		for _, client := range clients {
+			go func() {
+				_ = client.Handle()
+			}()
+		}
+
+		require.Eventually(b, func() bool {
+			for _, client := range clients {
+				if client.activity.workers.Load() == 0 {
+					return false
+				}
+			}
+			return true
+		}, time.Second, time.Millisecond)
+		time.Sleep(10 * time.Millisecond) // other workers of the connections
+
+		b.ReportMetric(float64(runtime.NumGoroutine()-n)/conns, "goroutines/conn")
+
+		for _, client := range clients {
+			_ = client.Stop()
+		}
+	}
+}
+
+func TestFastStart(t *testing.T) {
+	const sdp = `v=0
+o=- 0 0 IN IP4 0.0.0.0
+s=-
+t=0 0
+m=video 0 RTP/AVP 96
+a=rtpmap:96 H264/90000
+a=control:trackID=0
+m=audio 0 RTP/AVP 8
+a=rtpmap:8 PCMA/8000
+a=control:trackID=1
+`
+
+	start := func(t *testing.T, audioStatus int) (*fakeServer, chan error) {
+		server := newFakeServer(t)
+		server.SDP = sdp
+		server.Handle(MethodSetup, func(req *tcp.Request) *fakeResponse {
+			if strings.HasSuffix(req.URL.Path, "trackID=1") && audioStatus != 0 {
+				return &fakeResponse{StatusCode: audioStatus}
+			}
+			return &fakeResponse{Header: map[string]string{"Transport": req.Header.Get("Transport"), "Session": "1"}}
+		})
+
+		client := fakeDial(t, server.URL())
+		client.FastStart = true
+
+		errs := make(chan error, 1)
+		client.Listen(func(msg any) {
+			if err, ok := msg.(error); ok && errors.Is(err, ErrFastStart) {
+				errs <- err
+			}
+		})
+
+		require.Nil(t, client.Describe())
+		for _, media := range client.Medias {
+			_, err := client.GetTrack(media, media.Codecs[0])
+			require.Nil(t, err)
+		}
+		require.Len(t, server.Requests(MethodSetup), 1) // audio is deferred
+
+		go func() {
+			_ = client.Start()
+		}()
+
+		return server, errs
+	}
+
+	server, errs := start(t, 0)
+	require.Eventually(t, func() bool {
+		return len(server.Requests(MethodPlay)) == 2
+	}, time.Second, time.Millisecond)
+
+	setups := server.Requests(MethodSetup)
+	require.Len(t, setups, 2)
+	require.Equal(t, "RTP/AVP/TCP;unicast;interleaved=2-3", setups[1].Header.Get("Transport"))
+	require.Empty(t, errs)
+
+	// CSeq is unique for requests from the read loop and the deferred goroutine
+	seqs := map[string]bool{}
+	for _, method := range []string{MethodDescribe, MethodSetup, MethodPlay} {
+		for _, req := range server.Requests(method) {
+			require.False(t, seqs[req.Header.Get("CSeq")])
+			seqs[req.Header.Get("CSeq")] = true
+		}
+	}
+
+	// deferred SETUP error is reported and PLAY is not sent again
+	server, errs = start(t, 461)
+	select {
+	case err := <-errs:
+		require.ErrorContains(t, err, "status 461")
+	case <-time.After(time.Second):
+		require.FailNow(t, "fast start error timeout")
+	}
+	require.Len(t, server.Requests(MethodPlay), 1)
+}
+
+func TestReady(t *testing.T) {
+	server := newFakeServer(t)
+	server.Handle(MethodPlay, func(req *tcp.Request) *fakeResponse {
+		return &fakeResponse{Packets: []*rtp.Packet{
+			{Header: rtp.Header{Version: 2, PayloadType: 96, SequenceNumber: 1}, Payload: []byte{0x41, 0x9A, 0x00}}, // P-frame
+			{Header: rtp.Header{Version: 2, PayloadType: 96, SequenceNumber: 2, Marker: true}, Payload: []byte{0x65, 0x88, 0x84}},
+		}}
+	})
+
+	client := fakeDial(t, server.URL())
+	require.Nil(t, client.Describe())
+
+	var keyframes int
+	client.Listen(func(msg any) {
+		if msg == EventKeyframe {
+			keyframes++
+		}
+	})
+
+	media := client.Medias[0]
+	_, err := client.GetTrack(media, media.Codecs[0])
+	require.Nil(t, err)
+
+	go func() {
+		_ = client.Start()
+	}()
+
+	select {
+	case <-client.Ready():
+		require.Nil(t, client.ReadyErr())
+		require.Equal(t, 1, keyframes)
+	case <-time.After(time.Second):
+		require.FailNow(t, "ready timeout")
+	}
+
+	require.Nil(t, client.Stop())
+	require.Nil(t, client.ReadyErr()) // stays ready
+}
+
+func TestReadyError(t *testing.T) {
+	server := newFakeServer(t)
+
+	client := fakeDial(t, server.URL())
+	require.Nil(t, client.Describe())
+
+	// startup aborts without SETUP
+	ready := client.Ready()
+	require.NotNil(t, client.Start())
+
+	<-ready
+	require.ErrorContains(t, client.ReadyErr(), "start from CONN state")
+
+	// stopped before ready
+	client = fakeDial(t, server.URL())
+	require.Nil(t, client.Stop())
+	<-client.Ready()
+	require.ErrorIs(t, client.ReadyErr(), ErrNotReady)
+}
+
+func TestSetupData(t *testing.T) {
+	server := newFakeServer(t)
+	server.SDP = `v=0
+o=- 0 0 IN IP4 0.0.0.0
+s=-
+t=0 0
+m=video 0 RTP/AVP 96
+a=rtpmap:96 H264/90000
+a=control:trackID=0
+m=application 0 RTP/AVP 107
+a=rtpmap:107 vnd.vendor.telemetry/90000
+a=control:trackID=1
+`
+
+	client := fakeDial(t, server.URL())
+	require.Nil(t, client.Describe())
+	require.Len(t, client.DataMedias(), 1)
+
+	var payloads [][]byte
+	client.Listen(func(msg any) {
+		if data, ok := msg.(*Data); ok {
+			require.Equal(t, core.KindData, data.Media.Kind)
+			payloads = append(payloads, data.Payload)
+		}
+	})
+
+	require.Nil(t, client.SetupData())
+	require.Len(t, client.Receivers, 1)
+
+	receiver := client.Receivers[0]
+	for _, packet := range []*rtp.Packet{
+		{Header: rtp.Header{SequenceNumber: 1, Timestamp: 1}, Payload: []byte{1, 2}},
+		{Header: rtp.Header{SequenceNumber: 1, Timestamp: 1}, Payload: []byte{1, 2}}, // duplicate
+		{Header: rtp.Header{SequenceNumber: 2, Timestamp: 1, Marker: true}, Payload: []byte{3}},
+		{Header: rtp.Header{SequenceNumber: 3, Timestamp: 2}, Payload: []byte{4}},
+		{Header: rtp.Header{SequenceNumber: 5, Timestamp: 2, Marker: true}, Payload: []byte{6}}, // lost 4
+		{Header: rtp.Header{SequenceNumber: 6, Timestamp: 3}, Payload: []byte{7}},
+		{Header: rtp.Header{SequenceNumber: 7, Timestamp: 4}, Payload: []byte{8}}, // without marker
+		{Header: rtp.Header{SequenceNumber: 8, Timestamp: 4, Marker: true}, Payload: []byte{9}},
+	} {
+		receiver.WriteRTP(packet)
+	}
+
+	require.Equal(t, [][]byte{{1, 2, 3}, {7}, {8, 9}}, payloads)
+}
+
+func TestDataConsumer(t *testing.T) {
+	media := &core.Media{
+		Kind: core.KindData, Direction: core.DirectionRecvonly, ID: "trackID=1",
+		Codecs: []*core.Codec{{Name: "VND.VENDOR.TELEMETRY", ClockRate: 90000, PayloadType: 107}},
+	}
+	receiver := core.NewReceiver(media, media.Codecs[0])
+
+	cons := NewDataConsumer()
+	consMedia := cons.GetMedias()[0]
+	_, consCodec := media.MatchMedia(consMedia)
+	require.NotNil(t, consCodec)
+	require.Nil(t, cons.AddTrack(consMedia, consCodec, receiver))
+
+	receiver.WriteRTP(&rtp.Packet{Header: rtp.Header{SequenceNumber: 1, Timestamp: 1}, Payload: []byte{1, 2}})
+	receiver.WriteRTP(&rtp.Packet{Header: rtp.Header{SequenceNumber: 2, Timestamp: 1, Marker: true}, Payload: []byte{3}})
+
+	pr, pw := io.Pipe()
+	go func() {
+		_, _ = cons.WriteTo(pw)
+	}()
+
+	b, err := bufio.NewReader(pr).ReadBytes('\n')
+	require.Nil(t, err)
+	require.Nil(t, cons.Stop())
+
+	var line DataLine
+	require.Nil(t, json.Unmarshal(b, &line))
+	require.Equal(t, DataLine{Media: "trackID=1", Codec: "VND.VENDOR.TELEMETRY", Timestamp: 1, Payload: []byte{1, 2, 3}}, line)
+}
+
+func TestDialIPVersion(t *testing.T) {
+	server := newFakeServer(t) // listens on IPv4 localhost
+
+	client := NewClient(server.URL())
+	client.IPVersion = "4"
+	require.Nil(t, client.Dial())
+	require.Equal(t, "tcp4", client.network("tcp"))
+	_ = client.Close()
+
+	// preference is used for every Dial, ex. on Reconnect
+	client.IPVersion = "6"
+	require.NotNil(t, client.Dial())
+	require.Equal(t, "udp6", client.network("udp"))
+
+	client.IPVersion = ""
+	require.Equal(t, "udp", client.network("udp"))
+}
+
+func TestDebug(t *testing.T) {
+	server := newFakeServer(t)
+	server.Handle(MethodPlay, func(req *tcp.Request) *fakeResponse {
+		return &fakeResponse{Packets: []*rtp.Packet{
+			{Header: rtp.Header{Version: 2, PayloadType: 96, SequenceNumber: 1, Marker: true}, Payload: []byte{0x65, 0x88, 0x84}},
+		}}
+	})
+
+	client := fakeDial(t, server.URL())
+	require.Nil(t, client.Describe())
+
+	media := client.Medias[0]
+	_, err := client.GetTrack(media, media.Codecs[0])
+	require.Nil(t, err)
+
+	// the first call starts the debug session, times are unknown before it
+	d := client.Debug().(*Debug)
+	require.True(t, d.LastPacket.IsZero())
+
+	go func() {
+		_ = client.Start()
+	}()
+	<-client.Ready()
+
+	d = client.Debug().(*Debug)
+	require.Equal(t, StatePlay.String(), d.State)
+	require.True(t, d.Handling)
+	require.Equal(t, int32(1), d.Workers) // read loop, keepalive is on the timer
+	require.False(t, d.LastRead.IsZero())
+	require.False(t, d.LastPacket.IsZero())
+
+	// stuck connection doesn't block Debug
+	client.stateMu.Lock()
+	d = client.Debug().(*Debug)
+	client.stateMu.Unlock()
+	require.True(t, d.StateLocked)
+	require.Empty(t, d.State)
+
+	require.Nil(t, client.Stop())
+	require.Eventually(t, func() bool {
+		return client.Debug().(*Debug).Workers == 0
+	}, time.Second, time.Millisecond)
+}
+
+func TestPlayScale(t *testing.T) {
+	server := newFakeServer(t)
+	server.Handle(MethodPlay, func(req *tcp.Request) *fakeResponse {
+		// server answers with the lower rate, than requested
+		require.Equal(t, "4", req.Header.Get("Scale"))
+		return &fakeResponse{
+			Header: map[string]string{"Scale": "2"},
+			Packets: []*rtp.Packet{
+				{Header: rtp.Header{Version: 2, PayloadType: 96, SequenceNumber: 1, Timestamp: 1000}, Payload: []byte{0x65, 0x88, 0x84}},
+				{Header: rtp.Header{Version: 2, PayloadType: 96, SequenceNumber: 2, Timestamp: 7000}, Payload: []byte{0x41, 0x9A, 0x00}},
+				{Header: rtp.Header{Version: 2, PayloadType: 96, SequenceNumber: 3, Timestamp: 13000}, Payload: []byte{0x41, 0x9A, 0x00}},
+			},
+		}
+	})
+
+	client := fakeDial(t, server.URL())
+	client.Scale = 4
+	require.Nil(t, client.Describe())
+
+	var playback *Playback
+	client.Listen(func(msg any) {
+		if msg, ok := msg.(*Playback); ok {
+			playback = msg
+		}
+	})
+
+	media := client.Medias[0]
+	receiver, err := client.GetTrack(media, media.Codecs[0])
+	require.Nil(t, err)
+
+	timestamps := make(chan uint32, 3)
+	input := receiver.Input
+	receiver.Input = func(packet *core.Packet) {
+		timestamps <- packet.Timestamp
+		input(packet)
+	}
+
+	go func() {
+		_ = client.Start()
+	}()
+
+	// 2x playback, timestamps for the real time rendering
+	for _, ts := range []uint32{1000, 4000, 7000} {
+		select {
+		case got := <-timestamps:
+			require.Equal(t, ts, got)
+		case <-time.After(time.Second):
+			require.FailNow(t, "packet timeout")
+		}
+	}
+
+	require.Equal(t, &Playback{Scale: 4, Real: 2}, playback)
+	require.Equal(t, 2.0, client.PlayRate())
+	require.Nil(t, client.Stop())
+}
+
+func TestScaleState(t *testing.T) {
+	var s scaleState
+	require.Equal(t, uint32(1000), s.rescale(1000, -2))
+	require.Equal(t, uint32(2000), s.rescale(3000, -2))
+	require.Equal(t, uint32(1500), s.rescale(2000, -2)) // B-frame
+
+	// new rate continues from the current position
+	require.Equal(t, uint32(2000), s.rescale(3000, 4))
+	require.Equal(t, uint32(3000), s.rescale(7000, 4))
+
+	// timestamps overflow
+	s = scaleState{}
+	require.Equal(t, uint32(0xFFFFF000), s.rescale(0xFFFFF000, 2))
+	require.Equal(t, uint32(0xFFFFF800), s.rescale(0x00000000, 2))
+}
+
+func TestPlayNotifyEnd(t *testing.T) {
+	server := newFakeServer(t)
+	server.Handle(MethodPlay, func(req *tcp.Request) *fakeResponse {
+		notify := &tcp.Request{
+			Method: MethodPlayNotify,
+			URL:    req.URL,
+			Proto:  ProtoRTSP,
+			Header: textproto.MIMEHeader{"Cseq": {"1"}, "Notify-Reason": {"end-of-stream"}, "Session": {"1"}},
+		}
+		return &fakeResponse{
+			Packets: []*rtp.Packet{
+				{Header: rtp.Header{Version: 2, PayloadType: 96, SequenceNumber: 1}, Payload: []byte{0x65, 0x88, 0x84}},
+			},
+			Request: notify,
+		}
+	})
+
+	client := fakeDial(t, server.URL())
+	require.Nil(t, client.Describe())
+
+	var ended bool
+	client.Listen(func(msg any) {
+		if msg == EventEnd {
+			ended = true
+		}
+	})
+
+	media := client.Medias[0]
+	_, err := client.GetTrack(media, media.Codecs[0])
+	require.Nil(t, err)
+
+	// clean end of playback, not an error or the media timeout
+	require.ErrorIs(t, client.Start(), core.ErrEndOfStream)
+	require.True(t, ended)
+
+	require.Eventually(t, func() bool { return len(server.Responses()) == 1 }, time.Second, time.Millisecond)
+	require.Equal(t, 200, server.Responses()[0].StatusCode)
+	require.Equal(t, "1", server.Responses()[0].Header.Get("CSeq"))
+}
+
+func TestConnectionClose(t *testing.T) {
+	server := newFakeServer(t)
+	server.Handle(MethodDescribe, func(req *tcp.Request) *fakeResponse {
+		return &fakeResponse{
+			Header: map[string]string{"Content-Type": "application/sdp", "Connection": "close"},
+			Body:   server.SDP,
+		}
+	})
+
+	var closed int
+	client := fakeDial(t, server.URL())
+	client.Listen(func(msg any) {
+		if msg == EventClose {
+			closed++
+		}
+	})
+
+	// control: next request goes to the new connection
+	require.Nil(t, client.Describe())
+	_, err := client.SetupMedia(client.Medias[0])
+	require.Nil(t, err)
+	require.Equal(t, 2, server.Conns())
+	require.Equal(t, 1, closed)
+	require.Equal(t, "1", client.session)
+}
+
+func TestConnectionCloseSession(t *testing.T) {
+	server := newFakeServer(t)
+
+	var plays int
+	server.Handle(MethodPlay, func(req *tcp.Request) *fakeResponse {
+		if plays++; plays == 1 {
+			return &fakeResponse{Header: map[string]string{"Connection": "close"}}
+		}
+		notify := &tcp.Request{
+			Method: MethodPlayNotify,
+			URL:    req.URL,
+			Proto:  ProtoRTSP,
+			Header: textproto.MIMEHeader{"Cseq": {"1"}, "Notify-Reason": {"end-of-stream"}, "Session": {"1"}},
+		}
+		return &fakeResponse{Request: notify}
+	})
+
+	client := fakeDial(t, server.URL())
+	require.Nil(t, client.Describe())
+
+	var events []string
+	client.Listen(func(msg any) {
+		if msg, ok := msg.(string); ok {
+			events = append(events, msg)
+		}
+	})
+
+	media := client.Medias[0]
+	_, err := client.GetTrack(media, media.Codecs[0])
+	require.Nil(t, err)
+
+	// session: clean reconnect instead of the read error, second PLAY ends the playback
+	require.ErrorIs(t, client.Start(), core.ErrEndOfStream)
+	require.Contains(t, events, EventClose)
+	require.Contains(t, events, "RTSP reconnect")
+	require.Equal(t, 2, server.Conns())
+}
+
+func TestDialFingerprint(t *testing.T) {
+	server, cert := newFakeTLSServer(t)
+	rawURL := strings.Replace(server.URL(), "rtsp://", "rtsps://", 1)
+
+	sum := sha256.Sum256(cert.Raw)
+	pin := hex.EncodeToString(sum[:])
+
+	// self-signed certificate of the camera with the pinned fingerprint
+	client := NewClient(rawURL)
+	client.Fingerprint = pin
+	require.Nil(t, client.Dial())
+	require.Nil(t, client.Describe())
+	require.Equal(t, "rtsps", client.URL.Scheme)
+
+	b, err := client.MarshalJSON()
+	require.Nil(t, err)
+	require.Contains(t, string(b), `"tls_fingerprint":"`+tcp.Fingerprint(client.conn)+`"`)
+	require.Nil(t, client.Close())
+
+	client.Fingerprint = strings.Repeat("00", 32)
+	require.ErrorIs(t, client.Dial(), tcp.ErrFingerprint)
+}
+
+func TestStatusActions(t *testing.T) {
+	main := newFakeServer(t)
+	main.Handle(MethodDescribe, func(req *tcp.Request) *fakeResponse {
+		return &fakeResponse{StatusCode: 404}
+	})
+	sub := newFakeServer(t)
+
+	client := fakeDial(t, main.URL())
+	require.NotErrorIs(t, client.Describe(), core.ErrNoReconnect) // no actions, usual retry
+
+	client.StatusActions = ParseStatusActions("404:fatal")
+	require.ErrorIs(t, client.Describe(), core.ErrNoReconnect)
+
+	client.StatusActions = ParseStatusActions("404:retry")
+	require.ErrorIs(t, client.Describe(), core.ErrReconnect)
+
+	// main stream is not available, continue with the substream
+	client.StatusActions = ParseStatusActions("404:substream")
+	client.Fallback = sub.URL()
+	require.Nil(t, client.Describe())
+	require.Len(t, client.Medias, 1)
+	require.Len(t, sub.Requests(MethodDescribe), 1)
+}
+
+func TestWriteDTMF(t *testing.T) {
+	conn1, conn2 := net.Pipe()
+	defer conn2.Close()
+
+	c := &Conn{conn: conn1}
+	require.ErrorIs(t, c.WriteDTMF("1"), ErrNoDTMF)
+
+	// telephone-event with the clock rate of the audio codec
+	pcmu := &core.Codec{Name: core.CodecPCMU, ClockRate: 8000}
+	media := &core.Media{
+		Kind:      core.KindAudio,
+		Direction: core.DirectionSendonly,
+		Codecs: []*core.Codec{
+			pcmu,
+			{Name: core.CodecTelephoneEvent, ClockRate: 48000, PayloadType: 100},
+			{Name: core.CodecTelephoneEvent, ClockRate: 8000, PayloadType: 101},
+		},
+	}
+	c.setupDTMF(media, pcmu, 4)
+	require.NotNil(t, c.dtmf)
+	require.Equal(t, uint8(101), c.dtmf.PayloadType)
+
+	require.NotNil(t, c.WriteDTMF("1")) // not playing
+	c.state = StatePlay
+	c.playOK = true
+	c.Magic = "#" // noncompliant camera
+
+	// audio of the backchannel gets the SSRC and sequence numbers of the session
+	audio := c.packetWriter(pcmu, 4, 0)
+	go audio(&rtp.Packet{Header: rtp.Header{SSRC: 777, SequenceNumber: 5000, Timestamp: 1000}, Payload: make([]byte, 160)})
+
+	b := make([]byte, 4+12+160)
+	_, err := io.ReadFull(conn2, b)
+	require.Nil(t, err)
+	require.Equal(t, []byte{'#', 4, 0, 172}, b[:4])
+
+	var audioPacket rtp.Packet
+	require.Nil(t, audioPacket.Unmarshal(b[4:]))
+	require.NotEqual(t, uint32(777), audioPacket.SSRC)
+
+	go func() {
+		_ = c.WriteDTMF("1")
+	}()
+
+	b = make([]byte, 4+12+4)
+	_, err = io.ReadFull(conn2, b)
+	require.Nil(t, err)
+	require.Equal(t, []byte{'#', 4, 0, 16}, b[:4])
+
+	// events continue the audio stream (RFC 4733 2.1)
+	var packet rtp.Packet
+	require.Nil(t, packet.Unmarshal(b[4:]))
+	require.True(t, packet.Marker)
+	require.Equal(t, uint8(101), packet.PayloadType)
+	require.Equal(t, byte(1), packet.Payload[0])
+	require.Equal(t, audioPacket.SSRC, packet.SSRC)
+	require.Equal(t, audioPacket.SequenceNumber+1, packet.SequenceNumber)
+	require.GreaterOrEqual(t, packet.Timestamp, uint32(1000))
+	require.Less(t, packet.Timestamp, uint32(1000+8000))
+}
+
+func TestContentLength(t *testing.T) {
+	conn1, conn2 := net.Pipe()
+	defer conn2.Close()
+
+	c := &Conn{conn: conn1}
+	r := bufio.NewReader(conn2)
+
+	write := func(method string) string {
+		go func() {
+			_ = c.WriteRequest(&tcp.Request{Method: method, URL: &url.URL{Scheme: "rtsp", Host: "localhost"}})
+		}()
+		req, err := tcp.ReadRequest(r)
+		require.Nil(t, err)
+		return req.Header.Get("Content-Length")
+	}
+
+	// servers that dislike the header on bodyless methods
+	require.Equal(t, "", write(MethodOptions))
+	require.Equal(t, "", write(MethodDescribe))
+	require.Equal(t, "0", write(MethodGetParameter))
+
+	// quirk for strict cameras
+	c.ContentLength = true
+	require.Equal(t, "0", write(MethodOptions))
+	require.Equal(t, "0", write(MethodPlay))
 }
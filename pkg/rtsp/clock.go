@@ -0,0 +1,130 @@
+package rtsp
+
+import (
+	"time"
+
+	"github.com/AlexxIT/go2rtc/pkg/core"
+	"github.com/pion/rtp"
+)
+
+// ClockRate - camera changed the real RTP clock rate of the media (ex. on the mode
+// switch), timestamps are rescaled from the Real rate to the codec clock rate from SDP
+type ClockRate struct {
+	Media *core.Media
+	Codec *core.Codec
+	Old   uint32 // previous real clock rate
+	Real  uint32
+}
+
+// ClockWindow - measure timestamps rate over this time, the change should stay
+// for ClockWindows in a row to be applied
+var ClockWindow = 5 * time.Second
+
+const ClockWindows = 3
+
+// clockRates - known clock rates, measured rate snaps to one of them
+var clockRates = []uint32{8000, 11025, 12000, 16000, 22050, 24000, 32000, 44100, 48000, 90000}
+
+// clockAdapter - runtime detection of the clock rate change for one receiver
+type clockAdapter struct {
+	rate   uint32 // codec clock rate from SDP
+	source uint32 // current real clock rate of the source
+
+	ts0 uint32 // window start
+	t0  time.Time
+
+	candidate uint32
+	windows   int
+
+	// rescale: out = outBase + (ts - inBase) * rate / source
+	inBase, outBase uint32
+}
+
+// check - rescale packet timestamp to the codec clock rate, returns
+// the new real clock rate of the source on the change
+func (a *clockAdapter) check(packet *rtp.Packet, now time.Time) (changed uint32) {
+	ts := packet.Timestamp
+
+	if a.t0.IsZero() {
+		a.ts0, a.t0 = ts, now
+		a.inBase, a.outBase = ts, ts
+	} else if d := now.Sub(a.t0); d >= ClockWindow {
+		measured := uint64(ts-a.ts0) * uint64(time.Second) / uint64(d)
+		a.ts0, a.t0 = ts, now
+
+		// move the base, so the difference never overflows
+		a.outBase, a.inBase = a.scale(ts), ts
+
+		if rate := nearestRate(measured); rate != 0 && !near(measured, uint64(a.source)) {
+			if rate == a.candidate {
+				a.windows++
+			} else {
+				a.candidate, a.windows = rate, 1
+			}
+			if a.windows >= ClockWindows {
+				a.source = rate
+				a.candidate, a.windows = 0, 0
+				changed = rate
+			}
+		} else {
+			a.candidate, a.windows = 0, 0
+		}
+	}
+
+	packet.Timestamp = a.scale(ts)
+	return
+}
+
+func (a *clockAdapter) scale(ts uint32) uint32 {
+	if a.source == a.rate {
+		return a.outBase + (ts - a.inBase)
+	}
+	return a.outBase + uint32(uint64(ts-a.inBase)*uint64(a.rate)/uint64(a.source))
+}
+
+// near - measured rate within 5% of the rate, network jitter and bursts
+func near(measured, rate uint64) bool {
+	return measured*20 >= rate*19 && measured*20 <= rate*21
+}
+
+// nearestRate - closest known rate, zero if measured rate is far from all of them
+func nearestRate(measured uint64) (nearest uint32) {
+	var best uint64
+	for _, rate := range clockRates {
+		if !near(measured, uint64(rate)) {
+			continue
+		}
+		diff := max(measured, uint64(rate)) - min(measured, uint64(rate))
+		if nearest == 0 || diff < best {
+			nearest, best = rate, diff
+		}
+	}
+	return
+}
+
+// clockStates - created before the read loop, because UDP readers work in parallel
+func (c *Conn) clockStates() map[byte]*clockAdapter {
+	if !c.ClockAdapt {
+		return nil
+	}
+	states := map[byte]*clockAdapter{}
+	for _, receiver := range c.Receivers {
+		if rate := receiver.Codec.ClockRate; rate != 0 {
+			states[receiver.ID] = &clockAdapter{rate: rate, source: rate}
+		}
+	}
+	return states
+}
+
+// checkClock - adapt to the real clock rate of the receiver, each change is fired as *ClockRate
+func (c *Conn) checkClock(receiver *core.Receiver, packet *rtp.Packet) {
+	a := c.clocks[receiver.ID]
+	if a == nil {
+		return
+	}
+
+	old := a.source
+	if rate := a.check(packet, time.Now()); rate != 0 {
+		c.Fire(&ClockRate{Media: receiver.Media, Codec: receiver.Codec, Old: old, Real: rate})
+	}
+}
@@ -2,18 +2,26 @@ package rtsp
 
 import (
 	"bufio"
-	"context"
 	"encoding/binary"
+	"errors"
 	"fmt"
 	"io"
+	"math/rand/v2"
 	"net"
 	"net/url"
+	"os"
 	"strconv"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/AlexxIT/go2rtc/pkg/core"
+	"github.com/AlexxIT/go2rtc/pkg/dtmf"
+	"github.com/AlexxIT/go2rtc/pkg/h264"
+	"github.com/AlexxIT/go2rtc/pkg/h265"
 	"github.com/AlexxIT/go2rtc/pkg/tcp"
+	"github.com/pion/rtcp"
 	"github.com/pion/rtp"
 )
 
@@ -23,31 +31,101 @@ type Conn struct {
 
 	// public
 
-	Backchannel bool
-	Media       string
-	OnClose     func() error
-	PacketSize  uint16
-	SessionName string
-	Timeout     int
-	Transport   string // custom transport support, ex. RTSP over WebSocket
+	AVSync         time.Duration // client: correct audio timestamps if A/V drift by RTCP sender reports is bigger, zero means disabled
+	Backchannel    bool
+	ClockAdapt     bool          // client: rescale timestamps if camera changes the real RTP clock rate
+	Coalesce       bool          // server: send H264/H265 as complete access units, parameter sets aggregated with keyframe
+	CommandTimeout time.Duration // client: wait response on DESCRIBE/SETUP/PLAY, default - Timeout var
+	ContentLength  bool          // client: Content-Length: 0 on all requests without body, for strict cameras
+	DedupWindow    uint16        // drop duplicate RTP packets, zero means disabled
+	DrainTimeout   time.Duration // wait for queued backchannel packets before TEARDOWN
+	FastStart      bool          // PLAY video first and SETUP audio later
+	Feedback       string        // client: RTCP feedback to camera - rr, pli, fir, nack, auto (from SDP), default - none
+	Fallback       string        // substream URL for 453 Not Enough Bandwidth
+	Filter         *tcp.Filter   // client: allowed and denied hosts, also for redirects and fallback
+	Fingerprint    string        // client: pinned SHA-256 of the rtsps server certificate instead of the CA check
+	FreezeTime     time.Duration // reconnect if video keyframes don't change this time, zero means disabled
+	IPVersion      string        // client: "4" or "6" to force the address family for TCP and UDP, default - dual stack
+	Lang           string        // client: preferred language for cameras with several audio medias
+	Magic          string        // client: leading byte of interleaved frames for noncompliant cameras, ex. 0x23 or auto, default $
+	MaxSession     time.Duration // refresh session on keyframe after this time, for buggy firmwares
+	Media          string
+	NoDelay        bool // TCP_NODELAY for control connection, on by default for client
+	NoReconnect    bool // return any error from Start, without reconnects
+	OnClose        func() error
+	PacketSize     uint16
+	Pacing         bool                  // send packets paced to real time by RTP timestamps
+	ParamSets      bool                  // server: re-send cached H264/H265 parameter sets before keyframes
+	ParamsInterval time.Duration         // server: minimum time between re-sent parameter sets, zero - each keyframe
+	PayloadMap     map[uint8]uint8       // remap incoming payload types, for cameras with wrong SDP
+	ReadBuffer     int                   // socket receive buffer size, zero means OS default
+	Rebase         bool                  // server: start RTP timestamps of the session near zero
+	RTCPReports    bool                  // client: send receiver reports, for cameras that stop without RTCP
+	RTPMap         map[uint8]*core.Codec // client: codecs for payload types without a=rtpmap in SDP
+	RTX            bool                  // client: NACK lost packets over UDP and restore RTX retransmissions, if camera supports them
+	Scale          float64               // client: trick-play rate for VOD sources with the Scale header on PLAY, ex. 2 or -1
+	SessionName    string
+	SetupTimeout   time.Duration  // server: wait PLAY after SETUP, default - Timeout
+	SSRCRewrite    bool           // client: continue the SSRC, sequence and timestamps of the first source after the SSRC change
+	StatusActions  map[int]string // client: action by the response status code - retry, fatal or substream
+	Supported      []string       // options for Supported header, without unsupported by server
+	Timeout        int
+	Transport      string // custom transport support, ex. RTSP over WebSocket
+	Unknown        string // client: strategy for medias without supported codecs - fail, skip or passthrough
+	WriteBuffer    int    // socket send buffer size, zero means OS default
 
 	URL *url.URL
 
+	Skipped []*SkippedMedia // client: medias from SDP ignored by the Unknown strategy
+
 	// internal
 
+	activity  activity // atomic snapshot for Debug
 	auth      *tcp.Auth
+	channels  map[byte]*core.Media // client: interleaved channels from SETUP responses
+	clocks    map[byte]*clockAdapter
+	closing   bool // client: last response with Connection: close
 	conn      net.Conn
+	dtmf      *dtmf.Writer // client: telephone-event of the backchannel
+	dtmfCh    byte         // client: interleaved channel of the telephone-event
+	fprint    string       // client: SHA-256 of the server certificate for rtsps
+	freeze    freezeDetector
+	handling  chan struct{} // closed when the read loop exits
 	keepalive int
+	learn     bool                // client: learn the interleaved magic byte from the stream
+	losses    map[byte]*lossState // client: packets loss for RTCP feedback
+	magic     byte                // interleaved magic byte, parsed from Magic
+	magicOut  atomic.Uint32       // magic byte for writers, set by the reader
 	mode      core.Mode
+	pending   []*core.Receiver
 	playOK    bool
 	playErr   error
+	playRate  atomic.Uint64 // client: float64 bits of the rate from PLAY response
+	ready     readiness
+	rawSDP    []byte
+	rebase    core.Rebase
+	refresh   atomic.Bool
+	refreshAt time.Time
 	reader    *bufio.Reader
+	recvBuf   recvBuffer
+	rtcpBuf   []byte        // reused memory for incoming RTCP
+	rtcpMap   map[byte]byte // RTCP => RTP channels from SETUP, only if RTCP is not the next channel
+	rtcpStats rtcpStats
+	rtx       map[byte]*rtxState   // client: RTX streams for media channels
+	scales    map[byte]*scaleState // client: trick-play timestamps
 	sequence  int
 	session   string
+	ssrc      uint32              // client: sender SSRC for outgoing RTCP
+	ssrcs     map[byte]*ssrcState // client: continuous stream on the source SSRC change
+	sync      *avSync             // client: A/V drift by RTCP sender reports
 	uri       string
+	waitMu    sync.Mutex
+	waiting   *pendingResponse // client: deferred SETUP or PLAY waits the response from the Handle loop
+	writeMu   sync.Mutex       // CSeq and requests from several goroutines
 
-	state   State
-	stateMu sync.Mutex
+	state    State
+	stateMu  sync.Mutex
+	stopping atomic.Bool // signal for the read loop to exit
 
 	udpConn []*net.UDPConn
 	udpAddr []*net.UDPAddr
@@ -63,8 +141,39 @@ const (
 	MethodPause    = "PAUSE"
 	MethodAnnounce = "ANNOUNCE"
 	MethodRecord   = "RECORD"
+
+	MethodGetParameter = "GET_PARAMETER"
+	MethodSetParameter = "SET_PARAMETER"
+	MethodPlayNotify   = "PLAY_NOTIFY"
+)
+
+// sessionTimeout - server session timeout in seconds from SETUP response,
+// clients send keepalive (OPTIONS or GET_PARAMETER) before it
+const sessionTimeout = 60
+
+const (
+	StatusNotEnoughBandwidth = 453
+	StatusOptionNotSupported = 551
 )
 
+var ErrNotEnoughBandwidth = errors.New("not enough bandwidth")
+
+// ErrTimeout - camera didn't respond on RTSP command in CommandTimeout
+var ErrTimeout = errors.New("rtsp: command timeout")
+
+// ErrChannelCollision - camera answered SETUP with interleaved channel of another media
+var ErrChannelCollision = errors.New("rtsp: interleaved channel collision")
+
+const (
+	EventFallback = "RTSP fallback"
+	EventRefresh  = "RTSP session refresh"
+	EventFreeze   = "RTSP video freeze"
+	EventEnd      = "RTSP end of stream"
+	EventClose    = "RTSP connection close" // camera closes the connection after the response
+)
+
+const requireBackchannel = "www.onvif.org/ver20/backchannel"
+
 type State byte
 
 func (s State) String() string {
@@ -88,6 +197,12 @@ const (
 	StatePlay
 )
 
+// RawSDP - return exact SDP from camera DESCRIBE response (or client ANNOUNCE request),
+// before any fixes. Useful for bug reports and for generating compatible SDP for other protocols.
+func (c *Conn) RawSDP() []byte {
+	return c.rawSDP
+}
+
 func (c *Conn) Handle() (err error) {
 	var timeout time.Duration
 
@@ -101,9 +216,23 @@ func (c *Conn) Handle() (err error) {
 			keepaliveDT = 25 * time.Second
 		}
 
-		ctx, cancel := context.WithCancel(context.Background())
-		go c.handleKeepalive(ctx, keepaliveDT)
-		defer cancel()
+		// timers instead of sleeping goroutines, important for hosts with many cameras
+		keepalive := core.NewWorker(keepaliveDT, func() time.Duration {
+			return c.writeKeepalive(keepaliveDT)
+		})
+		defer keepalive.Stop()
+		c.clocks = c.clockStates() // new session, new timestamps
+		c.rtx = c.rtxStates()
+		c.scales = c.scaleStates()
+		c.ssrcs = c.ssrcStates()
+		c.sync = c.syncState()
+		c.playRate.Store(0)
+		if c.RTCPReports || c.Feedback != "" || c.rtx != nil {
+			c.ssrc = rand.Uint32()
+			c.losses = c.lossStates()
+			reports := core.NewWorker(ReportInterval, c.writeReports)
+			defer reports.Stop()
+		}
 
 		if c.Timeout == 0 {
 			// polling frames from remote RTSP Server (ex Camera)
@@ -127,59 +256,99 @@ func (c *Conn) Handle() (err error) {
 		}
 
 	case core.ModePassiveConsumer:
-		// pushing frames to remote RTSP Client (ex VLC)
-		timeout = time.Second * 60
+		// pushing frames to remote RTSP Client (ex VLC), any client request
+		// (keepalive) resets the deadline, small extra time for the network delay
+		timeout = time.Second * (sessionTimeout + 5)
 
 	default:
 		return fmt.Errorf("wrong RTSP conn mode: %d", c.mode)
 	}
 
 	for i := 0; i < len(c.udpConn); i++ {
-		go c.handleUDPData(byte(i))
+		channel := byte(i)
+		c.worker(func() { c.handleUDPData(channel) })
+	}
+
+	c.stateMu.Lock()
+	if c.state == StateNone {
+		c.stateMu.Unlock()
+		return nil // stopped before the loop start
 	}
+	handling := make(chan struct{})
+	c.handling = handling
+	c.stopping.Store(false)
+	c.stateMu.Unlock()
+
+	defer close(handling)
+
+	c.activity.handling.Store(true)
+	c.activity.workers.Add(1)
+	defer func() {
+		c.activity.workers.Add(-1)
+		c.activity.handling.Store(false)
+	}()
+
+	for !c.stopping.Load() {
+		if c.refresh.Load() {
+			return errRefresh
+		}
 
-	for c.state != StateNone {
 		ts := time.Now()
 
-		_ = c.conn.SetReadDeadline(ts.Add(timeout))
+		// waiting PLAY response, slow camera control shouldn't wait media timeout
+		waitPlay := c.mode == core.ModeActiveProducer && !c.playOK && c.CommandTimeout > 0
+		if waitPlay {
+			_ = c.conn.SetReadDeadline(ts.Add(c.CommandTimeout))
+		} else {
+			_ = c.conn.SetReadDeadline(ts.Add(timeout))
+		}
 
 		if err = c.handleTCPData(); err != nil {
+			if c.stopping.Load() {
+				return nil // read deadline from Stop
+			}
+			if c.refresh.Load() {
+				return errRefresh
+			}
+			if waitPlay && errors.Is(err, os.ErrDeadlineExceeded) {
+				return fmt.Errorf("%s: %w: %w", MethodPlay, ErrTimeout, err)
+			}
 			return
 		}
+
+		c.activity.touch(&c.activity.read)
 	}
 
 	return
 }
 
-func (c *Conn) handleKeepalive(ctx context.Context, d time.Duration) {
-	ticker := time.NewTicker(d)
-	for {
-		select {
-		case <-ticker.C:
-			req := &tcp.Request{Method: MethodOptions, URL: c.URL}
-			if err := c.WriteRequest(req); err != nil {
-				return
-			}
-		case <-ctx.Done():
-			return
-		}
+// writeKeepalive - returns the next run time, zero after the error
+func (c *Conn) writeKeepalive(d time.Duration) time.Duration {
+	req := &tcp.Request{Method: MethodOptions, URL: c.URL}
+	if err := c.WriteRequest(req); err != nil {
+		return 0
 	}
+	return d
 }
 
 func (c *Conn) handleUDPData(channel byte) {
 	// TODO: handle timeouts and drop TCP connection after any error
 	conn := c.udpConn[channel]
 
-	for {
-		// TP-Link Tapo camera has crazy 10000 bytes packet size
-		buf := make([]byte, 10240)
+	// TP-Link Tapo camera has crazy 10000 bytes packet size
+	b := make([]byte, 10240)
+	var recvBuf recvBuffer // one for each UDP reader
 
-		n, _, err := conn.ReadFromUDP(buf)
+	for {
+		n, _, err := conn.ReadFromUDP(b)
 		if err != nil {
 			return
 		}
 
-		if err = c.handleRawPacket(channel, buf[:n]); err != nil {
+		buf := recvBuf.Bytes(n)
+		copy(buf, b)
+
+		if err = c.handlePacket(channel, buf, recvBuf.Packet()); err != nil {
 			return
 		}
 	}
@@ -187,12 +356,17 @@ func (c *Conn) handleUDPData(channel byte) {
 
 func (c *Conn) handleTCPData() error {
 	// we can read:
-	// 1. RTP interleaved: `$` + 1B channel number + 2B size
+	// 1. RTP interleaved: `$` (or Magic) + 1B channel number + 2B size
 	// 2. RTSP response:   RTSP/1.0 200 OK
 	// 3. RTSP request:    OPTIONS ...
 	var buf4 []byte // `$` + 1B channel number + 2B size
 	var err error
 
+	if c.magic == 0 {
+		c.magic, c.learn = parseMagic(c.Magic)
+		c.magicOut.Store(uint32(c.magic))
+	}
+
 	buf4, err = c.reader.Peek(4)
 	if err != nil {
 		return err
@@ -201,7 +375,7 @@ func (c *Conn) handleTCPData() error {
 	var channel byte
 	var size uint16
 
-	if buf4[0] != '$' {
+	if buf4[0] != c.magic {
 		switch string(buf4) {
 		case "RTSP":
 			var res *tcp.Response
@@ -209,6 +383,20 @@ func (c *Conn) handleTCPData() error {
 				return err
 			}
 			c.Fire(res)
+			c.resolvePending(res)
+			if res.StatusCode == StatusNotEnoughBandwidth {
+				return ErrNotEnoughBandwidth
+			}
+			if s := res.Header.Get("RTP-Info"); s != "" {
+				c.applyRTPInfo(parseRTPInfo(s))
+			}
+			if s := res.Header.Get("Scale"); s != "" && c.useScale() {
+				c.applyScale(s)
+			}
+			if isClose(res) && c.mode == core.ModeActiveProducer {
+				// expected read error after the close, reconnect as on the session refresh
+				c.stopSession(EventClose)
+			}
 			// for playing backchannel only after OK response on play
 			c.playOK = true
 			return nil
@@ -219,20 +407,30 @@ func (c *Conn) handleTCPData() error {
 				return err
 			}
 			c.Fire(req)
-			if req.Method == MethodOptions {
+			// answer keepalives from the client (server mode) and from the camera
+			if req.Method == MethodOptions || req.Method == MethodGetParameter || req.Method == MethodPlayNotify {
 				res := &tcp.Response{Request: req}
 				if err = c.WriteResponse(res); err != nil {
 					return err
 				}
 			}
+			// VOD server finished playback (RFC 7826 13.5)
+			if req.Method == MethodPlayNotify && req.Header.Get("Notify-Reason") == "end-of-stream" {
+				c.Fire(EventEnd)
+				return core.ErrEndOfStream
+			}
 			return nil
 
 		default:
+			if c.learnMagic() {
+				return c.handleTCPData()
+			}
+
 			c.Fire("RTSP wrong input")
 
 			for i := 0; ; i++ {
 				// search next start symbol
-				if _, err = c.reader.ReadBytes('$'); err != nil {
+				if _, err = c.reader.ReadBytes(c.magic); err != nil {
 					return err
 				}
 
@@ -245,13 +443,13 @@ func (c *Conn) handleTCPData() error {
 					continue
 				}
 
-				buf4 = make([]byte, 2)
-				if _, err = io.ReadFull(c.reader, buf4); err != nil {
+				if buf4, err = c.reader.Peek(2); err != nil {
 					return err
 				}
 
 				// check if size good for RTP
 				size = binary.BigEndian.Uint16(buf4)
+				_, _ = c.reader.Discard(2)
 				if size <= 1500 {
 					break
 				}
@@ -263,7 +461,7 @@ func (c *Conn) handleTCPData() error {
 			}
 		}
 	} else {
-		// hope that the odd channels are always RTCP
+		// RTCP channel from SETUP response, or the odd channel
 		channel = buf4[1]
 
 		// get data size
@@ -275,50 +473,281 @@ func (c *Conn) handleTCPData() error {
 		}
 	}
 
+	c.Recv += int(size)
+
+	if c.isRTCP(channel) {
+		// RTCP is not passed to consumers, so the memory can be reused
+		if int(size) > cap(c.rtcpBuf) {
+			c.rtcpBuf = make([]byte, size)
+		}
+		buf := c.rtcpBuf[:size]
+		if _, err = io.ReadFull(c.reader, buf); err != nil {
+			return err
+		}
+		return c.handlePacket(channel, buf, nil)
+	}
+
 	// init memory for data
-	buf := make([]byte, size)
+	buf := c.recvBuf.Bytes(int(size))
 	if _, err = io.ReadFull(c.reader, buf); err != nil {
 		return err
 	}
 
-	c.Recv += int(size)
-
-	return c.handleRawPacket(channel, buf)
+	return c.handlePacket(channel, buf, c.recvBuf.Packet())
 }
 
 func (c *Conn) handleRawPacket(channel byte, buf []byte) error {
-	if channel&1 == 0 {
-		packet := &rtp.Packet{}
+	if c.isRTCP(channel) {
+		return c.handlePacket(channel, buf, nil)
+	}
+	return c.handlePacket(channel, buf, &rtp.Packet{})
+}
+
+// handlePacket - parse RTP to the packet or RTCP, packet memory is given by the caller,
+// it's nil for RTCP
+func (c *Conn) handlePacket(channel byte, buf []byte, packet *rtp.Packet) error {
+	c.activity.touch(&c.activity.packet)
+
+	if c.isRTCP(channel) {
+		c.handleRTCP(channel, c.rtpChannel(channel), buf)
+	} else if isRTCPMux(buf) {
+		// some cameras send RTCP on the RTP channel (RFC 5761)
+		c.handleRTCP(channel, channel, buf)
+	} else {
 		if err := packet.Unmarshal(buf); err != nil {
+			if len(buf) > 0 && buf[0]&0x20 != 0 {
+				return nil // drop packet with broken padding, don't break the session
+			}
 			return err
 		}
 
-		for _, receiver := range c.Receivers {
-			if receiver.ID == channel {
-				receiver.WriteRTP(packet)
-				break
+		if packet.Padding {
+			if packet.PaddingSize == 0 {
+				return nil // padding length can't be zero, packet is corrupted
 			}
+			// payload is already without padding, so consumers will not add it again
+			packet.Padding = false
+			packet.PaddingSize = 0
+			packet.Header.PaddingSize = 0
 		}
-	} else {
-		msg := &RTCP{Channel: channel}
 
-		if err := msg.Header.Unmarshal(buf); err != nil {
+		if c.rtx != nil && !c.restoreRTX(channel, packet) {
 			return nil
 		}
 
-		//var err error
-		//msg.Packets, err = rtcp.Unmarshal(buf)
-		//if err != nil {
-		//	return nil
-		//}
+		var target *core.Receiver
 
-		c.Fire(msg)
+		if c.PayloadMap != nil {
+			if pt, ok := c.PayloadMap[packet.PayloadType]; ok {
+				packet.PayloadType = pt
+			}
+
+			// some cameras send several medias with same channel,
+			// so try to find receiver by the fixed payload type first
+			for _, receiver := range c.Receivers {
+				if receiver.ID == channel && receiver.Codec.PayloadType == packet.PayloadType {
+					target = receiver
+					break
+				}
+			}
+		}
+
+		if target == nil {
+			for _, receiver := range c.Receivers {
+				if receiver.ID == channel {
+					target = receiver
+					break
+				}
+			}
+		}
+
+		if target != nil {
+			c.writePacket(target, packet)
+		}
 	}
 
 	return nil
 }
 
+// writePacket - all per-receiver checks of the incoming packet before consumers
+func (c *Conn) writePacket(receiver *core.Receiver, packet *rtp.Packet) {
+	if !c.refreshAt.IsZero() {
+		c.checkRefresh(receiver.Codec, packet)
+	}
+	if c.FreezeTime > 0 && receiver.Codec.IsVideo() {
+		c.checkFreeze(receiver.Codec, packet)
+	}
+	if receiver.Packets == 0 && receiver.StartSeq != nil {
+		c.checkStart(receiver, packet)
+	}
+	if !c.ready.done.Load() {
+		c.checkReady(receiver.Codec, packet)
+	}
+	if c.Feedback != "" || c.rtx != nil {
+		c.checkLoss(receiver, packet)
+	}
+	if c.sync != nil && !c.checkSync(receiver, packet) {
+		return // audio ahead of video
+	}
+	if c.ssrcs != nil {
+		// after checkLoss, because feedback goes to the real source
+		c.checkSSRC(receiver, packet)
+	}
+	if c.ClockAdapt && receiver.Codec.ClockRate != 0 {
+		c.checkClock(receiver, packet)
+	}
+	if c.scales != nil {
+		c.scaleTimestamp(receiver.ID, packet)
+	}
+	receiver.WriteRTP(packet)
+}
+
+// handleRTCP - RTCP from the channel, rtpChannel is the channel of the media
+func (c *Conn) handleRTCP(channel, rtpChannel byte, buf []byte) {
+	msg := &RTCP{Channel: channel}
+
+	if err := msg.Header.Unmarshal(buf); err != nil {
+		return
+	}
+
+	//var err error
+	//msg.Packets, err = rtcp.Unmarshal(buf)
+	//if err != nil {
+	//	return nil
+	//}
+
+	c.rtcpStats.add(rtpChannel)
+
+	c.Fire(msg)
+
+	if msg.Header.Type == rtcp.TypeSenderReport {
+		c.handleClock(rtpChannel, buf[:min(4*(int(msg.Header.Length)+1), len(buf))])
+	}
+
+	if c.sync != nil {
+		c.syncReport(rtpChannel, buf)
+	}
+
+	c.handleAPP(channel, rtpChannel, buf)
+}
+
+// handleClock - wall clock of the media timeline from the sender report, for
+// consumers that need the source time (ex. MP4 recorder)
+func (c *Conn) handleClock(rtpChannel byte, buf []byte) {
+	var sr rtcp.SenderReport
+	if err := sr.Unmarshal(buf); err != nil {
+		return
+	}
+
+	for _, receiver := range c.Receivers {
+		if receiver.ID == rtpChannel {
+			receiver.SetClock(&core.Clock{SSRC: sr.SSRC, RTPTime: sr.RTPTime, Wall: ntpTime(sr.NTPTime)})
+			return
+		}
+	}
+}
+
+// handleAPP - fire APP packets from compound RTCP packet, without parsing
+// of other packets types
+func (c *Conn) handleAPP(channel, rtpChannel byte, buf []byte) {
+	for len(buf) >= 4 {
+		var header rtcp.Header
+		if err := header.Unmarshal(buf); err != nil {
+			return
+		}
+
+		size := 4 * (int(header.Length) + 1)
+		if size > len(buf) {
+			return
+		}
+
+		if header.Type == rtcp.TypeApplicationDefined {
+			msg := &APP{Channel: channel}
+			if err := msg.Unmarshal(buf[:size]); err == nil {
+				// buf memory is reused by the caller
+				msg.Data = append([]byte(nil), msg.Data...)
+
+				for _, receiver := range c.Receivers {
+					if receiver.ID == rtpChannel {
+						msg.Media = receiver.Media
+						break
+					}
+				}
+
+				c.Fire(msg)
+			}
+		}
+
+		buf = buf[size:]
+	}
+}
+
+var errRefresh = errors.New("session refresh")
+
+// checkRefresh - stop session on the keyframe boundary after MaxSession time,
+// so consumers get the last GOP complete
+func (c *Conn) checkRefresh(codec *core.Codec, packet *rtp.Packet) {
+	if time.Now().Before(c.refreshAt) {
+		return
+	}
+
+	if codec.IsVideo() && !isKeyframeStart(codec.Name, packet.Payload) {
+		return
+	}
+	if !codec.IsVideo() && c.hasVideo() {
+		return // waiting keyframe
+	}
+
+	c.stopSession(EventRefresh)
+}
+
+// stopSession - stop Handle with errRefresh, so Start will reconnect
+func (c *Conn) stopSession(event string) {
+	if c.refresh.CompareAndSwap(false, true) {
+		c.Fire(event)
+		// unblock TCP reading for UDP transport
+		_ = c.conn.SetReadDeadline(time.Now())
+	}
+}
+
+// checkStart - detect lost packets between PLAY response and first packet
+func (c *Conn) checkStart(receiver *core.Receiver, packet *rtp.Packet) {
+	if lost := packet.SequenceNumber - *receiver.StartSeq; lost != 0 && lost < 0x8000 {
+		c.Fire(fmt.Sprintf("RTSP lost %d packets at start for %s", lost, receiver.Codec.Name))
+	}
+}
+
+func (c *Conn) hasVideo() bool {
+	for _, receiver := range c.Receivers {
+		if receiver.Codec.IsVideo() {
+			return true
+		}
+	}
+	return false
+}
+
+// isKeyframeStart - check if RTP packet starts H264/H265 keyframe (parameter sets or IDR)
+func isKeyframeStart(codecName string, payload []byte) bool {
+	switch codecName {
+	case core.CodecH264:
+		return h264.IsParamSetRTP(payload) || h264.IsKeyframeRTP(payload)
+	case core.CodecH265:
+		return h265.IsParamSetRTP(payload) || h265.IsKeyframeRTP(payload)
+	}
+	return true // no idea about keyframes for other codecs
+}
+
 func (c *Conn) WriteRequest(req *tcp.Request) error {
+	return c.writeRequest(req, nil)
+}
+
+// writeRequest - requests can be sent from the Handle loop, keepalive and deferred
+// SETUP at the same time, so CSeq and the write are serialized. Response of the
+// request with the wait is passed from the Handle loop.
+func (c *Conn) writeRequest(req *tcp.Request, wait *pendingResponse) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
 	if req.Proto == "" {
 		req.Proto = ProtoRTSP
 	}
@@ -332,8 +761,17 @@ func (c *Conn) WriteRequest(req *tcp.Request) error {
 	// https://github.com/AlexxIT/go2rtc/issues/7
 	req.Header["CSeq"] = []string{strconv.Itoa(c.sequence)}
 
+	if wait != nil {
+		wait.cseq = req.Header["CSeq"][0]
+		c.setPending(wait)
+	}
+
 	c.auth.Write(req)
 
+	if c.Supported != nil {
+		req.Header.Set("Supported", strings.Join(c.Supported, ", "))
+	}
+
 	if c.session != "" {
 		req.Header.Set("Session", c.session)
 	}
@@ -341,6 +779,9 @@ func (c *Conn) WriteRequest(req *tcp.Request) error {
 	if req.Body != nil {
 		val := strconv.Itoa(len(req.Body))
 		req.Header.Set("Content-Length", val)
+	} else if c.ContentLength || bodyMethod(req.Method) {
+		// some cameras wait for the body of parameter requests without the header
+		req.Header.Set("Content-Length", "0")
 	}
 
 	c.Fire(req)
@@ -352,8 +793,22 @@ func (c *Conn) WriteRequest(req *tcp.Request) error {
 	return req.Write(c.conn)
 }
 
+// bodyMethod - requests with the optional body, other methods never have it and
+// some servers reject them with Content-Length
+func bodyMethod(method string) bool {
+	switch method {
+	case MethodGetParameter, MethodSetParameter:
+		return true
+	}
+	return false
+}
+
 func (c *Conn) ReadRequest() (*tcp.Request, error) {
-	if err := c.conn.SetReadDeadline(time.Now().Add(Timeout)); err != nil {
+	return c.readRequest(Timeout)
+}
+
+func (c *Conn) readRequest(timeout time.Duration) (*tcp.Request, error) {
+	if err := c.conn.SetReadDeadline(time.Now().Add(timeout)); err != nil {
 		return nil, err
 	}
 	return tcp.ReadRequest(c.reader)
@@ -381,7 +836,7 @@ func (c *Conn) WriteResponse(res *tcp.Response) error {
 
 	if c.session != "" {
 		if res.Request != nil && res.Request.Method == MethodSetup {
-			res.Header.Set("Session", c.session+";timeout=60")
+			res.Header.Set("Session", c.session+";timeout="+strconv.Itoa(sessionTimeout))
 		} else {
 			res.Header.Set("Session", c.session)
 		}
@@ -402,8 +857,16 @@ func (c *Conn) WriteResponse(res *tcp.Response) error {
 }
 
 func (c *Conn) ReadResponse() (*tcp.Response, error) {
-	if err := c.conn.SetReadDeadline(time.Now().Add(Timeout)); err != nil {
+	timeout := Timeout
+	if c.CommandTimeout > 0 {
+		timeout = c.CommandTimeout
+	}
+	if err := c.conn.SetReadDeadline(time.Now().Add(timeout)); err != nil {
 		return nil, err
 	}
-	return tcp.ReadResponse(c.reader)
+	res, err := tcp.ReadResponse(c.reader)
+	if err != nil && errors.Is(err, os.ErrDeadlineExceeded) {
+		return nil, fmt.Errorf("%w: %w", ErrTimeout, err)
+	}
+	return res, err
 }
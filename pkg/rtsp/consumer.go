@@ -5,6 +5,7 @@ import (
 
 	"github.com/AlexxIT/go2rtc/pkg/aac"
 	"github.com/AlexxIT/go2rtc/pkg/core"
+	"github.com/AlexxIT/go2rtc/pkg/dtmf"
 	"github.com/AlexxIT/go2rtc/pkg/h264"
 	"github.com/AlexxIT/go2rtc/pkg/h265"
 	"github.com/AlexxIT/go2rtc/pkg/mjpeg"
@@ -37,6 +38,8 @@ func (c *Conn) AddTrack(media *core.Media, codec *core.Codec, track *core.Receiv
 
 		c.state = StateSetup
 
+		c.setupDTMF(media, codec, channel)
+
 	case core.ModePassiveConsumer:
 		channel = byte(len(c.Senders)) * 2
 
@@ -54,6 +57,10 @@ func (c *Conn) AddTrack(media *core.Media, codec *core.Codec, track *core.Receiv
 	// important to send original codec for valid IsRTP check
 	sender.Handler = c.packetWriter(track.Codec, channel, codec.PayloadType)
 
+	if c.mode == core.ModePassiveConsumer && c.Rebase {
+		sender.Rebase(&c.rebase)
+	}
+
 	if c.mode == core.ModeActiveProducer && track.Codec.Name == core.CodecPCMA {
 		// Fix Reolink Doorbell https://github.com/AlexxIT/go2rtc/issues/331
 		sender.Handler = pcm.RepackG711(true, sender.Handler)
@@ -84,6 +91,11 @@ func (c *Conn) packetWriter(codec *core.Codec, channel, payloadType uint8) core.
 		buf = make([]byte, startAudioBuf)
 	}
 
+	var session *dtmf.Session
+	if c.dtmf != nil && c.dtmfCh == channel {
+		session = c.dtmf.Session
+	}
+
 	flushBuf := func() {
 		//log.Printf("[rtsp] channel:%2d write_size:%6d buffer_size:%6d", channel, n, len(buf))
 		if err := c.writeInterleavedData(buf[:n]); err != nil {
@@ -110,7 +122,13 @@ func (c *Conn) packetWriter(codec *core.Codec, channel, payloadType uint8) core.
 		}
 
 		if !video {
-			packet.Marker = true // better to have marker on all audio packets
+			// better to have marker on all audio packets, the source packet
+			// is shared with other consumers, so change only the clone
+			clone.Marker = true
+		}
+
+		if session != nil {
+			session.Audio(&clone)
 		}
 
 		size := rtpHdr + len(packet.Payload)
@@ -127,7 +145,7 @@ func (c *Conn) packetWriter(codec *core.Codec, channel, payloadType uint8) core.
 
 		chunk := buf[n:]
 		_ = chunk[4] // bounds
-		chunk[0] = '$'
+		chunk[0] = c.writeMagic()
 		chunk[1] = channel
 		chunk[2] = byte(size >> 8)
 		chunk[3] = byte(size)
@@ -138,7 +156,7 @@ func (c *Conn) packetWriter(codec *core.Codec, channel, payloadType uint8) core.
 
 		n += 4 + size
 
-		if !packet.Marker || !c.playOK {
+		if !clone.Marker || !c.playOK {
 			// collect continious video packets to buffer
 			// or wait OK for PLAY command for backchannel
 			//log.Printf("[rtsp] collecting buffer ok=%t", c.playOK)
@@ -148,6 +166,10 @@ func (c *Conn) packetWriter(codec *core.Codec, channel, payloadType uint8) core.
 		flushBuf()
 	}
 
+	if c.Pacing && codec.ClockRate != 0 {
+		handlerFunc = pacedWriter(codec.ClockRate, handlerFunc)
+	}
+
 	if !codec.IsRTP() {
 		switch codec.Name {
 		case core.CodecH264:
@@ -161,7 +183,9 @@ func (c *Conn) packetWriter(codec *core.Codec, channel, payloadType uint8) core.
 		}
 	} else if codec.Name == core.CodecPCML {
 		handlerFunc = pcm.LittleToBig(handlerFunc)
-	} else if c.PacketSize != 0 {
+	} else if c.PacketSize != 0 || c.Coalesce {
+		// depay collects all NALs of the access unit (with SPS/PPS for keyframe),
+		// pay sends parameter sets in one aggregation packet before the keyframe
 		switch codec.Name {
 		case core.CodecH264:
 			handlerFunc = h264.RTPPay(c.PacketSize, handlerFunc)
@@ -172,9 +196,43 @@ func (c *Conn) packetWriter(codec *core.Codec, channel, payloadType uint8) core.
 		}
 	}
 
+	if c.ParamSets && codec.IsVideo() {
+		handlerFunc = paramsWriter(codec, c.ParamsInterval, handlerFunc)
+	}
+
 	return handlerFunc
 }
 
+// maxPacingDelay - resync pacing if the packet is too far from the real time
+const maxPacingDelay = time.Second
+
+// pacedWriter - spaces packets according to their RTP timestamps (real time),
+// instead of sending bursts from the source at once. It adds some latency.
+func pacedWriter(clockRate uint32, handler core.HandlerFunc) core.HandlerFunc {
+	var ts0 uint32
+	var time0 time.Time
+
+	return func(packet *rtp.Packet) {
+		now := time.Now()
+
+		if !time0.IsZero() {
+			dt := time.Duration(int32(packet.Timestamp-ts0)) * time.Second / time.Duration(clockRate)
+			if delay := time0.Add(dt).Sub(now); delay > maxPacingDelay || delay < -maxPacingDelay {
+				time0 = time.Time{} // timestamps jump, start again
+			} else if delay > 0 {
+				time.Sleep(delay)
+			}
+		}
+
+		if time0.IsZero() {
+			ts0 = packet.Timestamp
+			time0 = now
+		}
+
+		handler(packet)
+	}
+}
+
 func (c *Conn) writeInterleavedData(data []byte) error {
 	if c.Transport != "udp" {
 		_ = c.conn.SetWriteDeadline(time.Now().Add(Timeout))
@@ -182,7 +240,7 @@ func (c *Conn) writeInterleavedData(data []byte) error {
 		return err
 	}
 
-	for len(data) >= 4 && data[0] == '$' {
+	for len(data) >= 4 && data[0] == c.writeMagic() {
 		channel := data[1]
 		size := uint16(data[2])<<8 | uint16(data[3])
 		rtpData := data[4 : 4+size]
@@ -0,0 +1,172 @@
+package rtsp
+
+import (
+	"encoding/json"
+	"io"
+
+	"github.com/AlexxIT/go2rtc/pkg/core"
+	"github.com/pion/rtp"
+)
+
+// Data - reassembled payload from the application media (ex. vendor telemetry),
+// payloads of RTP packets are joined until the packet with the marker
+type Data struct {
+	Media     *core.Media
+	Timestamp uint32
+	Payload   []byte
+}
+
+// maxDataSize - memory protection for sources without the marker
+const maxDataSize = 1024 * 1024
+
+// DataMedias - application medias from SDP, except MPEG-TS with own demuxer
+func (c *Conn) DataMedias() []*core.Media {
+	var medias []*core.Media
+	for _, media := range c.Medias {
+		if media.Kind != core.KindData || media.Direction != core.DirectionRecvonly || len(media.Codecs) == 0 {
+			continue
+		}
+		if media.Codecs[0].Name == core.CodecMP2T {
+			continue
+		}
+		medias = append(medias, media)
+	}
+	return medias
+}
+
+// SetupData - setup all application medias and fire *Data for them, should be
+// called after Describe. Events are fired from the read loop, so listeners
+// should not block.
+func (c *Conn) SetupData() error {
+	for _, media := range c.DataMedias() {
+		receiver, err := c.GetTrack(media, media.Codecs[0])
+		if err != nil {
+			return err
+		}
+
+		handler := dataReader(func(data *Data) {
+			data.Media = media
+			c.Fire(data)
+		})
+
+		input := receiver.Input
+		receiver.Input = func(packet *core.Packet) {
+			handler(packet)
+			input(packet)
+		}
+	}
+	return nil
+}
+
+// dataReader - join packets of one payload by the marker bit, or by the
+// timestamp change for sources without the marker. Payloads with lost
+// packets are dropped.
+func dataReader(handler func(data *Data)) core.HandlerFunc {
+	var buf []byte
+	var seq uint16
+	var ts uint32
+	var started, broken bool
+
+	return func(packet *rtp.Packet) {
+		// skip duplicate and late packets, receiver dedup works after this handler
+		if started && int16(packet.SequenceNumber-seq) <= 0 {
+			return
+		}
+
+		gap := started && packet.SequenceNumber != seq+1
+
+		if started && packet.Timestamp != ts {
+			if buf != nil && !broken && !gap {
+				handler(&Data{Timestamp: ts, Payload: buf})
+			}
+			buf = nil
+			broken = gap // start of the new payload can be lost
+		} else if gap {
+			buf = nil
+			broken = true
+		}
+
+		started = true
+		seq = packet.SequenceNumber
+		ts = packet.Timestamp
+
+		if broken {
+			broken = !packet.Marker
+			return
+		}
+
+		// packet memory is reused by the reader
+		buf = append(buf, packet.Payload...)
+		if len(buf) > maxDataSize {
+			buf = nil
+			broken = !packet.Marker
+			return
+		}
+
+		if packet.Marker {
+			handler(&Data{Timestamp: ts, Payload: buf})
+			buf = nil
+		}
+	}
+}
+
+// DataConsumer - reassembled payloads of the application medias of the stream,
+// one JSON line for each payload, for integrations with the vendor telemetry
+type DataConsumer struct {
+	core.Connection
+	wr *core.WriteBuffer
+}
+
+// DataLine - JSON line of the DataConsumer, payload in base64
+type DataLine struct {
+	Media     string `json:"media"` // media ID, ex. RTSP control
+	Codec     string `json:"codec"`
+	Timestamp uint32 `json:"timestamp"`
+	Payload   []byte `json:"payload"`
+}
+
+func NewDataConsumer() *DataConsumer {
+	medias := []*core.Media{
+		{
+			Kind:      core.KindData,
+			Direction: core.DirectionSendonly,
+			Codecs:    []*core.Codec{{Name: core.CodecAny}},
+		},
+	}
+	wr := core.NewWriteBuffer(nil)
+	return &DataConsumer{
+		Connection: core.Connection{
+			ID:         core.NewID(),
+			FormatName: "data",
+			Medias:     medias,
+			Transport:  wr,
+		},
+		wr: wr,
+	}
+}
+
+func (c *DataConsumer) AddTrack(media *core.Media, _ *core.Codec, track *core.Receiver) error {
+	line := &DataLine{Codec: track.Codec.Name}
+	if track.Media != nil {
+		line.Media = track.Media.ID
+	}
+
+	sender := core.NewSender(media, track.Codec)
+	sender.Handler = dataReader(func(data *Data) {
+		line.Timestamp = data.Timestamp
+		line.Payload = data.Payload
+		b, _ := json.Marshal(line)
+		// one write for the whole line, so lines of different medias don't mix
+		if n, err := c.wr.Write(append(b, '\n')); err == nil {
+			c.Send += n
+		}
+	})
+
+	sender.HandleRTP(track)
+	c.Senders = append(c.Senders, sender)
+	return nil
+}
+
+func (c *DataConsumer) WriteTo(wr io.Writer) (int64, error) {
+	return c.wr.WriteTo(wr)
+}
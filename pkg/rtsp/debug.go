@@ -0,0 +1,73 @@
+package rtsp
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// Debug - snapshot of the connection internals for deadlock debugging in the field
+type Debug struct {
+	State       string    `json:"state,omitempty"` // empty if stateMu is held by someone
+	StateLocked bool      `json:"state_locked"`
+	Handling    bool      `json:"handling"` // read loop is running
+	Stopping    bool      `json:"stopping"`
+	Workers     int32     `json:"workers"`              // running goroutines of the connection
+	LastRead    time.Time `json:"last_read,omitzero"`   // last data from the RTSP connection
+	LastPacket  time.Time `json:"last_packet,omitzero"` // last RTP/RTCP packet, TCP or UDP
+}
+
+// activity - atomic counters, so Debug never waits for the stuck connection
+type activity struct {
+	handling atomic.Bool
+	workers  atomic.Int32
+	session  atomic.Bool  // somebody called Debug, so the read and packet times are recorded
+	read     atomic.Int64 // unix nano
+	packet   atomic.Int64
+}
+
+// Debug - safe to call at any time, even if the connection is stuck inside the lock.
+// The first call starts the debug session, last read and packet times are known
+// from the next call, so the media path doesn't call time.Now without the session.
+func (c *Conn) Debug() any {
+	c.activity.session.Store(true)
+
+	d := &Debug{
+		Handling:   c.activity.handling.Load(),
+		Stopping:   c.stopping.Load(),
+		Workers:    c.activity.workers.Load(),
+		LastRead:   unixTime(c.activity.read.Load()),
+		LastPacket: unixTime(c.activity.packet.Load()),
+	}
+
+	if c.stateMu.TryLock() {
+		d.State = c.state.String()
+		c.stateMu.Unlock()
+	} else {
+		d.StateLocked = true
+	}
+
+	return d
+}
+
+// worker - run the connection goroutine and count it for Debug
+func (c *Conn) worker(fn func()) {
+	c.activity.workers.Add(1)
+	go func() {
+		defer c.activity.workers.Add(-1)
+		fn()
+	}()
+}
+
+// touch - store the current time only during the debug session
+func (a *activity) touch(ts *atomic.Int64) {
+	if a.session.Load() {
+		ts.Store(time.Now().UnixNano())
+	}
+}
+
+func unixTime(ns int64) time.Time {
+	if ns == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, ns)
+}
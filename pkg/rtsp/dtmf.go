@@ -0,0 +1,66 @@
+package rtsp
+
+import (
+	"errors"
+
+	"github.com/AlexxIT/go2rtc/pkg/core"
+	"github.com/AlexxIT/go2rtc/pkg/dtmf"
+	"github.com/pion/rtp"
+)
+
+var ErrNoDTMF = errors.New("rtsp: backchannel without telephone-event")
+
+// setupDTMF - telephone-event codec from the same backchannel media, with the clock
+// rate of the audio codec (RFC 4733 2.1), if the camera advertised it. Events and
+// audio of the media share the SSRC and sequence numbers of one RTP stream.
+func (c *Conn) setupDTMF(media *core.Media, codec *core.Codec, channel byte) {
+	var event *core.Codec
+	for _, mc := range media.Codecs {
+		if mc.Name != core.CodecTelephoneEvent {
+			continue
+		}
+		if event == nil || mc.ClockRate == codec.ClockRate {
+			event = mc
+		}
+	}
+	if event == nil {
+		return
+	}
+
+	c.dtmf = &dtmf.Writer{
+		PayloadType: event.PayloadType,
+		ClockRate:   event.ClockRate,
+		Write: func(packet *rtp.Packet) error {
+			size := packet.MarshalSize()
+			b := make([]byte, 4+size)
+			b[0] = c.writeMagic()
+			b[1] = channel
+			b[2] = byte(size >> 8)
+			b[3] = byte(size)
+			if _, err := packet.MarshalTo(b[4:]); err != nil {
+				return err
+			}
+			return c.writeInterleavedData(b)
+		},
+		Session: dtmf.NewSession(),
+	}
+	c.dtmfCh = channel
+}
+
+// WriteDTMF - send digits (0-9, *, #, A-D) over the backchannel, blocks until all
+// tones are sent
+func (c *Conn) WriteDTMF(digits string) error {
+	c.stateMu.Lock()
+	w := c.dtmf
+	play := c.state == StatePlay
+	c.stateMu.Unlock()
+
+	if w == nil {
+		return ErrNoDTMF
+	}
+	if !play {
+		return errors.New("rtsp: backchannel is not playing")
+	}
+
+	return w.WriteDigits(digits)
+}
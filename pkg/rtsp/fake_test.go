@@ -0,0 +1,464 @@
+package rtsp
+
+import (
+	"bufio"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"math/big"
+	"net"
+	"net/textproto"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/AlexxIT/go2rtc/pkg/core"
+	"github.com/AlexxIT/go2rtc/pkg/tcp"
+	"github.com/pion/rtp"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeServer - scripted RTSP server for deterministic client tests.
+// Default handlers answer OPTIONS, DESCRIBE (with SDP), SETUP (TCP interleaved),
+// PLAY and TEARDOWN. Any method can be replaced by the custom handler.
+type fakeServer struct {
+	SDP string
+
+	// User and Pass - require Basic auth for all requests
+	User string
+	Pass string
+
+	// Nonce - require Digest auth with this nonce instead of Basic
+	Nonce string
+
+	ln        net.Listener
+	handlers  map[string]fakeHandler
+	requests  []*tcp.Request
+	responses []*tcp.Response // client responses on the server requests
+	conns     int
+	mu        sync.Mutex
+}
+
+type fakeHandler func(req *tcp.Request) *fakeResponse
+
+type fakeResponse struct {
+	StatusCode int // default 200
+	Header     map[string]string
+	Body       string
+
+	Delay time.Duration // delay before the response
+	Close bool          // close connection instead of the response
+
+	Channel byte          // channel for interleaved packets
+	Packets []*rtp.Packet // interleaved packets after the response
+
+	Request *tcp.Request // server request after the packets, ex. PLAY_NOTIFY
+}
+
+const fakeSDP = `v=0
+o=- 0 0 IN IP4 0.0.0.0
+s=-
+t=0 0
+m=video 0 RTP/AVP 96
+a=rtpmap:96 H264/90000
+a=control:trackID=0
+`
+
+func newFakeServer(t testing.TB) *fakeServer {
+	ln, err := net.Listen("tcp", "localhost:0")
+	require.Nil(t, err)
+	return startFakeServer(t, ln)
+}
+
+// newFakeTLSServer - same as newFakeServer for rtsps with the self-signed certificate
+func newFakeTLSServer(t *testing.T) (*fakeServer, *x509.Certificate) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.Nil(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "camera"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.Nil(t, err)
+
+	cert, err := x509.ParseCertificate(der)
+	require.Nil(t, err)
+
+	ln, err := net.Listen("tcp", "localhost:0")
+	require.Nil(t, err)
+
+	config := &tls.Config{Certificates: []tls.Certificate{{Certificate: [][]byte{der}, PrivateKey: key}}}
+	return startFakeServer(t, tls.NewListener(ln, config)), cert
+}
+
+func startFakeServer(t testing.TB, ln net.Listener) *fakeServer {
+	s := &fakeServer{SDP: fakeSDP, ln: ln, handlers: map[string]fakeHandler{}}
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+
+	t.Cleanup(func() {
+		_ = ln.Close()
+	})
+
+	return s
+}
+
+func (s *fakeServer) URL() string {
+	return "rtsp://" + s.ln.Addr().String() + "/stream"
+}
+
+func (s *fakeServer) Handle(method string, handler fakeHandler) {
+	s.mu.Lock()
+	s.handlers[method] = handler
+	s.mu.Unlock()
+}
+
+// Requests - all received requests with the method, empty method for all
+func (s *fakeServer) Requests(method string) (requests []*tcp.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, req := range s.requests {
+		if method == "" || req.Method == method {
+			requests = append(requests, req)
+		}
+	}
+	return
+}
+
+// Responses - client responses on the server requests
+func (s *fakeServer) Responses() []*tcp.Response {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.responses
+}
+
+// Conns - number of accepted connections, ex. for checking reconnects
+func (s *fakeServer) Conns() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.conns
+}
+
+func (s *fakeServer) serve(conn net.Conn) {
+	defer conn.Close()
+
+	s.mu.Lock()
+	s.conns++
+	s.mu.Unlock()
+
+	rd := bufio.NewReader(conn)
+
+	for {
+		b, err := rd.Peek(1)
+		if err != nil {
+			return
+		}
+
+		// skip interleaved packets from the client (ex. RTCP)
+		if b[0] == '$' {
+			header := make([]byte, 4)
+			if _, err = rd.Read(header); err != nil {
+				return
+			}
+			if _, err = rd.Discard(int(header[2])<<8 | int(header[3])); err != nil {
+				return
+			}
+			continue
+		}
+
+		if b, _ = rd.Peek(4); string(b) == "RTSP" {
+			res, err := tcp.ReadResponse(rd)
+			if err != nil {
+				return
+			}
+			s.mu.Lock()
+			s.responses = append(s.responses, res)
+			s.mu.Unlock()
+			continue
+		}
+
+		req, err := tcp.ReadRequest(rd)
+		if err != nil {
+			return
+		}
+
+		s.mu.Lock()
+		s.requests = append(s.requests, req)
+		handler := s.handlers[req.Method]
+		s.mu.Unlock()
+
+		var res *fakeResponse
+		if ok, stale := s.authorized(req); !ok {
+			res = &fakeResponse{
+				StatusCode: 401,
+				Header:     map[string]string{"WWW-Authenticate": s.challenge(stale)},
+			}
+		} else if handler != nil {
+			res = handler(req)
+		} else {
+			res = s.defaultResponse(req)
+		}
+
+		if res.Delay > 0 {
+			time.Sleep(res.Delay)
+		}
+
+		if res.Close {
+			return
+		}
+
+		if err = s.write(conn, req, res); err != nil {
+			return
+		}
+
+		if res.Header["Connection"] == "close" {
+			return
+		}
+	}
+}
+
+func (s *fakeServer) authorized(req *tcp.Request) (ok, stale bool) {
+	if s.User == "" {
+		return true, false
+	}
+
+	header := req.Header.Get("Authorization")
+
+	s.mu.Lock()
+	nonce := s.Nonce
+	s.mu.Unlock()
+
+	if nonce == "" {
+		auth := base64.StdEncoding.EncodeToString([]byte(s.User + ":" + s.Pass))
+		return header == "Basic "+auth, false
+	}
+
+	// valid response for the old nonce means stale nonce
+	reqNonce := tcp.Between(header, `nonce="`, `"`)
+	uri := tcp.Between(header, `uri="`, `"`)
+	h1 := tcp.HexMD5(s.User, "fake", s.Pass)
+	response := tcp.HexMD5(h1, reqNonce, tcp.HexMD5(req.Method, uri))
+	if tcp.Between(header, `response="`, `"`) != response {
+		return false, false
+	}
+	return reqNonce == nonce, reqNonce != nonce
+}
+
+func (s *fakeServer) challenge(stale bool) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.Nonce == "" {
+		return `Basic realm="fake"`
+	}
+	if stale {
+		return `Digest realm="fake", nonce="` + s.Nonce + `", stale=true`
+	}
+	return `Digest realm="fake", nonce="` + s.Nonce + `"`
+}
+
+// SetNonce - change Digest nonce, ex. for checking stale nonce
+func (s *fakeServer) SetNonce(nonce string) {
+	s.mu.Lock()
+	s.Nonce = nonce
+	s.mu.Unlock()
+}
+
+func (s *fakeServer) defaultResponse(req *tcp.Request) *fakeResponse {
+	switch req.Method {
+	case MethodDescribe:
+		return &fakeResponse{
+			Header: map[string]string{"Content-Type": "application/sdp"},
+			Body:   s.SDP,
+		}
+	case MethodSetup:
+		return &fakeResponse{
+			Header: map[string]string{
+				"Transport": "RTP/AVP/TCP;unicast;interleaved=0-1",
+				"Session":   "1",
+			},
+		}
+	}
+	return &fakeResponse{}
+}
+
+func (s *fakeServer) write(conn net.Conn, req *tcp.Request, res *fakeResponse) error {
+	code := res.StatusCode
+	if code == 0 {
+		code = 200
+	}
+
+	header := textproto.MIMEHeader{}
+	header.Set("CSeq", req.Header.Get("CSeq"))
+	for k, v := range res.Header {
+		header.Set(k, v)
+	}
+	if res.Body != "" {
+		header.Set("Content-Length", strconv.Itoa(len(res.Body)))
+	}
+
+	resp := &tcp.Response{
+		Status: strconv.Itoa(code) + " Fake",
+		Proto:  ProtoRTSP,
+		Header: header,
+	}
+	if res.Body != "" {
+		resp.Body = []byte(res.Body)
+	}
+
+	if err := resp.Write(conn); err != nil {
+		return err
+	}
+
+	for _, packet := range res.Packets {
+		b, err := packet.Marshal()
+		if err != nil {
+			return err
+		}
+
+		header := []byte{'$', res.Channel, byte(len(b) >> 8), byte(len(b))}
+		if _, err = conn.Write(append(header, b...)); err != nil {
+			return err
+		}
+	}
+
+	if res.Request != nil {
+		return res.Request.Write(conn)
+	}
+
+	return nil
+}
+
+func fakeDial(t testing.TB, rawURL string) *Conn {
+	client := NewClient(rawURL)
+	client.CommandTimeout = time.Second // other tests change the default Timeout
+	require.Nil(t, client.Dial())
+	t.Cleanup(func() {
+		_ = client.Close()
+	})
+	return client
+}
+
+func TestFakeAuth(t *testing.T) {
+	server := newFakeServer(t)
+	server.User = "admin"
+	server.Pass = "secret"
+
+	client := fakeDial(t, "rtsp://admin:secret@"+server.ln.Addr().String()+"/stream")
+	require.Nil(t, client.Describe())
+	require.Len(t, client.Medias, 1)
+
+	// first request without auth, second with auth
+	require.Len(t, server.Requests(MethodDescribe), 2)
+
+	client = fakeDial(t, "rtsp://admin:wrong@"+server.ln.Addr().String()+"/stream")
+	require.ErrorIs(t, client.Describe(), core.ErrAuth)
+
+	client = fakeDial(t, server.URL())
+	require.ErrorIs(t, client.Describe(), core.ErrAuth)
+}
+
+func TestFakeSetupError(t *testing.T) {
+	server := newFakeServer(t)
+	server.Handle(MethodSetup, func(req *tcp.Request) *fakeResponse {
+		return &fakeResponse{StatusCode: 461} // Unsupported transport
+	})
+
+	client := fakeDial(t, server.URL())
+	require.Nil(t, client.Describe())
+
+	media := client.Medias[0]
+	_, err := client.GetTrack(media, media.Codecs[0])
+	require.NotNil(t, err)
+}
+
+func TestFakeDelay(t *testing.T) {
+	server := newFakeServer(t)
+	server.Handle(MethodDescribe, func(req *tcp.Request) *fakeResponse {
+		return &fakeResponse{Delay: 100 * time.Millisecond, Body: fakeSDP}
+	})
+
+	client := fakeDial(t, server.URL())
+	client.CommandTimeout = 10 * time.Millisecond
+	require.ErrorIs(t, client.Describe(), ErrTimeout)
+}
+
+func TestFakePlay(t *testing.T) {
+	server := newFakeServer(t)
+	server.Handle(MethodPlay, func(req *tcp.Request) *fakeResponse {
+		var packets []*rtp.Packet
+		for i := uint16(0); i < 3; i++ {
+			packets = append(packets, &rtp.Packet{
+				Header:  rtp.Header{Version: 2, PayloadType: 96, SequenceNumber: i, Timestamp: uint32(i) * 3000},
+				Payload: []byte{0x65, 0x88, byte(i)},
+			})
+		}
+		return &fakeResponse{Packets: packets}
+	})
+	server.Handle(MethodTeardown, func(req *tcp.Request) *fakeResponse {
+		return &fakeResponse{Close: true}
+	})
+
+	client := fakeDial(t, server.URL())
+	require.Nil(t, client.Describe())
+
+	media := client.Medias[0]
+	receiver, err := client.GetTrack(media, media.Codecs[0])
+	require.Nil(t, err)
+
+	packets := make(chan *rtp.Packet, 3)
+	sender := core.NewSender(media, receiver.Codec)
+	sender.Handler = func(packet *rtp.Packet) {
+		packets <- packet
+	}
+	sender.HandleRTP(receiver)
+
+	go func() {
+		_ = client.Start()
+	}()
+
+	for i := uint16(0); i < 3; i++ {
+		select {
+		case packet := <-packets:
+			require.Equal(t, i, packet.SequenceNumber)
+		case <-time.After(time.Second):
+			require.FailNow(t, "packet timeout")
+		}
+	}
+
+	require.Equal(t, 1, server.Conns())
+	require.Len(t, server.Requests(MethodPlay), 1)
+}
+
+func TestFakeDisconnect(t *testing.T) {
+	server := newFakeServer(t)
+	server.Handle(MethodPlay, func(req *tcp.Request) *fakeResponse {
+		return &fakeResponse{Close: true} // camera drops connection on PLAY
+	})
+
+	client := fakeDial(t, server.URL())
+	require.Nil(t, client.Describe())
+
+	media := client.Medias[0]
+	_, err := client.GetTrack(media, media.Codecs[0])
+	require.Nil(t, err)
+
+	require.NotNil(t, client.Start())
+}
@@ -0,0 +1,66 @@
+package rtsp
+
+import (
+	"hash/crc32"
+	"sync"
+	"time"
+
+	"github.com/AlexxIT/go2rtc/pkg/core"
+	"github.com/AlexxIT/go2rtc/pkg/h264"
+	"github.com/AlexxIT/go2rtc/pkg/h265"
+	"github.com/pion/rtp"
+)
+
+// freezeDetector - some cameras with stuck encoder continue to send packets,
+// but with the same picture. Keyframes of a live picture always differ
+// (at least by sensor noise), so compare checksums of the keyframes start.
+type freezeDetector struct {
+	hash  uint32
+	since time.Time
+	mu    sync.Mutex // UDP transport reads channels in parallel
+}
+
+// check - return true if keyframe content didn't change for timeout
+func (f *freezeDetector) check(payload []byte, now time.Time, timeout time.Duration) bool {
+	hash := crc32.ChecksumIEEE(payload)
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if hash != f.hash || f.since.IsZero() {
+		f.hash = hash
+		f.since = now
+		return false
+	}
+
+	return now.Sub(f.since) >= timeout
+}
+
+func (f *freezeDetector) reset() {
+	f.mu.Lock()
+	f.hash = 0
+	f.since = time.Time{}
+	f.mu.Unlock()
+}
+
+func (c *Conn) checkFreeze(codec *core.Codec, packet *rtp.Packet) {
+	if !isIFrameStart(codec.Name, packet.Payload) {
+		return
+	}
+
+	if c.freeze.check(packet.Payload, time.Now(), c.FreezeTime) {
+		c.stopSession(EventFreeze)
+	}
+}
+
+// isIFrameStart - check if RTP packet starts H264/H265 IDR slice, without
+// parameter sets, because they are the same for all keyframes
+func isIFrameStart(codecName string, payload []byte) bool {
+	switch codecName {
+	case core.CodecH264:
+		return h264.IsKeyframeRTP(payload)
+	case core.CodecH265:
+		return h265.IsKeyframeRTP(payload)
+	}
+	return false
+}
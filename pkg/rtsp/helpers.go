@@ -2,6 +2,7 @@ package rtsp
 
 import (
 	"bytes"
+	"errors"
 	"io"
 	"net/url"
 	"regexp"
@@ -9,6 +10,8 @@ import (
 	"strings"
 
 	"github.com/AlexxIT/go2rtc/pkg/core"
+	"github.com/AlexxIT/go2rtc/pkg/h264"
+	"github.com/AlexxIT/go2rtc/pkg/h265"
 	"github.com/pion/rtcp"
 	"github.com/pion/sdp/v3"
 )
@@ -19,12 +22,74 @@ type RTCP struct {
 	Packets []rtcp.Packet
 }
 
+// APP - RTCP application-defined packet with vendor data (ex. motion flags),
+// Media is nil if RTCP channel doesn't belong to any receiver
+type APP struct {
+	Channel byte
+	Media   *core.Media
+	rtcp.ApplicationDefined
+}
+
 const sdpHeader = `v=0
 o=- 0 0 IN IP4 0.0.0.0
 s=-
 t=0 0`
 
 func UnmarshalSDP(rawSDP []byte) ([]*core.Media, error) {
+	sd, err := unmarshalSDP(rawSDP)
+	if err != nil {
+		return nil, err
+	}
+
+	// fix buggy camera https://github.com/AlexxIT/go2rtc/issues/771
+	forceDirection := sd.Origin.Username == "CV-RTSPHandler"
+
+	var medias []*core.Media
+
+	for _, md := range sd.MediaDescriptions {
+		media := core.UnmarshalMedia(md)
+
+		// Check buggy SDP with fmtp for H264 on another track
+		// https://github.com/AlexxIT/WebRTC/issues/419
+		for _, codec := range media.Codecs {
+			switch codec.Name {
+			case core.CodecH264:
+				if codec.FmtpLine == "" {
+					codec.FmtpLine = findFmtpLine(codec.PayloadType, sd.MediaDescriptions)
+				}
+			case core.CodecH265:
+				if codec.FmtpLine != "" {
+					// all three parameters are needed for a valid fmtp line
+					// https://github.com/AlexxIT/go2rtc/pull/1588
+					if !strings.Contains(codec.FmtpLine, "sprop-vps=") ||
+						!strings.Contains(codec.FmtpLine, "sprop-sps=") ||
+						!strings.Contains(codec.FmtpLine, "sprop-pps=") {
+						codec.FmtpLine = ""
+					}
+				}
+			case core.CodecOpus:
+				// fix OPUS for some cameras https://datatracker.ietf.org/doc/html/rfc7587
+				codec.ClockRate = 48000
+				codec.Channels = 2
+			}
+		}
+
+		if media.Direction == "" || forceDirection {
+			media.Direction = core.DirectionRecvonly
+		}
+
+		if media.Kind == core.KindVideo {
+			inferFrameInfo(media)
+		}
+
+		medias = append(medias, media)
+	}
+
+	return medias, nil
+}
+
+// unmarshalSDP - parse SDP with fixes for buggy cameras
+func unmarshalSDP(rawSDP []byte) (*sdp.SessionDescription, error) {
 	sd := &sdp.SessionDescription{}
 	if err := sd.Unmarshal(rawSDP); err != nil {
 		// fix multiple `s=` https://github.com/AlexxIT/WebRTC/issues/417
@@ -59,47 +124,178 @@ func UnmarshalSDP(rawSDP []byte) ([]*core.Media, error) {
 		}
 	}
 
-	// fix buggy camera https://github.com/AlexxIT/go2rtc/issues/771
-	forceDirection := sd.Origin.Username == "CV-RTSPHandler"
+	return sd, nil
+}
 
-	var medias []*core.Media
+// inferFrameInfo - video size and frame rate from SPS in fmtp, if SDP doesn't declare them
+func inferFrameInfo(media *core.Media) {
+	if media.Video != nil && media.Video.Width > 0 && media.Video.FrameRate > 0 {
+		return
+	}
 
-	for _, md := range sd.MediaDescriptions {
-		media := core.UnmarshalMedia(md)
+	for _, codec := range media.Codecs {
+		switch codec.Name {
+		case core.CodecH264:
+			sps, _ := h264.GetParameterSet(codec.FmtpLine)
+			if len(sps) == 0 {
+				continue
+			}
+			if s := h264.DecodeSPS(sps); s != nil {
+				media.SetVideoInfo(int(s.Width()), int(s.Height()), s.FrameRate())
+				return
+			}
+		case core.CodecH265:
+			_, sps, _ := h265.GetParameterSet(codec.FmtpLine)
+			if len(sps) < 3 {
+				continue
+			}
+			if s := h265.DecodeSPS(sps); s != nil {
+				media.SetVideoInfo(int(s.Width()), int(s.Height()), s.FrameRate())
+				return
+			}
+		}
+	}
+}
 
-		// Check buggy SDP with fmtp for H264 on another track
-		// https://github.com/AlexxIT/WebRTC/issues/419
+// applyRTPMap - set codec for payload types without a=rtpmap in SDP,
+// values from a=rtpmap always have priority over the override
+func applyRTPMap(medias []*core.Media, rawSDP []byte, rtpmap map[uint8]*core.Codec) {
+	sd, err := unmarshalSDP(rawSDP)
+	if err != nil || len(sd.MediaDescriptions) != len(medias) {
+		return
+	}
+
+	for i, media := range medias {
 		for _, codec := range media.Codecs {
-			switch codec.Name {
-			case core.CodecH264:
-				if codec.FmtpLine == "" {
-					codec.FmtpLine = findFmtpLine(codec.PayloadType, sd.MediaDescriptions)
-				}
-			case core.CodecH265:
-				if codec.FmtpLine != "" {
-					// all three parameters are needed for a valid fmtp line
-					// https://github.com/AlexxIT/go2rtc/pull/1588
-					if !strings.Contains(codec.FmtpLine, "sprop-vps=") ||
-						!strings.Contains(codec.FmtpLine, "sprop-sps=") ||
-						!strings.Contains(codec.FmtpLine, "sprop-pps=") {
-						codec.FmtpLine = ""
-					}
-				}
-			case core.CodecOpus:
-				// fix OPUS for some cameras https://datatracker.ietf.org/doc/html/rfc7587
-				codec.ClockRate = 48000
-				codec.Channels = 2
+			override, ok := rtpmap[codec.PayloadType]
+			if !ok || hasRTPMap(sd.MediaDescriptions[i], codec.PayloadType) {
+				continue
 			}
+			codec.Name = override.Name
+			codec.ClockRate = override.ClockRate
+			codec.Channels = override.Channels
 		}
+	}
+}
 
-		if media.Direction == "" || forceDirection {
-			media.Direction = core.DirectionRecvonly
+// Strategies for SDP medias without supported codecs
+const (
+	UnknownFail        = "fail"        // error on any unsupported media
+	UnknownSkip        = "skip"        // setup only supported medias, error if nothing left
+	UnknownPassthrough = "passthrough" // keep all medias, RTP is forwarded as is (default)
+)
+
+// SkippedMedia - media from SDP that was ignored and the reason
+type SkippedMedia struct {
+	Media  string `json:"media"`
+	Reason string `json:"reason"`
+}
+
+// filterUnknown - apply the strategy for medias without supported codecs
+func filterUnknown(medias []*core.Media, strategy string) ([]*core.Media, []*SkippedMedia, error) {
+	var supported []*core.Media
+	var skipped []*SkippedMedia
+
+	for _, media := range medias {
+		if hasKnownCodec(media) {
+			supported = append(supported, media)
+			continue
+		}
+		reason := "unsupported codecs"
+		if len(media.Codecs) == 0 {
+			reason = "no codecs"
 		}
+		skipped = append(skipped, &SkippedMedia{Media: media.String(), Reason: reason})
+	}
 
-		medias = append(medias, media)
+	switch strategy {
+	case UnknownFail:
+		if skipped != nil {
+			return nil, skipped, errors.New("rtsp: unsupported media: " + skipped[0].Media)
+		}
+	case UnknownSkip:
+		if supported == nil && skipped != nil {
+			return nil, skipped, errors.New("rtsp: no playable medias")
+		}
+		return supported, skipped, nil
 	}
 
-	return medias, nil
+	return medias, nil, nil
+}
+
+func hasKnownCodec(media *core.Media) bool {
+	for _, codec := range media.Codecs {
+		if isKnownCodec(codec.Name) {
+			return true
+		}
+	}
+	return false
+}
+
+// isKnownCodec - go2rtc can depacketize or restream RTP of this codec
+func isKnownCodec(name string) bool {
+	switch name {
+	case core.CodecH264, core.CodecH265, core.CodecVP8, core.CodecVP9, core.CodecAV1,
+		core.CodecJPEG, core.CodecRAW, core.CodecMP2T,
+		core.CodecPCMU, core.CodecPCMA, core.CodecAAC, core.CodecOpus, core.CodecG722,
+		core.CodecMP3, core.CodecPCM, core.CodecPCML, core.CodecELD, core.CodecFLAC:
+		return true
+	}
+	return false
+}
+
+// selectLang - leave only one of the incoming audio medias with a=lang, matched
+// by the preferred language (ex. `en` for `en-US`), or the first one
+func selectLang(medias []*core.Media, lang string) []*core.Media {
+	var selected *core.Media
+	for _, media := range medias {
+		if !isLangAudio(media) {
+			continue
+		}
+		if matchLang(media.Lang, lang) {
+			selected = media
+			break
+		}
+		if selected == nil {
+			selected = media // fallback
+		}
+	}
+
+	if selected == nil {
+		return medias
+	}
+
+	filtered := make([]*core.Media, 0, len(medias))
+	for _, media := range medias {
+		if media == selected || !isLangAudio(media) {
+			filtered = append(filtered, media)
+		}
+	}
+	return filtered
+}
+
+func isLangAudio(media *core.Media) bool {
+	return media.Kind == core.KindAudio && media.Direction == core.DirectionRecvonly && media.Lang != ""
+}
+
+func matchLang(mediaLang, lang string) bool {
+	if strings.EqualFold(mediaLang, lang) {
+		return true
+	}
+	prefix, _, _ := strings.Cut(mediaLang, "-")
+	return strings.EqualFold(prefix, lang)
+}
+
+// hasRTPMap - media has own a=rtpmap for the payload type, same payload type
+// can be used by other medias with another codec
+func hasRTPMap(md *sdp.MediaDescription, payloadType uint8) bool {
+	prefix := strconv.Itoa(int(payloadType)) + " "
+	for _, attr := range md.Attributes {
+		if attr.Key == "rtpmap" && strings.HasPrefix(attr.Value, prefix) {
+			return true
+		}
+	}
+	return false
 }
 
 func findFmtpLine(payloadType uint8, descriptions []*sdp.MediaDescription) string {
@@ -113,6 +309,74 @@ func findFmtpLine(payloadType uint8, descriptions []*sdp.MediaDescription) strin
 	return ""
 }
 
+// resolveControl - build URL from base URL and `a=control` value:
+// 1. Empty or `*` - same as base URL
+// 2. Absolute URL - as is
+// 3. Relative URL - appended to base URL path or query (this is how most cameras work)
+func resolveControl(base *url.URL, control string) (*url.URL, error) {
+	if control == "" || control == "*" {
+		return base, nil
+	}
+
+	if strings.Contains(control, "://") {
+		return urlParse(control)
+	}
+
+	rawURL := base.String()
+	// prefix check for https://github.com/AlexxIT/go2rtc/issues/1236
+	if !strings.HasSuffix(rawURL, "/") && !strings.HasPrefix(control, "/") {
+		rawURL += "/"
+	} else if strings.HasSuffix(rawURL, "/") && strings.HasPrefix(control, "/") {
+		control = control[1:]
+	}
+
+	return urlParse(rawURL + control)
+}
+
+// sessionControl - return session level `a=control` value from SDP
+func sessionControl(rawSDP []byte) string {
+	for _, line := range strings.Split(string(rawSDP), "\n") {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, "m=") {
+			break
+		}
+		if strings.HasPrefix(line, "a=control:") {
+			return line[10:]
+		}
+	}
+	return ""
+}
+
+type rtpInfo struct {
+	URL string
+	Seq *uint16
+}
+
+// parseRTPInfo - parse PLAY response header with one entry for each media, ex:
+// RTP-Info: url=rtsp://192.168.1.123/trackID=1;seq=12345;rtptime=123456789,url=trackID=2;seq=1234
+// rtptime is skipped, because muxers build the timeline from the packets
+func parseRTPInfo(s string) (infos []rtpInfo) {
+	for _, entry := range strings.Split(s, ",") {
+		var info rtpInfo
+		for _, param := range strings.Split(entry, ";") {
+			key, value, _ := strings.Cut(strings.TrimSpace(param), "=")
+			switch key {
+			case "url":
+				info.URL = value
+			case "seq":
+				if i, err := strconv.ParseUint(value, 10, 16); err == nil {
+					seq := uint16(i)
+					info.Seq = &seq
+				}
+			}
+		}
+		if info.URL != "" {
+			infos = append(infos, info)
+		}
+	}
+	return
+}
+
 // urlParse fix bugs:
 // 1. Content-Base: rtsp://::ffff:192.168.1.123/onvif/profile.1/
 // 2. Content-Base: rtsp://rtsp://turret2-cam.lan:554/stream1/
@@ -0,0 +1,73 @@
+package rtsp
+
+import (
+	"encoding/binary"
+	"strconv"
+)
+
+// Magic - camera frames interleaved data with the non-standard leading byte,
+// learned with the Magic "auto" option
+type Magic struct {
+	Byte byte
+}
+
+// standardMagic - leading byte of interleaved frames (RFC 2326 10.12)
+const standardMagic = '$'
+
+// parseMagic - compatibility shim for noncompliant cameras: "0x23", "35", "#" or "auto",
+// anything else is the standard byte
+func parseMagic(s string) (magic byte, learn bool) {
+	switch {
+	case s == "auto":
+		return standardMagic, true
+	case len(s) == 1 && (s[0] < '0' || s[0] > '9'):
+		return s[0], false
+	}
+	if i, err := strconv.ParseUint(s, 0, 8); err == nil {
+		return byte(i), false
+	}
+	return standardMagic, false
+}
+
+// learnMagic - accept the other leading byte once, if the rest of the header looks
+// like the interleaved frame: known channel, RTP size, RTP/RTCP version 2
+func (c *Conn) learnMagic() bool {
+	if !c.learn || c.magic != standardMagic {
+		return false
+	}
+
+	b, err := c.reader.Peek(5)
+	if err != nil || b[4]>>6 != 2 {
+		return false
+	}
+
+	if size := binary.BigEndian.Uint16(b[2:]); size == 0 || size > 1500 || !c.knownChannel(b[1]) {
+		return false
+	}
+
+	c.magic = b[0]
+	c.magicOut.Store(uint32(b[0]))
+	c.Fire(&Magic{Byte: b[0]})
+	return true
+}
+
+// writeMagic - leading byte of outgoing interleaved frames, the same as the camera uses
+func (c *Conn) writeMagic() byte {
+	if b := byte(c.magicOut.Load()); b != 0 {
+		return b
+	}
+	b, _ := parseMagic(c.Magic)
+	return b
+}
+
+func (c *Conn) knownChannel(channel byte) bool {
+	if _, ok := c.rtcpMap[channel]; ok {
+		return true
+	}
+	for _, receiver := range c.Receivers {
+		if receiver.ID == channel || receiver.ID == channel-1 {
+			return true
+		}
+	}
+	return false
+}
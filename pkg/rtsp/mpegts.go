@@ -0,0 +1,73 @@
+package rtsp
+
+import (
+	"io"
+
+	"github.com/AlexxIT/go2rtc/pkg/core"
+	"github.com/AlexxIT/go2rtc/pkg/mpegts"
+	"github.com/pion/rtp"
+)
+
+// MPEGTSMedia - return media with MPEG-TS over RTP (payload 33), used by some
+// broadcast encoders and drones with KLV metadata
+func (c *Conn) MPEGTSMedia() (*core.Media, *core.Codec) {
+	for _, media := range c.Medias {
+		for _, codec := range media.Codecs {
+			if codec.Name == core.CodecMP2T {
+				return media, codec
+			}
+		}
+	}
+	return nil, nil
+}
+
+// OpenMPEGTS - PLAY MPEG-TS over RTP and demux it to elementary streams
+func OpenMPEGTS(conn *Conn) (*mpegts.Producer, error) {
+	media, codec := conn.MPEGTSMedia()
+
+	receiver, err := conn.GetTrack(media, codec)
+	if err != nil {
+		return nil, err
+	}
+
+	rd, wr := io.Pipe()
+
+	sender := core.NewSender(media, codec)
+	sender.Handler = func(packet *rtp.Packet) {
+		// RTP payload has multiple 188 bytes TS packets
+		_, _ = wr.Write(packet.Payload)
+	}
+	sender.HandleRTP(receiver)
+
+	go func() {
+		err := conn.Start()
+		sender.Close()
+		_ = wr.CloseWithError(err)
+	}()
+
+	prod, err := mpegts.Open(&tsReader{PipeReader: rd, conn: conn})
+	if err != nil {
+		_ = conn.Stop()
+		return nil, err
+	}
+
+	prod.FormatName = "rtsp/mpegts"
+	prod.Protocol = conn.Protocol
+	prod.RemoteAddr = conn.RemoteAddr
+	prod.Source = conn.Source
+	prod.URL = conn.Connection.URL
+	prod.SDP = conn.SDP
+	prod.UserAgent = conn.UserAgent
+
+	return prod, nil
+}
+
+type tsReader struct {
+	*io.PipeReader
+	conn *Conn
+}
+
+func (r *tsReader) Close() error {
+	_ = r.PipeReader.Close()
+	return r.conn.Stop()
+}
@@ -0,0 +1,250 @@
+package rtsp
+
+import (
+	"encoding/binary"
+	"slices"
+	"time"
+
+	"github.com/AlexxIT/go2rtc/pkg/core"
+	"github.com/AlexxIT/go2rtc/pkg/h264"
+	"github.com/AlexxIT/go2rtc/pkg/h265"
+	"github.com/pion/rtp"
+)
+
+// paramCache - latest VPS/SPS/PPS of the video track, from the fmtp line
+// and from the stream, for cameras that send them only at the start
+type paramCache struct {
+	name string
+	sets [3][]byte // VPS (only H265), SPS, PPS
+}
+
+func newParamCache(codec *core.Codec) *paramCache {
+	p := &paramCache{name: codec.Name}
+
+	switch codec.Name {
+	case core.CodecH264:
+		p.sets[1], p.sets[2] = h264.GetParameterSet(codec.FmtpLine)
+	case core.CodecH265:
+		p.sets[0], p.sets[1], p.sets[2] = h265.GetParameterSet(codec.FmtpLine)
+	default:
+		return nil
+	}
+
+	return p
+}
+
+// index - position of the parameter set in the cache, -1 for any other NAL unit
+func (p *paramCache) index(nalu []byte) int {
+	if len(nalu) < 2 {
+		return -1
+	}
+
+	if p.name == core.CodecH264 {
+		switch nalu[0] & 0x1F {
+		case h264.NALUTypeSPS:
+			return 1
+		case h264.NALUTypePPS:
+			return 2
+		}
+	} else {
+		switch (nalu[0] >> 1) & 0x3F {
+		case h265.NALUTypeVPS:
+			return 0
+		case h265.NALUTypeSPS:
+			return 1
+		case h265.NALUTypePPS:
+			return 2
+		}
+	}
+
+	return -1
+}
+
+func (p *paramCache) isIFrame(nalu []byte) bool {
+	if len(nalu) < 2 {
+		return false
+	}
+	if p.name == core.CodecH264 {
+		return nalu[0]&0x1F == h264.NALUTypeIFrame
+	}
+	return isIFrame265((nalu[0] >> 1) & 0x3F)
+}
+
+func isIFrame265(nalType byte) bool {
+	switch nalType {
+	case h265.NALUTypeIFrame, h265.NALUTypeIFrame2, h265.NALUTypeIFrame3:
+		return true
+	}
+	return false
+}
+
+// update - remember the parameter set, the source buffer can be reused
+func (p *paramCache) update(nalu []byte) bool {
+	i := p.index(nalu)
+	if i < 0 {
+		return false
+	}
+	p.sets[i] = slices.Clone(nalu)
+	return true
+}
+
+func (p *paramCache) ready() bool {
+	if p.name == core.CodecH264 {
+		return p.sets[1] != nil && p.sets[2] != nil
+	}
+	return p.sets[0] != nil && p.sets[1] != nil && p.sets[2] != nil
+}
+
+func (p *paramCache) nalus() [][]byte {
+	if p.name == core.CodecH264 {
+		return p.sets[1:]
+	}
+	return p.sets[:]
+}
+
+// scanRTP - update cache from single and aggregation packets, check if
+// the packet starts IDR frame and if it has parameter sets
+func (p *paramCache) scanRTP(payload []byte) (key, params bool) {
+	if len(payload) < 3 {
+		return
+	}
+
+	var b []byte // aggregation packet NAL units
+
+	if p.name == core.CodecH264 {
+		switch payload[0] & 0x1F {
+		case 24: // STAP-A
+			b = payload[1:]
+		case 28: // FU-A
+			return h264.IsKeyframeRTP(payload), false
+		}
+	} else {
+		switch (payload[0] >> 1) & 0x3F {
+		case 48: // AP
+			b = payload[2:]
+		case h265.NALUTypeFU:
+			return h265.IsKeyframeRTP(payload), false
+		}
+	}
+
+	if b == nil {
+		return p.isIFrame(payload), p.update(payload)
+	}
+
+	for len(b) > 2 {
+		size := int(binary.BigEndian.Uint16(b))
+		if size == 0 || 2+size > len(b) {
+			break
+		}
+		nalu := b[2 : 2+size]
+		if p.update(nalu) {
+			params = true
+		} else if p.isIFrame(nalu) {
+			key = true
+		}
+		b = b[2+size:]
+	}
+
+	return
+}
+
+// scanAVCC - same as scanRTP for the whole access unit in AVCC format
+func (p *paramCache) scanAVCC(avcc []byte) (key, params bool) {
+	for len(avcc) > 4 {
+		size := 4 + int(binary.BigEndian.Uint32(avcc))
+		if size > len(avcc) {
+			break
+		}
+		nalu := avcc[4:size]
+		if p.update(nalu) {
+			params = true
+		} else if p.isIFrame(nalu) {
+			key = true
+		}
+		avcc = avcc[size:]
+	}
+	return
+}
+
+// paramsWriter - re-send cached parameter sets before the keyframe without
+// them, so players joining mid-stream can start decoding. Interval limits how
+// often they are repeated, zero - before each keyframe.
+func paramsWriter(codec *core.Codec, interval time.Duration, handler core.HandlerFunc) core.HandlerFunc {
+	p := newParamCache(codec)
+	if p == nil {
+		return handler
+	}
+
+	var sent time.Time // last time consumer got parameter sets
+
+	repeat := func(key bool) bool {
+		now := time.Now()
+		if !key || !p.ready() || now.Sub(sent) < interval {
+			return false
+		}
+		sent = now
+		return true
+	}
+
+	if !codec.IsRTP() {
+		return func(packet *rtp.Packet) {
+			key, params := p.scanAVCC(packet.Payload)
+			if params {
+				sent = time.Now() // from the camera
+			}
+			if params || !repeat(key) {
+				handler(packet)
+				return
+			}
+
+			clone := *packet
+			clone.Payload = h264.Join(h264.JoinNALU(p.nalus()...), packet.Payload)
+			handler(&clone)
+		}
+	}
+
+	var seq uint16 // sequence offset for the inserted packets
+	var ts uint32
+	var inband bool // current access unit already has parameter sets
+
+	return func(packet *rtp.Packet) {
+		if packet.Timestamp != ts {
+			ts = packet.Timestamp
+			inband = false
+		}
+
+		key, params := p.scanRTP(packet.Payload)
+		if params {
+			sent = time.Now() // from the camera
+			inband = true
+		}
+
+		// IDR slices of one frame can be in many packets, check only the first
+		if !inband && repeat(key) {
+			inband = true
+
+			for _, nalu := range p.nalus() {
+				handler(&rtp.Packet{
+					Header: rtp.Header{
+						Version:        2,
+						PayloadType:    packet.PayloadType,
+						SequenceNumber: packet.SequenceNumber + seq,
+						Timestamp:      packet.Timestamp,
+						SSRC:           packet.SSRC,
+					},
+					Payload: nalu,
+				})
+				seq++
+			}
+		}
+
+		if seq == 0 {
+			handler(packet)
+			return
+		}
+
+		clone := *packet
+		clone.SequenceNumber += seq
+		handler(&clone)
+	}
+}
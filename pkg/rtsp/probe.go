@@ -0,0 +1,74 @@
+package rtsp
+
+import (
+	"strings"
+
+	"github.com/AlexxIT/go2rtc/pkg/core"
+)
+
+// CodecProbe - codec from the DESCRIBE answer with the verdict, can go2rtc handle it
+type CodecProbe struct {
+	Media       string `json:"media"` // media kind, ex. video
+	Direction   string `json:"direction"`
+	Control     string `json:"control,omitempty"`
+	Codec       string `json:"codec"`
+	ClockRate   uint32 `json:"clock_rate,omitempty"`
+	Channels    uint8  `json:"channels,omitempty"`
+	PayloadType uint8  `json:"payload_type"`
+	FmtpLine    string `json:"fmtp,omitempty"`
+	Supported   bool   `json:"supported"`
+	Note        string `json:"note,omitempty"` // reason for unsupported or limits of supported codec
+
+	Video *core.VideoInfo `json:"video,omitempty"` // size and frame rate from SDP or SPS
+}
+
+// Probe - all codecs of the SDP medias after Describe, without SETUP and PLAY
+func (c *Conn) Probe() []*CodecProbe {
+	var probes []*CodecProbe
+	for _, media := range c.Medias {
+		for _, codec := range media.Codecs {
+			probe := &CodecProbe{
+				Media:       media.Kind,
+				Direction:   media.Direction,
+				Control:     media.ID,
+				Codec:       codec.Name,
+				ClockRate:   codec.ClockRate,
+				Channels:    codec.Channels,
+				PayloadType: codec.PayloadType,
+				FmtpLine:    codec.FmtpLine,
+				Video:       media.Video,
+			}
+			probe.Supported, probe.Note = probeCodec(codec)
+			probes = append(probes, probe)
+		}
+	}
+	return probes
+}
+
+func probeCodec(codec *core.Codec) (bool, string) {
+	switch codec.Name {
+	case core.CodecH264:
+		if !strings.Contains(codec.FmtpLine, "sprop-parameter-sets=") {
+			return true, "no SPS/PPS in SDP, waits them in the stream"
+		}
+	case core.CodecH265:
+		if !strings.Contains(codec.FmtpLine, "sprop-sps=") {
+			return true, "no VPS/SPS/PPS in SDP, waits them in the stream"
+		}
+	case core.CodecAAC:
+		if !strings.Contains(codec.FmtpLine, "config=") {
+			return false, "no AAC config in SDP"
+		}
+	case core.CodecMP2T:
+		return true, "MPEG-TS is demuxed to elementary streams"
+	case core.CodecTelephoneEvent:
+		return true, "DTMF tones in the backchannel"
+	case "":
+		return false, "unknown payload type"
+	}
+
+	if !isKnownCodec(codec.Name) {
+		return false, "no depacketizer, only raw RTP"
+	}
+	return true, ""
+}
@@ -3,6 +3,8 @@ package rtsp
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
+	"time"
 
 	"github.com/AlexxIT/go2rtc/pkg/core"
 )
@@ -29,6 +31,16 @@ func (c *Conn) GetTrack(media *core.Media, codec *core.Codec) (*core.Receiver, e
 			}
 		}
 
+		if c.deferSetup(media) {
+			// audio will be setup after PLAY for video
+			track := core.NewReceiver(media, codec)
+			track.ID = c.interleavedChannel(media)
+			track.Dedup(c.DedupWindow)
+			c.Receivers = append(c.Receivers, track)
+			c.pending = append(c.pending, track)
+			return track, nil
+		}
+
 		var err error
 		channel, err = c.SetupMedia(media)
 		if err != nil {
@@ -37,7 +49,7 @@ func (c *Conn) GetTrack(media *core.Media, codec *core.Codec) (*core.Receiver, e
 
 		c.state = StateSetup
 	case core.ModePassiveConsumer:
-		// Backchannel
+		// Backchannel, final channel will be set on DESCRIBE after all senders
 		channel = byte(len(c.Senders)) * 2
 	default:
 		return nil, errors.New("rtsp: wrong mode for GetTrack")
@@ -45,6 +57,7 @@ func (c *Conn) GetTrack(media *core.Media, codec *core.Codec) (*core.Receiver, e
 
 	track := core.NewReceiver(media, codec)
 	track.ID = channel
+	track.Dedup(c.DedupWindow)
 	c.Receivers = append(c.Receivers, track)
 
 	return track, nil
@@ -53,6 +66,15 @@ func (c *Conn) GetTrack(media *core.Media, codec *core.Codec) (*core.Receiver, e
 func (c *Conn) Start() (err error) {
 	core.Assert(c.mode == core.ModeActiveProducer || c.mode == core.ModePassiveProducer)
 
+	defer func() {
+		// no-op if the producer was ready before
+		if err != nil {
+			c.ready.set(err)
+		} else {
+			c.ready.set(ErrNotReady)
+		}
+	}()
+
 	for {
 		ok := false
 
@@ -75,11 +97,28 @@ func (c *Conn) Start() (err error) {
 			if err == nil {
 				c.state = StatePlay
 				ok = true
+
+				if c.MaxSession > 0 {
+					c.refreshAt = time.Now().Add(c.MaxSession)
+				}
+
+				if pending := c.pending; pending != nil {
+					c.pending = nil
+					go func() {
+						// video is already playing, so the error doesn't stop the stream
+						if err := c.playPending(pending); err != nil {
+							c.Fire(fmt.Errorf("%w: %w", ErrFastStart, err))
+						}
+					}()
+				}
 			}
 		}
 		c.stateMu.Unlock()
 
 		if !ok {
+			if err != nil && c.NoReconnect {
+				err = fmt.Errorf("%w: %w", core.ErrNoReconnect, err)
+			}
 			return
 		}
 
@@ -88,29 +127,126 @@ func (c *Conn) Start() (err error) {
 		// 2. Play after PLAY should exit from Start with error
 		// 3. Setup after PLAY should Play once again
 		err = c.Handle()
+
+		// camera at its session limit on PLAY, try lower bandwidth substream
+		if isFallback(err) && !c.NoReconnect && c.switchFallback() {
+			c.stateMu.Lock()
+			if err = c.Reconnect(); err == nil {
+				c.state = StateSetup
+			}
+			c.stateMu.Unlock()
+
+			if err != nil {
+				return
+			}
+		}
+
+		// MaxSession reached or video freeze, reconnect to the camera
+		if errors.Is(err, errRefresh) && !c.NoReconnect {
+			c.stateMu.Lock()
+			c.refresh.Store(false)
+			c.refreshAt = time.Time{}
+			c.freeze.reset()
+			if err = c.Reconnect(); err == nil {
+				c.state = StateSetup
+			}
+			c.stateMu.Unlock()
+
+			if err != nil {
+				return
+			}
+		}
 	}
 }
 
+// stopTimeout - wait the read loop exit on Stop, Stop can be called from the loop itself
+const stopTimeout = time.Second
+
+// Stop - deterministic shutdown: flush backchannel, signal the read loop to exit
+// and wait for it, only then close tracks and the connection. So the read loop
+// never gets "use of closed network connection" error.
 func (c *Conn) Stop() (err error) {
-	for _, receiver := range c.Receivers {
-		receiver.Close()
-	}
 	for _, sender := range c.Senders {
 		sender.Close()
 	}
 
+	if c.mode == core.ModeActiveProducer && c.DrainTimeout > 0 {
+		c.drainSenders(c.DrainTimeout)
+	}
+
 	c.stateMu.Lock()
-	if c.state != StateNone {
-		c.state = StateNone
-		err = c.Close()
+	running := c.state != StateNone
+	c.state = StateNone
+	handling := c.handling
+	if running && handling != nil {
+		c.stopping.Store(true)
+		_ = c.conn.SetReadDeadline(time.Now()) // wake up the read loop
 	}
 	c.stateMu.Unlock()
 
+	if running && handling != nil {
+		select {
+		case <-handling:
+		case <-time.After(stopTimeout):
+		}
+	}
+
+	for _, receiver := range c.Receivers {
+		receiver.Close()
+	}
+
+	c.ready.set(ErrNotReady)
+
+	if running {
+		c.stateMu.Lock()
+		err = c.Close()
+		c.stateMu.Unlock()
+	}
+
 	return
 }
 
+// drainSenders - wait until closed backchannel senders write queued packets,
+// so the audio at the camera side is not cut off in the middle of a word
+func (c *Conn) drainSenders(timeout time.Duration) {
+	done := make(chan struct{})
+	go func() {
+		for _, sender := range c.Senders {
+			sender.Wait()
+		}
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(timeout):
+		c.Fire("RTSP drain timeout")
+	}
+}
+
 func (c *Conn) MarshalJSON() ([]byte, error) {
-	return json.Marshal(c.Connection)
+	rtcps := c.rtcpInfo()
+	if c.Supported == nil && c.Skipped == nil && rtcps == nil && !c.useScale() && c.fprint == "" {
+		return json.Marshal(c.Connection)
+	}
+	info := struct {
+		core.Connection
+		Supported []string        `json:"supported,omitempty"`
+		Skipped   []*SkippedMedia `json:"skipped_medias,omitempty"`
+		RTCP      []*rtcpInfo     `json:"rtcp,omitempty"`
+		Scale     float64         `json:"scale,omitempty"` // trick-play rate
+		TLS       string          `json:"tls_fingerprint,omitempty"`
+	}{
+		Connection: c.Connection,
+		Supported:  c.Supported,
+		Skipped:    c.Skipped,
+		RTCP:       rtcps,
+		TLS:        c.fprint,
+	}
+	if c.useScale() {
+		info.Scale = c.PlayRate()
+	}
+	return json.Marshal(info)
 }
 
 func (c *Conn) Reconnect() error {
@@ -119,6 +255,9 @@ func (c *Conn) Reconnect() error {
 	// close current session
 	_ = c.Close()
 
+	// all medias will be setup
+	c.pending = nil
+
 	// start new session
 	if err := c.Dial(); err != nil {
 		return err
@@ -0,0 +1,81 @@
+package rtsp
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+
+	"github.com/AlexxIT/go2rtc/pkg/core"
+	"github.com/pion/rtp"
+)
+
+const EventKeyframe = "RTSP first keyframe"
+
+// ErrNotReady - producer stopped before the first keyframe
+var ErrNotReady = errors.New("rtsp: stopped before ready")
+
+// readiness - one shot signal, closed on the first keyframe or on the startup error
+type readiness struct {
+	ch   chan struct{}
+	err  error
+	done atomic.Bool // fast check for the read loop
+	mu   sync.Mutex
+}
+
+func (r *readiness) wait() <-chan struct{} {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.ch == nil {
+		r.ch = make(chan struct{})
+	}
+	return r.ch
+}
+
+func (r *readiness) set(err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.done.Load() {
+		return
+	}
+
+	r.err = err
+	if r.ch == nil {
+		r.ch = make(chan struct{})
+	}
+	close(r.ch)
+	r.done.Store(true)
+}
+
+func (r *readiness) error() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.err
+}
+
+// Ready - closed when the producer is in the PLAY state and has delivered the first
+// video keyframe (or the first packet for the stream without video). Also closed
+// if startup aborts, check ReadyErr after it.
+func (c *Conn) Ready() <-chan struct{} {
+	return c.ready.wait()
+}
+
+// ReadyErr - nil if the producer is ready, an error if startup aborted
+func (c *Conn) ReadyErr() error {
+	return c.ready.error()
+}
+
+// checkReady - called by the read loop until the producer is ready
+func (c *Conn) checkReady(codec *core.Codec, packet *rtp.Packet) {
+	if codec.IsVideo() {
+		if !isKeyframeStart(codec.Name, packet.Payload) {
+			return
+		}
+		c.Fire(EventKeyframe)
+	} else if c.hasVideo() {
+		return // waiting keyframe
+	}
+
+	c.ready.set(nil)
+}
@@ -0,0 +1,280 @@
+package rtsp
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/AlexxIT/go2rtc/pkg/core"
+	"github.com/pion/rtcp"
+	"github.com/pion/rtp"
+)
+
+// isRTCP - RTCP channel from SETUP response or the odd channel after RTP
+func (c *Conn) isRTCP(channel byte) bool {
+	if _, ok := c.rtcpMap[channel]; ok {
+		return true
+	}
+	return channel&1 == 1
+}
+
+// rtpChannel - media channel for the RTCP channel
+func (c *Conn) rtpChannel(channel byte) byte {
+	if rtpChannel, ok := c.rtcpMap[channel]; ok {
+		return rtpChannel
+	}
+	return channel - 1
+}
+
+// rtcpChannel - RTCP channel for the media channel
+func (c *Conn) rtcpChannel(rtpChannel byte) byte {
+	for channel, id := range c.rtcpMap {
+		if id == rtpChannel {
+			return channel
+		}
+	}
+	return rtpChannel + 1
+}
+
+// isRTCPMux - RTCP packet on the RTP channel, packet types 200-204 (SR, RR, SDES, BYE, APP)
+// can't be valid RTP payload types with the marker https://www.rfc-editor.org/rfc/rfc5761#section-4
+func isRTCPMux(buf []byte) bool {
+	return len(buf) >= 8 && buf[0]>>6 == 2 && buf[1] >= 200 && buf[1] <= 204
+}
+
+// rtcpStats - number of incoming RTCP packets for each media channel,
+// API reads it in parallel with the read loop
+type rtcpStats struct {
+	packets map[byte]int
+	mu      sync.Mutex
+}
+
+func (s *rtcpStats) add(rtpChannel byte) {
+	s.mu.Lock()
+	if s.packets == nil {
+		s.packets = map[byte]int{}
+	}
+	s.packets[rtpChannel]++
+	s.mu.Unlock()
+}
+
+func (s *rtcpStats) get(rtpChannel byte) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.packets[rtpChannel]
+}
+
+// rtcpInfo - RTCP info for the media in the API, many cameras never send RTCP
+type rtcpInfo struct {
+	Media    string   `json:"media"`
+	Channel  byte     `json:"channel"`
+	Received int      `json:"rtcp_received"`
+	Feedback []string `json:"feedback,omitempty"` // types the client sends to the camera
+}
+
+func (c *Conn) rtcpInfo() []*rtcpInfo {
+	var infos []*rtcpInfo
+	for _, receiver := range c.Receivers {
+		if receiver.Media == nil {
+			continue
+		}
+		infos = append(infos, &rtcpInfo{
+			Media:    receiver.Media.String(),
+			Channel:  c.rtcpChannel(receiver.ID),
+			Received: c.rtcpStats.get(receiver.ID),
+			Feedback: c.feedbackSet(receiver.Media).names(),
+		})
+	}
+	return infos
+}
+
+// ReportInterval - how often to send receiver reports with the RTCPReports option
+const ReportInterval = 5 * time.Second
+
+// writeReports - send minimal receiver reports (without report blocks) for
+// each media, for cameras that stop the stream without any RTCP from the client.
+// Returns the next run time, zero after the error.
+func (c *Conn) writeReports() time.Duration {
+	for _, receiver := range c.Receivers {
+		if !c.feedbackSet(receiver.Media).rr {
+			continue
+		}
+		if err := c.writeRTCP(receiver.ID, &rtcp.ReceiverReport{SSRC: c.ssrc}); err != nil {
+			return 0
+		}
+	}
+	return ReportInterval
+}
+
+// writeRTCP - send RTCP packets on the RTCP channel of the media
+func (c *Conn) writeRTCP(rtpChannel byte, packets ...rtcp.Packet) error {
+	b, err := rtcp.Marshal(packets)
+	if err != nil {
+		return err
+	}
+
+	size := len(b)
+	data := append([]byte{c.writeMagic(), c.rtcpChannel(rtpChannel), byte(size >> 8), byte(size)}, b...)
+	return c.writeInterleavedData(data)
+}
+
+// RTCP feedback types for the Feedback option
+const (
+	FeedbackRR   = "rr"   // receiver reports
+	FeedbackPLI  = "pli"  // picture loss indication on video packets loss
+	FeedbackFIR  = "fir"  // full intra request on video packets loss, if PLI is disabled
+	FeedbackNACK = "nack" // retransmission request for lost packets
+	FeedbackAuto = "auto" // types advertised by the camera in SDP a=rtcp-fb
+)
+
+// feedback - RTCP feedback types the client sends for the media
+type feedback struct {
+	rr, pli, fir, nack bool
+}
+
+// parseFeedback - comma separated types, "-" prefix removes the type,
+// ex. "auto,-nack" for the camera that advertises NACK but breaks on it
+func parseFeedback(s string, media *core.Media) (fb feedback) {
+	for _, name := range strings.Split(s, ",") {
+		name = strings.TrimSpace(name)
+		name, remove := strings.CutPrefix(name, "-")
+
+		switch name {
+		case FeedbackRR:
+			fb.rr = !remove
+		case FeedbackPLI:
+			fb.pli = !remove
+		case FeedbackFIR:
+			fb.fir = !remove
+		case FeedbackNACK:
+			fb.nack = !remove
+		case FeedbackAuto:
+			if media == nil || remove {
+				continue
+			}
+			for _, value := range media.Feedback {
+				switch value {
+				case "nack":
+					fb.nack = true
+				case "nack pli":
+					fb.pli = true
+				case "ccm fir":
+					fb.fir = true
+				}
+			}
+		}
+	}
+	return
+}
+
+func (fb feedback) names() (names []string) {
+	if fb.rr {
+		names = append(names, FeedbackRR)
+	}
+	if fb.pli {
+		names = append(names, FeedbackPLI)
+	}
+	if fb.fir {
+		names = append(names, FeedbackFIR)
+	}
+	if fb.nack {
+		names = append(names, FeedbackNACK)
+	}
+	return
+}
+
+// feedbackSet - types for the media, RTCPReports option always adds receiver reports,
+// RTX option adds NACK for medias with RTX
+func (c *Conn) feedbackSet(media *core.Media) feedback {
+	fb := parseFeedback(c.Feedback, media)
+	if c.RTCPReports {
+		fb.rr = true
+	}
+	if c.useRTX(media) {
+		fb.nack = true
+	}
+	return fb
+}
+
+// KeyframeRequestInterval - minimum time between PLI/FIR requests for the media
+const KeyframeRequestInterval = time.Second
+
+// maxNackGap - don't request retransmission for bigger losses, only the keyframe
+const maxNackGap = 32
+
+// lossState - packets loss detection for the media, used only by the read loop
+type lossState struct {
+	feedback
+	seq     uint16
+	ssrc    uint32
+	started bool
+	keyAt   time.Time // last PLI or FIR
+	firSeq  uint8
+}
+
+// lossStates - created before the read loop, because UDP readers work in parallel
+func (c *Conn) lossStates() map[byte]*lossState {
+	states := map[byte]*lossState{}
+	for _, receiver := range c.Receivers {
+		states[receiver.ID] = &lossState{feedback: c.feedbackSet(receiver.Media)}
+	}
+	return states
+}
+
+// checkLoss - send NACK and keyframe request on the sequence gap
+func (c *Conn) checkLoss(receiver *core.Receiver, packet *rtp.Packet) {
+	loss := c.losses[receiver.ID]
+	if loss == nil {
+		return
+	}
+
+	// new source (SSRC change) starts the new sequence
+	if !loss.started || packet.SSRC != loss.ssrc {
+		loss.seq, loss.ssrc, loss.started = packet.SequenceNumber, packet.SSRC, true
+		return
+	}
+
+	// skip duplicate and reordered packets
+	if int16(packet.SequenceNumber-loss.seq) <= 0 {
+		return
+	}
+
+	gap := packet.SequenceNumber - loss.seq - 1
+	first := loss.seq + 1
+	loss.seq = packet.SequenceNumber
+
+	if gap == 0 {
+		return
+	}
+
+	var packets []rtcp.Packet
+
+	if loss.nack && gap <= maxNackGap {
+		seqs := make([]uint16, gap)
+		for i := range seqs {
+			seqs[i] = first + uint16(i)
+		}
+		packets = append(packets, &rtcp.TransportLayerNack{
+			SenderSSRC: c.ssrc, MediaSSRC: packet.SSRC, Nacks: rtcp.NackPairsFromSequenceNumbers(seqs),
+		})
+	}
+
+	if (loss.pli || loss.fir) && receiver.Codec.IsVideo() && time.Since(loss.keyAt) >= KeyframeRequestInterval {
+		loss.keyAt = time.Now()
+		if loss.pli {
+			packets = append(packets, &rtcp.PictureLossIndication{
+				SenderSSRC: c.ssrc, MediaSSRC: packet.SSRC,
+			})
+		} else {
+			loss.firSeq++
+			packets = append(packets, &rtcp.FullIntraRequest{
+				SenderSSRC: c.ssrc, MediaSSRC: packet.SSRC,
+				FIR: []rtcp.FIREntry{{SSRC: packet.SSRC, SequenceNumber: loss.firSeq}},
+			})
+		}
+	}
+
+	if packets != nil {
+		_ = c.writeRTCP(receiver.ID, packets...)
+	}
+}
@@ -1,9 +1,17 @@
 package rtsp
 
 import (
+	"bufio"
+	"bytes"
+	"net"
+	"slices"
 	"testing"
+	"time"
 
 	"github.com/AlexxIT/go2rtc/pkg/core"
+	"github.com/AlexxIT/go2rtc/pkg/h264"
+	"github.com/pion/rtcp"
+	"github.com/pion/rtp"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -273,3 +281,901 @@ a=appversion:1.0
 	assert.Len(t, medias, 2)
 	assert.Equal(t, core.CodecPCML, medias[1].Codecs[0].Name)
 }
+
+func TestPayloadMap(t *testing.T) {
+	video := core.NewReceiver(nil, &core.Codec{Name: core.CodecH264, PayloadType: 96})
+	audio := core.NewReceiver(nil, &core.Codec{Name: core.CodecPCMA, PayloadType: 8})
+
+	c := &Conn{PayloadMap: map[uint8]uint8{35: 8}}
+	c.Receivers = []*core.Receiver{video, audio} // both on channel 0
+
+	packet := &rtp.Packet{Header: rtp.Header{Version: 2, PayloadType: 35}, Payload: []byte{1, 2, 3}}
+	b, err := packet.Marshal()
+	assert.Nil(t, err)
+
+	err = c.handleRawPacket(0, b)
+	assert.Nil(t, err)
+	assert.Equal(t, 0, video.Packets)
+	assert.Equal(t, 1, audio.Packets)
+}
+
+func TestPayloadMapHooks(t *testing.T) {
+	video := core.NewReceiver(nil, &core.Codec{Name: core.CodecH264, PayloadType: 96})
+
+	c := &Conn{PayloadMap: map[uint8]uint8{97: 96}}
+	c.Receivers = []*core.Receiver{video}
+
+	// remapped keyframe goes through the same checks, so the producer is ready
+	packet := &rtp.Packet{Header: rtp.Header{Version: 2, PayloadType: 97}, Payload: []byte{0x65, 0x88, 0x84}}
+	b, err := packet.Marshal()
+	assert.Nil(t, err)
+
+	assert.Nil(t, c.handleRawPacket(0, b))
+	assert.Equal(t, 1, video.Packets)
+
+	select {
+	case <-c.Ready():
+	default:
+		t.Fatal("not ready after keyframe")
+	}
+}
+
+func TestPadding(t *testing.T) {
+	receiver := core.NewReceiver(nil, &core.Codec{Name: core.CodecH264, PayloadType: 96})
+
+	c := &Conn{}
+	c.Receivers = []*core.Receiver{receiver}
+
+	var payloads [][]byte
+	receiver.AppendChild(&core.Node{Input: func(packet *rtp.Packet) {
+		assert.False(t, packet.Padding)
+		payloads = append(payloads, packet.Payload)
+	}})
+
+	// 3 bytes payload + 4 bytes padding (last byte is padding length)
+	b := []byte{0xA0, 96, 0, 1, 0, 0, 0, 0, 0, 0, 0, 0, 0x65, 0x88, 0x84, 0, 0, 0, 4}
+	assert.Nil(t, c.handleRawPacket(0, b))
+	assert.Equal(t, [][]byte{{0x65, 0x88, 0x84}}, payloads)
+
+	// zero padding length
+	b = []byte{0xA0, 96, 0, 2, 0, 0, 0, 0, 0, 0, 0, 0, 0x65, 0x88, 0x84, 0}
+	assert.Nil(t, c.handleRawPacket(0, b))
+
+	// padding bigger than packet
+	b = []byte{0xA0, 96, 0, 3, 0, 0, 0, 0, 0, 0, 0, 0, 0x65, 0x88, 0x84, 100}
+	assert.Nil(t, c.handleRawPacket(0, b))
+
+	assert.Len(t, payloads, 1)
+	assert.Equal(t, 1, receiver.Packets)
+}
+
+func TestResolveControl(t *testing.T) {
+	base, err := urlParse("rtsp://192.168.1.123/cam/realmonitor?channel=1&subtype=0")
+	assert.NoError(t, err)
+
+	for control, expected := range map[string]string{
+		"":                                 "rtsp://192.168.1.123/cam/realmonitor?channel=1&subtype=0",
+		"*":                                "rtsp://192.168.1.123/cam/realmonitor?channel=1&subtype=0",
+		"trackID=0":                        "rtsp://192.168.1.123/cam/realmonitor?channel=1&subtype=0/trackID=0",
+		"/trackID=0":                       "rtsp://192.168.1.123/cam/realmonitor?channel=1&subtype=0/trackID=0",
+		"rtsp://192.168.1.123/live/track1": "rtsp://192.168.1.123/live/track1",
+	} {
+		u, err := resolveControl(base, control)
+		assert.NoError(t, err)
+		assert.Equal(t, expected, u.String())
+	}
+
+	base, err = urlParse("rtsp://192.168.1.12:554/Streaming/channels/101/")
+	assert.NoError(t, err)
+
+	u, err := resolveControl(base, "/trackID=1")
+	assert.NoError(t, err)
+	assert.Equal(t, "rtsp://192.168.1.12:554/Streaming/channels/101/trackID=1", u.String())
+}
+
+func TestSessionControl(t *testing.T) {
+	s := `v=0
+o=- 1721969533379665 1721969533379665 IN IP4 192.168.1.12
+s=Media Presentation
+t=0 0
+a=control:rtsp://192.168.1.12:554/Streaming/channels/101/
+m=video 0 RTP/AVP 96
+a=control:rtsp://192.168.1.12:554/Streaming/channels/101/trackID=1
+`
+	assert.Equal(t, "rtsp://192.168.1.12:554/Streaming/channels/101/", sessionControl([]byte(s)))
+}
+
+func TestKeyframeStart(t *testing.T) {
+	assert.True(t, isKeyframeStart(core.CodecH264, []byte{0x67, 0x64, 0x00}))       // SPS
+	assert.True(t, isKeyframeStart(core.CodecH264, []byte{0x78, 0x00, 0x10, 0x67})) // STAP-A with SPS
+	assert.True(t, isKeyframeStart(core.CodecH264, []byte{0x7C, 0x85, 0x88}))       // FU-A IDR start
+	assert.False(t, isKeyframeStart(core.CodecH264, []byte{0x7C, 0x45, 0x88}))      // FU-A IDR end
+	assert.False(t, isKeyframeStart(core.CodecH264, []byte{0x41, 0x9A, 0x00}))      // P-frame
+	assert.True(t, isKeyframeStart(core.CodecH265, []byte{0x40, 0x01, 0x0C}))       // VPS
+	assert.True(t, isKeyframeStart(core.CodecH265, []byte{0x62, 0x01, 0x93}))       // FU IDR start
+	assert.False(t, isKeyframeStart(core.CodecH265, []byte{0x62, 0x01, 0x13}))      // FU IDR middle
+	assert.True(t, isKeyframeStart(core.CodecPCMA, []byte{0x00, 0x00, 0x00}))
+}
+
+func TestFreeze(t *testing.T) {
+	assert.True(t, isIFrameStart(core.CodecH264, []byte{0x65, 0x88, 0x84}))                                // IDR
+	assert.False(t, isIFrameStart(core.CodecH264, []byte{0x67, 0x64, 0x00}))                               // SPS
+	assert.True(t, isIFrameStart(core.CodecH264, []byte{0x78, 0x00, 0x01, 0x68, 0x00, 0x02, 0x65, 0x88}))  // STAP-A with PPS and IDR
+	assert.False(t, isIFrameStart(core.CodecH264, []byte{0x78, 0x00, 0x02, 0x67, 0x64, 0x00, 0x01, 0x68})) // STAP-A with SPS and PPS
+	assert.True(t, isIFrameStart(core.CodecH264, []byte{0x7C, 0x85, 0x88}))                                // FU-A IDR start
+
+	var f freezeDetector
+	now := time.Now()
+	assert.False(t, f.check([]byte{1, 2, 3}, now, time.Second))
+	assert.False(t, f.check([]byte{1, 2, 3}, now.Add(500*time.Millisecond), time.Second))
+	assert.True(t, f.check([]byte{1, 2, 3}, now.Add(time.Second), time.Second))
+	assert.False(t, f.check([]byte{1, 2, 4}, now.Add(2*time.Second), time.Second)) // picture changed
+	assert.False(t, f.check([]byte{1, 2, 4}, now.Add(2500*time.Millisecond), time.Second))
+}
+
+func TestMPEGTS(t *testing.T) {
+	s := `v=0
+o=- 0 0 IN IP4 127.0.0.1
+s=No Name
+t=0 0
+a=tool:libavformat 60.3.100
+m=video 0 RTP/AVP 33
+c=IN IP4 0.0.0.0
+a=control:streamid=0
+`
+	medias, err := UnmarshalSDP([]byte(s))
+	assert.Nil(t, err)
+	assert.Len(t, medias, 1)
+	assert.Equal(t, core.CodecMP2T, medias[0].Codecs[0].Name)
+	assert.Equal(t, uint32(90000), medias[0].Codecs[0].ClockRate)
+}
+
+type loopReader struct {
+	data []byte
+	pos  int
+}
+
+func (r *loopReader) Read(p []byte) (n int, err error) {
+	for n < len(p) {
+		i := copy(p[n:], r.data[r.pos:])
+		r.pos = (r.pos + i) % len(r.data)
+		n += i
+	}
+	return
+}
+
+type discardConn struct {
+	net.Conn
+}
+
+func (discardConn) Write(b []byte) (int, error)      { return len(b), nil }
+func (discardConn) SetWriteDeadline(time.Time) error { return nil }
+
+func BenchmarkPassthrough(b *testing.B) {
+	packet := &rtp.Packet{
+		Header:  rtp.Header{Version: 2, PayloadType: 96, Marker: true},
+		Payload: make([]byte, 1200),
+	}
+	data, _ := packet.Marshal()
+	frame := append([]byte{'$', 0, byte(len(data) >> 8), byte(len(data))}, data...)
+
+	codec := &core.Codec{Name: core.CodecH264, ClockRate: 90000, PayloadType: 96}
+	media := &core.Media{Kind: core.KindVideo, Codecs: []*core.Codec{codec}}
+
+	src := &Conn{reader: bufio.NewReader(&loopReader{data: frame}), state: StatePlay}
+	receiver := core.NewReceiver(media, codec)
+	src.Receivers = []*core.Receiver{receiver}
+
+	dst := &Conn{conn: discardConn{}, state: StatePlay, playOK: true}
+	receiver.AppendChild(&core.Node{Input: dst.packetWriter(codec, 0, 96)})
+
+	b.ReportAllocs()
+	b.SetBytes(int64(len(frame)))
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		if err := src.handleTCPData(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkHandleTCPData - interleaved parser for the high packet rate stream:
+// video and audio RTP with RTCP sender report, without consumers
+func BenchmarkHandleTCPData(b *testing.B) {
+	var stream []byte
+	for i := 0; i < 20; i++ {
+		video := &rtp.Packet{
+			Header:  rtp.Header{Version: 2, PayloadType: 96, SequenceNumber: uint16(i)},
+			Payload: make([]byte, 1200),
+		}
+		stream = appendFrame(stream, 0, video)
+	}
+	audio := &rtp.Packet{
+		Header:  rtp.Header{Version: 2, PayloadType: 8},
+		Payload: make([]byte, 160),
+	}
+	stream = appendFrame(stream, 2, audio)
+	sr := &rtcp.SenderReport{SSRC: 1}
+	stream = appendFrame(stream, 1, sr)
+
+	video := &core.Media{Kind: core.KindVideo}
+	audio2 := &core.Media{Kind: core.KindAudio}
+
+	src := &Conn{reader: bufio.NewReader(&loopReader{data: stream}), state: StatePlay}
+	src.Receivers = []*core.Receiver{
+		core.NewReceiver(video, &core.Codec{Name: core.CodecH264, ClockRate: 90000, PayloadType: 96}),
+		core.NewReceiver(audio2, &core.Codec{Name: core.CodecPCMA, ClockRate: 8000, PayloadType: 8}),
+	}
+	src.Receivers[1].ID = 2
+
+	b.ReportAllocs()
+	b.SetBytes(int64(len(stream)))
+	b.ResetTimer()
+
+	// one op is the whole sequence of 22 frames
+	for i := 0; i < b.N; i++ {
+		for j := 0; j < 22; j++ {
+			if err := src.handleTCPData(); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
+
+func appendFrame(b []byte, channel byte, packet interface{ Marshal() ([]byte, error) }) []byte {
+	data, _ := packet.Marshal()
+	b = append(b, '$', channel, byte(len(data)>>8), byte(len(data)))
+	return append(b, data...)
+}
+
+func TestMagic(t *testing.T) {
+	for s, magic := range map[string]byte{"": '$', "0x23": '#', "35": '#', "#": '#', "auto": '$', "wrong": '$'} {
+		b, _ := parseMagic(s)
+		assert.Equal(t, magic, b, s)
+	}
+
+	packet := &rtp.Packet{Header: rtp.Header{Version: 2, PayloadType: 96}, Payload: []byte{0x41, 0x9A}}
+	frame := appendFrame(nil, 0, packet)
+	frame[0] = '#'
+
+	newConn := func(magic string, data []byte) (*Conn, *int) {
+		c := &Conn{Magic: magic, reader: bufio.NewReader(bytes.NewReader(data))}
+		receiver := core.NewReceiver(&core.Media{Kind: core.KindVideo}, &core.Codec{Name: core.CodecH264, PayloadType: 96})
+		c.Receivers = []*core.Receiver{receiver}
+		packets := new(int)
+		receiver.Input = func(*rtp.Packet) { *packets++ }
+		return c, packets
+	}
+
+	// configured byte, resync after the junk with the same byte
+	stream := append(append(slices.Clone(frame), "junk"...), frame...)
+	c, packets := newConn("0x23", stream)
+	assert.Nil(t, c.handleTCPData())
+	assert.Nil(t, c.handleTCPData())
+	assert.Equal(t, 2, *packets)
+
+	// learned from the first frame
+	var learned []byte
+	c, packets = newConn("auto", append(slices.Clone(frame), frame...))
+	c.Listen(func(msg any) {
+		if msg, ok := msg.(*Magic); ok {
+			learned = append(learned, msg.Byte)
+		}
+	})
+	assert.Nil(t, c.handleTCPData())
+	assert.Nil(t, c.handleTCPData())
+	assert.Equal(t, 2, *packets)
+	assert.Equal(t, []byte{'#'}, learned)
+
+	// standard parser doesn't accept it
+	c, packets = newConn("", frame)
+	assert.NotNil(t, c.handleTCPData())
+	assert.Equal(t, 0, *packets)
+}
+
+func TestRTPInfo(t *testing.T) {
+	infos := parseRTPInfo("url=rtsp://192.168.1.123/stream/trackID=1;seq=12345;rtptime=3450012, url=trackID=2;seq=100")
+	assert.Len(t, infos, 2)
+	assert.Equal(t, uint16(12345), *infos[0].Seq)
+	assert.Equal(t, uint16(100), *infos[1].Seq)
+
+	base, err := urlParse("rtsp://192.168.1.123/stream")
+	assert.NoError(t, err)
+
+	c := &Conn{URL: base}
+	for _, id := range []string{"trackID=1", "trackID=2"} {
+		media := &core.Media{Kind: core.KindVideo, ID: id}
+		c.Medias = append(c.Medias, media)
+		c.Receivers = append(c.Receivers, core.NewReceiver(media, &core.Codec{Name: core.CodecH264}))
+	}
+
+	c.applyRTPInfo(infos)
+	assert.Equal(t, uint16(12345), *c.Receivers[0].StartSeq)
+	assert.Equal(t, uint16(100), *c.Receivers[1].StartSeq)
+}
+
+type captureConn struct {
+	net.Conn
+	writes [][]byte
+}
+
+func (c *captureConn) Write(b []byte) (int, error) {
+	c.writes = append(c.writes, append([]byte(nil), b...))
+	return len(b), nil
+}
+func (c *captureConn) SetWriteDeadline(time.Time) error { return nil }
+
+func TestCoalesce(t *testing.T) {
+	conn := &captureConn{}
+	c := &Conn{conn: conn, state: StatePlay, playOK: true, Coalesce: true}
+
+	codec := &core.Codec{Name: core.CodecH264, ClockRate: 90000, PayloadType: 96}
+	handler := c.packetWriter(codec, 0, 96)
+
+	for _, payload := range [][]byte{
+		{0x67, 0x64, 0x00, 0x1F}, // SPS
+		{0x68, 0xEE, 0x3C, 0x80}, // PPS
+		{0x65, 0x88, 0x84, 0x00}, // IDR
+	} {
+		handler(&rtp.Packet{
+			Header:  rtp.Header{Marker: payload[0] == 0x65, Timestamp: 1000},
+			Payload: payload,
+		})
+	}
+
+	// one write with STAP-A (SPS+PPS) and IDR packets
+	if !assert.Len(t, conn.writes, 1) {
+		return
+	}
+
+	var packets []*rtp.Packet
+	for b := conn.writes[0]; len(b) > 4; {
+		size := 4 + (int(b[2])<<8 | int(b[3]))
+		packet := &rtp.Packet{}
+		assert.Nil(t, packet.Unmarshal(b[4:size]))
+		packets = append(packets, packet)
+		b = b[size:]
+	}
+	if !assert.Len(t, packets, 2) {
+		return
+	}
+	assert.Equal(t, byte(24), packets[0].Payload[0]&0x1F) // STAP-A
+	assert.Equal(t, byte(h264.NALUTypeIFrame), packets[1].Payload[0]&0x1F)
+	assert.True(t, packets[1].Marker)
+}
+
+func TestMarker(t *testing.T) {
+	conn := &captureConn{}
+	c := &Conn{conn: conn, state: StatePlay, playOK: true}
+
+	// video marker is the access unit boundary, packets are sent as is
+	video := c.packetWriter(&core.Codec{Name: core.CodecH264, ClockRate: 90000, PayloadType: 96}, 0, 96)
+	video(&rtp.Packet{Header: rtp.Header{Timestamp: 1}, Payload: []byte{0x7C, 0x85, 0x00}})
+	video(&rtp.Packet{Header: rtp.Header{Timestamp: 1, Marker: true}, Payload: []byte{0x7C, 0x45, 0x00}})
+
+	if !assert.Len(t, conn.writes, 1) {
+		return
+	}
+	var markers []bool
+	for b := conn.writes[0]; len(b) > 4; {
+		size := 4 + (int(b[2])<<8 | int(b[3]))
+		packet := &rtp.Packet{}
+		assert.Nil(t, packet.Unmarshal(b[4:size]))
+		markers = append(markers, packet.Marker)
+		b = b[size:]
+	}
+	assert.Equal(t, []bool{false, true}, markers)
+
+	// audio always has marker on the wire, but source packet is not changed
+	audio := c.packetWriter(&core.Codec{Name: core.CodecPCMA, ClockRate: 8000, PayloadType: 8}, 2, 8)
+	packet := &rtp.Packet{Header: rtp.Header{Timestamp: 160}, Payload: []byte{1, 2, 3}}
+	audio(packet)
+
+	if !assert.Len(t, conn.writes, 2) {
+		return
+	}
+	clone := &rtp.Packet{}
+	assert.Nil(t, clone.Unmarshal(conn.writes[1][4:]))
+	assert.True(t, clone.Marker)
+	assert.False(t, packet.Marker)
+}
+
+func TestFeedback(t *testing.T) {
+	media := &core.Media{Kind: core.KindVideo, Feedback: []string{"nack", "nack pli", "goog-remb"}}
+
+	assert.Nil(t, parseFeedback("", media).names())
+	assert.Equal(t, []string{FeedbackPLI, FeedbackNACK}, parseFeedback("auto", media).names())
+	assert.Equal(t, []string{FeedbackPLI}, parseFeedback("auto,-nack", media).names())
+	assert.Equal(t, []string{FeedbackRR, FeedbackFIR}, parseFeedback("rr, fir", media).names())
+
+	conn := &captureConn{}
+	c := &Conn{conn: conn, Feedback: "pli,nack", ssrc: 1}
+	receiver := core.NewReceiver(media, &core.Codec{Name: core.CodecH264, ClockRate: 90000})
+	receiver.ID = 2
+	c.Receivers = []*core.Receiver{receiver}
+	c.losses = c.lossStates() // same as Handle
+
+	for _, seq := range []uint16{65534, 65535, 2, 1, 3} {
+		c.checkLoss(receiver, &rtp.Packet{Header: rtp.Header{SequenceNumber: seq, SSRC: 5}})
+	}
+
+	// one compound RTCP for lost 0 and 1, on the RTCP channel of the media
+	if !assert.Len(t, conn.writes, 1) {
+		return
+	}
+	b := conn.writes[0]
+	assert.Equal(t, byte(3), b[1])
+
+	packets, err := rtcp.Unmarshal(b[4:])
+	if !assert.Nil(t, err) || !assert.Len(t, packets, 2) {
+		return
+	}
+	nack := packets[0].(*rtcp.TransportLayerNack)
+	assert.Equal(t, uint32(5), nack.MediaSSRC)
+	assert.Equal(t, []uint16{0, 1}, nack.Nacks[0].PacketList())
+	assert.IsType(t, &rtcp.PictureLossIndication{}, packets[1])
+
+	// next keyframe request only after the interval
+	c.checkLoss(receiver, &rtp.Packet{Header: rtp.Header{SequenceNumber: 10, SSRC: 5}})
+	if assert.Len(t, conn.writes, 2) {
+		packets, _ = rtcp.Unmarshal(conn.writes[1][4:])
+		assert.Len(t, packets, 1)
+	}
+}
+
+func TestSSRCChange(t *testing.T) {
+	media := &core.Media{Kind: core.KindVideo}
+	conn := &captureConn{}
+	c := &Conn{conn: conn, Feedback: "nack", ssrc: 1, SSRCRewrite: true}
+	receiver := core.NewReceiver(media, &core.Codec{Name: core.CodecH264, ClockRate: 90000})
+	c.Receivers = []*core.Receiver{receiver}
+	c.losses = c.lossStates() // same as Handle
+	c.ssrcs = c.ssrcStates()
+
+	var changes []*SSRCChange
+	c.Listen(func(msg any) {
+		if msg, ok := msg.(*SSRCChange); ok {
+			changes = append(changes, msg)
+		}
+	})
+
+	var packets []rtp.Header
+	receiver.Input = func(packet *rtp.Packet) {
+		packets = append(packets, packet.Header)
+	}
+
+	write := func(seq uint16, ts, ssrc uint32) {
+		b, _ := (&rtp.Packet{
+			Header:  rtp.Header{Version: 2, PayloadType: 96, SequenceNumber: seq, Timestamp: ts, SSRC: ssrc},
+			Payload: []byte{0x41, 0x9A},
+		}).Marshal()
+		assert.Nil(t, c.handleRawPacket(0, b))
+	}
+
+	write(100, 3000, 5)
+	write(101, 6000, 5)
+
+	// encoder restart: new SSRC, sequence and timestamps
+	time.Sleep(10 * time.Millisecond)
+	write(20000, 90000, 7)
+	write(20001, 93000, 7)
+
+	if !assert.Len(t, packets, 4) || !assert.Len(t, changes, 1) {
+		return
+	}
+	assert.Equal(t, uint32(5), changes[0].Old)
+	assert.Equal(t, uint32(7), changes[0].New)
+	assert.True(t, changes[0].Rewrite)
+
+	// consumers get one continuous stream
+	for i, packet := range packets {
+		assert.Equal(t, uint32(5), packet.SSRC)
+		assert.Equal(t, uint16(100+i), packet.SequenceNumber)
+	}
+	pause := packets[2].Timestamp - packets[1].Timestamp
+	assert.True(t, pause >= 900 && pause < 90000, pause) // real pause between sources, 10ms or more
+	assert.Equal(t, uint32(3000), packets[3].Timestamp-packets[2].Timestamp)
+
+	// no NACK for the sequence jump, feedback goes to the new source
+	assert.Empty(t, conn.writes)
+	assert.Equal(t, uint32(7), c.losses[0].ssrc)
+
+	// old source is back, so it is several sources on one channel, leave as is
+	write(102, 9000, 5)
+	write(20002, 96000, 7)
+	assert.Len(t, changes, 1)
+	assert.Equal(t, uint16(102), packets[4].SequenceNumber)
+	assert.Equal(t, uint16(20002), packets[5].SequenceNumber)
+	assert.Equal(t, uint32(7), packets[5].SSRC)
+}
+
+// without the option consumers get the new source as is, the change is still fired
+func TestSSRCChangeNoRewrite(t *testing.T) {
+	c := &Conn{}
+	receiver := core.NewReceiver(&core.Media{Kind: core.KindVideo}, &core.Codec{Name: core.CodecH264, ClockRate: 90000})
+	c.Receivers = []*core.Receiver{receiver}
+	c.ssrcs = c.ssrcStates()
+
+	var changes []*SSRCChange
+	c.Listen(func(msg any) {
+		if msg, ok := msg.(*SSRCChange); ok {
+			changes = append(changes, msg)
+		}
+	})
+
+	var packets []rtp.Header
+	receiver.Input = func(packet *rtp.Packet) {
+		packets = append(packets, packet.Header)
+	}
+
+	for _, header := range []rtp.Header{
+		{Version: 2, PayloadType: 96, SequenceNumber: 100, Timestamp: 3000, SSRC: 5},
+		{Version: 2, PayloadType: 96, SequenceNumber: 20000, Timestamp: 90000, SSRC: 7},
+	} {
+		b, _ := (&rtp.Packet{Header: header, Payload: []byte{0x41, 0x9A}}).Marshal()
+		assert.Nil(t, c.handleRawPacket(0, b))
+	}
+
+	if !assert.Len(t, packets, 2) || !assert.Len(t, changes, 1) {
+		return
+	}
+	assert.False(t, changes[0].Rewrite)
+	assert.Equal(t, uint32(7), packets[1].SSRC)
+	assert.Equal(t, uint16(20000), packets[1].SequenceNumber)
+	assert.Equal(t, uint32(90000), packets[1].Timestamp)
+	assert.True(t, c.ssrcs[0].lastTime.IsZero()) // no clock reads without the rewrite
+}
+
+func TestRTX(t *testing.T) {
+	media := &core.Media{
+		Kind: core.KindVideo,
+		Codecs: []*core.Codec{
+			{Name: core.CodecH264, ClockRate: 90000, PayloadType: 96},
+			{Name: codecRTX, ClockRate: 90000, PayloadType: 97, FmtpLine: "apt=96;rtx-time=3000"},
+		},
+	}
+	assert.Equal(t, map[byte]byte{97: 96}, rtxApt(media))
+
+	// camera address for NACK
+	camera, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if !assert.Nil(t, err) {
+		return
+	}
+	defer camera.Close()
+	local, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if !assert.Nil(t, err) {
+		return
+	}
+	defer local.Close()
+
+	addr := camera.LocalAddr().(*net.UDPAddr)
+	c := &Conn{
+		RTX: true, Transport: "udp",
+		udpConn: []*net.UDPConn{local, local}, udpAddr: []*net.UDPAddr{addr, addr},
+	}
+	receiver := core.NewReceiver(media, media.Codecs[0])
+	c.Receivers = []*core.Receiver{receiver}
+
+	// RTX is opt-in and works only over UDP
+	assert.False(t, (&Conn{RTX: true}).useRTX(media))
+	c.rtx = c.rtxStates()
+	c.losses = c.lossStates()
+	assert.True(t, c.feedbackSet(media).nack)
+
+	var seqs []uint16
+	receiver.Input = func(packet *rtp.Packet) {
+		assert.Equal(t, uint8(96), packet.PayloadType)
+		assert.Equal(t, uint32(1), packet.SSRC)
+		assert.Equal(t, []byte{0x41, 0x9A}, packet.Payload)
+		seqs = append(seqs, packet.SequenceNumber)
+	}
+
+	write := func(pt uint8, seq uint16, ssrc uint32, payload []byte) {
+		b, _ := (&rtp.Packet{
+			Header:  rtp.Header{Version: 2, PayloadType: pt, SequenceNumber: seq, SSRC: ssrc},
+			Payload: payload,
+		}).Marshal()
+		assert.Nil(t, c.handleRawPacket(0, b))
+	}
+
+	write(96, 10, 1, []byte{0x41, 0x9A})
+	write(96, 12, 1, []byte{0x41, 0x9A})
+
+	// NACK for the lost packet
+	buf := make([]byte, 1500)
+	_ = camera.SetReadDeadline(time.Now().Add(time.Second))
+	n, err := camera.Read(buf)
+	if assert.Nil(t, err) {
+		packets, err := rtcp.Unmarshal(buf[:n])
+		if assert.Nil(t, err) && assert.Len(t, packets, 1) {
+			nack := packets[0].(*rtcp.TransportLayerNack)
+			assert.Equal(t, []uint16{11}, nack.Nacks[0].PacketList())
+		}
+	}
+
+	// retransmission with own SSRC and sequence, padding only RTX packet is skipped
+	write(97, 500, 2, []byte{0, 11, 0x41, 0x9A})
+	write(97, 501, 2, []byte{0, 0})
+	assert.Equal(t, []uint16{10, 12, 11}, seqs)
+}
+
+func TestClockAdapt(t *testing.T) {
+	a := &clockAdapter{rate: 16000, source: 16000}
+	now := time.Now()
+
+	var ts uint32 = 1000
+	var last uint32
+
+	// step - expected timestamps step of the output, zero - don't check
+	send := func(seconds int, rate, step uint32) (changed uint32) {
+		// 50 packets per second
+		for i := 0; i < seconds*50; i++ {
+			packet := &rtp.Packet{Header: rtp.Header{Timestamp: ts}}
+			if c := a.check(packet, now); c != 0 {
+				changed = c
+			}
+			if step != 0 && last != 0 {
+				assert.Equal(t, step, packet.Timestamp-last)
+			}
+			last = packet.Timestamp
+			ts += rate / 50
+			now = now.Add(20 * time.Millisecond)
+		}
+		return
+	}
+
+	assert.Zero(t, send(20, 16000, 320)) // 20ms at 16000
+	assert.Equal(t, uint32(16000), a.source)
+
+	// camera mode switch, the change is applied after three windows
+	changed := send(16, 8000, 0)
+	assert.Equal(t, uint32(8000), changed)
+	assert.Equal(t, uint32(8000), a.source)
+
+	// timestamps are rescaled back to the SDP clock rate
+	last = 0
+	assert.Zero(t, send(20, 8000, 320))
+
+	assert.Equal(t, uint32(44100), nearestRate(44000))
+	assert.Equal(t, uint32(48000), nearestRate(47500))
+	assert.Zero(t, nearestRate(60000))
+}
+
+func TestAVSync(t *testing.T) {
+	video := &core.Codec{Name: core.CodecH264, ClockRate: 90000}
+	audio := &core.Codec{Name: core.CodecPCMA, ClockRate: 8000}
+
+	c := &Conn{AVSync: 100 * time.Millisecond}
+	c.Receivers = []*core.Receiver{core.NewReceiver(nil, video), core.NewReceiver(nil, audio)}
+	c.Receivers[0].ID = 0
+	c.Receivers[1].ID = 2
+	c.sync = c.syncState()
+	assert.NotNil(t, c.sync)
+
+	var fixes []*AVSync
+	c.Listen(func(msg any) {
+		if msg, ok := msg.(*AVSync); ok {
+			fixes = append(fixes, msg)
+		}
+	})
+
+	report := func(channel byte, ssrc uint32, ntp uint64, ts uint32) {
+		b, _ := (&rtcp.SenderReport{SSRC: ssrc, NTPTime: ntp << 32, RTPTime: ts}).Marshal()
+		c.syncReport(channel, b)
+	}
+
+	var vts, ats uint32 = 1000, 5000
+	var dropped int
+
+	// 10 seconds, camera audio clock is 250ms ahead of video at the end
+	send := func(vstep, astep uint32) {
+		for i := 0; i < 500; i++ {
+			c.checkSync(c.Receivers[0], &rtp.Packet{Header: rtp.Header{SSRC: 1, Timestamp: vts}})
+			packet := &rtp.Packet{Header: rtp.Header{SSRC: 2, Timestamp: ats}}
+			if !c.checkSync(c.Receivers[1], packet) {
+				dropped++
+			}
+			vts += vstep
+			ats += astep
+		}
+	}
+
+	send(0, 0)
+	report(0, 1, 1000, vts)
+	report(2, 2, 1000, ats)
+	send(1800, 164) // 20ms of video and 20.5ms of audio
+	report(0, 1, 1010, vts)
+	report(2, 2, 1010, ats)
+	assert.Len(t, fixes, 1)
+	assert.Equal(t, 250*time.Millisecond, fixes[0].Drift)
+
+	// audio ahead of video is dropped
+	send(1800, 160)
+	assert.Equal(t, 12, dropped)
+
+	// drift less than threshold
+	report(0, 1, 1020, vts)
+	report(2, 2, 1020, ats+400)
+	assert.Len(t, fixes, 1)
+}
+
+func TestSenderReportClock(t *testing.T) {
+	codec := &core.Codec{Name: core.CodecH264, ClockRate: 90000}
+
+	c := &Conn{}
+	c.Receivers = []*core.Receiver{core.NewReceiver(nil, codec)}
+	sender := core.NewSender(nil, codec)
+	sender.WithParent(c.Receivers[0])
+	assert.Nil(t, sender.Clock())
+
+	// compound packet, sender report and SDES
+	ntp := uint64(3913056000)<<32 | 1<<31 // 2024-01-01 00:00:00.5 UTC
+	b, _ := rtcp.Marshal([]rtcp.Packet{
+		&rtcp.SenderReport{SSRC: 1, NTPTime: ntp, RTPTime: 12345},
+		&rtcp.SourceDescription{Chunks: []rtcp.SourceDescriptionChunk{{Source: 1}}},
+	})
+	c.handleRTCP(1, 0, b)
+
+	clock := sender.Clock()
+	assert.Equal(t, &core.Clock{SSRC: 1, RTPTime: 12345, Wall: time.Unix(1704067200, 500_000_000)}, clock)
+}
+
+func TestParamSets(t *testing.T) {
+	codec := &core.Codec{Name: core.CodecH264, ClockRate: 90000, PayloadType: 96}
+
+	var packets []*rtp.Packet
+	handler := paramsWriter(codec, time.Hour, func(packet *rtp.Packet) {
+		packets = append(packets, packet)
+	})
+
+	sps := []byte{0x67, 0x64, 0x00, 0x1F}
+	pps := []byte{0x68, 0xEE, 0x3C, 0x80}
+
+	// camera sends parameter sets only at the start
+	handler(&rtp.Packet{Header: rtp.Header{SequenceNumber: 1, Timestamp: 1}, Payload: sps})
+	handler(&rtp.Packet{Header: rtp.Header{SequenceNumber: 2, Timestamp: 1}, Payload: pps})
+	handler(&rtp.Packet{Header: rtp.Header{SequenceNumber: 3, Timestamp: 1, Marker: true}, Payload: []byte{0x65, 0x88, 0x84}})
+	assert.Len(t, packets, 3)
+
+	// next keyframe is inside the interval
+	handler(&rtp.Packet{Header: rtp.Header{SequenceNumber: 4, Timestamp: 2, Marker: true}, Payload: []byte{0x65, 0x88, 0x84}})
+	assert.Len(t, packets, 4)
+
+	// each keyframe without parameter sets, FU-A start and continuation
+	packets = nil
+	handler = paramsWriter(codec, 0, func(packet *rtp.Packet) {
+		packets = append(packets, packet)
+	})
+	handler(&rtp.Packet{Header: rtp.Header{SequenceNumber: 1, Timestamp: 1}, Payload: sps})
+	handler(&rtp.Packet{Header: rtp.Header{SequenceNumber: 2, Timestamp: 1}, Payload: pps})
+	handler(&rtp.Packet{Header: rtp.Header{SequenceNumber: 3, Timestamp: 1, Marker: true}, Payload: []byte{0x65, 0x88, 0x84}})
+	handler(&rtp.Packet{Header: rtp.Header{SequenceNumber: 4, Timestamp: 2}, Payload: []byte{0x7C, 0x85, 0x00}})
+	handler(&rtp.Packet{Header: rtp.Header{SequenceNumber: 5, Timestamp: 2, Marker: true}, Payload: []byte{0x7C, 0x45, 0x00}})
+	handler(&rtp.Packet{Header: rtp.Header{SequenceNumber: 6, Timestamp: 3, Marker: true}, Payload: []byte{0x41, 0x9A, 0x00}})
+
+	if !assert.Len(t, packets, 8) {
+		return
+	}
+	assert.Equal(t, sps, packets[3].Payload)
+	assert.Equal(t, pps, packets[4].Payload)
+	assert.Equal(t, uint32(2), packets[3].Timestamp)
+
+	var seqs []uint16
+	for _, packet := range packets {
+		seqs = append(seqs, packet.SequenceNumber)
+	}
+	assert.Equal(t, []uint16{1, 2, 3, 4, 5, 6, 7, 8}, seqs)
+
+	// parameter sets from SDP for AVCC source
+	codec = h264.AVCCToCodec(h264.JoinNALU(sps, pps))
+	codec.PayloadType = core.PayloadTypeRAW
+	handler = paramsWriter(codec, 0, func(packet *rtp.Packet) {
+		packets = append(packets, packet)
+	})
+	packets = nil
+	handler(&rtp.Packet{Payload: h264.JoinNALU([]byte{0x65, 0x88, 0x84})})
+	if assert.Len(t, packets, 1) {
+		assert.Equal(t, []byte{h264.NALUTypeSPS, h264.NALUTypePPS, h264.NALUTypeIFrame}, h264.NALUTypes(packets[0].Payload))
+	}
+}
+
+func TestRTCPAPP(t *testing.T) {
+	media := &core.Media{Kind: core.KindVideo}
+	c := &Conn{}
+	c.Receivers = []*core.Receiver{{Media: media, ID: 0}}
+
+	var apps []*APP
+	c.Listen(func(msg any) {
+		if app, ok := msg.(*APP); ok {
+			apps = append(apps, app)
+		}
+	})
+
+	// compound packet: receiver report + APP
+	rr, _ := (&rtcp.ReceiverReport{SSRC: 1}).Marshal()
+	app, _ := (&rtcp.ApplicationDefined{SubType: 1, SSRC: 2, Name: "MOTI", Data: []byte{1, 0, 0, 0}}).Marshal()
+	buf := append(rr, app...)
+
+	err := c.handleRawPacket(1, buf)
+	assert.Nil(t, err)
+
+	buf[len(buf)-4] = 0 // data must be copied
+
+	assert.Len(t, apps, 1)
+	assert.Equal(t, byte(1), apps[0].Channel)
+	assert.Equal(t, media, apps[0].Media)
+	assert.Equal(t, "MOTI", apps[0].Name)
+	assert.Equal(t, uint8(1), apps[0].SubType)
+	assert.Equal(t, []byte{1, 0, 0, 0}, apps[0].Data)
+}
+
+func TestRTCPChannels(t *testing.T) {
+	video := &core.Media{Kind: core.KindVideo}
+	audio := &core.Media{Kind: core.KindAudio}
+	c := &Conn{rtcpMap: map[byte]byte{7: 2}} // audio RTCP on channel 7 from SETUP
+	c.Receivers = []*core.Receiver{{Media: video, ID: 0}, {Media: audio, ID: 2}}
+
+	var channels []byte
+	c.Listen(func(msg any) {
+		if msg, ok := msg.(*RTCP); ok {
+			channels = append(channels, msg.Channel)
+		}
+	})
+
+	sr, _ := (&rtcp.SenderReport{SSRC: 1}).Marshal()
+
+	assert.Nil(t, c.handleRawPacket(1, sr))
+	assert.Nil(t, c.handleRawPacket(7, sr))
+	assert.Nil(t, c.handleRawPacket(0, sr)) // RTCP on the RTP channel
+	assert.Equal(t, []byte{1, 7, 0}, channels)
+
+	assert.Equal(t, byte(1), c.rtcpChannel(0))
+	assert.Equal(t, byte(7), c.rtcpChannel(2))
+
+	infos := c.rtcpInfo()
+	assert.Len(t, infos, 2)
+	assert.Equal(t, 2, infos[0].Received)
+	assert.Equal(t, 1, infos[1].Received)
+	assert.Equal(t, byte(7), infos[1].Channel)
+
+	// normal RTP is not RTCP
+	packet, _ := (&rtp.Packet{Header: rtp.Header{Version: 2, PayloadType: 96, Marker: true}}).Marshal()
+	assert.False(t, isRTCPMux(packet))
+}
+
+func TestFrameInfo(t *testing.T) {
+	s := `v=0
+o=- 0 0 IN IP4 0.0.0.0
+s=-
+t=0 0
+m=video 0 RTP/AVP 96
+a=rtpmap:96 H264/90000
+a=framerate:25
+a=framesize:96 1280-720
+a=control:trackID=0
+m=video 0 RTP/AVP 26
+a=rtpmap:26 JPEG/90000
+a=x-dimensions:640,480
+a=control:trackID=1
+m=video 0 RTP/AVP 96
+a=rtpmap:96 H264/90000
+a=fmtp:96 packetization-mode=1;profile-level-id=42001E;sprop-parameter-sets=Z0IAHvQCgC3I,aM48gA==
+a=control:trackID=2
+`
+	medias, err := UnmarshalSDP([]byte(s))
+	assert.Nil(t, err)
+	assert.Len(t, medias, 3)
+
+	assert.Equal(t, &core.VideoInfo{Width: 1280, Height: 720, FrameRate: 25}, medias[0].Video)
+	assert.Equal(t, "video, recvonly, H264", medias[0].String()) // info is not in the media name
+
+	assert.Equal(t, &core.VideoInfo{Width: 640, Height: 480}, medias[1].Video)
+
+	// fallback to SPS from fmtp
+	assert.Equal(t, 1280, medias[2].Video.Width)
+	assert.Equal(t, 720, medias[2].Video.Height)
+
+	probes := (&Conn{Connection: core.Connection{Medias: medias}}).Probe()
+	assert.Equal(t, medias[0].Video, probes[0].Video)
+}
@@ -0,0 +1,82 @@
+package rtsp
+
+import (
+	"encoding/binary"
+	"strings"
+
+	"github.com/AlexxIT/go2rtc/pkg/core"
+	"github.com/pion/rtp"
+)
+
+const codecRTX = "RTX"
+
+// rtxState - RTX streams of the media https://www.rfc-editor.org/rfc/rfc4588
+type rtxState struct {
+	apt  map[byte]byte // RTX payload type => associated payload type from a=fmtp
+	ssrc uint32        // SSRC of the original stream, from the last original packet
+}
+
+// rtxApt - RTX payload types of the media, nil if camera doesn't advertise RTX
+func rtxApt(media *core.Media) map[byte]byte {
+	var apt map[byte]byte
+	for _, codec := range media.Codecs {
+		if codec.Name != codecRTX {
+			continue
+		}
+		for _, param := range strings.Split(codec.FmtpLine, ";") {
+			if s, ok := strings.CutPrefix(strings.TrimSpace(param), "apt="); ok {
+				if apt == nil {
+					apt = map[byte]byte{}
+				}
+				apt[codec.PayloadType] = byte(core.Atoi(s))
+			}
+		}
+	}
+	return apt
+}
+
+// useRTX - RTX option works only over UDP and only for medias with RTX in SDP
+func (c *Conn) useRTX(media *core.Media) bool {
+	return c.RTX && c.Transport == "udp" && media != nil && rtxApt(media) != nil
+}
+
+// rtxStates - RTX streams for each media channel
+func (c *Conn) rtxStates() map[byte]*rtxState {
+	var states map[byte]*rtxState
+	for _, receiver := range c.Receivers {
+		if !c.useRTX(receiver.Media) {
+			continue
+		}
+		if states == nil {
+			states = map[byte]*rtxState{}
+		}
+		states[receiver.ID] = &rtxState{apt: rtxApt(receiver.Media)}
+	}
+	return states
+}
+
+// restoreRTX - change the retransmitted packet back to the original one,
+// returns false for packets without the original payload (bandwidth probing)
+func (c *Conn) restoreRTX(channel byte, packet *rtp.Packet) bool {
+	state := c.rtx[channel]
+	if state == nil {
+		return true
+	}
+
+	apt, ok := state.apt[packet.PayloadType]
+	if !ok {
+		state.ssrc = packet.SSRC
+		return true
+	}
+
+	if len(packet.Payload) <= 2 {
+		return false
+	}
+
+	// RTX payload starts with the original sequence number
+	packet.SequenceNumber = binary.BigEndian.Uint16(packet.Payload)
+	packet.Payload = packet.Payload[2:]
+	packet.PayloadType = apt
+	packet.SSRC = state.ssrc
+	return true
+}
@@ -0,0 +1,95 @@
+package rtsp
+
+import (
+	"math"
+	"strconv"
+
+	"github.com/pion/rtp"
+)
+
+// Playback - server answered PLAY with the trick-play rate (Scale header),
+// ex. 2 - fast forward, -1 - rewind. RTP timestamps are rescaled by the rate,
+// so consumers render the altered-rate playback in real time.
+type Playback struct {
+	Scale float64 // requested rate
+	Real  float64 // rate from the server, can differ from the requested one
+}
+
+// scaleState - rescale timestamps of one receiver: out = out0 + (ts - in0) / rate
+type scaleState struct {
+	in0, out0 uint32
+	rate      float64
+	started   bool
+}
+
+func (s *scaleState) rescale(ts uint32, rate float64) uint32 {
+	rate = math.Abs(rate)
+
+	if !s.started {
+		s.in0, s.out0, s.rate, s.started = ts, ts, rate, true
+	} else if rate != s.rate {
+		// new PLAY with the other rate, continue from the current position
+		s.in0, s.out0, s.rate = ts, s.out(ts), rate
+	}
+
+	out := s.out(ts)
+
+	// signed difference for B-frames, move the base before it overflows
+	if int32(ts-s.in0) > 1<<30 {
+		s.in0, s.out0 = ts, out
+	}
+
+	return out
+}
+
+func (s *scaleState) out(ts uint32) uint32 {
+	return s.out0 + uint32(int64(float64(int32(ts-s.in0))/s.rate))
+}
+
+// useScale - trick-play rate is requested, zero and one are normal playback
+func (c *Conn) useScale() bool {
+	return c.Scale != 0 && c.Scale != 1
+}
+
+func formatScale(rate float64) string {
+	return strconv.FormatFloat(rate, 'f', -1, 64)
+}
+
+// scaleStates - created before the read loop, because UDP readers work in parallel
+func (c *Conn) scaleStates() map[byte]*scaleState {
+	if !c.useScale() {
+		return nil
+	}
+	states := map[byte]*scaleState{}
+	for _, receiver := range c.Receivers {
+		states[receiver.ID] = &scaleState{}
+	}
+	return states
+}
+
+// applyScale - rate from the PLAY response, the requested one if server doesn't answer it
+func (c *Conn) applyScale(s string) {
+	rate, err := strconv.ParseFloat(s, 64)
+	if err != nil || rate == 0 {
+		return
+	}
+	c.playRate.Store(math.Float64bits(rate))
+	c.Fire(&Playback{Scale: c.Scale, Real: rate})
+}
+
+// PlayRate - current trick-play rate of the session, 1 - normal playback
+func (c *Conn) PlayRate() float64 {
+	if bits := c.playRate.Load(); bits != 0 {
+		return math.Float64frombits(bits)
+	}
+	if c.useScale() {
+		return c.Scale
+	}
+	return 1
+}
+
+func (c *Conn) scaleTimestamp(channel byte, packet *rtp.Packet) {
+	if state := c.scales[channel]; state != nil {
+		packet.Timestamp = state.rescale(packet.Timestamp, c.PlayRate())
+	}
+}
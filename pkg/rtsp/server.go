@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"net"
 	"net/url"
+	"os"
 	"strconv"
 	"strings"
 
@@ -15,6 +16,9 @@ import (
 
 var FailedAuth = errors.New("failed authentication")
 
+// ErrSetupTimeout - client did SETUP, but didn't PLAY or RECORD in time
+var ErrSetupTimeout = errors.New("no PLAY after SETUP")
+
 func NewServer(conn net.Conn) *Conn {
 	return &Conn{
 		Connection: core.Connection{
@@ -35,8 +39,17 @@ func (c *Conn) Auth(username, password string) {
 
 func (c *Conn) Accept() error {
 	for {
-		req, err := c.ReadRequest()
+		timeout := Timeout
+		if c.state == StateSetup && c.SetupTimeout != 0 {
+			timeout = c.SetupTimeout
+		}
+
+		// the deadline is reset on each client request
+		req, err := c.readRequest(timeout)
 		if err != nil {
+			if c.state == StateSetup && errors.Is(err, os.ErrDeadlineExceeded) {
+				return fmt.Errorf("%w: %w", ErrSetupTimeout, err)
+			}
 			return err
 		}
 
@@ -70,7 +83,7 @@ func (c *Conn) Accept() error {
 		case MethodOptions:
 			res := &tcp.Response{
 				Header: map[string][]string{
-					"Public": {"OPTIONS, SETUP, TEARDOWN, DESCRIBE, PLAY, PAUSE, ANNOUNCE, RECORD"},
+					"Public": {"OPTIONS, SETUP, TEARDOWN, DESCRIBE, PLAY, PAUSE, ANNOUNCE, RECORD, GET_PARAMETER"},
 				},
 				Request: req,
 			}
@@ -78,12 +91,20 @@ func (c *Conn) Accept() error {
 				return err
 			}
 
+		case MethodGetParameter:
+			// keepalive before PLAY, ex. slow client between SETUP and PLAY
+			res := &tcp.Response{Request: req}
+			if err = c.WriteResponse(res); err != nil {
+				return err
+			}
+
 		case MethodAnnounce:
 			if req.Header.Get("Content-Type") != "application/sdp" {
 				return errors.New("wrong content type")
 			}
 
 			c.SDP = string(req.Body) // for info
+			c.rawSDP = req.Body
 
 			c.Medias, err = UnmarshalSDP(req.Body)
 			if err != nil {
@@ -107,6 +128,8 @@ func (c *Conn) Accept() error {
 
 		case MethodDescribe:
 			c.mode = core.ModePassiveConsumer
+			// ONVIF client (ex. VMS) wants to send talkback audio
+			c.Backchannel = strings.Contains(req.Header.Get("Require"), requireBackchannel)
 			c.Fire(MethodDescribe)
 
 			if c.Senders == nil {
@@ -137,6 +160,9 @@ func (c *Conn) Accept() error {
 			}
 
 			for i, track := range c.Receivers {
+				// backchannel goes after all senders, so channel is known only now
+				track.ID = byte(i+len(c.Senders)) * 2
+
 				media := &core.Media{
 					Kind:      core.GetKind(track.Codec.Name),
 					Direction: core.DirectionSendonly,
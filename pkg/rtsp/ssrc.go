@@ -0,0 +1,105 @@
+package rtsp
+
+import (
+	"time"
+
+	"github.com/AlexxIT/go2rtc/pkg/core"
+	"github.com/pion/rtp"
+)
+
+// SSRCChange - source changed the SSRC of the media in the middle of the session
+// (ex. camera restarted the encoder), the stream continues without the reconnect.
+// Without Rewrite consumers get the new SSRC, sequence and timestamps as is.
+type SSRCChange struct {
+	Media   *core.Media
+	Codec   *core.Codec
+	Old     uint32
+	New     uint32
+	Rewrite bool // consumers get the first SSRC with continuous sequence and timestamps
+}
+
+// ssrcState - detect the new source of the media, with the rewrite option continue
+// the sequence and timestamps of the first one, so consumers see one continuous stream
+type ssrcState struct {
+	rewrite bool
+
+	ssrc    uint32 // first SSRC, consumers always get it
+	current uint32
+	prev    uint32
+
+	seqShift uint16
+	tsShift  uint32
+
+	lastSeq  uint16 // last output values
+	lastTS   uint32
+	lastTime time.Time
+
+	started bool
+	mixed   bool // several sources on one channel, not a restart, leave as is
+}
+
+// check - returns the old SSRC on the change, now is used only with the rewrite
+func (s *ssrcState) check(packet *rtp.Packet, clockRate uint32, now time.Time) (changed uint32, ok bool) {
+	if s.mixed {
+		return
+	}
+
+	if !s.started {
+		s.ssrc, s.current, s.started = packet.SSRC, packet.SSRC, true
+	} else if packet.SSRC != s.current {
+		if packet.SSRC == s.prev {
+			// restarted encoder never returns to the old SSRC
+			s.mixed = true
+			return
+		}
+
+		if s.rewrite {
+			// next sequence and the timestamp with the real pause between the sources
+			ts := s.lastTS + 1
+			if clockRate != 0 {
+				ts = s.lastTS + uint32(uint64(max(now.Sub(s.lastTime), 0))*uint64(clockRate)/uint64(time.Second))
+			}
+			s.seqShift = s.lastSeq + 1 - packet.SequenceNumber
+			s.tsShift = ts - packet.Timestamp
+		}
+
+		changed, ok = s.current, true
+		s.prev, s.current = s.current, packet.SSRC
+	}
+
+	if !s.rewrite {
+		return
+	}
+
+	packet.SSRC = s.ssrc
+	packet.SequenceNumber += s.seqShift
+	packet.Timestamp += s.tsShift
+
+	s.lastSeq, s.lastTS, s.lastTime = packet.SequenceNumber, packet.Timestamp, now
+	return
+}
+
+// ssrcStates - created before the read loop, because UDP readers work in parallel
+func (c *Conn) ssrcStates() map[byte]*ssrcState {
+	states := map[byte]*ssrcState{}
+	for _, receiver := range c.Receivers {
+		states[receiver.ID] = &ssrcState{rewrite: c.SSRCRewrite}
+	}
+	return states
+}
+
+func (c *Conn) checkSSRC(receiver *core.Receiver, packet *rtp.Packet) {
+	state := c.ssrcs[receiver.ID]
+	if state == nil {
+		return
+	}
+
+	var now time.Time
+	if state.rewrite {
+		now = time.Now() // for the real pause between the sources
+	}
+
+	if old, ok := state.check(packet, receiver.Codec.ClockRate, now); ok {
+		c.Fire(&SSRCChange{Media: c.channels[receiver.ID], Codec: receiver.Codec, Old: old, New: state.current, Rewrite: state.rewrite})
+	}
+}
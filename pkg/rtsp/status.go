@@ -0,0 +1,59 @@
+package rtsp
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/AlexxIT/go2rtc/pkg/core"
+	"github.com/AlexxIT/go2rtc/pkg/tcp"
+)
+
+// Actions on the response status code, for cameras with own error quirks
+const (
+	StatusRetry     = "retry"     // transient error, reconnect without the backoff delay
+	StatusFatal     = "fatal"     // permanent error, stop reconnects
+	StatusSubstream = "substream" // switch to the Fallback URL
+)
+
+// ErrSubstream - response status code with the substream action
+var ErrSubstream = errors.New("substream status")
+
+// ParseStatusActions - ex. "500:retry,503:retry,404:fatal,453:substream",
+// unknown actions and wrong codes are skipped
+func ParseStatusActions(s string) map[int]string {
+	var actions map[int]string
+	for _, item := range strings.Split(s, ",") {
+		code, action, _ := strings.Cut(strings.TrimSpace(item), ":")
+		switch action {
+		case StatusRetry, StatusFatal, StatusSubstream:
+		default:
+			continue
+		}
+		if i := core.Atoi(code); i >= 300 && i < 1000 {
+			if actions == nil {
+				actions = map[int]string{}
+			}
+			actions[i] = action
+		}
+	}
+	return actions
+}
+
+// statusError - error for the configured status action, nil if the code has no action
+func (c *Conn) statusError(req *tcp.Request, res *tcp.Response) error {
+	switch c.StatusActions[res.StatusCode] {
+	case StatusRetry:
+		return fmt.Errorf("%w: status %d on %s", core.ErrReconnect, res.StatusCode, req.Method)
+	case StatusFatal:
+		return fmt.Errorf("%w: status %d on %s", core.ErrNoReconnect, res.StatusCode, req.Method)
+	case StatusSubstream:
+		return fmt.Errorf("%w: %d on %s", ErrSubstream, res.StatusCode, req.Method)
+	}
+	return nil
+}
+
+// isFallback - error should switch the source to the substream
+func isFallback(err error) bool {
+	return errors.Is(err, ErrNotEnoughBandwidth) || errors.Is(err, ErrSubstream)
+}
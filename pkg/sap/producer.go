@@ -0,0 +1,160 @@
+package sap
+
+import (
+	"bytes"
+	"errors"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/AlexxIT/go2rtc/pkg/core"
+	"github.com/AlexxIT/go2rtc/pkg/rtsp"
+	"github.com/pion/rtp"
+	"github.com/pion/sdp/v3"
+)
+
+// Producer - RTP of the announced session from the multicast groups, one for each media
+type Producer struct {
+	core.Connection
+	conns []*net.UDPConn
+	mu    sync.Mutex // Recv from the parallel readers
+}
+
+func NewProducer(rawSDP []byte) (*Producer, error) {
+	sd := &sdp.SessionDescription{}
+	if err := sd.Unmarshal(rawSDP); err != nil {
+		return nil, err
+	}
+
+	addrs, err := mediaAddrs(sd)
+	if err != nil {
+		return nil, err
+	}
+
+	medias, err := rtsp.UnmarshalSDP(rawSDP)
+	if err != nil {
+		return nil, err
+	}
+	for _, media := range medias {
+		media.Direction = core.DirectionRecvonly // announcements can be from the sender side
+	}
+
+	p := &Producer{
+		Connection: core.Connection{
+			ID:         core.NewID(),
+			FormatName: "sap",
+			Protocol:   "udp",
+			RemoteAddr: addrs[0].String(),
+			SDP:        string(rawSDP),
+			Medias:     medias,
+		},
+	}
+
+	for _, addr := range addrs {
+		conn, err := net.ListenMulticastUDP("udp", nil, addr)
+		if err != nil {
+			_ = p.Stop()
+			return nil, err
+		}
+		p.conns = append(p.conns, conn)
+	}
+
+	return p, nil
+}
+
+// Start - read all medias, exit on the first error (ex. Stop)
+func (p *Producer) Start() error {
+	errs := make(chan error, len(p.conns))
+	for i, conn := range p.conns {
+		go func() { errs <- p.read(conn, p.Medias[i]) }()
+	}
+	err := <-errs
+	for _, conn := range p.conns {
+		_ = conn.Close() // stop other readers
+	}
+	return err
+}
+
+func (p *Producer) Stop() error {
+	for _, conn := range p.conns {
+		_ = conn.Close()
+	}
+	return p.Connection.Stop()
+}
+
+func (p *Producer) read(conn *net.UDPConn, media *core.Media) error {
+	// tracks are known before Start
+	var receivers []*core.Receiver
+	for _, receiver := range p.Receivers {
+		if receiver.Media == media {
+			receivers = append(receivers, receiver)
+		}
+	}
+
+	b := make([]byte, 64*1024)
+	for {
+		n, err := conn.Read(b)
+		if err != nil {
+			return err
+		}
+
+		p.mu.Lock()
+		p.Recv += n
+		p.mu.Unlock()
+
+		// new memory for each packet, consumers can keep it in queues
+		packet := &rtp.Packet{}
+		if err = packet.Unmarshal(bytes.Clone(b[:n])); err != nil {
+			continue // not RTP on the group port
+		}
+
+		for _, receiver := range receivers {
+			if receiver.Codec.PayloadType == packet.PayloadType {
+				receiver.WriteRTP(packet)
+				break
+			}
+		}
+	}
+}
+
+var errAddress = errors.New("sap: no multicast address for media")
+
+// mediaAddrs - group and port of each media, media connection line has priority
+func mediaAddrs(sd *sdp.SessionDescription) ([]*net.UDPAddr, error) {
+	if len(sd.MediaDescriptions) == 0 {
+		return nil, errors.New("sap: no medias")
+	}
+
+	addrs := make([]*net.UDPAddr, 0, len(sd.MediaDescriptions))
+	for _, md := range sd.MediaDescriptions {
+		info := md.ConnectionInformation
+		if info == nil {
+			info = sd.ConnectionInformation
+		}
+		if info == nil || info.Address == nil {
+			return nil, errAddress
+		}
+
+		// address with TTL ex. 239.1.1.1/127
+		host, _, _ := strings.Cut(info.Address.Address, "/")
+		ip := net.ParseIP(host)
+		if ip == nil || !ip.IsMulticast() {
+			return nil, errAddress
+		}
+
+		addrs = append(addrs, &net.UDPAddr{IP: ip, Port: md.MediaName.Port.Value})
+	}
+	return addrs, nil
+}
+
+// Session - ID and name of the announced session, ID is the same for all SDP versions
+func Session(rawSDP []byte) (id, name string, err error) {
+	sd := &sdp.SessionDescription{}
+	if err = sd.Unmarshal(rawSDP); err != nil {
+		return
+	}
+	o := sd.Origin
+	id = o.Username + " " + strconv.FormatUint(o.SessionID, 10) + " " + o.UnicastAddress
+	return id, string(sd.SessionName), nil
+}
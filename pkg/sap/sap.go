@@ -0,0 +1,89 @@
+package sap
+
+import (
+	"bytes"
+	"compress/zlib"
+	"errors"
+	"io"
+	"net"
+)
+
+// DefaultAddress - SAP announcements of the global scope multicast sessions
+// https://datatracker.ietf.org/doc/html/rfc2974
+const DefaultAddress = "224.2.127.254:9875"
+
+// Announcement - SAP packet with the SDP of the multicast session
+type Announcement struct {
+	Delete bool   // session deletion packet
+	Hash   uint16 // message ID hash, changes with SDP
+	Origin net.IP // originating source
+	SDP    []byte
+}
+
+var (
+	errShort     = errors.New("sap: packet too short")
+	errVersion   = errors.New("sap: unsupported version")
+	errEncrypted = errors.New("sap: encrypted payload not supported")
+	errPayload   = errors.New("sap: unsupported payload type")
+	errTooBig    = errors.New("sap: decompressed payload too big")
+)
+
+// maxPayload - RFC 2974 recommends announcements under 1 KB, so a few KB
+// of the decompressed SDP is plenty and stops the zlib bombs
+const maxPayload = 8 * 1024
+
+// Unmarshal - header, optional authentication data and payload type, then the payload
+func Unmarshal(b []byte) (*Announcement, error) {
+	if len(b) < 4 {
+		return nil, errShort
+	}
+
+	if b[0]>>5 != 1 {
+		return nil, errVersion
+	}
+	if b[0]&0x02 != 0 {
+		return nil, errEncrypted
+	}
+
+	a := &Announcement{
+		Delete: b[0]&0x04 != 0,
+		Hash:   uint16(b[2])<<8 | uint16(b[3]),
+	}
+
+	size := 4 // IPv4 originating source
+	if b[0]&0x10 != 0 {
+		size = 16
+	}
+	i := 4 + size + int(b[1])*4 // auth length in 32-bit words
+	if len(b) < i {
+		return nil, errShort
+	}
+	a.Origin = net.IP(b[4 : 4+size])
+
+	payload := b[i:]
+
+	if b[0]&0x01 != 0 {
+		r, err := zlib.NewReader(bytes.NewReader(payload))
+		if err != nil {
+			return nil, err
+		}
+		if payload, err = io.ReadAll(io.LimitReader(r, maxPayload+1)); err != nil {
+			return nil, err
+		}
+		if len(payload) > maxPayload {
+			return nil, errTooBig
+		}
+	}
+
+	// payload type is optional, SDP always starts with "v="
+	if !bytes.HasPrefix(payload, []byte("v=")) {
+		j := bytes.IndexByte(payload, 0)
+		if j < 0 || string(payload[:j]) != "application/sdp" {
+			return nil, errPayload
+		}
+		payload = payload[j+1:]
+	}
+
+	a.SDP = payload
+	return a, nil
+}
@@ -0,0 +1,86 @@
+package sap
+
+import (
+	"bytes"
+	"compress/zlib"
+	"net"
+	"testing"
+
+	"github.com/pion/sdp/v3"
+	"github.com/stretchr/testify/require"
+)
+
+const testSDP = "v=0\r\n" +
+	"o=- 1234 2 IN IP4 192.168.1.10\r\n" +
+	"s=Lobby camera\r\n" +
+	"c=IN IP4 239.1.1.1/127\r\n" +
+	"t=0 0\r\n" +
+	"m=video 5004 RTP/AVP 96\r\n" +
+	"a=rtpmap:96 H264/90000\r\n" +
+	"m=audio 5006 RTP/AVP 8\r\n" +
+	"c=IN IP4 239.1.1.2/127\r\n"
+
+func TestUnmarshal(t *testing.T) {
+	// V=1, IPv4, announcement, auth 1 word, hash, origin, auth data, payload type
+	b := []byte{0x20, 1, 0xAB, 0xCD, 192, 168, 1, 10, 0, 0, 0, 0}
+	b = append(b, "application/sdp\x00"+testSDP...)
+
+	a, err := Unmarshal(b)
+	require.Nil(t, err)
+	require.False(t, a.Delete)
+	require.Equal(t, uint16(0xABCD), a.Hash)
+	require.Equal(t, "192.168.1.10", a.Origin.String())
+	require.Equal(t, testSDP, string(a.SDP))
+
+	// deletion, compressed, without payload type
+	var buf bytes.Buffer
+	w := zlib.NewWriter(&buf)
+	_, _ = w.Write([]byte(testSDP))
+	_ = w.Close()
+
+	b = append([]byte{0x25, 0, 0xAB, 0xCD, 192, 168, 1, 10}, buf.Bytes()...)
+	a, err = Unmarshal(b)
+	require.Nil(t, err)
+	require.True(t, a.Delete)
+	require.Equal(t, testSDP, string(a.SDP))
+
+	// zlib bomb: tiny packet with megabytes of the payload
+	buf.Reset()
+	w = zlib.NewWriter(&buf)
+	_, _ = w.Write([]byte(testSDP))
+	_, _ = w.Write(make([]byte, 1<<20))
+	_ = w.Close()
+
+	b = append([]byte{0x21, 0, 0xAB, 0xCD, 192, 168, 1, 10}, buf.Bytes()...)
+	_, err = Unmarshal(b)
+	require.Equal(t, errTooBig, err)
+
+	_, err = Unmarshal([]byte{0x22, 0, 0, 0, 192, 168, 1, 10})
+	require.Equal(t, errEncrypted, err)
+	_, err = Unmarshal([]byte{0x20, 0, 0, 0, 192, 168, 1, 10, 't', 'e', 'x', 't', 0})
+	require.Equal(t, errPayload, err)
+	_, err = Unmarshal([]byte{0x20, 2, 0, 0, 192, 168, 1, 10})
+	require.Equal(t, errShort, err)
+}
+
+func TestMediaAddrs(t *testing.T) {
+	sd := &sdp.SessionDescription{}
+	require.Nil(t, sd.Unmarshal([]byte(testSDP)))
+
+	addrs, err := mediaAddrs(sd)
+	require.Nil(t, err)
+	require.Equal(t, []*net.UDPAddr{
+		{IP: net.ParseIP("239.1.1.1"), Port: 5004},
+		{IP: net.ParseIP("239.1.1.2"), Port: 5006},
+	}, addrs)
+
+	// unicast session is not for the discovery
+	sd.ConnectionInformation.Address.Address = "192.168.1.10"
+	_, err = mediaAddrs(sd)
+	require.Equal(t, errAddress, err)
+
+	id, name, err := Session([]byte(testSDP))
+	require.Nil(t, err)
+	require.Equal(t, "- 1234 192.168.1.10", id)
+	require.Equal(t, "Lobby camera", name)
+}
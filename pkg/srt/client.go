@@ -0,0 +1,426 @@
+package srt
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/AlexxIT/go2rtc/pkg/core"
+)
+
+const (
+	ctrlDropReq = 0x0007
+
+	userKMReq = 3
+	userKMRsp = 4
+
+	defaultLatency = 120 * time.Millisecond
+	ackInterval    = 10 * time.Millisecond
+	keepalive      = time.Second
+	flowWindow     = 8192
+)
+
+// Conn - SRT caller in live mode, receives the stream as io.Reader with
+// ordered payloads of data packets (usually MPEG-TS)
+type Conn struct {
+	conn    *net.UDPConn
+	start   time.Time
+	latency time.Duration
+
+	socketID uint32
+	peerID   uint32
+
+	crypto *crypto
+
+	nextSeq uint32             // next expected sequence number
+	maxSeq  uint32             // highest received sequence number
+	pending map[uint32]*packet // out of order packets
+	ready   [][]byte           // ordered payloads for Read
+	payload []byte             // rest of current payload
+
+	ackNo    uint32
+	ackSeq   uint32
+	lastSend time.Time
+	lastRecv time.Time
+
+	buf []byte
+}
+
+type packet struct {
+	payload []byte
+	recv    time.Time
+}
+
+// Dial - srt://host:port?streamid=xxx&passphrase=xxx&latency=120
+func Dial(rawURL string) (*Conn, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+
+	addr, err := net.ResolveUDPAddr("udp", u.Host)
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := net.DialUDP("udp", nil, addr)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &Conn{
+		conn:     conn,
+		start:    time.Now(),
+		latency:  defaultLatency,
+		socketID: rand.Uint32() & seqMask,
+		pending:  map[uint32]*packet{},
+		buf:      make([]byte, MTU),
+	}
+
+	query := u.Query()
+
+	if s := query.Get("latency"); s != "" {
+		ms, _ := strconv.Atoi(s)
+		c.latency = time.Duration(ms) * time.Millisecond
+	}
+
+	if s := query.Get("passphrase"); s != "" {
+		c.crypto = &crypto{passphrase: s}
+	}
+
+	if err = c.handshake(query.Get("streamid")); err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+
+	return c, nil
+}
+
+func (c *Conn) handshake(streamID string) error {
+	initSeq := rand.Uint32() & seqMask
+
+	req := &handshake{
+		version:    4,
+		extension:  2, // UDT4 DGRAM
+		initSeq:    initSeq,
+		mtu:        MTU,
+		flowWindow: flowWindow,
+		typ:        hsInduction,
+		socketID:   c.socketID,
+	}
+
+	res, err := c.roundTrip(req)
+	if err != nil {
+		return err
+	}
+
+	if res.version != 5 || res.extension != hsMagic {
+		return errors.New("srt: unsupported listener version")
+	}
+
+	req.version = 5
+	req.extension = extFlagHS
+	req.typ = hsConclusion
+	req.cookie = res.cookie
+
+	latency := uint32(c.latency.Milliseconds())
+	ext := binary.BigEndian.AppendUint32(nil, srtVersion)
+	ext = binary.BigEndian.AppendUint32(ext, hsFlags)
+	ext = binary.BigEndian.AppendUint32(ext, latency<<16|latency)
+	req.exts = map[uint16][]byte{extHSReq: ext}
+
+	if c.crypto != nil {
+		const keyLen = 16 // AES-128
+		km, err := c.crypto.newKeyMaterial(keyLen)
+		if err != nil {
+			return err
+		}
+		req.encryption = keyLen / 8
+		req.extension |= extFlagKM
+		req.exts[extKMReq] = km
+	}
+
+	if streamID != "" {
+		req.extension |= extFlagSID
+		req.exts[extSID] = encodeSID(streamID)
+	}
+
+	if res, err = c.roundTrip(req); err != nil {
+		return err
+	}
+
+	if ext, ok := res.exts[extKMRsp]; ok && len(ext) == 4 {
+		if binary.BigEndian.Uint32(ext) == 3 {
+			return errBadSecret
+		}
+		return errors.New("srt: encryption not supported")
+	} else if c.crypto != nil && !ok {
+		return errors.New("srt: listener without encryption")
+	}
+
+	c.peerID = res.socketID
+	c.nextSeq = res.initSeq
+	c.maxSeq = (c.nextSeq - 1) & seqMask
+	c.ackSeq = c.nextSeq
+	c.lastRecv = time.Now()
+
+	return nil
+}
+
+// roundTrip - send handshake request and wait the response, UDP can lose
+// packets, so the request is repeated until timeout
+func (c *Conn) roundTrip(req *handshake) (*handshake, error) {
+	b := appendControl(nil, ctrlHandshake, 0, 0, c.timestamp(), 0)
+	b = req.marshal(b)
+
+	deadline := time.Now().Add(core.ConnDialTimeout)
+
+	for time.Now().Before(deadline) {
+		if _, err := c.conn.Write(b); err != nil {
+			return nil, err
+		}
+
+		_ = c.conn.SetReadDeadline(time.Now().Add(250 * time.Millisecond))
+
+		for {
+			n, err := c.conn.Read(c.buf)
+			if err != nil {
+				if ne, ok := err.(net.Error); ok && ne.Timeout() {
+					break
+				}
+				return nil, err
+			}
+
+			var h header
+			if !h.unmarshal(c.buf[:n]) || !h.control || h.typ != ctrlHandshake || h.socketID != c.socketID {
+				continue
+			}
+
+			res := &handshake{}
+			if !res.unmarshal(c.buf[HeaderSize:n]) {
+				return nil, errors.New("srt: wrong handshake")
+			}
+
+			if res.rejected() {
+				return nil, fmt.Errorf("srt: connection rejected: %d", res.typ)
+			}
+
+			if res.typ != req.typ {
+				continue
+			}
+
+			return res, nil
+		}
+	}
+
+	return nil, errors.New("srt: handshake timeout")
+}
+
+func (c *Conn) Read(p []byte) (int, error) {
+	for len(c.payload) == 0 {
+		if len(c.ready) > 0 {
+			c.payload = c.ready[0]
+			c.ready = c.ready[1:]
+			break
+		}
+
+		if err := c.receive(); err != nil {
+			return 0, err
+		}
+	}
+
+	n := copy(p, c.payload)
+	c.payload = c.payload[n:]
+	return n, nil
+}
+
+// receive - read one UDP packet and do timer tasks (ACK, keepalive, latency)
+func (c *Conn) receive() error {
+	_ = c.conn.SetReadDeadline(time.Now().Add(ackInterval))
+
+	n, err := c.conn.Read(c.buf)
+	now := time.Now()
+
+	if err != nil {
+		if ne, ok := err.(net.Error); !ok || !ne.Timeout() {
+			return err
+		}
+	} else if err = c.handle(c.buf[:n], now); err != nil {
+		return err
+	}
+
+	if now.Sub(c.lastRecv) > core.ConnDeadline {
+		return errors.New("srt: read timeout")
+	}
+
+	c.skipLost(now)
+
+	if c.ackSeq != c.nextSeq {
+		c.sendACK()
+	} else if now.Sub(c.lastSend) > keepalive {
+		c.sendControl(ctrlKeepalive, 0, 0, nil)
+	}
+
+	return nil
+}
+
+func (c *Conn) handle(b []byte, now time.Time) error {
+	var h header
+	if !h.unmarshal(b) || h.socketID != c.socketID {
+		return nil
+	}
+
+	c.lastRecv = now
+
+	if !h.control {
+		payload := b[HeaderSize:]
+		if kk := h.key(); kk != 0 {
+			if c.crypto == nil || !c.crypto.decrypt(kk, h.seq, payload) {
+				return nil
+			}
+		}
+		c.handleData(h.seq, append([]byte(nil), payload...), now)
+		return nil
+	}
+
+	switch h.typ {
+	case ctrlShutdown:
+		return io.EOF
+	case ctrlDropReq:
+		// sender dropped message: first and last sequence numbers
+		if len(b) >= HeaderSize+8 {
+			last := binary.BigEndian.Uint32(b[HeaderSize+4:]) & seqMask
+			c.dropTo((last + 1) & seqMask)
+		}
+	case ctrlUser:
+		if h.subtype == userKMReq && c.crypto != nil {
+			// keys refresh from the sender, reply with the same message
+			km := b[HeaderSize:]
+			if err := c.crypto.setKeyMaterial(km); err != nil {
+				return err
+			}
+			c.sendControl(ctrlUser, userKMRsp, 0, km)
+		}
+	}
+
+	return nil
+}
+
+func (c *Conn) handleData(seq uint32, payload []byte, now time.Time) {
+	switch diff := seqDiff(seq, c.nextSeq); {
+	case diff < 0:
+		return // duplicate or too late
+	case diff == 0:
+		c.ready = append(c.ready, payload)
+		c.nextSeq = (c.nextSeq + 1) & seqMask
+		c.dropTo(c.nextSeq)
+	default:
+		if _, ok := c.pending[seq]; ok {
+			return
+		}
+		c.pending[seq] = &packet{payload: payload, recv: now}
+	}
+
+	if diff := seqDiff(seq, c.maxSeq); diff > 0 {
+		if diff > 1 {
+			// request retransmission of the lost packets
+			c.sendNAK((c.maxSeq+1)&seqMask, (seq-1)&seqMask)
+		}
+		c.maxSeq = seq
+	}
+}
+
+// dropTo - move expected sequence number and flush pending packets in order
+func (c *Conn) dropTo(seq uint32) {
+	for seqDiff(c.nextSeq, seq) < 0 {
+		if pkt, ok := c.pending[c.nextSeq]; ok {
+			c.ready = append(c.ready, pkt.payload)
+			delete(c.pending, c.nextSeq)
+		}
+		c.nextSeq = (c.nextSeq + 1) & seqMask
+	}
+
+	for {
+		pkt, ok := c.pending[c.nextSeq]
+		if !ok {
+			return
+		}
+		c.ready = append(c.ready, pkt.payload)
+		delete(c.pending, c.nextSeq)
+		c.nextSeq = (c.nextSeq + 1) & seqMask
+	}
+}
+
+// skipLost - live mode can't wait lost packets longer than latency
+func (c *Conn) skipLost(now time.Time) {
+	var oldest uint32
+	var found bool
+
+	for seq, pkt := range c.pending {
+		if now.Sub(pkt.recv) < c.latency {
+			continue
+		}
+		if !found || seqDiff(seq, oldest) < 0 {
+			oldest = seq
+			found = true
+		}
+	}
+
+	if found {
+		c.dropTo(oldest)
+	}
+}
+
+func (c *Conn) sendACK() {
+	c.ackNo++
+	c.ackSeq = c.nextSeq
+
+	b := binary.BigEndian.AppendUint32(nil, c.nextSeq)
+	b = binary.BigEndian.AppendUint32(b, 100_000) // RTT, us
+	b = binary.BigEndian.AppendUint32(b, 50_000)  // RTT variance, us
+	b = binary.BigEndian.AppendUint32(b, flowWindow)
+	b = append(b, make([]byte, 12)...) // receiving rate and link capacity
+	c.sendControl(ctrlACK, 0, c.ackNo, b)
+}
+
+func (c *Conn) sendNAK(from, to uint32) {
+	var b []byte
+	if from == to {
+		b = binary.BigEndian.AppendUint32(b, from)
+	} else {
+		b = binary.BigEndian.AppendUint32(b, 0x80000000|from)
+		b = binary.BigEndian.AppendUint32(b, to)
+	}
+	c.sendControl(ctrlNAK, 0, 0, b)
+}
+
+func (c *Conn) sendControl(typ, subtype uint16, info uint32, cif []byte) {
+	c.writeControl(typ, subtype, info, cif)
+	c.lastSend = time.Now()
+}
+
+func (c *Conn) writeControl(typ, subtype uint16, info uint32, cif []byte) {
+	b := appendControl(nil, typ, subtype, info, c.timestamp(), c.peerID)
+	b = append(b, cif...)
+	_, _ = c.conn.Write(b)
+}
+
+func (c *Conn) timestamp() uint32 {
+	return uint32(time.Since(c.start).Microseconds())
+}
+
+func (c *Conn) RemoteAddr() net.Addr {
+	return c.conn.RemoteAddr()
+}
+
+func (c *Conn) Close() error {
+	// can be called from another goroutine while Read
+	c.writeControl(ctrlShutdown, 0, 0, make([]byte, 4))
+	return c.conn.Close()
+}
@@ -0,0 +1,199 @@
+package srt
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/binary"
+	"errors"
+)
+
+const (
+	kmHeaderSize = 16
+	saltSize     = 16
+	pbkdf2Iter   = 2048
+	pbkdf2Salt   = 8 // LSB 64 bits of the salt
+
+	keyEven = 1
+	keyOdd  = 2
+)
+
+var errBadSecret = errors.New("srt: wrong passphrase")
+
+// crypto - AES-CTR decryption of data packets with Stream Encrypting Keys (SEK),
+// SEK are transferred wrapped by Key Encrypting Key (KEK) from the passphrase
+type crypto struct {
+	passphrase string
+	salt       []byte
+	keys       [2]cipher.Block // even and odd
+}
+
+// newKeyMaterial - generate new salt and even SEK, return KM message for KMREQ
+func (c *crypto) newKeyMaterial(keyLen int) ([]byte, error) {
+	c.salt = make([]byte, saltSize)
+	if _, err := rand.Read(c.salt); err != nil {
+		return nil, err
+	}
+
+	sek := make([]byte, keyLen)
+	if _, err := rand.Read(sek); err != nil {
+		return nil, err
+	}
+
+	kek, err := c.kek(keyLen)
+	if err != nil {
+		return nil, err
+	}
+
+	wrapped, err := keyWrap(kek, sek)
+	if err != nil {
+		return nil, err
+	}
+
+	if c.keys[0], err = aes.NewCipher(sek); err != nil {
+		return nil, err
+	}
+
+	b := make([]byte, kmHeaderSize, kmHeaderSize+saltSize+len(wrapped))
+	b[0] = 0x12 // S=0, V=1, PT=2 (KM)
+	binary.BigEndian.PutUint16(b[1:], 0x2029)
+	b[3] = keyEven
+	b[8] = 2  // cipher AES-CTR
+	b[10] = 2 // stream encapsulation MPEG-TS/SRT
+	b[14] = saltSize / 4
+	b[15] = byte(keyLen / 4)
+	b = append(b, c.salt...)
+	return append(b, wrapped...), nil
+}
+
+// setKeyMaterial - unwrap keys from KM message (ex. keys refresh from the peer)
+func (c *crypto) setKeyMaterial(b []byte) error {
+	if len(b) < kmHeaderSize || b[0] != 0x12 {
+		return errors.New("srt: wrong key material")
+	}
+
+	kk := b[3] & 0b11
+	saltLen := 4 * int(b[14])
+	keyLen := 4 * int(b[15])
+
+	n := 1
+	if kk == keyEven|keyOdd {
+		n = 2
+	}
+
+	if len(b) != kmHeaderSize+saltLen+8+n*keyLen {
+		return errors.New("srt: wrong key material size")
+	}
+
+	c.salt = append(c.salt[:0], b[kmHeaderSize:kmHeaderSize+saltLen]...)
+
+	kek, err := c.kek(keyLen)
+	if err != nil {
+		return err
+	}
+
+	keys, err := keyUnwrap(kek, b[kmHeaderSize+saltLen:])
+	if err != nil {
+		return err
+	}
+
+	for i := 0; i < 2; i++ {
+		if kk&(1<<i) == 0 {
+			continue
+		}
+		if c.keys[i], err = aes.NewCipher(keys[:keyLen]); err != nil {
+			return err
+		}
+		keys = keys[keyLen:]
+	}
+
+	return nil
+}
+
+func (c *crypto) kek(keyLen int) ([]byte, error) {
+	return pbkdf2.Key(sha1.New, c.passphrase, c.salt[len(c.salt)-pbkdf2Salt:], pbkdf2Iter, keyLen)
+}
+
+// decrypt - decrypt payload in place,
+// IV = (MSB(112, Salt) XOR (PacketIndex << 16)) || block counter
+func (c *crypto) decrypt(kk byte, seq uint32, payload []byte) bool {
+	if kk != keyEven && kk != keyOdd {
+		return false
+	}
+
+	block := c.keys[kk-1]
+	if block == nil {
+		return false
+	}
+
+	var iv [aes.BlockSize]byte
+	binary.BigEndian.PutUint32(iv[10:], seq)
+	for i := 0; i < 14; i++ {
+		iv[i] ^= c.salt[i]
+	}
+
+	cipher.NewCTR(block, iv[:]).XORKeyStream(payload, payload)
+	return true
+}
+
+var keyWrapIV = []byte{0xA6, 0xA6, 0xA6, 0xA6, 0xA6, 0xA6, 0xA6, 0xA6}
+
+// keyWrap - AES Key Wrap https://datatracker.ietf.org/doc/html/rfc3394
+func keyWrap(kek, key []byte) ([]byte, error) {
+	block, err := aes.NewCipher(kek)
+	if err != nil {
+		return nil, err
+	}
+
+	n := len(key) / 8
+	b := make([]byte, 8+len(key))
+	copy(b, keyWrapIV)
+	copy(b[8:], key)
+
+	buf := make([]byte, 16)
+	for j := 0; j < 6; j++ {
+		for i := 1; i <= n; i++ {
+			copy(buf, b[:8])
+			copy(buf[8:], b[8*i:])
+			block.Encrypt(buf, buf)
+
+			t := uint64(n*j + i)
+			binary.BigEndian.PutUint64(b, binary.BigEndian.Uint64(buf)^t)
+			copy(b[8*i:], buf[8:])
+		}
+	}
+
+	return b, nil
+}
+
+func keyUnwrap(kek, wrapped []byte) ([]byte, error) {
+	block, err := aes.NewCipher(kek)
+	if err != nil {
+		return nil, err
+	}
+
+	n := len(wrapped)/8 - 1
+	b := append([]byte(nil), wrapped...)
+
+	buf := make([]byte, 16)
+	for j := 5; j >= 0; j-- {
+		for i := n; i >= 1; i-- {
+			t := uint64(n*j + i)
+			binary.BigEndian.PutUint64(buf, binary.BigEndian.Uint64(b)^t)
+			copy(buf[8:], b[8*i:8*i+8])
+			block.Decrypt(buf, buf)
+
+			copy(b, buf[:8])
+			copy(b[8*i:], buf[8:])
+		}
+	}
+
+	if subtle.ConstantTimeCompare(b[:8], keyWrapIV) == 0 {
+		return nil, errBadSecret
+	}
+
+	return b[8:], nil
+}
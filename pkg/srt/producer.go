@@ -0,0 +1,26 @@
+package srt
+
+import (
+	"github.com/AlexxIT/go2rtc/pkg/mpegts"
+)
+
+// Open - SRT source with MPEG-TS payload
+func Open(rawURL string) (*mpegts.Producer, error) {
+	conn, err := Dial(rawURL)
+	if err != nil {
+		return nil, err
+	}
+
+	prod, err := mpegts.Open(conn)
+	if err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+
+	prod.FormatName = "srt"
+	prod.Protocol = "srt"
+	prod.RemoteAddr = conn.RemoteAddr().String()
+	prod.URL = rawURL
+
+	return prod, nil
+}
@@ -0,0 +1,183 @@
+package srt
+
+import (
+	"encoding/binary"
+)
+
+// https://datatracker.ietf.org/doc/html/draft-sharabayko-srt
+
+const (
+	HeaderSize = 16
+	MTU        = 1500
+
+	ctrlHandshake = 0x0000
+	ctrlKeepalive = 0x0001
+	ctrlACK       = 0x0002
+	ctrlNAK       = 0x0003
+	ctrlShutdown  = 0x0005
+	ctrlACKACK    = 0x0006
+	ctrlUser      = 0x7FFF
+
+	hsInduction  = 0x00000001
+	hsRejectMin  = 1000 // handshake type 1000+ is a rejection reason
+	hsAgreement  = 0xFFFFFFFE
+	hsConclusion = 0xFFFFFFFF
+
+	hsMagic = 0x4A17 // extension field in the induction response
+
+	extHSReq   = 1
+	extHSRsp   = 2
+	extKMReq   = 3
+	extKMRsp   = 4
+	extSID     = 5
+	extFlagHS  = 0x1
+	extFlagKM  = 0x2
+	extFlagSID = 0x4
+
+	// HSREQ flags: TSBPDSND, TSBPDRCV, CRYPT, TLPKTDROP, PERIODICNAK, REXMITFLG
+	hsFlags    = 0x3F
+	srtVersion = 0x00010502 // 1.5.2
+
+	seqMask = 0x7FFFFFFF
+)
+
+// seqDiff - difference between 31-bit sequence numbers with overflow
+func seqDiff(a, b uint32) int32 {
+	return int32((a-b)<<1) >> 1
+}
+
+// packet header for data (first bit 0) and control (first bit 1) packets
+type header struct {
+	control   bool
+	seq       uint32 // data: sequence number
+	msg       uint32 // data: PP(2) O(1) KK(2) R(1) MsgNo(26)
+	typ       uint16 // control: type
+	subtype   uint16 // control: subtype
+	info      uint32 // control: type-specific information
+	timestamp uint32
+	socketID  uint32 // destination socket ID
+}
+
+func (h *header) unmarshal(b []byte) bool {
+	if len(b) < HeaderSize {
+		return false
+	}
+
+	h.control = b[0]&0x80 != 0
+	if h.control {
+		h.typ = binary.BigEndian.Uint16(b) & 0x7FFF
+		h.subtype = binary.BigEndian.Uint16(b[2:])
+		h.info = binary.BigEndian.Uint32(b[4:])
+	} else {
+		h.seq = binary.BigEndian.Uint32(b) & seqMask
+		h.msg = binary.BigEndian.Uint32(b[4:])
+	}
+	h.timestamp = binary.BigEndian.Uint32(b[8:])
+	h.socketID = binary.BigEndian.Uint32(b[12:])
+	return true
+}
+
+// key - encryption key flags of data packet: 0 - none, 1 - even, 2 - odd
+func (h *header) key() byte {
+	return byte(h.msg>>27) & 0b11
+}
+
+func appendControl(b []byte, typ, subtype uint16, info, timestamp, socketID uint32) []byte {
+	b = binary.BigEndian.AppendUint16(b, 0x8000|typ)
+	b = binary.BigEndian.AppendUint16(b, subtype)
+	b = binary.BigEndian.AppendUint32(b, info)
+	b = binary.BigEndian.AppendUint32(b, timestamp)
+	return binary.BigEndian.AppendUint32(b, socketID)
+}
+
+type handshake struct {
+	version    uint32
+	encryption uint16
+	extension  uint16
+	initSeq    uint32
+	mtu        uint32
+	flowWindow uint32
+	typ        uint32
+	socketID   uint32
+	cookie     uint32
+
+	exts map[uint16][]byte
+}
+
+const handshakeSize = 48
+
+func (h *handshake) marshal(b []byte) []byte {
+	b = binary.BigEndian.AppendUint32(b, h.version)
+	b = binary.BigEndian.AppendUint16(b, h.encryption)
+	b = binary.BigEndian.AppendUint16(b, h.extension)
+	b = binary.BigEndian.AppendUint32(b, h.initSeq)
+	b = binary.BigEndian.AppendUint32(b, h.mtu)
+	b = binary.BigEndian.AppendUint32(b, h.flowWindow)
+	b = binary.BigEndian.AppendUint32(b, h.typ)
+	b = binary.BigEndian.AppendUint32(b, h.socketID)
+	b = binary.BigEndian.AppendUint32(b, h.cookie)
+	b = append(b, make([]byte, 16)...) // peer IP, not used by listeners
+
+	// stable order of extensions
+	for _, typ := range []uint16{extHSReq, extHSRsp, extKMReq, extKMRsp, extSID} {
+		if ext, ok := h.exts[typ]; ok {
+			b = binary.BigEndian.AppendUint16(b, typ)
+			b = binary.BigEndian.AppendUint16(b, uint16(len(ext)/4))
+			b = append(b, ext...)
+		}
+	}
+	return b
+}
+
+func (h *handshake) rejected() bool {
+	return h.typ >= hsRejectMin && h.typ < hsAgreement
+}
+
+func (h *handshake) unmarshal(b []byte) bool {
+	if len(b) < handshakeSize {
+		return false
+	}
+
+	h.version = binary.BigEndian.Uint32(b)
+	h.encryption = binary.BigEndian.Uint16(b[4:])
+	h.extension = binary.BigEndian.Uint16(b[6:])
+	h.initSeq = binary.BigEndian.Uint32(b[8:])
+	h.mtu = binary.BigEndian.Uint32(b[12:])
+	h.flowWindow = binary.BigEndian.Uint32(b[16:])
+	h.typ = binary.BigEndian.Uint32(b[20:])
+	h.socketID = binary.BigEndian.Uint32(b[24:])
+	h.cookie = binary.BigEndian.Uint32(b[28:])
+
+	h.exts = map[uint16][]byte{}
+	for b = b[handshakeSize:]; len(b) >= 4; {
+		typ := binary.BigEndian.Uint16(b)
+		size := 4 + 4*int(binary.BigEndian.Uint16(b[2:]))
+		if size > len(b) {
+			return false
+		}
+		h.exts[typ] = b[4:size]
+		b = b[size:]
+	}
+	return true
+}
+
+// encodeSID - stream ID is sent in 4 bytes words with reversed bytes order
+func encodeSID(s string) []byte {
+	b := make([]byte, (len(s)+3)/4*4)
+	copy(b, s)
+	for i := 0; i < len(b); i += 4 {
+		b[i], b[i+1], b[i+2], b[i+3] = b[i+3], b[i+2], b[i+1], b[i]
+	}
+	return b
+}
+
+func decodeSID(b []byte) string {
+	s := make([]byte, len(b)/4*4)
+	for i := 0; i < len(s); i += 4 {
+		s[i], s[i+1], s[i+2], s[i+3] = b[i+3], b[i+2], b[i+1], b[i]
+	}
+	for len(s) > 0 && s[len(s)-1] == 0 {
+		s = s[:len(s)-1]
+	}
+	return string(s)
+}
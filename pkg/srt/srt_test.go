@@ -0,0 +1,115 @@
+package srt
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"io"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestKeyWrap(t *testing.T) {
+	// https://datatracker.ietf.org/doc/html/rfc3394#section-4.1
+	kek, _ := hex.DecodeString("000102030405060708090A0B0C0D0E0F")
+	key, _ := hex.DecodeString("00112233445566778899AABBCCDDEEFF")
+
+	wrapped, err := keyWrap(kek, key)
+	require.Nil(t, err)
+	require.Equal(t, "1fa68b0a8112b447aef34bd8fb5a7b829d3e862371d2cfe5", hex.EncodeToString(wrapped))
+
+	unwrapped, err := keyUnwrap(kek, wrapped)
+	require.Nil(t, err)
+	require.Equal(t, key, unwrapped)
+}
+
+func TestKeyMaterial(t *testing.T) {
+	sender := &crypto{passphrase: "1234567890"}
+	km, err := sender.newKeyMaterial(16)
+	require.Nil(t, err)
+
+	receiver := &crypto{passphrase: "1234567890"}
+	require.Nil(t, receiver.setKeyMaterial(km))
+
+	payload := []byte("hello world")
+	require.True(t, sender.decrypt(keyEven, 100, payload)) // CTR encrypt is the same
+	require.NotEqual(t, "hello world", string(payload))
+	require.True(t, receiver.decrypt(keyEven, 100, payload))
+	require.Equal(t, "hello world", string(payload))
+
+	wrong := &crypto{passphrase: "0987654321"}
+	require.ErrorIs(t, wrong.setKeyMaterial(km), errBadSecret)
+}
+
+func TestStreamID(t *testing.T) {
+	b := encodeSID("#!::r=cam1")
+	require.Equal(t, []byte("::!#ac=r\x00\x001m"), b)
+	require.Equal(t, "#!::r=cam1", decodeSID(b))
+}
+
+func TestDial(t *testing.T) {
+	ln, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	require.Nil(t, err)
+	defer ln.Close()
+
+	streamID := make(chan string, 1)
+
+	go func() {
+		buf := make([]byte, MTU)
+		for {
+			n, addr, err := ln.ReadFromUDP(buf)
+			if err != nil {
+				return
+			}
+
+			var h header
+			if !h.unmarshal(buf[:n]) || !h.control || h.typ != ctrlHandshake {
+				continue
+			}
+
+			var req handshake
+			if !req.unmarshal(buf[HeaderSize:n]) {
+				continue
+			}
+
+			res := req
+			res.socketID = 0x1234
+
+			if req.typ == hsInduction {
+				res.version = 5
+				res.extension = hsMagic
+				res.cookie = 42
+				_, _ = ln.WriteToUDP(res.marshal(appendControl(nil, ctrlHandshake, 0, 0, 0, req.socketID)), addr)
+				continue
+			}
+
+			streamID <- decodeSID(req.exts[extSID])
+
+			res.exts = map[uint16][]byte{extHSRsp: req.exts[extHSReq]}
+			_, _ = ln.WriteToUDP(res.marshal(appendControl(nil, ctrlHandshake, 0, 0, 0, req.socketID)), addr)
+
+			// packets out of order
+			for _, i := range []uint32{1, 0, 2} {
+				b := binary.BigEndian.AppendUint32(nil, req.initSeq+i)
+				b = binary.BigEndian.AppendUint32(b, 0xC0000000|i)
+				b = binary.BigEndian.AppendUint32(b, 0)
+				b = binary.BigEndian.AppendUint32(b, req.socketID)
+				b = append(b, 'a'+byte(i))
+				_, _ = ln.WriteToUDP(b, addr)
+			}
+
+			_, _ = ln.WriteToUDP(appendControl(nil, ctrlShutdown, 0, 0, 0, req.socketID), addr)
+		}
+	}()
+
+	conn, err := Dial("srt://" + ln.LocalAddr().String() + "?streamid=cam1")
+	require.Nil(t, err)
+	defer conn.Close()
+
+	require.Equal(t, "cam1", <-streamID)
+
+	b, err := io.ReadAll(conn)
+	require.Nil(t, err)
+	require.Equal(t, "abc", string(b))
+}
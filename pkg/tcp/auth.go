@@ -7,6 +7,8 @@ import (
 	"fmt"
 	"net/url"
 	"strings"
+	"sync"
+	"time"
 )
 
 type Auth struct {
@@ -15,6 +17,8 @@ type Auth struct {
 	pass    string
 	header  string
 	h1nonce string
+	nonce   string
+	cached  bool // challenge from the cache, may be expired on the server side
 }
 
 const (
@@ -50,6 +54,7 @@ func (a *Auth) Read(res *Response) bool {
 		realm := Between(auth, `realm="`, `"`)
 		nonce := Between(auth, `nonce="`, `"`)
 
+		a.nonce = nonce
 		a.h1nonce = HexMD5(a.user, realm, a.pass) + ":" + nonce
 		a.header = fmt.Sprintf(
 			`Digest username="%s", realm="%s", nonce="%s"`,
@@ -62,6 +67,26 @@ func (a *Auth) Read(res *Response) bool {
 	}
 }
 
+// ReadAgain - process 401 for the already sent credentials. Makes sense only
+// for the cached challenge or for the stale nonce (RFC 2617 3.2.1) with a new
+// nonce, otherwise credentials are wrong.
+func (a *Auth) ReadAgain(res *Response) bool {
+	auth := res.Header.Get("WWW-Authenticate")
+
+	switch {
+	case a.cached:
+		a.cached = false
+	case a.Method == AuthDigest && strings.Contains(strings.ToLower(auth), "stale=true"):
+		if Between(auth, `nonce="`, `"`) == a.nonce {
+			return false
+		}
+	default:
+		return false
+	}
+
+	return a.Read(res)
+}
+
 func (a *Auth) Write(req *Request) {
 	if a == nil {
 		return
@@ -116,6 +141,61 @@ func (a *Auth) UserInfo() *url.Userinfo {
 	return url.UserPassword(a.user, a.pass)
 }
 
+// AuthCacheTTL - max age of the cached challenge. Cameras don't tell nonce
+// lifetime, so after this time the full challenge is used. Zero disables cache.
+var AuthCacheTTL = 10 * time.Minute
+
+type authCacheItem struct {
+	auth Auth
+	ts   time.Time
+}
+
+var authCache = map[string]*authCacheItem{}
+var authCacheMu sync.Mutex
+
+// CacheAuth - save the challenge result for the next connections to the same
+// host, so they can send Authorization header with the first request
+func CacheAuth(host string, a *Auth) {
+	if AuthCacheTTL == 0 || a.Method != AuthBasic && a.Method != AuthDigest {
+		return
+	}
+
+	authCacheMu.Lock()
+	// keep the time of the first use for the same challenge
+	if item := authCache[host]; item == nil || item.auth.header != a.header {
+		item = &authCacheItem{auth: *a, ts: time.Now()}
+		item.auth.cached = false
+		authCache[host] = item
+	}
+	authCacheMu.Unlock()
+}
+
+// CachedAuth - new Auth with the cached challenge if it's not too old and has
+// the same credentials, otherwise same as NewAuth
+func CachedAuth(host string, user *url.Userinfo) *Auth {
+	a := NewAuth(user)
+	if a.Method == AuthNone {
+		return a
+	}
+
+	authCacheMu.Lock()
+	defer authCacheMu.Unlock()
+
+	item := authCache[host]
+	if item == nil || item.auth.user != a.user || item.auth.pass != a.pass {
+		return a
+	}
+
+	if time.Since(item.ts) > AuthCacheTTL {
+		delete(authCache, host)
+		return a
+	}
+
+	auth := item.auth
+	auth.cached = true
+	return &auth
+}
+
 func Between(s, sub1, sub2 string) string {
 	i := strings.Index(s, sub1)
 	if i < 0 {
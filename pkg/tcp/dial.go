@@ -11,6 +11,15 @@ import (
 
 // Dial - for RTSP(S|X) and RTMP(S|X)
 func Dial(u *url.URL, timeout time.Duration) (net.Conn, error) {
+	return dial("tcp", u, &net.Dialer{Timeout: timeout})
+}
+
+// DialNetwork - same as Dial, network "tcp4" or "tcp6" forces the address family
+func DialNetwork(network string, u *url.URL, timeout time.Duration) (net.Conn, error) {
+	return dial(network, u, &net.Dialer{Timeout: timeout})
+}
+
+func dial(network string, u *url.URL, dialer *net.Dialer) (net.Conn, error) {
 	var address string
 	var hostname string // without port
 	if i := strings.IndexByte(u.Host, ':'); i > 0 {
@@ -42,7 +51,7 @@ func Dial(u *url.URL, timeout time.Duration) (net.Conn, error) {
 		return nil, errors.New("unsupported scheme: " + u.Scheme)
 	}
 
-	conn, err := net.DialTimeout("tcp", address, timeout)
+	conn, err := dialer.Dial(network, address)
 	if err != nil {
 		return nil, err
 	}
@@ -62,3 +71,31 @@ func Dial(u *url.URL, timeout time.Duration) (net.Conn, error) {
 
 	return tlsConn, nil
 }
+
+// SetOptions - set TCP socket options for plain or TLS connection.
+// Zero buffer size means OS default.
+func SetOptions(conn net.Conn, noDelay bool, readBuffer, writeBuffer int) error {
+	if tlsConn, ok := conn.(*tls.Conn); ok {
+		conn = tlsConn.NetConn()
+	}
+
+	tcpConn, ok := conn.(*net.TCPConn)
+	if !ok {
+		return nil // not TCP, ex. WebSocket transport
+	}
+
+	if err := tcpConn.SetNoDelay(noDelay); err != nil {
+		return err
+	}
+	if readBuffer > 0 {
+		if err := tcpConn.SetReadBuffer(readBuffer); err != nil {
+			return err
+		}
+	}
+	if writeBuffer > 0 {
+		if err := tcpConn.SetWriteBuffer(writeBuffer); err != nil {
+			return err
+		}
+	}
+	return nil
+}
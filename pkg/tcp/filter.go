@@ -0,0 +1,173 @@
+package tcp
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"path"
+	"strings"
+	"syscall"
+	"time"
+)
+
+var ErrDenied = errors.New("tcp: host denied")
+
+// Filter - allow and deny lists of hosts for outgoing connections, protects
+// from requests to internal services (SSRF) when sources can be set by users.
+// Rules are CIDR (10.0.0.0/8), IP (127.0.0.1) or hostname pattern (*.local).
+// Deny rules win. With allow rules only matching hosts are allowed.
+type Filter struct {
+	allowNets  []*net.IPNet
+	allowHosts []string
+	denyNets   []*net.IPNet
+	denyHosts  []string
+}
+
+func NewFilter(allow, deny []string) (*Filter, error) {
+	f := &Filter{}
+
+	var err error
+	if f.allowNets, f.allowHosts, err = parseRules(allow); err != nil {
+		return nil, err
+	}
+	if f.denyNets, f.denyHosts, err = parseRules(deny); err != nil {
+		return nil, err
+	}
+
+	return f, nil
+}
+
+func parseRules(rules []string) (nets []*net.IPNet, hosts []string, err error) {
+	for _, rule := range rules {
+		rule = strings.ToLower(strings.TrimSpace(rule))
+
+		if strings.IndexByte(rule, '/') > 0 {
+			var ipNet *net.IPNet
+			if _, ipNet, err = net.ParseCIDR(rule); err != nil {
+				return
+			}
+			nets = append(nets, ipNet)
+		} else if ip := net.ParseIP(rule); ip != nil {
+			nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(len(ip)*8, len(ip)*8)})
+		} else {
+			if _, err = path.Match(rule, ""); err != nil {
+				return
+			}
+			hosts = append(hosts, rule)
+		}
+	}
+	return
+}
+
+// CheckHost - check hostname (without port) by patterns and by IP rules if
+// hostname is IP. Returns true if hostname allowed by pattern.
+func (f *Filter) CheckHost(hostname string) (bool, error) {
+	hostname = strings.ToLower(strings.Trim(hostname, "[]"))
+
+	if matchHost(f.denyHosts, hostname) {
+		return false, deniedError(hostname)
+	}
+
+	if ip := net.ParseIP(hostname); ip != nil {
+		err := f.CheckIP(ip, false)
+		return err == nil, err
+	}
+
+	return matchHost(f.allowHosts, hostname), nil
+}
+
+// CheckIP - check resolved IP of the connection
+func (f *Filter) CheckIP(ip net.IP, allowed bool) error {
+	if matchNet(f.denyNets, ip) {
+		return deniedError(ip.String())
+	}
+	if allowed || len(f.allowNets) == 0 && len(f.allowHosts) == 0 || matchNet(f.allowNets, ip) {
+		return nil
+	}
+	return deniedError(ip.String())
+}
+
+// CheckURL - check URL host for transports without own Dial, ex. WebSocket
+func (f *Filter) CheckURL(rawURL string) error {
+	if f == nil {
+		return nil
+	}
+
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return err
+	}
+
+	allowed, err := f.CheckHost(u.Hostname())
+	if err != nil {
+		return err
+	}
+
+	if net.ParseIP(u.Hostname()) != nil {
+		return nil
+	}
+
+	ips, err := net.LookupIP(u.Hostname())
+	if err != nil {
+		return err
+	}
+
+	for _, ip := range ips {
+		if err = f.CheckIP(ip, allowed); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Dial - same as Dial, but checks the hostname and each resolved IP right
+// before the connection, so DNS can't return another address after the check
+func (f *Filter) Dial(u *url.URL, timeout time.Duration) (net.Conn, error) {
+	return f.DialNetwork("tcp", u, timeout)
+}
+
+// DialNetwork - same as Dial with "tcp4" or "tcp6" network
+func (f *Filter) DialNetwork(network string, u *url.URL, timeout time.Duration) (net.Conn, error) {
+	if f == nil {
+		return DialNetwork(network, u, timeout)
+	}
+
+	allowed, err := f.CheckHost(u.Hostname())
+	if err != nil {
+		return nil, err
+	}
+
+	dialer := &net.Dialer{
+		Timeout: timeout,
+		Control: func(_, address string, _ syscall.RawConn) error {
+			host, _, _ := net.SplitHostPort(address)
+			return f.CheckIP(net.ParseIP(host), allowed)
+		},
+	}
+
+	return dial(network, u, dialer)
+}
+
+func deniedError(host string) error {
+	return fmt.Errorf("%w: %s", ErrDenied, host)
+}
+
+func matchHost(patterns []string, hostname string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, hostname); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func matchNet(nets []*net.IPNet, ip net.IP) bool {
+	for _, ipNet := range nets {
+		if ipNet.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
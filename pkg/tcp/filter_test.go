@@ -0,0 +1,61 @@
+package tcp
+
+import (
+	"net"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFilter(t *testing.T) {
+	f, err := NewFilter([]string{"192.168.1.0/24", "*.lan"}, []string{"192.168.1.1", "router.lan"})
+	require.Nil(t, err)
+
+	allowed, err := f.CheckHost("camera.lan")
+	require.Nil(t, err)
+	require.True(t, allowed)
+
+	_, err = f.CheckHost("router.lan")
+	require.ErrorIs(t, err, ErrDenied)
+
+	_, err = f.CheckHost("192.168.1.1")
+	require.ErrorIs(t, err, ErrDenied)
+
+	allowed, err = f.CheckHost("192.168.1.10")
+	require.Nil(t, err)
+	require.True(t, allowed)
+
+	allowed, err = f.CheckHost("example.com")
+	require.Nil(t, err)
+	require.False(t, allowed)
+	require.ErrorIs(t, f.CheckIP(net.ParseIP("10.0.0.1"), allowed), ErrDenied)
+	require.Nil(t, f.CheckIP(net.ParseIP("192.168.1.20"), allowed))
+
+	_, err = NewFilter([]string{"10.0.0.0/33"}, nil)
+	require.NotNil(t, err)
+}
+
+func TestFilterDial(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.Nil(t, err)
+	defer ln.Close()
+
+	_, port, _ := net.SplitHostPort(ln.Addr().String())
+
+	f, err := NewFilter(nil, []string{"127.0.0.0/8", "::1"})
+	require.Nil(t, err)
+
+	// hostname is checked by resolved IP right before the connection
+	u, _ := url.Parse("rtsp://localhost:" + port + "/stream")
+	_, err = f.Dial(u, time.Second)
+	require.ErrorIs(t, err, ErrDenied)
+
+	f, err = NewFilter(nil, nil)
+	require.Nil(t, err)
+
+	conn, err := f.Dial(u, time.Second)
+	require.Nil(t, err)
+	_ = conn.Close()
+}
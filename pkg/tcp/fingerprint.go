@@ -0,0 +1,58 @@
+package tcp
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+)
+
+// ErrFingerprint - server certificate doesn't match the pinned one,
+// possible MITM or the camera certificate was rotated
+var ErrFingerprint = errors.New("tcp: certificate fingerprint mismatch")
+
+// Fingerprint - SHA-256 of the server leaf certificate in the OpenSSL format
+// (AB:CD:...), empty for the plain connection
+func Fingerprint(conn net.Conn) string {
+	tlsConn, ok := conn.(*tls.Conn)
+	if !ok {
+		return ""
+	}
+
+	certs := tlsConn.ConnectionState().PeerCertificates
+	if len(certs) == 0 {
+		return ""
+	}
+
+	sum := sha256.Sum256(certs[0].Raw)
+
+	var sb strings.Builder
+	for i, b := range sum {
+		if i > 0 {
+			sb.WriteByte(':')
+		}
+		sb.WriteString(strings.ToUpper(hex.EncodeToString([]byte{b})))
+	}
+	return sb.String()
+}
+
+// CheckFingerprint - compare the server certificate with the pinned fingerprint,
+// case and colons don't matter
+func CheckFingerprint(conn net.Conn, pin string) error {
+	fingerprint := Fingerprint(conn)
+	if fingerprint == "" {
+		return fmt.Errorf("%w: not a TLS connection", ErrFingerprint)
+	}
+
+	if normFingerprint(fingerprint) != normFingerprint(pin) {
+		return fmt.Errorf("%w: %s", ErrFingerprint, fingerprint)
+	}
+	return nil
+}
+
+func normFingerprint(s string) string {
+	return strings.ToLower(strings.ReplaceAll(s, ":", ""))
+}
@@ -0,0 +1,40 @@
+package tcp
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/hex"
+	"net"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFingerprint(t *testing.T) {
+	server := httptest.NewTLSServer(nil)
+	defer server.Close()
+
+	conn, err := tls.Dial("tcp", server.Listener.Addr().String(), &tls.Config{InsecureSkipVerify: true})
+	require.Nil(t, err)
+	defer conn.Close()
+
+	sum := sha256.Sum256(server.Certificate().Raw)
+	pin := hex.EncodeToString(sum[:])
+
+	fingerprint := Fingerprint(conn)
+	require.Len(t, fingerprint, 32*3-1)
+	require.Equal(t, pin, strings.ToLower(strings.ReplaceAll(fingerprint, ":", "")))
+
+	require.Nil(t, CheckFingerprint(conn, pin))
+	require.Nil(t, CheckFingerprint(conn, fingerprint))
+	require.ErrorIs(t, CheckFingerprint(conn, strings.Repeat("00", 32)), ErrFingerprint)
+
+	// plain connection can't match
+	plain, err := net.Dial("tcp", server.Listener.Addr().String())
+	require.Nil(t, err)
+	defer plain.Close()
+	require.Empty(t, Fingerprint(plain))
+	require.ErrorIs(t, CheckFingerprint(plain, pin), ErrFingerprint)
+}
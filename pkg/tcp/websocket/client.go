@@ -3,13 +3,21 @@ package websocket
 import (
 	cryptorand "crypto/rand"
 	"encoding/binary"
+	"errors"
 	"fmt"
 	"io"
+	"math"
 	"net"
 	"time"
 )
 
-const BinaryMessage = 2
+const (
+	ContinuationFrame = 0
+	BinaryMessage     = 2
+	CloseMessage      = 8
+	PingMessage       = 9
+	PongMessage       = 10
+)
 
 type Client struct {
 	conn   net.Conn
@@ -23,34 +31,45 @@ func NewClient(conn net.Conn) *Client {
 const finalBit = 0x80
 const maskBit = 0x80
 
+// maxControlSize - control frames (close, ping, pong) can't be bigger and can't be fragmented (RFC 6455 5.5)
+const maxControlSize = 125
+
+var ErrProtocol = errors.New("websocket: protocol error")
+
 func (w *Client) Read(b []byte) (n int, err error) {
-	if w.remain == 0 {
-		b2 := make([]byte, 2)
-		if _, err = io.ReadFull(w.conn, b2); err != nil {
+	for w.remain == 0 {
+		var frameType byte
+		var fin bool
+		if frameType, fin, err = w.readHeader(); err != nil {
 			return 0, err
 		}
 
-		frameType := b2[0] & 0xF
-		w.remain = int(b2[1] & 0x7F)
-
-		switch frameType {
-		case BinaryMessage:
-		default:
-			return 0, fmt.Errorf("unsupported frame type: %d", frameType)
+		// check before the allocation, because the size comes from the peer
+		if frameType >= CloseMessage && (!fin || w.remain > maxControlSize) {
+			return 0, ErrProtocol
 		}
 
-		switch w.remain {
-		case 126:
-			if _, err = io.ReadFull(w.conn, b2); err != nil {
+		switch frameType {
+		case BinaryMessage, ContinuationFrame:
+		case PingMessage:
+			// some cloud gateways close connection without answer on ping
+			payload := make([]byte, w.remain)
+			if _, err = io.ReadFull(w.conn, payload); err != nil {
+				return 0, err
+			}
+			w.remain = 0
+			if _, err = w.writeFrame(PongMessage, payload); err != nil {
 				return 0, err
 			}
-			w.remain = int(binary.BigEndian.Uint16(b2))
-		case 127:
-			b8 := make([]byte, 8)
-			if _, err = io.ReadFull(w.conn, b8); err != nil {
+		case PongMessage:
+			if _, err = io.CopyN(io.Discard, w.conn, int64(w.remain)); err != nil {
 				return 0, err
 			}
-			w.remain = int(binary.BigEndian.Uint64(b8))
+			w.remain = 0
+		case CloseMessage:
+			return 0, io.EOF
+		default:
+			return 0, fmt.Errorf("unsupported frame type: %d", frameType)
 		}
 	}
 
@@ -66,7 +85,43 @@ func (w *Client) Read(b []byte) (n int, err error) {
 	return
 }
 
+func (w *Client) readHeader() (frameType byte, fin bool, err error) {
+	b2 := make([]byte, 2)
+	if _, err = io.ReadFull(w.conn, b2); err != nil {
+		return 0, false, err
+	}
+
+	frameType = b2[0] & 0xF
+	fin = b2[0]&finalBit != 0
+	w.remain = int(b2[1] & 0x7F)
+
+	switch w.remain {
+	case 126:
+		if _, err = io.ReadFull(w.conn, b2); err != nil {
+			return 0, false, err
+		}
+		w.remain = int(binary.BigEndian.Uint16(b2))
+	case 127:
+		b8 := make([]byte, 8)
+		if _, err = io.ReadFull(w.conn, b8); err != nil {
+			return 0, false, err
+		}
+		// 64-bit size from the peer can overflow int (negative remain)
+		size := binary.BigEndian.Uint64(b8)
+		if size > math.MaxInt32 {
+			return 0, false, ErrProtocol
+		}
+		w.remain = int(size)
+	}
+
+	return
+}
+
 func (w *Client) Write(b []byte) (n int, err error) {
+	return w.writeFrame(BinaryMessage, b)
+}
+
+func (w *Client) writeFrame(frameType byte, b []byte) (n int, err error) {
 	var data []byte
 	var start byte
 
@@ -89,7 +144,7 @@ func (w *Client) Write(b []byte) (n int, err error) {
 		data[1] = maskBit | byte(size)
 	}
 
-	data[0] = BinaryMessage | finalBit
+	data[0] = frameType | finalBit
 
 	mask := data[start : start+4]
 	msg := data[start+4:]
@@ -0,0 +1,60 @@
+package websocket
+
+import (
+	"io"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPing(t *testing.T) {
+	conn1, conn2 := net.Pipe()
+	client := NewClient(conn1)
+
+	go func() {
+		_, _ = conn2.Write([]byte{PingMessage | finalBit, 2, 'h', 'i'})
+		_, _ = conn2.Write([]byte{BinaryMessage | finalBit, 4, 'R', 'T', 'S', 'P'})
+	}()
+
+	pong := make(chan []byte)
+	go func() {
+		b := make([]byte, 8) // header 2 bytes + mask 4 bytes + payload 2 bytes
+		_, _ = io.ReadFull(conn2, b)
+		pong <- b
+	}()
+
+	b := make([]byte, 4)
+	n, err := client.Read(b)
+	require.Nil(t, err)
+	require.Equal(t, "RTSP", string(b[:n]))
+
+	b = <-pong
+	require.Equal(t, byte(PongMessage|finalBit), b[0])
+	require.Equal(t, []byte{'h' ^ b[2], 'i' ^ b[3]}, b[6:])
+}
+
+func TestControlFrames(t *testing.T) {
+	frames := [][]byte{
+		{PingMessage | finalBit, 126, 0xFF, 0xFF},                           // big ping
+		{PingMessage, 2, 'h', 'i'},                                          // fragmented ping
+		{PongMessage | finalBit, 127, 0, 0, 0, 0, 0, 0, 1, 0},               // big pong
+		{BinaryMessage | finalBit, 127, 0xFF, 0xFF, 0xFF, 0xFF, 0, 0, 0, 0}, // negative size
+		{BinaryMessage | finalBit, 127, 0, 0, 0, 1, 0, 0, 0, 0},             // size overflow on 32-bit
+	}
+
+	for _, frame := range frames {
+		conn1, conn2 := net.Pipe()
+		client := NewClient(conn1)
+
+		go func() {
+			_, _ = conn2.Write(frame)
+		}()
+
+		_, err := client.Read(make([]byte, 4))
+		require.ErrorIs(t, err, ErrProtocol)
+
+		_ = conn1.Close()
+		_ = conn2.Close()
+	}
+}
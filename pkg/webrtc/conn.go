@@ -4,6 +4,8 @@ import (
 	"encoding/json"
 	"fmt"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/AlexxIT/go2rtc/pkg/core"
@@ -22,6 +24,11 @@ type Conn struct {
 
 	offer  string
 	closed core.Waiter
+
+	talkback *core.Codec // source codec of the DataChannel backchannel
+
+	mu   sync.Mutex   // Receivers are changed by GetTrack and read by the DataChannel
+	recv atomic.Int64 // bytes from all remote tracks and the DataChannel
 }
 
 func NewConn(pc *webrtc.PeerConnection) *Conn {
@@ -42,6 +49,7 @@ func NewConn(pc *webrtc.PeerConnection) *Conn {
 	})
 
 	pc.OnDataChannel(func(channel *webrtc.DataChannel) {
+		c.handleDataChannel(channel)
 		c.Fire(channel)
 	})
 
@@ -105,7 +113,7 @@ func NewConn(pc *webrtc.PeerConnection) *Conn {
 				return
 			}
 
-			c.Recv += n
+			c.recv.Add(int64(n))
 
 			packet := &rtp.Packet{}
 			if err := packet.Unmarshal(b[:n]); err != nil {
@@ -148,7 +156,11 @@ func NewConn(pc *webrtc.PeerConnection) *Conn {
 }
 
 func (c *Conn) MarshalJSON() ([]byte, error) {
-	return json.Marshal(c.Connection)
+	c.mu.Lock()
+	conn := c.Connection
+	c.mu.Unlock()
+	conn.Recv = int(c.recv.Load())
+	return json.Marshal(conn)
 }
 
 func (c *Conn) Close() error {
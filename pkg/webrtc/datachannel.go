@@ -0,0 +1,105 @@
+package webrtc
+
+import (
+	"errors"
+
+	"github.com/AlexxIT/go2rtc/pkg/core"
+	"github.com/AlexxIT/go2rtc/pkg/pcm"
+	"github.com/pion/rtp"
+	"github.com/pion/webrtc/v4"
+)
+
+// DataChannelLabel - label of the browser DataChannel with the backchannel audio,
+// for clients that can't add the microphone track to the offer
+const DataChannelLabel = "talkback"
+
+// SetTalkback - accept the backchannel audio from the DataChannel, each binary
+// message has raw frames of the source codec, ex. PCML/16000 from AudioWorklet.
+// The audio is transcoded to G.711 if the camera doesn't support the source codec.
+func (c *Conn) SetTalkback(source *core.Codec) error {
+	switch source.Name {
+	case core.CodecPCMA, core.CodecPCMU, core.CodecPCM, core.CodecPCML:
+	default:
+		return errors.New("webrtc: unsupported talkback codec")
+	}
+	if source.ClockRate == 0 {
+		return errors.New("webrtc: talkback codec without clock rate")
+	}
+
+	codecs := []*core.Codec{source}
+	for _, name := range []string{core.CodecPCMA, core.CodecPCMU} {
+		if source.Name != name || source.ClockRate != 8000 {
+			codecs = append(codecs, &core.Codec{Name: name, ClockRate: 8000})
+		}
+	}
+
+	c.talkback = source
+	c.Medias = append(c.Medias, &core.Media{
+		Kind:      core.KindAudio,
+		Direction: core.DirectionRecvonly,
+		ID:        DataChannelLabel,
+		Codecs:    codecs,
+	})
+	return nil
+}
+
+func (c *Conn) handleDataChannel(channel *webrtc.DataChannel) {
+	if channel.Label() != DataChannelLabel || c.talkback == nil {
+		return
+	}
+
+	handler := c.talkbackHandler()
+
+	channel.OnMessage(func(msg webrtc.DataChannelMessage) {
+		// text messages are reserved for the control commands
+		if !msg.IsString {
+			handler(msg.Data)
+		}
+	})
+}
+
+// talkbackHandler - wrap DataChannel messages to RTP packets of the GetTrack receiver.
+// The browser can open the channel before the stream adds the backchannel, so the
+// receiver is searched on messages until it appears. Messages before it are dropped.
+func (c *Conn) talkbackHandler() func([]byte) {
+	var track *core.Receiver
+	var transcode func([]byte) []byte
+	var size int
+
+	var seq uint16
+	var ts uint32
+	return func(b []byte) {
+		if len(b) == 0 {
+			return
+		}
+		c.recv.Add(int64(len(b)))
+
+		if track == nil {
+			if track = c.talkbackReceiver(); track == nil {
+				return // no backchannel in the stream
+			}
+			transcode = pcm.Transcode(track.Codec, c.talkback)
+			size = pcm.BytesPerFrame(track.Codec)
+		}
+
+		payload := transcode(b)
+		track.WriteRTP(&rtp.Packet{
+			Header:  rtp.Header{Version: 2, Marker: true, SequenceNumber: seq, Timestamp: ts},
+			Payload: payload,
+		})
+
+		seq++
+		ts += uint32(len(payload) / size)
+	}
+}
+
+func (c *Conn) talkbackReceiver() *core.Receiver {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, receiver := range c.Receivers {
+		if receiver.Media != nil && receiver.Media.ID == DataChannelLabel {
+			return receiver
+		}
+	}
+	return nil
+}
@@ -0,0 +1,52 @@
+package webrtc
+
+import (
+	"testing"
+
+	"github.com/AlexxIT/go2rtc/pkg/core"
+	"github.com/pion/webrtc/v4"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTalkback(t *testing.T) {
+	pc, err := webrtc.NewPeerConnection(webrtc.Configuration{})
+	require.Nil(t, err)
+	defer pc.Close()
+
+	conn := NewConn(pc)
+	conn.Mode = core.ModePassiveConsumer
+
+	require.NotNil(t, conn.SetTalkback(&core.Codec{Name: core.CodecOpus, ClockRate: 48000}))
+
+	err = conn.SetTalkback(&core.Codec{Name: core.CodecPCML, ClockRate: 16000})
+	require.Nil(t, err)
+
+	media := conn.GetMedias()[0]
+	require.Equal(t, DataChannelLabel, media.ID)
+	require.Equal(t, core.DirectionRecvonly, media.Direction)
+	require.Len(t, media.Codecs, 3) // source codec and G.711 for transcoding
+
+	// the browser can open the DataChannel before the stream adds the backchannel
+	handler := conn.talkbackHandler()
+	handler(make([]byte, 640)) // dropped, no track
+
+	// camera backchannel supports only PCMA/8000
+	track, err := conn.GetTrack(media, media.Codecs[1])
+	require.Nil(t, err)
+	require.Equal(t, core.CodecPCMA, track.Codec.Name)
+
+	var packets []*core.Packet
+	track.Input = func(packet *core.Packet) {
+		packets = append(packets, packet)
+	}
+
+	handler(make([]byte, 640)) // 20ms of PCML/16000
+	handler(nil)
+	handler(make([]byte, 640))
+
+	require.Len(t, packets, 2)
+	require.Len(t, packets[0].Payload, 160) // 20ms of PCMA/8000
+	require.Equal(t, uint16(1), packets[1].SequenceNumber)
+	require.Equal(t, uint32(160), packets[1].Timestamp)
+	require.Equal(t, int64(1920), conn.recv.Load())
+}
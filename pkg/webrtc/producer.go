@@ -8,6 +8,9 @@ import (
 func (c *Conn) GetTrack(media *core.Media, codec *core.Codec) (*core.Receiver, error) {
 	core.Assert(media.Direction == core.DirectionRecvonly)
 
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
 	for _, track := range c.Receivers {
 		if track.Codec == codec {
 			return track, nil
@@ -16,6 +19,10 @@ func (c *Conn) GetTrack(media *core.Media, codec *core.Codec) (*core.Receiver, e
 
 	switch c.Mode {
 	case core.ModePassiveConsumer: // backchannel from browser
+		if media.ID == DataChannelLabel {
+			break // audio comes from the DataChannel, not from the transceiver
+		}
+
 		// set codec for consumer recv track so remote peer should send media with this codec
 		params := webrtc.RTPCodecParameters{
 			RTPCodecCapability: webrtc.RTPCodecCapability{